@@ -5,15 +5,12 @@ import (
 )
 
 const (
-	// URI
-	APIPortDev    = "8081"
-	APIURLDev     = "localhost:" + APIPortDev
-	ClientURLDev  = "https://localhost:3000"
-	ClientURLProd = "https://forume-react.herokuapp.com"
-
 	// Session
 	SessionCookieName = "forumSecretKey"
-	SessionExpiration = 1 * time.Hour
+	CSRFCookieName    = "forumCSRF"
+	CSRFHeaderName    = "X-CSRF-Token"
+	OIDCCookieName    = "forumOIDC"
+	OIDC2FACookieName = "forumOIDC2FA"
 
 	// User roles
 	RoleGuest     = -1
@@ -21,17 +18,61 @@ const (
 	RoleModerator = 1
 	RoleAdmin     = 2
 
+	// Moderation actions
+	ActionBanned  = "banned"
+	ActionDeleted = "deleted"
+
+	// Appeal statuses
+	AppealPending  = 0
+	AppealReversed = 1
+	AppealUpheld   = 2
+
 	// Database
-	DBDriver   = "sqlite3"
-	DBPath     = "./db"
-	DBFileName = "forum.db"
-	DBSchema   = "schema.sql"
-
-	// Images
-	ImagesPath   = "./images"
-	MaxImageSize = 20 * 1024 * 1024
-)
+	DBDriver = "sqlite3"
+	DBSchema = "db/schema.sql"
+	// Every repository call is cancelled after QueryTimeout, well inside the
+	// server's WriteTimeout
+	QueryTimeout = 3 * time.Second
+
+	// TLS modes of the server
+	TLSOff        = "off"
+	TLSFile       = "file"
+	TLSSelfSigned = "self-signed"
+
+	// Rate limit stores
+	RateLimitMemory = "memory"
+	RateLimitSQLite = "sqlite"
+
+	// Blob storage backends of uploaded files
+	StorageFilesystem = "filesystem"
+	StorageS3         = "s3"
 
-var (
-	ClientURL = ClientURLDev
+	// Users notified of the @mentions of one post or comment at most
+	MaxMentions = 20
+
+	// Tags of one post at most
+	MaxTagsPerPost = 5
+	// Tags suggested for one prefix, and listed as popular, at most
+	TagSuggestionsLimit = 10
+	PopularTagsLimit    = 50
+
+	// How filters combine categories and tags
+	MatchAll = "all"
+	MatchAny = "any"
+
+	// Document types that can be attached to posts besides images
+	AttachmentPDF  = "application/pdf"
+	AttachmentText = "text/plain"
+
+	// Handling of uploaded SVG images
+	SVGSanitize = "sanitize"
+	SVGReject   = "reject"
+
+	// Log output formats
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+
+	// Client origins
+	ClientURLDev  = "https://localhost:3000"
+	ClientURLProd = "https://forume-react.herokuapp.com"
 )
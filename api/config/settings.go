@@ -0,0 +1,763 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config holds the deployment settings of the server. It is built from the
+// defaults, then a YAML file, then environment variables, then CLI flags,
+// each layer overriding the previous one.
+type Config struct {
+	Server      ServerConfig      `yaml:"server"`
+	ClientURL   string            `yaml:"clientURL"`
+	DB          DBConfig          `yaml:"db"`
+	Session     SessionConfig     `yaml:"session"`
+	Images      ImagesConfig      `yaml:"images"`
+	Attachments AttachmentsConfig `yaml:"attachments"`
+	Storage     StorageConfig     `yaml:"storage"`
+	Purge       PurgeConfig       `yaml:"purge"`
+	Admin       AdminConfig       `yaml:"admin"`
+	Log         LogConfig         `yaml:"log"`
+	RateLimit   RateLimitConfig   `yaml:"rateLimit"`
+	Login       LoginConfig       `yaml:"login"`
+	TwoFactor   TwoFactorConfig   `yaml:"twoFactor"`
+	OIDC        OIDCConfig        `yaml:"oidc"`
+	Metrics     MetricsConfig     `yaml:"metrics"`
+}
+
+type ServerConfig struct {
+	Host string `yaml:"host"`
+	Port string `yaml:"port"`
+	// TLS is TLSOff behind a TLS-terminating proxy, TLSFile to serve CertFile
+	// and KeyFile (reloaded when they change) or TLSSelfSigned for development
+	TLS      string `yaml:"tls"`
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+	// PublicURL is where clients reach the API, behind a proxy for
+	// instance; links to uploaded images start with it. It defaults to the
+	// scheme of TLS and host:port
+	PublicURL string `yaml:"publicURL"`
+	// X-Forwarded-For and X-Forwarded-Proto are only honored from these
+	// addresses or CIDR ranges
+	TrustedProxies  []string      `yaml:"trustedProxies"`
+	ReadTimeout     time.Duration `yaml:"readTimeout"`
+	WriteTimeout    time.Duration `yaml:"writeTimeout"`
+	IdleTimeout     time.Duration `yaml:"idleTimeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+}
+
+type DBConfig struct {
+	Path     string `yaml:"path"`
+	FileName string `yaml:"fileName"`
+	User     string `yaml:"user"`
+	Pass     string `yaml:"pass"`
+}
+
+type SessionConfig struct {
+	Expiration time.Duration `yaml:"expiration"`
+	// SameSite of the session cookie: lax, strict, or none when the client
+	// is served from another site (none implies Secure)
+	SameSite string `yaml:"sameSite"`
+}
+
+// SameSiteMode is SameSite as an http.SameSite.
+func (s SessionConfig) SameSiteMode() http.SameSite {
+	switch strings.ToLower(s.SameSite) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	}
+	return http.SameSiteLaxMode
+}
+
+type ImagesConfig struct {
+	Path    string `yaml:"path"`
+	MaxSize int64  `yaml:"maxSize"`
+	// Raster uploads are refused beyond these dimensions, checked before
+	// the pixels are decoded
+	MaxWidth  int   `yaml:"maxWidth"`
+	MaxHeight int   `yaml:"maxHeight"`
+	MaxPixels int64 `yaml:"maxPixels"`
+	MaxFrames int   `yaml:"maxFrames"`
+	// MaxTotalPixels bounds an animated GIF: its frame count times its
+	// screen area
+	MaxTotalPixels int64 `yaml:"maxTotalPixels"`
+	// Longer side, in pixels, of the scaled variants generated on upload
+	ThumbnailSize int `yaml:"thumbnailSize"`
+	MediumSize    int `yaml:"mediumSize"`
+	// JPEGQuality of re-encoded JPEGs, 1 to 100
+	JPEGQuality int `yaml:"jpegQuality"`
+	// SVG uploads are SVGSanitize'd down to safe elements, or SVGReject'ed
+	SVG string `yaml:"svg"`
+}
+
+type AttachmentsConfig struct {
+	// MaxSize of one attached file; attached images must also fit
+	// images.maxSize and the other image limits
+	MaxSize    int64 `yaml:"maxSize"`
+	MaxPerPost int   `yaml:"maxPerPost"`
+	// Types lists the document types accepted besides images, among
+	// AttachmentPDF and AttachmentText
+	Types []string `yaml:"types"`
+}
+
+type StorageConfig struct {
+	// Backend keeps uploads under images.path with StorageFilesystem, or in
+	// a bucket with StorageS3
+	Backend string   `yaml:"backend"`
+	S3      S3Config `yaml:"s3"`
+	// MigrateFrom is set by -migrate-images-from: the server copies the
+	// files of that backend into Backend and exits
+	MigrateFrom string `yaml:"-"`
+}
+
+// S3Config addresses a bucket of AWS S3 or of a compatible server such as
+// MinIO.
+type S3Config struct {
+	Endpoint string `yaml:"endpoint"`
+	Region   string `yaml:"region"`
+	Bucket   string `yaml:"bucket"`
+	// AccessKey and SecretKey may also come from FORUM_S3_ACCESS_KEY and
+	// FORUM_S3_SECRET_KEY
+	AccessKey string `yaml:"accessKey"`
+	SecretKey string `yaml:"secretKey"`
+	// PathStyle addresses the bucket as endpoint/bucket rather than as a
+	// subdomain, as MinIO expects
+	PathStyle bool `yaml:"pathStyle"`
+	// PublicURL serves a public bucket, or a CDN in front of it; without
+	// it /images/ redirects to signed URLs valid for SignedURLExpiry
+	PublicURL       string        `yaml:"publicURL"`
+	SignedURLExpiry time.Duration `yaml:"signedURLExpiry"`
+}
+
+type PurgeConfig struct {
+	// Soft deleted posts and comments are purged after the retention window
+	Retention time.Duration `yaml:"retention"`
+	Interval  time.Duration `yaml:"interval"`
+	// Uploads no post uses are deleted once older than OrphanUploads, which
+	// leaves time to finish writing the post
+	OrphanUploads time.Duration `yaml:"orphanUploads"`
+}
+
+type AdminConfig struct {
+	AuthToken string `yaml:"authToken"`
+}
+
+// MetricsConfig keeps /metrics from the public. With Addr it is served on a
+// listener of its own, for an internal network, instead of on the API port;
+// Token, when set, must come as a bearer token. Without either it is not
+// served.
+type MetricsConfig struct {
+	Addr  string `yaml:"addr"`
+	Token string `yaml:"token"`
+}
+
+type RateLimitConfig struct {
+	// Store is RateLimitMemory, or RateLimitSQLite to keep counters across
+	// restarts
+	Store   string          `yaml:"store"`
+	Default RateLimitPolicy `yaml:"default"`
+	// Routes overrides the default policy per route pattern, as registered on
+	// the mux
+	Routes map[string]RateLimitPolicy `yaml:"routes"`
+}
+
+// RateLimitPolicy allows Requests per Window, counted per client IP or, with
+// PerUser, per signed-in user. Exempt routes are not limited at all.
+type RateLimitPolicy struct {
+	Requests int           `yaml:"requests"`
+	Window   time.Duration `yaml:"window"`
+	PerUser  bool          `yaml:"perUser"`
+	Exempt   bool          `yaml:"exempt"`
+}
+
+type LoginConfig struct {
+	// Failed sign-ins allowed per account and per IP before every further
+	// failure blocks the next attempt for BaseDelay, doubling up to MaxDelay
+	AccountFreeAttempts int           `yaml:"accountFreeAttempts"`
+	IPFreeAttempts      int           `yaml:"ipFreeAttempts"`
+	BaseDelay           time.Duration `yaml:"baseDelay"`
+	MaxDelay            time.Duration `yaml:"maxDelay"`
+	// After LockoutAttempts failures the account is locked for
+	// LockoutDuration and its owner notified
+	LockoutAttempts int           `yaml:"lockoutAttempts"`
+	LockoutDuration time.Duration `yaml:"lockoutDuration"`
+	// Failures are forgotten after ResetAfter without a new one
+	ResetAfter time.Duration `yaml:"resetAfter"`
+}
+
+type TwoFactorConfig struct {
+	// Issuer names the forum in authenticator apps
+	Issuer string `yaml:"issuer"`
+	// RequireForStaff keeps moderators and admins without 2FA out of
+	// everything but enrollment
+	RequireForStaff bool `yaml:"requireForStaff"`
+	// Codes from Skew time steps before or after the current one are
+	// accepted, to allow for clock drift
+	Skew          int `yaml:"skew"`
+	RecoveryCodes int `yaml:"recoveryCodes"`
+}
+
+type OIDCConfig struct {
+	// RedirectURL is the public URL of /api/auth/oidc/callback; the provider
+	// name is appended to it, and each provider must allow the result
+	RedirectURL string                        `yaml:"redirectURL"`
+	Providers   map[string]OIDCProviderConfig `yaml:"providers"`
+}
+
+type OIDCProviderConfig struct {
+	Issuer   string `yaml:"issuer"`
+	ClientID string `yaml:"clientID"`
+	// ClientSecret may also come from FORUM_OIDC_<NAME>_CLIENT_SECRET
+	ClientSecret string   `yaml:"clientSecret"`
+	Scopes       []string `yaml:"scopes"`
+	// AutoCreate creates an account on the first sign-in of a verified email
+	// no user has; otherwise such sign-ins are refused
+	AutoCreate bool `yaml:"autoCreate"`
+}
+
+type LogConfig struct {
+	// Level is one of debug, info, warn, error
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+// Addr is the host:port the server listens on.
+func (s ServerConfig) Addr() string {
+	return net.JoinHostPort(s.Host, s.Port)
+}
+
+// URL is PublicURL, or the URL the server listens on.
+func (s ServerConfig) URL() string {
+	if s.PublicURL != "" {
+		return strings.TrimSuffix(s.PublicURL, "/")
+	}
+	if s.TLS == TLSOff {
+		return "http://" + s.Addr()
+	}
+	return "https://" + s.Addr()
+}
+
+// Policy returns the policy name and policy for a route pattern.
+func (rl RateLimitConfig) Policy(route string) (string, RateLimitPolicy) {
+	if policy, ok := rl.Routes[route]; ok {
+		return route, policy
+	}
+	return "default", rl.Default
+}
+
+// TrustsProxy reports whether ip belongs to one of the trusted proxies.
+func (s ServerConfig) TrustsProxy(ip net.IP) bool {
+	for _, proxy := range s.TrustedProxies {
+		if network, err := parseProxy(proxy); err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseProxy(proxy string) (*net.IPNet, error) {
+	if !strings.Contains(proxy, "/") {
+		ip := net.ParseIP(proxy)
+		if ip == nil {
+			return nil, errors.New("invalid proxy address " + proxy)
+		}
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, network, err := net.ParseCIDR(proxy)
+	return network, err
+}
+
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Host:            "localhost",
+			Port:            "8081",
+			TLS:             TLSFile,
+			CertFile:        "ssl/localhost.pem",
+			KeyFile:         "ssl/localhost-key.pem",
+			ReadTimeout:     5 * time.Second,
+			WriteTimeout:    5 * time.Second,
+			IdleTimeout:     30 * time.Second,
+			ShutdownTimeout: 10 * time.Second,
+		},
+		ClientURL: ClientURLDev,
+		DB: DBConfig{
+			Path:     "./db",
+			FileName: "forum.db",
+		},
+		Session: SessionConfig{
+			Expiration: 1 * time.Hour,
+			SameSite:   "lax",
+		},
+		Images: ImagesConfig{
+			Path:           "./images",
+			MaxSize:        20 * 1024 * 1024,
+			MaxWidth:       8192,
+			MaxHeight:      8192,
+			MaxPixels:      40 * 1000 * 1000,
+			MaxFrames:      300,
+			MaxTotalPixels: 100 * 1000 * 1000,
+			ThumbnailSize:  320,
+			MediumSize:     1280,
+			JPEGQuality:    90,
+			SVG:            SVGSanitize,
+		},
+		Attachments: AttachmentsConfig{
+			MaxSize:    20 * 1024 * 1024,
+			MaxPerPost: 10,
+			Types:      []string{AttachmentPDF, AttachmentText},
+		},
+		Storage: StorageConfig{
+			Backend: StorageFilesystem,
+			S3: S3Config{
+				Region:          "us-east-1",
+				SignedURLExpiry: 1 * time.Hour,
+			},
+		},
+		Purge: PurgeConfig{
+			Retention:     30 * 24 * time.Hour,
+			Interval:      1 * time.Hour,
+			OrphanUploads: 24 * time.Hour,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: LogFormatText,
+		},
+		Login: LoginConfig{
+			AccountFreeAttempts: 3,
+			IPFreeAttempts:      20,
+			BaseDelay:           1 * time.Second,
+			MaxDelay:            15 * time.Minute,
+			LockoutAttempts:     10,
+			LockoutDuration:     30 * time.Minute,
+			ResetAfter:          1 * time.Hour,
+		},
+		TwoFactor: TwoFactorConfig{
+			Issuer:        "forum",
+			Skew:          1,
+			RecoveryCodes: 10,
+		},
+		RateLimit: RateLimitConfig{
+			Store:   RateLimitMemory,
+			Default: RateLimitPolicy{Requests: 120, Window: time.Minute},
+			Routes: map[string]RateLimitPolicy{
+				"/api/auth/signin":       {Requests: 10, Window: time.Minute},
+				"/api/auth/signup":       {Requests: 5, Window: time.Hour},
+				"/api/post/create":       {Requests: 10, Window: time.Minute, PerUser: true},
+				"/api/comment/create":    {Requests: 30, Window: time.Minute, PerUser: true},
+				"/api/post/rate":         {Requests: 60, Window: time.Minute, PerUser: true},
+				"/api/comment/rate":      {Requests: 60, Window: time.Minute, PerUser: true},
+				"/api/image/upload":      {Requests: 10, Window: time.Hour, PerUser: true},
+				"/api/attachment/upload": {Requests: 30, Window: time.Hour, PerUser: true},
+				"/api/auth/oidc/login/":  {Requests: 20, Window: time.Minute},
+				// TOTP codes are short, keep them from being guessed
+				"/api/auth/2fa/confirm":        {Requests: 5, Window: time.Minute, PerUser: true},
+				"/api/auth/2fa/recovery-codes": {Requests: 5, Window: time.Minute, PerUser: true},
+				"/api/auth/2fa/disable":        {Requests: 5, Window: time.Minute, PerUser: true},
+				"/api/auth/oidc/2fa":           {Requests: 5, Window: time.Minute},
+				// Probes come often from the one address of the orchestrator
+				"/healthz": {Exempt: true},
+				"/readyz":  {Exempt: true},
+			},
+		},
+	}
+}
+
+// Load builds the configuration for the given command line arguments. The
+// YAML file is taken from -config, then FORUM_CONFIG, then ./config.yaml if it
+// exists.
+func Load(args []string) (cfg *Config, err error) {
+	var (
+		fs   = flag.NewFlagSet("forum", flag.ContinueOnError)
+		path = fs.String("config", "", "path to the YAML config file")
+		host = fs.String("host", "", "host to listen on")
+		port = fs.String("port", "", "port to listen on")
+		tlsm = fs.String("tls", "", "TLS mode: off, file or self-signed")
+		cert = fs.String("cert", "", "TLS certificate file")
+		key  = fs.String("key", "", "TLS key file")
+		dbp  = fs.String("db-path", "", "directory of the SQLite database")
+		imgs = fs.String("images-path", "", "directory for uploaded images")
+		mig  = fs.String("migrate-images-from", "", "copy uploaded images from this storage backend into the configured one, then exit")
+		cli  = fs.String("client-url", "", "allowed CORS origin")
+		logl = fs.String("log-level", "", "log level: debug, info, warn or error")
+	)
+	if err = fs.Parse(args); err != nil {
+		return nil, err
+	}
+	cfg = Default()
+	if err = cfg.loadFile(*path); err != nil {
+		return nil, err
+	}
+	if err = cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "host":
+			cfg.Server.Host = *host
+		case "port":
+			cfg.Server.Port = *port
+		case "tls":
+			cfg.Server.TLS = *tlsm
+		case "cert":
+			cfg.Server.CertFile = *cert
+		case "key":
+			cfg.Server.KeyFile = *key
+		case "db-path":
+			cfg.DB.Path = *dbp
+		case "images-path":
+			cfg.Images.Path = *imgs
+		case "migrate-images-from":
+			cfg.Storage.MigrateFrom = *mig
+		case "client-url":
+			cfg.ClientURL = *cli
+		case "log-level":
+			cfg.Log.Level = *logl
+		}
+	})
+	if err = cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (cfg *Config) loadFile(path string) (err error) {
+	var data []byte
+	explicit := path != ""
+	if !explicit {
+		path = os.Getenv("FORUM_CONFIG")
+		explicit = path != ""
+	}
+	if !explicit {
+		path = "config.yaml"
+	}
+	if data, err = ioutil.ReadFile(path); err != nil {
+		if os.IsNotExist(err) && !explicit {
+			return nil
+		}
+		return fmt.Errorf("config file: %v", err)
+	}
+	// Strict decoding refuses keys already in a map, so the built-in route
+	// policies are merged back after the file's
+	builtin := cfg.RateLimit.Routes
+	cfg.RateLimit.Routes = nil
+	if err = yaml.UnmarshalStrict(data, cfg); err != nil {
+		return fmt.Errorf("config file %s: %v", path, err)
+	}
+	if cfg.RateLimit.Routes == nil {
+		cfg.RateLimit.Routes = make(map[string]RateLimitPolicy, len(builtin))
+	}
+	for route, policy := range builtin {
+		if _, ok := cfg.RateLimit.Routes[route]; !ok {
+			cfg.RateLimit.Routes[route] = policy
+		}
+	}
+	return nil
+}
+
+// loadEnv applies FORUM_* variables, plus the PORT, DB_USER, DB_PASS and
+// ADMIN_AUTH_TOKEN names the deployment already uses.
+func (cfg *Config) loadEnv() (err error) {
+	if value, ok := os.LookupEnv("PORT"); ok {
+		cfg.Server.Port = value
+	}
+	strs := map[string]*string{
+		"FORUM_HOST":              &cfg.Server.Host,
+		"FORUM_PORT":              &cfg.Server.Port,
+		"FORUM_TLS":               &cfg.Server.TLS,
+		"FORUM_CERT_FILE":         &cfg.Server.CertFile,
+		"FORUM_KEY_FILE":          &cfg.Server.KeyFile,
+		"FORUM_CLIENT_URL":        &cfg.ClientURL,
+		"FORUM_DB_PATH":           &cfg.DB.Path,
+		"FORUM_DB_FILE":           &cfg.DB.FileName,
+		"DB_USER":                 &cfg.DB.User,
+		"DB_PASS":                 &cfg.DB.Pass,
+		"FORUM_IMAGES_PATH":       &cfg.Images.Path,
+		"FORUM_IMAGES_SVG":        &cfg.Images.SVG,
+		"FORUM_PUBLIC_URL":        &cfg.Server.PublicURL,
+		"FORUM_STORAGE_BACKEND":   &cfg.Storage.Backend,
+		"FORUM_S3_ENDPOINT":       &cfg.Storage.S3.Endpoint,
+		"FORUM_S3_REGION":         &cfg.Storage.S3.Region,
+		"FORUM_S3_BUCKET":         &cfg.Storage.S3.Bucket,
+		"FORUM_S3_ACCESS_KEY":     &cfg.Storage.S3.AccessKey,
+		"FORUM_S3_SECRET_KEY":     &cfg.Storage.S3.SecretKey,
+		"FORUM_S3_PUBLIC_URL":     &cfg.Storage.S3.PublicURL,
+		"ADMIN_AUTH_TOKEN":        &cfg.Admin.AuthToken,
+		"FORUM_SESSION_SAMESITE":  &cfg.Session.SameSite,
+		"FORUM_LOG_LEVEL":         &cfg.Log.Level,
+		"FORUM_LOG_FORMAT":        &cfg.Log.Format,
+		"FORUM_RATE_LIMIT_STORE":  &cfg.RateLimit.Store,
+		"FORUM_2FA_ISSUER":        &cfg.TwoFactor.Issuer,
+		"FORUM_OIDC_REDIRECT_URL": &cfg.OIDC.RedirectURL,
+		"FORUM_METRICS_ADDR":      &cfg.Metrics.Addr,
+		"FORUM_METRICS_TOKEN":     &cfg.Metrics.Token,
+	}
+	durations := map[string]*time.Duration{
+		"FORUM_READ_TIMEOUT":         &cfg.Server.ReadTimeout,
+		"FORUM_WRITE_TIMEOUT":        &cfg.Server.WriteTimeout,
+		"FORUM_IDLE_TIMEOUT":         &cfg.Server.IdleTimeout,
+		"FORUM_SHUTDOWN_TIMEOUT":     &cfg.Server.ShutdownTimeout,
+		"FORUM_SESSION_EXPIRATION":   &cfg.Session.Expiration,
+		"FORUM_PURGE_RETENTION":      &cfg.Purge.Retention,
+		"FORUM_PURGE_INTERVAL":       &cfg.Purge.Interval,
+		"FORUM_PURGE_ORPHAN_UPLOADS": &cfg.Purge.OrphanUploads,
+		"FORUM_S3_SIGNED_URL_EXPIRY": &cfg.Storage.S3.SignedURLExpiry,
+	}
+	for name, field := range strs {
+		if value, ok := os.LookupEnv(name); ok {
+			*field = value
+		}
+	}
+	for name, field := range durations {
+		if value, ok := os.LookupEnv(name); ok {
+			if *field, err = time.ParseDuration(value); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+		}
+	}
+	if value, ok := os.LookupEnv("FORUM_TRUSTED_PROXIES"); ok {
+		cfg.Server.TrustedProxies = strings.Split(value, ",")
+		for i := range cfg.Server.TrustedProxies {
+			cfg.Server.TrustedProxies[i] = strings.TrimSpace(cfg.Server.TrustedProxies[i])
+		}
+	}
+	if value, ok := os.LookupEnv("FORUM_S3_PATH_STYLE"); ok {
+		if cfg.Storage.S3.PathStyle, err = strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("FORUM_S3_PATH_STYLE: %v", err)
+		}
+	}
+	if value, ok := os.LookupEnv("FORUM_2FA_REQUIRE_STAFF"); ok {
+		if cfg.TwoFactor.RequireForStaff, err = strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("FORUM_2FA_REQUIRE_STAFF: %v", err)
+		}
+	}
+	for name, provider := range cfg.OIDC.Providers {
+		envName := "FORUM_OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_CLIENT_SECRET"
+		if value, ok := os.LookupEnv(envName); ok {
+			provider.ClientSecret = value
+			cfg.OIDC.Providers[name] = provider
+		}
+	}
+	if value, ok := os.LookupEnv("FORUM_IMAGES_MAX_SIZE"); ok {
+		if cfg.Images.MaxSize, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("FORUM_IMAGES_MAX_SIZE: %v", err)
+		}
+	}
+	if value, ok := os.LookupEnv("FORUM_ATTACHMENTS_MAX_SIZE"); ok {
+		if cfg.Attachments.MaxSize, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("FORUM_ATTACHMENTS_MAX_SIZE: %v", err)
+		}
+	}
+	return nil
+}
+
+// Provider names end up in callback URLs and environment variable names.
+var oidcProviderName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// Validate reports every invalid setting at once.
+func (cfg *Config) Validate() error {
+	var problems []string
+	if port, err := strconv.Atoi(cfg.Server.Port); err != nil || port < 1 || port > 65535 {
+		problems = append(problems, "server.port must be a number between 1 and 65535")
+	}
+	switch cfg.Server.TLS {
+	case TLSOff, TLSSelfSigned:
+	case TLSFile:
+		if cfg.Server.CertFile == "" || cfg.Server.KeyFile == "" {
+			problems = append(problems, "server.certFile and server.keyFile are required with tls: file")
+		}
+	default:
+		problems = append(problems, "server.tls must be one of off, file, self-signed")
+	}
+	for _, proxy := range cfg.Server.TrustedProxies {
+		if _, err := parseProxy(proxy); err != nil {
+			problems = append(problems, "server.trustedProxies: "+err.Error())
+		}
+	}
+	if cfg.Server.PublicURL != "" {
+		if u, err := url.Parse(cfg.Server.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, "server.publicURL must be an http or https URL")
+		}
+	}
+	if cfg.ClientURL == "" {
+		problems = append(problems, "clientURL is required")
+	}
+	if cfg.DB.Path == "" || cfg.DB.FileName == "" {
+		problems = append(problems, "db.path and db.fileName are required")
+	}
+	if (cfg.DB.User == "") != (cfg.DB.Pass == "") {
+		problems = append(problems, "db.user and db.pass must be set together")
+	}
+	if cfg.Images.Path == "" {
+		problems = append(problems, "images.path is required")
+	}
+	if cfg.Images.MaxSize <= 0 {
+		problems = append(problems, "images.maxSize must be positive")
+	}
+	if cfg.Images.MaxWidth <= 0 || cfg.Images.MaxHeight <= 0 || cfg.Images.MaxPixels <= 0 ||
+		cfg.Images.MaxFrames <= 0 || cfg.Images.MaxTotalPixels <= 0 {
+		problems = append(problems, "images.maxWidth, maxHeight, maxPixels, maxFrames and maxTotalPixels must be positive")
+	}
+	if cfg.Images.ThumbnailSize <= 0 || cfg.Images.MediumSize <= cfg.Images.ThumbnailSize {
+		problems = append(problems, "images.thumbnailSize must be positive and below images.mediumSize")
+	}
+	if cfg.Images.JPEGQuality < 1 || cfg.Images.JPEGQuality > 100 {
+		problems = append(problems, "images.jpegQuality must be between 1 and 100")
+	}
+	if cfg.Images.SVG != SVGSanitize && cfg.Images.SVG != SVGReject {
+		problems = append(problems, "images.svg must be sanitize or reject")
+	}
+	if cfg.Attachments.MaxSize <= 0 || cfg.Attachments.MaxPerPost <= 0 {
+		problems = append(problems, "attachments.maxSize and attachments.maxPerPost must be positive")
+	}
+	for _, contentType := range cfg.Attachments.Types {
+		if contentType != AttachmentPDF && contentType != AttachmentText {
+			problems = append(problems, "attachments.types may only list application/pdf and text/plain")
+		}
+	}
+	problems = append(problems, cfg.Storage.validate()...)
+	if cfg.Metrics.Addr != "" {
+		if _, _, err := net.SplitHostPort(cfg.Metrics.Addr); err != nil {
+			problems = append(problems, "metrics.addr must be host:port")
+		}
+	}
+	switch strings.ToLower(cfg.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		problems = append(problems, "log.level must be one of debug, info, warn, error")
+	}
+	if cfg.Log.Format != LogFormatText && cfg.Log.Format != LogFormatJSON {
+		problems = append(problems, "log.format must be text or json")
+	}
+	switch strings.ToLower(cfg.Session.SameSite) {
+	case "lax", "strict", "none":
+	default:
+		problems = append(problems, "session.sameSite must be one of lax, strict, none")
+	}
+	if cfg.RateLimit.Store != RateLimitMemory && cfg.RateLimit.Store != RateLimitSQLite {
+		problems = append(problems, "rateLimit.store must be memory or sqlite")
+	}
+	policies := map[string]RateLimitPolicy{"default": cfg.RateLimit.Default}
+	for route, policy := range cfg.RateLimit.Routes {
+		policies["routes."+route] = policy
+	}
+	for _, name := range sortedKeys(policies) {
+		if !policies[name].Exempt && (policies[name].Requests <= 0 || policies[name].Window <= 0) {
+			problems = append(problems, "rateLimit."+name+" needs positive requests and window")
+		}
+	}
+	if cfg.Login.AccountFreeAttempts < 0 || cfg.Login.IPFreeAttempts < 0 {
+		problems = append(problems, "login free attempts must not be negative")
+	}
+	if cfg.Login.LockoutAttempts <= cfg.Login.AccountFreeAttempts {
+		problems = append(problems, "login.lockoutAttempts must exceed login.accountFreeAttempts")
+	}
+	if cfg.TwoFactor.Issuer == "" {
+		problems = append(problems, "twoFactor.issuer is required")
+	}
+	if cfg.TwoFactor.Skew < 0 || cfg.TwoFactor.Skew > 10 {
+		problems = append(problems, "twoFactor.skew must be between 0 and 10")
+	}
+	if cfg.TwoFactor.RecoveryCodes <= 0 {
+		problems = append(problems, "twoFactor.recoveryCodes must be positive")
+	}
+	if len(cfg.OIDC.Providers) > 0 && cfg.OIDC.RedirectURL == "" {
+		problems = append(problems, "oidc.redirectURL is required with providers")
+	}
+	for _, name := range sortedKeys(cfg.OIDC.Providers) {
+		provider := cfg.OIDC.Providers[name]
+		if !oidcProviderName.MatchString(name) {
+			problems = append(problems, "oidc.providers."+name+": name must be lowercase letters, digits and dashes")
+		}
+		if provider.Issuer == "" || provider.ClientID == "" {
+			problems = append(problems, "oidc.providers."+name+" needs issuer and clientID")
+		}
+	}
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"server.readTimeout", cfg.Server.ReadTimeout},
+		{"server.writeTimeout", cfg.Server.WriteTimeout},
+		{"server.idleTimeout", cfg.Server.IdleTimeout},
+		{"server.shutdownTimeout", cfg.Server.ShutdownTimeout},
+		{"session.expiration", cfg.Session.Expiration},
+		{"purge.retention", cfg.Purge.Retention},
+		{"purge.interval", cfg.Purge.Interval},
+		{"purge.orphanUploads", cfg.Purge.OrphanUploads},
+		{"login.baseDelay", cfg.Login.BaseDelay},
+		{"login.maxDelay", cfg.Login.MaxDelay},
+		{"login.lockoutDuration", cfg.Login.LockoutDuration},
+		{"login.resetAfter", cfg.Login.ResetAfter},
+	}
+	for _, d := range durations {
+		if d.value <= 0 {
+			problems = append(problems, d.name+" must be positive")
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New("invalid config: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (s StorageConfig) validate() (problems []string) {
+	backends := map[string]bool{StorageFilesystem: true, StorageS3: true}
+	if !backends[s.Backend] {
+		problems = append(problems, "storage.backend must be filesystem or s3")
+	}
+	if s.MigrateFrom != "" && (!backends[s.MigrateFrom] || s.MigrateFrom == s.Backend) {
+		problems = append(problems, "-migrate-images-from must be the storage backend not configured, filesystem or s3")
+	}
+	if s.Backend != StorageS3 && s.MigrateFrom != StorageS3 {
+		return problems
+	}
+	if u, err := url.Parse(s.S3.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.Trim(u.Path, "/") != "" {
+		problems = append(problems, "storage.s3.endpoint must be an http or https URL without a path")
+	}
+	if s.S3.Region == "" || s.S3.Bucket == "" {
+		problems = append(problems, "storage.s3.region and storage.s3.bucket are required")
+	}
+	if s.S3.AccessKey == "" || s.S3.SecretKey == "" {
+		problems = append(problems, "storage.s3.accessKey and storage.s3.secretKey are required")
+	}
+	if s.S3.PublicURL != "" {
+		if u, err := url.Parse(s.S3.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, "storage.s3.publicURL must be an http or https URL")
+		}
+	}
+	// the longest expiry S3 signatures allow
+	if s.S3.SignedURLExpiry < time.Second || s.S3.SignedURLExpiry > 7*24*time.Hour {
+		problems = append(problems, "storage.s3.signedURLExpiry must be between 1s and 168h")
+	}
+	return problems
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -16,28 +16,21 @@ var (
 )
 
 // get instance of db connection, and check db integrity with schema
-func GetDBInstance() (*sql.DB, error) {
+func GetDBInstance(cfg *config.Config) (*sql.DB, error) {
 	var (
-		DB_USER string = os.Getenv("DB_USER")
-		DB_PASS string = os.Getenv("DB_PASS")
 		DB_AUTH string
 		DB_URI  string
 	)
-	if _, err = os.Stat(config.DBPath); os.IsNotExist(err) {
-		if err = os.Mkdir(config.DBPath, 0755); err != nil {
+	if _, err = os.Stat(cfg.DB.Path); os.IsNotExist(err) {
+		if err = os.Mkdir(cfg.DB.Path, 0755); err != nil {
 			return nil, err
 		}
 	}
-	if _, err = os.Stat(config.ImagesPath); os.IsNotExist(err) {
-		if err = os.Mkdir(config.ImagesPath, 0755); err != nil {
-			return nil, err
-		}
-	}
-	if DB_PASS != "" && DB_USER != "" {
-		DB_AUTH = fmt.Sprintf("?_auth&_auth_user=%s&_auth_pass=%s", DB_USER, DB_PASS)
+	if cfg.DB.Pass != "" && cfg.DB.User != "" {
+		DB_AUTH = fmt.Sprintf("?_auth&_auth_user=%s&_auth_pass=%s", cfg.DB.User, cfg.DB.Pass)
 	}
-	DB_URI = fmt.Sprintf("%s/%s%s", config.DBPath, config.DBFileName, DB_AUTH)
-	if DBConn, err = sql.Open(config.DBDriver, DB_URI); err != nil {
+	DB_URI = fmt.Sprintf("%s/%s%s", cfg.DB.Path, cfg.DB.FileName, DB_AUTH)
+	if DBConn, err = sql.Open(instrumentedDriverName, DB_URI); err != nil {
 		return nil, err
 	}
 	DBConn.SetMaxIdleConns(100)
@@ -59,5 +52,5 @@ func CheckDB(DBConn *sql.DB, path string) error {
 			return err
 		}
 	}
-	return nil
+	return Migrate(DBConn)
 }
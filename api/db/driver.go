@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"time"
+
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/logger"
+	"github.com/innovember/forum/api/metrics"
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// instrumentedDriverName is the SQLite driver wrapped so every statement is
+// timed and failing ones are logged through the logger of the query context;
+// repository errors thereby carry the ID of the request that caused them.
+const instrumentedDriverName = config.DBDriver + "-instrumented"
+
+var (
+	queryDuration = metrics.NewHistogramVec("forum_db_query_duration_seconds",
+		"Time spent in SQLite statements.", metrics.DefaultBuckets, "op")
+	queryErrors = metrics.NewCounterVec("forum_db_query_errors_total",
+		"SQLite statements that failed.", "op")
+)
+
+func init() {
+	sql.Register(instrumentedDriverName, instrumentedDriver{&sqlite3.SQLiteDriver{}})
+}
+
+type instrumentedDriver struct {
+	driver.Driver
+}
+
+func (d instrumentedDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedConn{conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+type instrumentedConn struct {
+	*sqlite3.SQLiteConn
+}
+
+func (c *instrumentedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	start := time.Now()
+	stmt, err := c.SQLiteConn.PrepareContext(ctx, query)
+	observe(ctx, "prepare", query, start, err)
+	return stmt, err
+}
+
+func (c *instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	result, err := c.SQLiteConn.ExecContext(ctx, query, args)
+	observe(ctx, "exec", query, start, err)
+	return result, err
+}
+
+func (c *instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := c.SQLiteConn.QueryContext(ctx, query, args)
+	observe(ctx, "query", query, start, err)
+	return rows, err
+}
+
+func (c *instrumentedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	start := time.Now()
+	tx, err := c.SQLiteConn.BeginTx(ctx, opts)
+	observe(ctx, "begin", "BEGIN", start, err)
+	return tx, err
+}
+
+func observe(ctx context.Context, op, query string, start time.Time, err error) {
+	if err == driver.ErrSkip {
+		return
+	}
+	queryDuration.Observe(time.Since(start).Seconds(), op)
+	if err == nil {
+		return
+	}
+	queryErrors.Inc(op)
+	logger.FromContext(ctx).ErrorContext(ctx, "query failed",
+		"query", strings.Join(strings.Fields(query), " "),
+		"error", err)
+}
+
+// RegisterMetrics exposes the connection pool statistics of dbConn.
+func RegisterMetrics(dbConn *sql.DB) {
+	pool := func(value func(stats sql.DBStats) float64) func(ctx context.Context) ([]metrics.Sample, error) {
+		return func(ctx context.Context) ([]metrics.Sample, error) {
+			return []metrics.Sample{{Value: value(dbConn.Stats())}}, nil
+		}
+	}
+	metrics.NewGaugeFunc("forum_db_open_connections", "Open database connections.", nil,
+		pool(func(stats sql.DBStats) float64 { return float64(stats.OpenConnections) }))
+	metrics.NewGaugeFunc("forum_db_in_use_connections", "Database connections currently in use.", nil,
+		pool(func(stats sql.DBStats) float64 { return float64(stats.InUse) }))
+	metrics.NewGaugeFunc("forum_db_wait_seconds", "Total time spent waiting for a free connection.", nil,
+		pool(func(stats sql.DBStats) float64 { return stats.WaitDuration.Seconds() }))
+}
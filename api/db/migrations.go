@@ -0,0 +1,364 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/innovember/forum/api/services/markdown"
+	"github.com/innovember/forum/api/services/slug"
+)
+
+// Migrations evolve the base schema.sql. Each entry runs once, in order,
+// and is recorded in schema_migrations; never edit an applied migration,
+// append a new one instead.
+var migrations = []struct {
+	version    int
+	statements []string
+}{
+	{1, []string{
+		`ALTER TABLE posts ADD COLUMN deleted_at INTEGER DEFAULT 0`,
+		`ALTER TABLE comments ADD COLUMN deleted_at INTEGER DEFAULT 0`,
+		`CREATE INDEX IF NOT EXISTS posts_deleted_at ON posts (deleted_at)`,
+		`CREATE INDEX IF NOT EXISTS comments_deleted_at ON comments (deleted_at)`,
+	}},
+	{2, []string{
+		`CREATE TABLE IF NOT EXISTS rate_limits (
+			key TEXT PRIMARY KEY,
+			hits INTEGER,
+			reset_at INTEGER
+		)`,
+		`CREATE INDEX IF NOT EXISTS rate_limits_reset_at ON rate_limits (reset_at)`,
+	}},
+	{3, []string{
+		`CREATE TABLE IF NOT EXISTS login_attempts (
+			key TEXT PRIMARY KEY,
+			failures INTEGER,
+			last_failure_at INTEGER,
+			blocked_until INTEGER
+		)`,
+		`CREATE TABLE IF NOT EXISTS notifications_security (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			receiver_id INTEGER,
+			locked_until INTEGER,
+			created_at INTEGER,
+			FOREIGN KEY (receiver_id) REFERENCES users (id) ON DELETE CASCADE
+		)`,
+	}},
+	{4, []string{
+		`CREATE TABLE IF NOT EXISTS two_factor (
+			user_id INTEGER PRIMARY KEY,
+			secret TEXT,
+			enabled INTEGER DEFAULT 0,
+			last_used_step INTEGER DEFAULT 0,
+			created_at INTEGER,
+			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS recovery_codes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER,
+			code_hash TEXT,
+			used_at INTEGER DEFAULT 0,
+			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS recovery_codes_user_id ON recovery_codes (user_id)`,
+	}},
+	{5, []string{
+		`CREATE TABLE IF NOT EXISTS user_identities (
+			provider TEXT,
+			subject TEXT,
+			user_id INTEGER,
+			email TEXT,
+			created_at INTEGER,
+			PRIMARY KEY (provider, subject),
+			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS oidc_states (
+			state TEXT PRIMARY KEY,
+			provider TEXT,
+			nonce TEXT,
+			verifier TEXT,
+			expires_at INTEGER
+		)`,
+	}},
+	{6, []string{
+		`CREATE TABLE IF NOT EXISTS uploads (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			file_name TEXT UNIQUE,
+			owner_id INTEGER,
+			post_id INTEGER DEFAULT 0,
+			size INTEGER DEFAULT 0,
+			hash TEXT DEFAULT '',
+			created_at INTEGER
+		)`,
+		`CREATE INDEX IF NOT EXISTS uploads_post_id ON uploads (post_id)`,
+		// Images uploaded before tracking belong to the first post that
+		// used them; their size and hash are unknown
+		`INSERT OR IGNORE INTO uploads(file_name, owner_id, post_id, created_at)
+		SELECT replace(image_path, rtrim(image_path, replace(image_path, '/', '')), ''),
+		author_id, id, created_at
+		FROM posts
+		WHERE is_image = 1 AND image_path != ''
+		ORDER BY id`,
+	}},
+	{7, []string{
+		// deleting is set while the orphan collector deletes the blob's
+		// file; attachment links are built from file_name when read
+		`CREATE TABLE IF NOT EXISTS blobs (
+			hash TEXT PRIMARY KEY,
+			file_name TEXT,
+			content_type TEXT,
+			size INTEGER,
+			ref_count INTEGER DEFAULT 0,
+			released_at INTEGER DEFAULT 0,
+			deleting INTEGER DEFAULT 0,
+			created_at INTEGER
+		)`,
+		`CREATE TABLE IF NOT EXISTS attachments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			post_id INTEGER DEFAULT 0,
+			owner_id INTEGER,
+			blob_hash TEXT,
+			name TEXT,
+			caption TEXT DEFAULT '',
+			position INTEGER DEFAULT 0,
+			created_at INTEGER,
+			FOREIGN KEY (blob_hash) REFERENCES blobs (hash)
+		)`,
+		`CREATE INDEX IF NOT EXISTS attachments_post_id ON attachments (post_id, position)`,
+		`CREATE INDEX IF NOT EXISTS attachments_blob_hash ON attachments (blob_hash)`,
+		`CREATE INDEX IF NOT EXISTS blobs_released_at ON blobs (released_at)`,
+	}},
+	{8, []string{
+		`ALTER TABLE posts ADD COLUMN content_html TEXT DEFAULT ''`,
+		`ALTER TABLE comments ADD COLUMN content_html TEXT DEFAULT ''`,
+	}},
+	{9, []string{
+		`ALTER TABLE users ADD COLUMN mention_notifications INTEGER DEFAULT 1`,
+		`ALTER TABLE notifications ADD COLUMN mention INTEGER DEFAULT 0`,
+		// A mention edited out keeps its row, with removed_at set, so that
+		// mentioning the user again doesn't notify them twice
+		`CREATE TABLE IF NOT EXISTS mentions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER,
+			author_id INTEGER,
+			post_id INTEGER,
+			comment_id INTEGER DEFAULT 0,
+			created_at INTEGER,
+			removed_at INTEGER DEFAULT 0,
+			UNIQUE (user_id, post_id, comment_id),
+			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+			FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS mentions_post_id ON mentions (post_id, comment_id)`,
+	}},
+	{10, []string{
+		`ALTER TABLE categories ADD COLUMN parent_id INTEGER DEFAULT 0`,
+		`ALTER TABLE categories ADD COLUMN slug TEXT DEFAULT ''`,
+		`ALTER TABLE categories ADD COLUMN description TEXT DEFAULT ''`,
+		`ALTER TABLE categories ADD COLUMN position INTEGER DEFAULT 0`,
+		`ALTER TABLE categories ADD COLUMN locked INTEGER DEFAULT 0`,
+		`CREATE INDEX IF NOT EXISTS categories_parent_id ON categories (parent_id, position)`,
+	}},
+	{11, []string{
+		`CREATE TABLE IF NOT EXISTS category_moderators (
+			category_id INTEGER,
+			user_id INTEGER,
+			created_at INTEGER,
+			PRIMARY KEY (category_id, user_id),
+			FOREIGN KEY (category_id) REFERENCES categories (id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS category_moderators_user_id ON category_moderators (user_id)`,
+	}},
+	{12, []string{
+		`CREATE TABLE IF NOT EXISTS tags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			created_at INTEGER
+		)`,
+		// A synonym is only ever a name; posts are tagged with its tag
+		`CREATE TABLE IF NOT EXISTS tag_synonyms (
+			name TEXT PRIMARY KEY,
+			tag_id INTEGER,
+			FOREIGN KEY (tag_id) REFERENCES tags (id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS tag_synonyms_tag_id ON tag_synonyms (tag_id)`,
+		`CREATE TABLE IF NOT EXISTS posts_tags_bridge (
+			post_id INTEGER,
+			tag_id INTEGER,
+			PRIMARY KEY (post_id, tag_id),
+			FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
+			FOREIGN KEY (tag_id) REFERENCES tags (id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS posts_tags_bridge_tag_id ON posts_tags_bridge (tag_id)`,
+	}},
+	{13, []string{
+		// set when a signed-in user links a provider to their account
+		`ALTER TABLE oidc_states ADD COLUMN link_user_id INTEGER DEFAULT 0`,
+		// provider sign-ins waiting for the user's two-factor code
+		`CREATE TABLE IF NOT EXISTS oidc_challenges (
+			token TEXT PRIMARY KEY,
+			user_id INTEGER,
+			expires_at INTEGER,
+			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+		)`,
+	}},
+	{14, []string{
+		// a scoped moderator moderates only the categories assigned, and
+		// nothing once they are all gone
+		`ALTER TABLE users ADD COLUMN moderator_scoped INTEGER DEFAULT 0`,
+		`UPDATE users SET moderator_scoped = 1
+			WHERE id IN (SELECT user_id FROM category_moderators)`,
+	}},
+}
+
+// backfills run in the transaction of the migration of their version,
+// after its statements, for data SQL alone can't compute.
+var backfills = map[int]func(tx *sql.Tx) error{
+	8:  renderContentHTML,
+	10: slugCategories,
+}
+
+// renderContentHTML caches the rendered Markdown of existing posts and
+// comments.
+func renderContentHTML(tx *sql.Tx) (err error) {
+	for _, table := range []string{"posts", "comments"} {
+		var (
+			rows     *sql.Rows
+			ids      []int64
+			contents []string
+		)
+		if rows, err = tx.Query(`SELECT id, IFNULL(content, '') FROM ` + table); err != nil {
+			return err
+		}
+		for rows.Next() {
+			var (
+				id      int64
+				content string
+			)
+			if err = rows.Scan(&id, &content); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+			contents = append(contents, content)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		for i, id := range ids {
+			if _, err = tx.Exec(`UPDATE `+table+` SET content_html = ? WHERE id = ?`,
+				markdown.Render(contents[i]), id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// slugCategories gives the existing categories slugs made from their
+// names, which must be unique before they can be indexed as such.
+func slugCategories(tx *sql.Tx) (err error) {
+	var (
+		rows  *sql.Rows
+		ids   []int64
+		names []string
+		taken = map[string]bool{}
+	)
+	if rows, err = tx.Query(`SELECT id, IFNULL(name, '') FROM categories ORDER BY id`); err != nil {
+		return err
+	}
+	for rows.Next() {
+		var (
+			id   int64
+			name string
+		)
+		if err = rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+		names = append(names, name)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	for i, id := range ids {
+		categorySlug := slug.Make(names[i])
+		for categorySlug == "" || taken[categorySlug] {
+			categorySlug = strings.TrimPrefix(categorySlug+"-"+strconv.FormatInt(id, 10), "-")
+		}
+		taken[categorySlug] = true
+		if _, err = tx.Exec(`UPDATE categories SET slug = ? WHERE id = ?`, categorySlug, id); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS categories_slug ON categories (slug)`)
+	return err
+}
+
+// LatestVersion is the schema version this build expects.
+func LatestVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// CurrentVersion returns the highest migration applied to the database.
+func CurrentVersion(DBConn *sql.DB) (version int, err error) {
+	if err = DBConn.QueryRow(`SELECT IFNULL(MAX(version), 0)
+							  FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+func Migrate(DBConn *sql.DB) (err error) {
+	var (
+		ctx     context.Context
+		tx      *sql.Tx
+		current int
+	)
+	if _, err = DBConn.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+								version INTEGER PRIMARY KEY,
+								applied_at INTEGER
+							)`); err != nil {
+		return err
+	}
+	if current, err = CurrentVersion(DBConn); err != nil {
+		return err
+	}
+	ctx = context.Background()
+	for _, migration := range migrations {
+		if migration.version <= current {
+			continue
+		}
+		if tx, err = DBConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+			return err
+		}
+		for _, statement := range migration.statements {
+			if _, err = tx.Exec(statement); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		if backfill, ok := backfills[migration.version]; ok {
+			if err = backfill(tx); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		if _, err = tx.Exec(`INSERT INTO schema_migrations(version, applied_at)
+							 VALUES (?, ?)`, migration.version, time.Now().Unix()); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -162,4 +162,48 @@ CREATE TABLE IF NOT EXISTS notifications_posts (
 	created_at INTEGER,
 	FOREIGN KEY (receiver_id) REFERENCES users (id) ON
 DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS moderation_actions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	post_id INTEGER,
+	author_id INTEGER,
+	moderator_id INTEGER,
+	action TEXT,
+	post_title TEXT,
+	post_content TEXT,
+	post_created_at INTEGER,
+	post_is_image INTEGER,
+	post_image_path TEXT,
+	post_categories TEXT,
+	created_at INTEGER,
+	FOREIGN KEY (author_id) REFERENCES users (id) ON
+DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS appeals (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	action_id INTEGER UNIQUE,
+	author_id INTEGER,
+	content TEXT,
+	status INTEGER DEFAULT 0,
+	admin_id INTEGER DEFAULT 0,
+	created_at INTEGER,
+	resolved_at INTEGER DEFAULT 0,
+	FOREIGN KEY (action_id) REFERENCES moderation_actions (id) ON
+DELETE CASCADE,
+	FOREIGN KEY (author_id) REFERENCES users (id) ON
+DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS notifications_appeals (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	receiver_id INTEGER,
+	appeal_id INTEGER,
+	submitted INTEGER,
+	reversed INTEGER,
+	upheld INTEGER,
+	created_at INTEGER,
+	FOREIGN KEY (receiver_id) REFERENCES users (id) ON
+DELETE CASCADE
 );
\ No newline at end of file
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Executor is the query surface shared by *sql.DB and *sql.Tx, so a
+// repository query can run either on its own or inside a unit of work.
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// UnitOfWork runs several repository calls in one transaction. Repository
+// methods with a Tx suffix take the transaction handed to fn and never
+// commit or roll it back themselves.
+type UnitOfWork interface {
+	Do(ctx context.Context, fn func(tx *sql.Tx) error) (err error)
+}
+
+type unitOfWork struct {
+	dbConn *sql.DB
+}
+
+func NewUnitOfWork(conn *sql.DB) UnitOfWork {
+	return &unitOfWork{dbConn: conn}
+}
+
+// Do commits when fn returns nil and rolls back on an error, a panic or when
+// ctx is cancelled.
+func (u *unitOfWork) Do(ctx context.Context, fn func(tx *sql.Tx) error) (err error) {
+	var (
+		tx *sql.Tx
+	)
+	if tx, err = u.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestUnitOfWork(t *testing.T) {
+	errFailed := errors.New("failed")
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		fn        func(tx *sql.Tx) error
+		wantErr   bool
+		wantPanic bool
+		wantRows  int
+	}{
+		{
+			name: "commit",
+			ctx:  context.Background(),
+			fn: func(tx *sql.Tx) error {
+				_, err := tx.Exec(`INSERT INTO items(name) VALUES ('a'), ('b')`)
+				return err
+			},
+			wantRows: 2,
+		},
+		{
+			name: "error rolls back",
+			ctx:  context.Background(),
+			fn: func(tx *sql.Tx) error {
+				if _, err := tx.Exec(`INSERT INTO items(name) VALUES ('a')`); err != nil {
+					return err
+				}
+				return errFailed
+			},
+			wantErr: true,
+		},
+		{
+			name: "failed query rolls back the earlier ones",
+			ctx:  context.Background(),
+			fn: func(tx *sql.Tx) error {
+				if _, err := tx.Exec(`INSERT INTO items(name) VALUES ('a')`); err != nil {
+					return err
+				}
+				_, err := tx.Exec(`INSERT INTO missing(name) VALUES ('b')`)
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name: "panic rolls back",
+			ctx:  context.Background(),
+			fn: func(tx *sql.Tx) error {
+				if _, err := tx.Exec(`INSERT INTO items(name) VALUES ('a')`); err != nil {
+					return err
+				}
+				panic("boom")
+			},
+			wantPanic: true,
+		},
+		{
+			name:    "cancelled context",
+			ctx:     cancelled,
+			fn:      func(tx *sql.Tx) error { return nil },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := sql.Open("sqlite3", ":memory:")
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn.SetMaxOpenConns(1)
+			defer conn.Close()
+			if _, err = conn.Exec(`CREATE TABLE items (name TEXT)`); err != nil {
+				t.Fatal(err)
+			}
+			func() {
+				defer func() {
+					if p := recover(); (p != nil) != tt.wantPanic {
+						t.Errorf("panic = %v, want %v", p, tt.wantPanic)
+					}
+				}()
+				err = NewUnitOfWork(conn).Do(tt.ctx, tt.fn)
+			}()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+			var rows int
+			// the connection is free again only if the transaction ended
+			if err = conn.QueryRow(`SELECT COUNT(*) FROM items`).Scan(&rows); err != nil {
+				t.Fatal(err)
+			}
+			if rows != tt.wantRows {
+				t.Errorf("rows = %d, want %d", rows, tt.wantRows)
+			}
+		})
+	}
+}
@@ -1,11 +1,11 @@
 module github.com/innovember/forum/api
 
-go 1.15
+go 1.21
 
 require (
 	github.com/innovember/forum v0.0.0-20210119150956-f221de8e3380 // indirect
 	github.com/mattn/go-sqlite3 v1.14.4
 	github.com/satori/go.uuid v1.2.0
 	golang.org/x/crypto v0.0.0-20200510223506-06a226fb4e37
-	golang.org/x/time v0.0.0-20201208040808-7e3f01d25324
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,78 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/innovember/forum/api/config"
+)
+
+type contextKey struct{}
+
+// requestInfo carries the request-scoped log fields. It is stored as a
+// pointer so the user ID found by the auth middleware is visible to the
+// access log wrapping it.
+type requestInfo struct {
+	logger *slog.Logger
+	id     string
+	userID int64
+}
+
+// New builds the process logger writing to stdout in the configured format.
+func New(cfg config.LogConfig) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: ParseLevel(cfg.Level)}
+	if cfg.Format == config.LogFormatJSON {
+		return slog.New(slog.NewJSONHandler(os.Stdout, opts))
+	}
+	return slog.New(slog.NewTextHandler(os.Stdout, opts))
+}
+
+// ParseLevel maps debug, info, warn and error to their slog level, defaulting
+// to info.
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+// WithRequest starts the log scope of one request.
+func WithRequest(ctx context.Context, logger *slog.Logger, requestID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, &requestInfo{logger: logger, id: requestID})
+}
+
+// SetUserID adds the authenticated user to the request scope.
+func SetUserID(ctx context.Context, userID int64) {
+	if info, ok := ctx.Value(contextKey{}).(*requestInfo); ok {
+		info.userID = userID
+	}
+}
+
+// RequestID returns the ID of the request ctx belongs to, if any.
+func RequestID(ctx context.Context) string {
+	if info, ok := ctx.Value(contextKey{}).(*requestInfo); ok {
+		return info.id
+	}
+	return ""
+}
+
+// FromContext returns a logger carrying the request ID and user ID of ctx,
+// or the default logger outside of a request.
+func FromContext(ctx context.Context) *slog.Logger {
+	info, ok := ctx.Value(contextKey{}).(*requestInfo)
+	if !ok {
+		return slog.Default()
+	}
+	logger := info.logger.With("request_id", info.id)
+	if info.userID != 0 {
+		logger = logger.With("user_id", info.userID)
+	}
+	return logger
+}
@@ -1,12 +1,28 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
+	"database/sql"
 	config "github.com/innovember/forum/api/config"
 	db "github.com/innovember/forum/api/db"
+	"github.com/innovember/forum/api/logger"
+	"github.com/innovember/forum/api/metrics"
 	"github.com/innovember/forum/api/middleware"
+	"github.com/innovember/forum/api/services/certs"
+	"github.com/innovember/forum/api/services/health"
+	"github.com/innovember/forum/api/services/imaging"
 	"github.com/innovember/forum/api/services/loadEnv"
+	"github.com/innovember/forum/api/services/oidc"
+	"github.com/innovember/forum/api/services/orphans"
+	purge "github.com/innovember/forum/api/services/purge"
+	"github.com/innovember/forum/api/services/ratelimit"
 	session "github.com/innovember/forum/api/services/session"
+	"github.com/innovember/forum/api/services/stats"
+	"github.com/innovember/forum/api/services/storage"
+	"github.com/innovember/forum/api/services/worker"
+	"os/signal"
+	"syscall"
 	"time"
 
 	userHandler "github.com/innovember/forum/api/user/delivery"
@@ -16,118 +32,247 @@ import (
 	postHandler "github.com/innovember/forum/api/post/delivery"
 	postRepo "github.com/innovember/forum/api/post/repository"
 	postUsecase "github.com/innovember/forum/api/post/usecases"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 )
 
 func Run() {
-	log.Println("Server is starting...")
 	if errEnv := loadEnv.Load(); errEnv != nil {
-		log.Fatal(errEnv)
+		fatal("env", errEnv)
 	}
-	dbConn, err := db.GetDBInstance()
+	cfg, err := config.Load(os.Args[1:])
 	if err != nil {
-		log.Fatal("DB conn", err)
+		fatal("config", err)
 	}
-	if err = db.CheckDB(dbConn, config.DBPath+"/"+config.DBSchema); err != nil {
-		log.Fatal("DB schema", err)
+	slog.SetDefault(logger.New(cfg.Log))
+	if cfg.Storage.MigrateFrom != "" {
+		migrateImages(cfg)
+		return
+	}
+	slog.Info("server is starting")
+	dbConn, err := db.GetDBInstance(cfg)
+	if err != nil {
+		fatal("db connection", err)
+	}
+	if err = db.CheckDB(dbConn, config.DBSchema); err != nil {
+		fatal("db schema", err)
 	}
 	if err = session.ResetAll(dbConn); err != nil {
-		log.Fatal("Session reset", err)
+		fatal("session reset", err)
 	}
-	session.Init(dbConn)
+	// Background workers run until shutdown
+	workers := worker.NewGroup(context.Background())
+	session.Init(workers, dbConn)
+	purge.Init(workers, dbConn, cfg.Purge, cfg.Login)
+	limiter := ratelimit.NewLimiter(ratelimit.NewStore(cfg.RateLimit, dbConn))
+	workers.Go("rate-limiter", limiter.Cleanup)
+	imageStore, err := storage.New(cfg, cfg.Storage.Backend)
+	if err != nil {
+		fatal("image storage", err)
+	}
+	imageVariants := imaging.NewGenerator(cfg.Images, imageStore)
+	workers.Go("image-variants", imageVariants.Run)
+	orphans.Init(workers, dbConn, imageStore, cfg.Purge)
 	// User repositories
 	userRepository := userRepo.NewUserDBRepository(dbConn)
 	adminRepository := userRepo.NewAdminDBRepository(dbConn)
 	moderatorRepository := userRepo.NewModeratorDBRepository(dbConn)
 	userNotificationRepository := userRepo.NewUserNotificationDBRepository(dbConn)
+	appealRepository := userRepo.NewAppealDBRepository(dbConn)
+	loginAttemptRepository := userRepo.NewLoginAttemptDBRepository(dbConn)
+	twoFactorRepository := userRepo.NewTwoFactorDBRepository(dbConn)
+	identityRepository := userRepo.NewIdentityDBRepository(dbConn)
 
 	// Post repositories
-	postRepository := postRepo.NewPostDBRepository(dbConn)
+	postRepository := postRepo.NewPostDBRepository(dbConn, cfg.Server.URL())
 	postRateRepository := postRepo.NewRateDBRepository(dbConn)
 	categoryRepository := postRepo.NewCategoryDBRepository(dbConn)
 	commentRepository := postRepo.NewCommentDBRepository(dbConn)
-	notificationRepository := postRepo.NewNotificationDBRepository(dbConn)
+	notificationRepository := postRepo.NewNotificationDBRepository(dbConn, cfg.Server.URL())
 	commentRateRepository := postRepo.NewRateCommentDBRepository(dbConn)
+	uploadRepository := postRepo.NewUploadDBRepository(dbConn)
+	attachmentRepository := postRepo.NewAttachmentDBRepository(dbConn, cfg.Server.URL())
+	mentionRepository := postRepo.NewMentionDBRepository(dbConn)
+	tagRepository := postRepo.NewTagDBRepository(dbConn)
+
+	// Unit of work spans repositories within one transaction
+	uow := db.NewUnitOfWork(dbConn)
 
 	// User usecases
 	userUcase := userUsecase.NewUserUsecase(userRepository)
-	adminUcase := userUsecase.NewAdminUsecase(adminRepository)
-	moderatorUcase := userUsecase.NewModeratorUsecase(moderatorRepository)
+	adminUcase := userUsecase.NewAdminUsecase(adminRepository, userNotificationRepository,
+		moderatorRepository, appealRepository, uow)
+	moderatorUcase := userUsecase.NewModeratorUsecase(moderatorRepository, appealRepository, userNotificationRepository, uow)
 	userNotificationUcase := userUsecase.NewUserNotificationUsecase(userNotificationRepository)
+	appealUcase := userUsecase.NewAppealUsecase(appealRepository, userNotificationRepository, postRepository, uow)
+	loginAttemptUcase := userUsecase.NewLoginAttemptUsecase(loginAttemptRepository, userNotificationRepository, cfg.Login, uow)
+	twoFactorUcase := userUsecase.NewTwoFactorUsecase(twoFactorRepository, cfg.TwoFactor)
+	oidcUcase := userUsecase.NewOIDCUsecase(userRepository, identityRepository, twoFactorRepository, oidc.NewProviders(cfg.OIDC))
 
 	// Post usecases
-	postUcase := postUsecase.NewPostUsecase(postRepository)
-	postRateUcase := postUsecase.NewRateUsecase(postRateRepository)
+	postUcase := postUsecase.NewPostUsecase(postRepository, categoryRepository, tagRepository,
+		uploadRepository, attachmentRepository, mentionRepository, uow)
+	postRateUcase := postUsecase.NewRateUsecase(postRateRepository, notificationRepository, uow)
 	categoryUcase := postUsecase.NewCategoryUsecase(categoryRepository)
-	commentUcase := postUsecase.NewCommentUsecase(commentRepository)
+	commentUcase := postUsecase.NewCommentUsecase(commentRepository, mentionRepository, uow)
 	notificationUcase := postUsecase.NewNotificationUsecase(notificationRepository)
-	commentRateUcase := postUsecase.NewRateCommentUsecase(commentRateRepository)
+	commentRateUcase := postUsecase.NewRateCommentUsecase(commentRateRepository, notificationRepository, uow)
+	uploadUcase := postUsecase.NewUploadUsecase(uploadRepository)
+	attachmentUcase := postUsecase.NewAttachmentUsecase(attachmentRepository, cfg.Attachments.MaxPerPost)
+	tagUcase := postUsecase.NewTagUsecase(tagRepository)
+
+	// Metrics read from the database at scrape time
+	db.RegisterMetrics(dbConn)
+	stats.Register(dbConn)
+	ratelimit.RegisterMetrics(limiter)
 
 	//Middleware
 	mux := http.NewServeMux()
-	mw := middleware.NewMiddlewareManager()
+	mw := middleware.NewMiddlewareManager(cfg, limiter)
+	// Metrics are kept off the public API port unless a token guards them
+	var metricsServer *http.Server
+	switch {
+	case cfg.Metrics.Addr != "":
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", mw.MetricsAuth(metrics.Handler()))
+		metricsServer = &http.Server{
+			Addr:         cfg.Metrics.Addr,
+			Handler:      metricsMux,
+			ReadTimeout:  cfg.Server.ReadTimeout,
+			WriteTimeout: cfg.Server.WriteTimeout,
+		}
+	case cfg.Metrics.Token != "":
+		mux.Handle("/metrics", mw.MetricsAuth(metrics.Handler()))
+	default:
+		slog.Warn("metrics are not served, set metrics.addr or metrics.token")
+	}
 	// User delivery
 	userHandler := userHandler.NewUserHandler(
+		cfg,
 		userUcase,
 		adminUcase,
 		moderatorUcase,
 		userNotificationUcase,
 		postUcase, postRateUcase,
-		categoryUcase, commentUcase,
+		categoryUcase, tagUcase, commentUcase,
 		notificationUcase, commentRateUcase,
+		appealUcase,
+		loginAttemptUcase,
+		twoFactorUcase,
+		oidcUcase,
 	)
 	userHandler.Configure(mux, mw)
 
 	// Post delivery
-	postHandler := postHandler.NewPostHandler(postUcase, userUcase,
+	postHandler := postHandler.NewPostHandler(cfg, postUcase, userUcase,
 		postRateUcase, categoryUcase,
 		commentUcase, notificationUcase,
-		commentRateUcase)
+		commentRateUcase, uploadUcase, attachmentUcase,
+		tagUcase, imageStore, imageVariants)
 	postHandler.Configure(mux, mw)
 
-	port := config.APIPortDev
-	if port == "" {
-		port = getPort()
-	}
-	cer, err := tls.LoadX509KeyPair("ssl/localhost.pem", "ssl/localhost-key.pem")
-	if err != nil {
-		log.Fatal("SSL", err)
-		return
-	}
+	// Liveness and readiness probes
+	health.NewHealthHandler(cfg, dbConn, workers, imageStore).Configure(mux, mw)
+
 	server := &http.Server{
-		ReadTimeout:    5 * time.Second,
-		WriteTimeout:   5 * time.Second,
-		IdleTimeout:    30 * time.Second,
-		Addr:           config.APIURLDev,
+		ReadTimeout:    cfg.Server.ReadTimeout,
+		WriteTimeout:   cfg.Server.WriteTimeout,
+		IdleTimeout:    cfg.Server.IdleTimeout,
+		Addr:           cfg.Server.Addr(),
 		MaxHeaderBytes: 1 << 20,
-		TLSConfig: &tls.Config{
-			Certificates:       []tls.Certificate{cer},
-			InsecureSkipVerify: true,
-		},
-		Handler:      middleware.Limit(mux),
-		TLSNextProto: make(map[string]func(*http.Server, *tls.Conn, http.Handler), 0),
-	}
-	// log.Println("Server is listening", "http://"+config.APIURLDev)
-	// err = http.ListenAndServe(":"+port, mux)
-	// if err != nil {
-	// 	log.Fatal("ListenAndServe: ", err)
-	// }
-	log.Println("Server is listening", "https://"+config.APIURLDev)
-	err = server.ListenAndServeTLS("", "")
-	if err != nil {
-		log.Fatal("ListenAndServe: ", err)
+		Handler:        mw.AccessLog(mux, mw.Limit(mux)),
+		TLSNextProto:   make(map[string]func(*http.Server, *tls.Conn, http.Handler), 0),
 	}
+	switch cfg.Server.TLS {
+	case config.TLSFile:
+		reloader, err := certs.NewReloader(cfg.Server.CertFile, cfg.Server.KeyFile)
+		if err != nil {
+			fatal("tls", err)
+		}
+		server.TLSConfig = &tls.Config{GetCertificate: reloader.GetCertificate}
+		workers.Go("tls-reload", reloader.Watch)
+	case config.TLSSelfSigned:
+		cer, err := certs.SelfSigned(cfg.Server.Host, "localhost", "127.0.0.1")
+		if err != nil {
+			fatal("tls", err)
+		}
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cer}}
+		slog.Warn("serving a self-signed certificate, use it for development only")
+	}
+	serverErr := make(chan error, 2)
+	if metricsServer != nil {
+		go func() {
+			slog.Info("metrics are listening", "url", "http://"+cfg.Metrics.Addr+"/metrics")
+			serverErr <- metricsServer.ListenAndServe()
+		}()
+	}
+	go func() {
+		if cfg.Server.TLS == config.TLSOff {
+			slog.Info("server is listening", "url", "http://"+cfg.Server.Addr())
+			serverErr <- server.ListenAndServe()
+			return
+		}
+		slog.Info("server is listening", "url", "https://"+cfg.Server.Addr())
+		serverErr <- server.ListenAndServeTLS("", "")
+	}()
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err = <-serverErr:
+	case sig := <-quit:
+		slog.Info("server is shutting down", "signal", sig.String())
+		err = nil
+	}
+	shutdown(server, metricsServer, workers, dbConn, cfg.Server.ShutdownTimeout)
+	if err != nil && err != http.ErrServerClosed {
+		fatal("listen and serve", err)
+	}
+}
 
+// shutdown drains in-flight requests, then stops the background workers and
+// only after that closes the database they use. metricsServer may be nil.
+func shutdown(server, metricsServer *http.Server, workers *worker.Group, dbConn *sql.DB, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("server shutdown failed", "error", err)
+	}
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(ctx); err != nil {
+			slog.Error("metrics server shutdown failed", "error", err)
+		}
+	}
+	workers.Stop()
+	if err := dbConn.Close(); err != nil {
+		slog.Error("db close failed", "error", err)
+	}
+	slog.Info("server stopped")
 }
 
-func getPort() string {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8181"
+// migrateImages copies the uploads stored by the -migrate-images-from
+// backend into the configured one.
+func migrateImages(cfg *config.Config) {
+	from, err := storage.New(cfg, cfg.Storage.MigrateFrom)
+	if err != nil {
+		fatal("image migration", err)
+	}
+	to, err := storage.New(cfg, cfg.Storage.Backend)
+	if err != nil {
+		fatal("image migration", err)
 	}
-	return port
+	slog.Info("image migration is starting", "from", from.Name(), "to", to.Name())
+	copied, err := storage.Migrate(context.Background(), from, to)
+	if err != nil {
+		fatal("image migration", err)
+	}
+	slog.Info("image migration done", "copied", copied)
+}
+
+// fatal logs err and exits, like log.Fatal.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
 
 func main() {
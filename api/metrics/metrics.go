@@ -0,0 +1,239 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets suit request and query latencies, in seconds.
+var DefaultBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5}
+
+// Sample is one labelled value reported by a GaugeFunc.
+type Sample struct {
+	Labels []string
+	Value  float64
+}
+
+type collector interface {
+	write(ctx context.Context, w *bufio.Writer)
+}
+
+var (
+	mu         sync.Mutex
+	collectors []collector
+)
+
+func register(c collector) {
+	mu.Lock()
+	collectors = append(collectors, c)
+	mu.Unlock()
+}
+
+// Handler serves every registered metric in the Prometheus text format.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		registered := append([]collector(nil), collectors...)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		buf := bufio.NewWriter(w)
+		for _, c := range registered {
+			c.write(r.Context(), buf)
+		}
+		buf.Flush()
+	})
+}
+
+type family struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func (f family) header(w *bufio.Writer) {
+	w.WriteString("# HELP " + f.name + " " + f.help + "\n")
+	w.WriteString("# TYPE " + f.name + " " + f.kind + "\n")
+}
+
+func (f family) sample(w *bufio.Writer, suffix string, values []string, extra string, value float64) {
+	w.WriteString(f.name + suffix)
+	var pairs []string
+	for i, label := range f.labels {
+		pairs = append(pairs, label+`="`+escape(values[i])+`"`)
+	}
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+	if len(pairs) > 0 {
+		w.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+	w.WriteString(" " + formatFloat(value) + "\n")
+}
+
+// CounterVec is a monotonically increasing value per label combination.
+type CounterVec struct {
+	family
+	mu     sync.Mutex
+	values map[string]*counterValue
+}
+
+type counterValue struct {
+	labels []string
+	value  float64
+}
+
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		family: family{name: name, help: help, kind: "counter", labels: labels},
+		values: make(map[string]*counterValue),
+	}
+	register(c)
+	return c
+}
+
+func (c *CounterVec) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+func (c *CounterVec) Add(delta float64, labels ...string) {
+	key := strings.Join(labels, "\xff")
+	c.mu.Lock()
+	v, ok := c.values[key]
+	if !ok {
+		v = &counterValue{labels: labels}
+		c.values[key] = v
+	}
+	v.value += delta
+	c.mu.Unlock()
+}
+
+func (c *CounterVec) write(ctx context.Context, w *bufio.Writer) {
+	c.header(w)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.values) {
+		v := c.values[key]
+		c.sample(w, "", v.labels, "", v.value)
+	}
+}
+
+// HistogramVec counts observations into cumulative buckets per label
+// combination.
+type HistogramVec struct {
+	family
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogramValue
+}
+
+type histogramValue struct {
+	labels []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		family:  family{name: name, help: help, kind: "histogram", labels: labels},
+		buckets: buckets,
+		values:  make(map[string]*histogramValue),
+	}
+	register(h)
+	return h
+}
+
+func (h *HistogramVec) Observe(value float64, labels ...string) {
+	key := strings.Join(labels, "\xff")
+	h.mu.Lock()
+	v, ok := h.values[key]
+	if !ok {
+		v = &histogramValue{labels: labels, counts: make([]uint64, len(h.buckets))}
+		h.values[key] = v
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			v.counts[i]++
+		}
+	}
+	v.count++
+	v.sum += value
+	h.mu.Unlock()
+}
+
+func (h *HistogramVec) write(ctx context.Context, w *bufio.Writer) {
+	h.header(w)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.values) {
+		v := h.values[key]
+		for i, bound := range h.buckets {
+			h.sample(w, "_bucket", v.labels, `le="`+formatFloat(bound)+`"`, float64(v.counts[i]))
+		}
+		h.sample(w, "_bucket", v.labels, `le="+Inf"`, float64(v.count))
+		h.sample(w, "_sum", v.labels, "", v.sum)
+		h.sample(w, "_count", v.labels, "", float64(v.count))
+	}
+}
+
+// GaugeFunc is a gauge whose samples are read at scrape time.
+type GaugeFunc struct {
+	family
+	collect func(ctx context.Context) ([]Sample, error)
+}
+
+// NewGaugeFunc registers a gauge computed by collect on every scrape. A
+// failing collect is logged and its samples left out of that scrape.
+func NewGaugeFunc(name, help string, labels []string, collect func(ctx context.Context) ([]Sample, error)) *GaugeFunc {
+	g := &GaugeFunc{
+		family:  family{name: name, help: help, kind: "gauge", labels: labels},
+		collect: collect,
+	}
+	register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(ctx context.Context, w *bufio.Writer) {
+	samples, err := g.collect(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "collect metric failed", "metric", g.name, "error", err)
+		return
+	}
+	g.header(w)
+	for _, s := range samples {
+		g.sample(w, "", s.Labels, "", s.Value)
+	}
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func escape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/innovember/forum/api/logger"
+	"github.com/innovember/forum/api/metrics"
+	uuid "github.com/satori/go.uuid"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+var requestDuration = metrics.NewHistogramVec("forum_http_request_duration_seconds",
+	"Time to serve HTTP requests per route.", metrics.DefaultBuckets, "method", "route", "status")
+
+// Incoming request IDs are kept only when they are short and printable, so
+// they cannot be used to forge log lines.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// statusRecorder captures what the handler chain wrote, for the access log.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+	err    error
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+// RecordError keeps the error response.Error sent to the client.
+func (rec *statusRecorder) RecordError(err error) {
+	rec.err = err
+}
+
+// AccessLog assigns every request an ID, echoed in the X-Request-ID header
+// and attached to every log line written for the request, and logs one line
+// per request once it is served. mux resolves the route pattern that is
+// logged; next is the handler chain in front of it.
+func (mw *MiddlewareManager) AccessLog(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = fmt.Sprint(uuid.NewV4())
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+		ctx := logger.WithRequest(r.Context(), slog.Default(), requestID)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		latency := time.Since(start)
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+		requestDuration.Observe(latency.Seconds(), r.Method, route, strconv.Itoa(rec.status))
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("route", route),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Int("bytes", rec.bytes),
+			slog.Duration("latency", latency),
+			slog.String("client_ip", ClientIP(r, mw.cfg.Server)),
+		}
+		level := slog.LevelInfo
+		switch {
+		case rec.status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case rec.status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		if rec.err != nil {
+			attrs = append(attrs, slog.String("error", rec.err.Error()))
+		}
+		logger.FromContext(ctx).LogAttrs(ctx, level, "request", attrs...)
+	})
+}
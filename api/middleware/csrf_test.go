@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/innovember/forum/api/config"
+)
+
+func TestCSRF(t *testing.T) {
+	cfg := config.Default()
+	cfg.ClientURL = "https://client.example/"
+	mw := NewMiddlewareManager(cfg, nil)
+	handler := mw.CSRF(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	tests := []struct {
+		name    string
+		method  string
+		cookie  string
+		header  string
+		origin  string
+		referer string
+		want    int
+	}{
+		{name: "safe method", method: http.MethodGet, want: http.StatusOK},
+		{name: "head", method: http.MethodHead, want: http.StatusOK},
+		{name: "no token nor origin", method: http.MethodPost, want: http.StatusForbidden},
+		{name: "double submit", method: http.MethodPost, cookie: "abc", header: "abc", want: http.StatusOK},
+		{name: "token mismatch", method: http.MethodPost, cookie: "abc", header: "abd", want: http.StatusForbidden},
+		{name: "token without cookie", method: http.MethodDelete, header: "abc", want: http.StatusForbidden},
+		{name: "client origin", method: http.MethodPost, origin: "https://client.example", want: http.StatusOK},
+		{name: "client origin case", method: http.MethodPut, origin: "HTTPS://Client.Example", want: http.StatusOK},
+		{name: "same origin", method: http.MethodPost, origin: "http://api.example", want: http.StatusOK},
+		{name: "foreign origin", method: http.MethodPost, origin: "https://evil.example", want: http.StatusForbidden},
+		{name: "scheme mismatch", method: http.MethodPost, origin: "http://client.example", want: http.StatusForbidden},
+		{name: "client referer", method: http.MethodPost, referer: "https://client.example/posts/1", want: http.StatusOK},
+		{name: "null origin, client referer", method: http.MethodPost, origin: "null", referer: "https://client.example/", want: http.StatusOK},
+		{name: "foreign referer", method: http.MethodPost, referer: "https://evil.example/?https://client.example", want: http.StatusForbidden},
+		{name: "relative referer", method: http.MethodPost, referer: "/posts/1", want: http.StatusForbidden},
+		{name: "foreign origin beats referer", method: http.MethodPost, origin: "https://evil.example", referer: "https://client.example/", want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "http://api.example/api/post/create", nil)
+			if tt.cookie != "" || tt.header != "" {
+				r.AddCookie(&http.Cookie{Name: config.CSRFCookieName, Value: tt.cookie})
+				r.Header.Set(config.CSRFHeaderName, tt.header)
+			}
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.referer != "" {
+				r.Header.Set("Referer", tt.referer)
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/innovember/forum/api/config"
+)
+
+// ClientIP returns the address of the client that sent r. X-Forwarded-For is
+// only honored when the request comes from a trusted proxy; the entries are
+// walked from the right and the first one that is not a trusted proxy itself
+// is the client, so a client cannot spoof its address by sending the header.
+func ClientIP(r *http.Request, server config.ServerConfig) string {
+	ip := remoteIP(r)
+	if !server.TrustsProxy(net.ParseIP(ip)) {
+		return ip
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		ip = hop.String()
+		if !server.TrustsProxy(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// Scheme returns "https" or "http" as seen by the client, taking
+// X-Forwarded-Proto into account when r comes from a trusted proxy.
+func Scheme(r *http.Request, server config.ServerConfig) string {
+	if server.TrustsProxy(net.ParseIP(remoteIP(r))) {
+		switch proto := strings.ToLower(strings.TrimSpace(r.Header.Get("X-Forwarded-Proto"))); proto {
+		case "http", "https":
+			return proto
+		}
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+func remoteIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
@@ -1,16 +1,18 @@
 package middleware
 
 import (
-	"github.com/innovember/forum/api/config"
 	"net/http"
+
+	"github.com/innovember/forum/api/config"
 )
 
 func (mw *MiddlewareManager) SetHeaders(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Headers", "X-Requested-With, Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "X-Requested-With, Content-Type, Authorization, "+config.CSRFHeaderName)
+		w.Header().Set("Access-Control-Expose-Headers", config.CSRFHeaderName+", "+RequestIDHeader+", Retry-After")
 		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
-		w.Header().Set("Access-Control-Allow-Origin", config.ClientURL)
+		w.Header().Set("Access-Control-Allow-Origin", mw.cfg.ClientURL)
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(200)
 			return
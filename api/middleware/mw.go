@@ -1,7 +1,15 @@
 package middleware
 
-type MiddlewareManager struct{}
+import (
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/services/ratelimit"
+)
 
-func NewMiddlewareManager() *MiddlewareManager {
-	return &MiddlewareManager{}
+type MiddlewareManager struct {
+	cfg     *config.Config
+	limiter *ratelimit.Limiter
+}
+
+func NewMiddlewareManager(cfg *config.Config, limiter *ratelimit.Limiter) *MiddlewareManager {
+	return &MiddlewareManager{cfg: cfg, limiter: limiter}
 }
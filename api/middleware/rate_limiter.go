@@ -1,79 +1,72 @@
 package middleware
 
 import (
-	"log"
-	"net"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
 	"net/http"
-	"sync"
+	"strconv"
 	"time"
 
-	"golang.org/x/time/rate"
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/logger"
+	"github.com/innovember/forum/api/metrics"
 )
 
-// Create a custom visitor struct which holds the rate limiter for each
-// visitor and the last time that the visitor was seen.
-type visitor struct {
-	limiter  *rate.Limiter
-	lastSeen time.Time
-}
-
-// Change the the map to hold values of the type visitor.
-var visitors = make(map[string]*visitor)
-var mu sync.Mutex
-
-// Run a background goroutine to remove old entries from the visitors map.
-func init() {
-	go cleanupVisitors()
-}
-
-func getVisitor(ip string) *rate.Limiter {
-	mu.Lock()
-	defer mu.Unlock()
-
-	v, exists := visitors[ip]
-	if !exists {
-		limiter := rate.NewLimiter(1, 50)
-		// Include the current time when creating a new visitor.
-		visitors[ip] = &visitor{limiter, time.Now()}
-		return limiter
-	}
-
-	// Update the last seen time for the visitor.
-	v.lastSeen = time.Now()
-	return v.limiter
-}
+var rejections = metrics.NewCounterVec("forum_rate_limit_rejections_total",
+	"Requests rejected by the rate limiter.", "policy")
 
-// Every minute check the map for visitors that haven't been seen for
-// more than 3 minutes and delete the entries.
-func cleanupVisitors() {
-	for {
-		time.Sleep(time.Minute)
-
-		mu.Lock()
-		for ip, v := range visitors {
-			if time.Since(v.lastSeen) > 3*time.Minute {
-				delete(visitors, ip)
+// Limit applies the rate limit policy of the route each request resolves to
+// on mux. Requests are counted per client IP, or per session for PerUser
+// policies, and every response carries the X-RateLimit-* headers.
+// Exempt routes and, when the store fails, every request are let through.
+func (mw *MiddlewareManager) Limit(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, route := mux.Handler(r)
+		name, policy := mw.cfg.RateLimit.Policy(route)
+		if policy.Exempt {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		key := "ip:" + ClientIP(r, mw.cfg.Server)
+		if policy.PerUser {
+			if session := sessionKey(r); session != "" {
+				key = "session:" + session
 			}
 		}
-		mu.Unlock()
-	}
-}
-
-func Limit(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		result, err := mw.limiter.Allow(r.Context(), name, policy, key)
 		if err != nil {
-			log.Println(err.Error())
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			logger.FromContext(r.Context()).Error("rate limit failed", "policy", name, "error", err)
+			mux.ServeHTTP(w, r)
 			return
 		}
-
-		limiter := getVisitor(ip)
-		if limiter.Allow() == false {
+		setRateLimitHeaders(w, result.Limit, result.Remaining, result.Reset)
+		if !result.Allowed {
+			rejections.Inc(name)
+			retryAfter := int(math.Ceil(time.Until(result.Reset).Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
 			http.Error(w, http.StatusText(429), http.StatusTooManyRequests)
 			return
 		}
-
-		next.ServeHTTP(w, r)
+		mux.ServeHTTP(w, r)
 	})
 }
+
+func setRateLimitHeaders(w http.ResponseWriter, limit, remaining int, reset time.Time) {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+}
+
+// sessionKey identifies the session cookie of r, or is empty. A user holds
+// one session at a time, so counting per session counts per user without
+// the session lookup the handler does anyway; a forged cookie only gets
+// past the limiter to be refused by AuthorizedOnly.
+func sessionKey(r *http.Request) string {
+	cookie, err := r.Cookie(config.SessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(cookie.Value))
+	return hex.EncodeToString(sum[:16])
+}
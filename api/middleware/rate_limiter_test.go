@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/services/ratelimit"
+)
+
+func TestLimit(t *testing.T) {
+	cfg := config.Default()
+	cfg.RateLimit.Default = config.RateLimitPolicy{Requests: 3, Window: time.Minute}
+	cfg.RateLimit.Routes = map[string]config.RateLimitPolicy{
+		"/healthz":         {Exempt: true},
+		"/api/auth/signin": {Requests: 1, Window: time.Minute},
+		"/api/post/create": {Requests: 2, Window: time.Minute, PerUser: true},
+	}
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	mux := http.NewServeMux()
+	for _, route := range []string{"/healthz", "/api/auth/signin", "/api/post/create", "/api/posts"} {
+		mux.HandleFunc(route, ok)
+	}
+
+	type request struct {
+		path, ip, session string
+		want              int
+		remaining         string
+	}
+	tests := []struct {
+		name     string
+		requests []request
+	}{
+		{"exempt route", []request{
+			{"/healthz", "10.0.0.1", "", 200, ""},
+			{"/healthz", "10.0.0.1", "", 200, ""},
+			{"/healthz", "10.0.0.1", "", 200, ""},
+			{"/healthz", "10.0.0.1", "", 200, ""},
+		}},
+		{"default policy", []request{
+			{"/api/posts", "10.0.0.1", "", 200, "2"},
+			{"/api/posts", "10.0.0.1", "", 200, "1"},
+			{"/api/posts", "10.0.0.1", "", 200, "0"},
+			{"/api/posts", "10.0.0.1", "", 429, "0"},
+			{"/api/posts", "10.0.0.2", "", 200, "2"},
+		}},
+		{"route policy apart from default", []request{
+			{"/api/posts", "10.0.0.1", "", 200, "2"},
+			{"/api/auth/signin", "10.0.0.1", "", 200, "0"},
+			{"/api/auth/signin", "10.0.0.1", "", 429, "0"},
+			{"/api/posts", "10.0.0.1", "", 200, "1"},
+		}},
+		{"per user by session", []request{
+			{"/api/post/create", "10.0.0.1", "alice", 200, "1"},
+			{"/api/post/create", "10.0.0.2", "alice", 200, "0"},
+			{"/api/post/create", "10.0.0.3", "alice", 429, "0"},
+			// another session on the same IP has its own budget
+			{"/api/post/create", "10.0.0.1", "bob", 200, "1"},
+		}},
+		{"per user without session", []request{
+			{"/api/post/create", "10.0.0.1", "", 200, "1"},
+			{"/api/post/create", "10.0.0.1", "", 200, "0"},
+			{"/api/post/create", "10.0.0.1", "", 429, "0"},
+			{"/api/post/create", "10.0.0.1", "alice", 200, "1"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewMiddlewareManager(cfg, ratelimit.NewLimiter(ratelimit.NewMemoryStore())).Limit(mux)
+			for i, req := range tt.requests {
+				r := httptest.NewRequest(http.MethodGet, req.path, nil)
+				r.RemoteAddr = req.ip + ":1234"
+				if req.session != "" {
+					r.AddCookie(&http.Cookie{Name: config.SessionCookieName, Value: req.session})
+				}
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, r)
+				if w.Code != req.want {
+					t.Errorf("request %d: status = %d, want %d", i, w.Code, req.want)
+				}
+				if got := w.Header().Get("X-RateLimit-Remaining"); got != req.remaining {
+					t.Errorf("request %d: remaining = %q, want %q", i, got, req.remaining)
+				}
+				if req.want == http.StatusTooManyRequests {
+					if retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || retryAfter < 1 || retryAfter > 60 {
+						t.Errorf("request %d: Retry-After = %q", i, w.Header().Get("Retry-After"))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSessionKey(t *testing.T) {
+	tests := []struct {
+		cookie  string
+		wantLen int
+	}{
+		{"", 0},
+		// the cookie is hashed, never used as is
+		{"abc", 32},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: config.SessionCookieName, Value: tt.cookie})
+		}
+		key := sessionKey(r)
+		if len(key) != tt.wantLen || key != "" && key == tt.cookie {
+			t.Errorf("sessionKey(%q) = %q", tt.cookie, key)
+		}
+	}
+}
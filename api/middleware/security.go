@@ -4,25 +4,31 @@ import (
 	"errors"
 	"github.com/innovember/forum/api/config"
 	// "github.com/innovember/forum/api/db"
-	// "github.com/innovember/forum/api/models"
 	"github.com/innovember/forum/api/db"
+	"github.com/innovember/forum/api/logger"
+	"github.com/innovember/forum/api/models"
 	"github.com/innovember/forum/api/response"
 	userRepo "github.com/innovember/forum/api/user/repository"
 	userUsecase "github.com/innovember/forum/api/user/usecases"
 	"net/http"
+	"strings"
 )
 
 func (mw *MiddlewareManager) AuthorizedOnly(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var (
-			err    error
-			cookie *http.Cookie
+			err     error
+			cookie  *http.Cookie
+			user    *models.User
+			enabled bool
 		)
 		//Repository
 		userRepository := userRepo.NewUserDBRepository(db.DBConn)
+		twoFactorRepository := userRepo.NewTwoFactorDBRepository(db.DBConn)
 
 		//Usecases
 		userUcase := userUsecase.NewUserUsecase(userRepository)
+		twoFactorUcase := userUsecase.NewTwoFactorUsecase(twoFactorRepository, mw.cfg.TwoFactor)
 
 		cookie, err = r.Cookie(config.SessionCookieName)
 		if err != nil {
@@ -33,10 +39,23 @@ func (mw *MiddlewareManager) AuthorizedOnly(next http.HandlerFunc) http.HandlerF
 			response.Error(w, http.StatusForbidden, errors.New("user not authorized"))
 			return
 		}
-		if _, _, err = userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, _, err = userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, http.StatusForbidden, errors.New("session not valid,user not authorized"))
 			return
 		}
-		next(w, r)
+		logger.SetUserID(r.Context(), user.ID)
+		// Staff held to the 2FA policy keep access to /api/auth/ only, to
+		// enroll or sign out
+		if twoFactorUcase.Required(user) && !strings.HasPrefix(r.URL.Path, "/api/auth/") {
+			if enabled, err = twoFactorUcase.IsEnabled(r.Context(), user.ID); err != nil {
+				response.Error(w, http.StatusInternalServerError, err)
+				return
+			}
+			if !enabled {
+				response.Error(w, http.StatusForbidden, errors.New("two-factor authentication required for your role, enroll first"))
+				return
+			}
+		}
+		mw.CSRF(next)(w, r)
 	}
 }
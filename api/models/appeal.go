@@ -0,0 +1,30 @@
+package models
+
+type ModerationAction struct {
+	ID            int64    `json:"id"`
+	PostID        int64    `json:"postId"`
+	AuthorID      int64    `json:"authorId"`
+	ModeratorID   int64    `json:"moderatorId"`
+	Action        string   `json:"action"` // banned or deleted
+	PostTitle     string   `json:"postTitle"`
+	PostContent   string   `json:"postContent"`
+	PostCreatedAt int64    `json:"postCreatedAt,omitempty"`
+	IsImage       bool     `json:"isImage"`
+	ImagePath     string   `json:"imagePath"`
+	Categories    []string `json:"categories"`
+	CreatedAt     int64    `json:"createdAt,omitempty"`
+	Appeal        *Appeal  `json:"appeal,omitempty"`
+}
+
+type Appeal struct {
+	ID         int64             `json:"id"`
+	ActionID   int64             `json:"actionId"`
+	AuthorID   int64             `json:"authorId"`
+	Content    string            `json:"content"`
+	Status     int               `json:"status"` // 0 pending, 1 reversed, 2 upheld
+	AdminID    int64             `json:"adminId"`
+	CreatedAt  int64             `json:"createdAt,omitempty"`
+	ResolvedAt int64             `json:"resolvedAt,omitempty"`
+	Author     *User             `json:"author,omitempty"`
+	Action     *ModerationAction `json:"action,omitempty"`
+}
@@ -0,0 +1,30 @@
+package models
+
+// Attachment is a file shown with a post, in Position order. Its content is
+// a Blob that identical uploads share.
+type Attachment struct {
+	ID          int64  `json:"id"`
+	PostID      int64  `json:"postId"`
+	OwnerID     int64  `json:"-"`
+	Name        string `json:"name"`
+	Caption     string `json:"caption"`
+	Position    int    `json:"position"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	Hash        string `json:"-"`
+	Path        string `json:"path"`
+	// Variants maps thumbnail, medium and original to their URLs, for images
+	Variants  map[string]string `json:"variants,omitempty"`
+	CreatedAt int64             `json:"createdAt,omitempty"`
+}
+
+// Blob is stored content, kept under FileName for as long as RefCount
+// attachments use it.
+type Blob struct {
+	Hash        string
+	FileName    string
+	ContentType string
+	Size        int64
+	RefCount    int
+	ReleasedAt  int64
+}
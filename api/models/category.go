@@ -2,6 +2,21 @@ package models
 
 type Category struct {
 	ID           int    `json:"id"`
+	ParentID     int    `json:"parentId"` // 0 for a top-level category
 	Name         string `json:"name"`
+	Slug         string `json:"slug"`
+	Description  string `json:"description"`
+	Position     int    `json:"position"` // order among its siblings
+	Locked       bool   `json:"locked"`   // only moderators and admins can post in it
 	PostAttached int64  `json:"postAttached,omitempty"`
 }
+
+type CategoryModerator struct {
+	CategoryID  int64 `json:"categoryId"`
+	ModeratorID int64 `json:"moderatorId"`
+}
+
+type ModeratorScope struct {
+	ModeratorID int64 `json:"moderatorId"`
+	Scoped      bool  `json:"scoped"` // false moderates every category
+}
@@ -5,9 +5,11 @@ type Comment struct {
 	PostID        int64  `json:"postId"`
 	AuthorID      int64  `json:"-"`
 	Content       string `json:"content"`
+	ContentHTML   string `json:"contentHtml"`
 	CreatedAt     int64  `json:"createdAt"`
 	EditedAt      int64  `json:"editedAt"`
 	Author        *User  `json:"author"`
 	CommentRating int    `json:"commentRating"`
 	UserRating    int    `json:"userRating"`
+	DeletedAt     int64  `json:"deletedAt,omitempty"`
 }
@@ -3,6 +3,11 @@ package models
 type InputUserSignIn struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	Code     string `json:"code"` // TOTP or recovery code, once 2FA is enabled
+}
+
+type InputTwoFactorCode struct {
+	Code string `json:"code"`
 }
 
 type InputUserSignUp struct {
@@ -18,10 +23,24 @@ type InputPost struct {
 	AuthorID   int64    `json:"authorId"`
 	Title      string   `json:"title"`
 	Content    string   `json:"content"`
-	Categories []string `json:"categories"`
+	Categories []int64  `json:"categories"` // IDs of existing categories
+	Tags       []string `json:"tags"`       // free-form; nil leaves those of an edited post as they are
 	IsImage    bool     `json:"isImage"`
 	ImagePath  string   `json:"imagePath"`
 	Bans       []string `json:"bans"`
+	// Attachments in display order; nil leaves those of an edited post as
+	// they are, an empty list removes them
+	Attachments []InputAttachment `json:"attachments"`
+}
+
+type InputAttachment struct {
+	ID      int64  `json:"id"` // from /api/attachment/upload
+	Caption string `json:"caption"`
+}
+
+type InputMergeCategories struct {
+	SourceID int64 `json:"sourceId"` // merged into the target, then deleted
+	TargetID int64 `json:"targetId"`
 }
 
 type InputComment struct {
@@ -49,11 +68,18 @@ type InputCommentRate struct {
 }
 
 type InputFilterPost struct {
-	Option     string   `json:"option"`   // categories or date or rating or author or banned
+	Option     string   `json:"option"`   // categories or tags or date or rating or author or banned
 	AuthorID   int64    `json:"authorId"` // getAllPosts created by AuthorId
 	Date       string   `json:"date"`     // ASC or DESC
 	Rating     string   `json:"rating"`   // ASC or DESC
 	Categories []string `json:"categories"`
+	Tags       []string `json:"tags"`
+	Match      string   `json:"match"`      // all (default) or any of the categories and tags
 	UserRating string   `json:"userRating"` // upvoted or downvoted
 	UserID     int64    `json:"userId"`
 }
+
+type InputAppeal struct {
+	ActionID int64  `json:"actionId"`
+	Content  string `json:"content"`
+}
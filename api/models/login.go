@@ -0,0 +1,57 @@
+package models
+
+// LoginAttempt tracks failed sign-ins for one account or one client IP.
+type LoginAttempt struct {
+	Key           string `json:"key"`
+	Failures      int    `json:"failures"`
+	LastFailureAt int64  `json:"lastFailureAt"`
+	BlockedUntil  int64  `json:"blockedUntil"`
+}
+
+// TwoFactor is the TOTP enrollment of a user. It is pending until a first
+// code confirms it, and LastUsedStep keeps a code from being replayed.
+type TwoFactor struct {
+	UserID       int64  `json:"userId"`
+	Secret       string `json:"-"`
+	Enabled      bool   `json:"enabled"`
+	LastUsedStep int64  `json:"-"`
+	CreatedAt    int64  `json:"createdAt"`
+}
+
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type TwoFactorStatus struct {
+	Enabled           bool `json:"enabled"`
+	Required          bool `json:"required"`
+	RecoveryCodesLeft int  `json:"recoveryCodesLeft"`
+}
+
+// Identity links a user to the subject of an OpenID Connect provider.
+type Identity struct {
+	Provider  string `json:"provider"`
+	Subject   string `json:"subject"`
+	UserID    int64  `json:"userId"`
+	Email     string `json:"email"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// OIDCState is a sign-in started at a provider and not yet completed.
+type OIDCState struct {
+	State      string `json:"state"`
+	Provider   string `json:"provider"`
+	Nonce      string `json:"-"`
+	Verifier   string `json:"-"`
+	LinkUserID int64  `json:"-"` // links the provider to this user instead of signing in
+	ExpiresAt  int64  `json:"expiresAt"`
+}
+
+// OIDCChallenge is a provider sign-in of a user with 2FA, completed once
+// the user gives a code.
+type OIDCChallenge struct {
+	Token     string `json:"-"`
+	UserID    int64  `json:"-"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
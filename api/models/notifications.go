@@ -7,6 +7,7 @@ type Notification struct {
 	RateID        int64          `json:"rateId"`
 	CommentID     int64          `json:"commentId"`
 	CommentRateID int64          `json:"commentRateId"`
+	Mention       bool           `json:"mention"` // the receiver is @mentioned in the post or comment
 	CreatedAt     int64          `json:"createdAt"`
 	Post          *Post          `json:"post"`
 	PostRating    *PostRating    `json:"postRating"`
@@ -39,3 +40,24 @@ type PostNotification struct {
 	Deleted    bool  `json:"deleted"`
 	CreatedAt  int64 `json:"createdAt,omitempty"`
 }
+
+type AppealNotification struct {
+	ID         int64 `json:"id"`
+	ReceiverID int64 `json:"receiverId"`
+	AppealID   int64 `json:"appealId"`
+	Submitted  bool  `json:"submitted"`
+	Reversed   bool  `json:"reversed"`
+	Upheld     bool  `json:"upheld"`
+	CreatedAt  int64 `json:"createdAt,omitempty"`
+}
+
+type SecurityNotification struct {
+	ID          int64 `json:"id"`
+	ReceiverID  int64 `json:"receiverId"`
+	LockedUntil int64 `json:"lockedUntil"`
+	CreatedAt   int64 `json:"createdAt,omitempty"`
+}
+
+type MentionSettings struct {
+	Enabled bool `json:"enabled"` // notify the user of their @mentions
+}
@@ -6,7 +6,9 @@ type Post struct {
 	Author         *User      `json:"author"`
 	Title          string     `json:"title"`
 	Content        string     `json:"content"`
+	ContentHTML    string     `json:"contentHtml"`
 	Categories     []Category `json:"categories"`
+	Tags           []string   `json:"tags"`
 	PostRating     int        `json:"postRating"`
 	UserRating     int        `json:"userRating"`
 	CreatedAt      int64      `json:"createdAt,omitempty"`
@@ -14,6 +16,10 @@ type Post struct {
 	CommentsNumber int        `json:"commentsNumber"`
 	IsImage        bool       `json:"isImage"`
 	ImagePath      string     `json:"imagePath"`
-	IsApproved     bool       `json:"isApproved"`
-	IsBanned       bool       `json:"isBanned"`
+	// Variants maps thumbnail, medium and original to their image URLs
+	Variants    map[string]string `json:"variants,omitempty"`
+	Attachments []Attachment      `json:"attachments"`
+	IsApproved  bool              `json:"isApproved"`
+	IsBanned    bool              `json:"isBanned"`
+	DeletedAt   int64             `json:"deletedAt,omitempty"`
 }
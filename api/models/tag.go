@@ -0,0 +1,12 @@
+package models
+
+type Tag struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	PostsNumber int64  `json:"postsNumber"` // approved posts tagged with it
+}
+
+type TagSynonym struct {
+	Name string `json:"name"` // resolved to Tag wherever a tag is given
+	Tag  string `json:"tag"`
+}
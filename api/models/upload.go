@@ -0,0 +1,13 @@
+package models
+
+// Upload is a file stored through /api/image/upload. PostID is 0 until a
+// post uses it.
+type Upload struct {
+	ID        int64  `json:"id"`
+	FileName  string `json:"fileName"`
+	OwnerID   int64  `json:"ownerId"`
+	PostID    int64  `json:"postId"`
+	Size      int64  `json:"size"`
+	Hash      string `json:"hash"` // hex SHA-256 of the stored file
+	CreatedAt int64  `json:"createdAt,omitempty"`
+}
@@ -1,14 +1,14 @@
 package delivery
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"mime/multipart"
 	"net/http"
-	"os"
-	"regexp"
+	"path"
 	"strconv"
 	"strings"
 	"time"
@@ -20,10 +20,14 @@ import (
 	"github.com/innovember/forum/api/models"
 	"github.com/innovember/forum/api/post"
 	"github.com/innovember/forum/api/response"
+	"github.com/innovember/forum/api/services/attachments"
+	"github.com/innovember/forum/api/services/imaging"
+	"github.com/innovember/forum/api/services/storage"
 	"github.com/innovember/forum/api/user"
 )
 
 type PostHandler struct {
+	cfg               *config.Config
 	postUcase         post.PostUsecase
 	userUcase         user.UserUsecase
 	rateUcase         post.RateUsecase
@@ -31,13 +35,22 @@ type PostHandler struct {
 	commentUcase      post.CommentUsecase
 	notificationUcase post.NotificationUsecase
 	commentRateUcase  post.RateCommentUsecase
+	uploadUcase       post.UploadUsecase
+	attachmentUcase   post.AttachmentUsecase
+	tagUcase          post.TagUsecase
+	imagePipeline     *imaging.Pipeline
+	attachments       *attachments.Processor
+	imageStore        storage.Store
+	imageVariants     *imaging.Generator
 }
 
-func NewPostHandler(postUcase post.PostUsecase, userUcase user.UserUsecase,
+func NewPostHandler(cfg *config.Config, postUcase post.PostUsecase, userUcase user.UserUsecase,
 	rateUcase post.RateUsecase, categoryUcase post.CategoryUsecase,
 	commentUcase post.CommentUsecase, notificationUcase post.NotificationUsecase,
-	commentRateUcase post.RateCommentUsecase) *PostHandler {
+	commentRateUcase post.RateCommentUsecase, uploadUcase post.UploadUsecase,
+	attachmentUcase post.AttachmentUsecase, tagUcase post.TagUsecase, imageStore storage.Store, imageVariants *imaging.Generator) *PostHandler {
 	return &PostHandler{
+		cfg:               cfg,
 		postUcase:         postUcase,
 		userUcase:         userUcase,
 		rateUcase:         rateUcase,
@@ -45,6 +58,13 @@ func NewPostHandler(postUcase post.PostUsecase, userUcase user.UserUsecase,
 		commentUcase:      commentUcase,
 		notificationUcase: notificationUcase,
 		commentRateUcase:  commentRateUcase,
+		uploadUcase:       uploadUcase,
+		attachmentUcase:   attachmentUcase,
+		tagUcase:          tagUcase,
+		imagePipeline:     imaging.NewPipeline(cfg.Images),
+		attachments:       attachments.NewProcessor(cfg),
+		imageStore:        imageStore,
+		imageVariants:     imageVariants,
 	}
 }
 
@@ -58,6 +78,9 @@ func (ph *PostHandler) Configure(mux *http.ServeMux, mw *middleware.MiddlewareMa
 	mux.HandleFunc("/api/post/edit", mw.SetHeaders(mw.AuthorizedOnly(ph.EditPostHandler)))
 	mux.HandleFunc("/api/post/delete/", mw.SetHeaders(mw.AuthorizedOnly(ph.DeletePostHandler)))
 	mux.HandleFunc("/api/categories", mw.SetHeaders(ph.GetAllCategoriesHandler))
+	// Tags
+	mux.HandleFunc("/api/tags/suggest", mw.SetHeaders(ph.GetTagSuggestionsHandler))
+	mux.HandleFunc("/api/tags/popular", mw.SetHeaders(ph.GetPopularTagsHandler))
 	// Comments
 	mux.HandleFunc("/api/comment/create", mw.SetHeaders(mw.AuthorizedOnly(ph.CreateCommentHandler)))
 	mux.HandleFunc("/api/comment/filter", mw.SetHeaders(ph.FilterComments))
@@ -71,7 +94,46 @@ func (ph *PostHandler) Configure(mux *http.ServeMux, mw *middleware.MiddlewareMa
 	// Images
 	mux.HandleFunc("/api/image/upload", mw.SetHeaders(mw.AuthorizedOnly(ph.UploadImageHandler)))
 	mux.HandleFunc("/api/image/delete/", mw.SetHeaders(mw.AuthorizedOnly(ph.DeleteImageHandler)))
-	mux.Handle("/images/", http.StripPrefix("/images", http.FileServer(http.Dir("./images"))))
+	mux.Handle("/images/", imageHeaders(http.StripPrefix("/images", imaging.Server(ph.imageStore))))
+	// Attachments
+	mux.HandleFunc("/api/attachment/upload", mw.SetHeaders(mw.AuthorizedOnly(ph.UploadAttachmentHandler)))
+	mux.Handle("/attachments/", attachmentHeaders(http.StripPrefix("/attachments", imaging.Server(ph.imageStore))))
+}
+
+// imageHeaders keeps browsers from sniffing uploads as another type, and
+// from running anything an SVG might still carry.
+func imageHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// attachmentHeaders serves attachments as imageHeaders does images, and has
+// documents downloaded rather than opened by the browser.
+func attachmentHeaders(next http.Handler) http.Handler {
+	return imageHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(storage.ContentType(r.URL.Path), "image/") {
+			w.Header().Set("Content-Disposition", "attachment")
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// imageURL is the link to an uploaded file. It goes through the API
+// whatever the backend, so it survives a move to another one.
+func (ph *PostHandler) imageURL(fileName string) string {
+	return fmt.Sprintf("%s/images/%s", ph.cfg.Server.URL(), fileName)
+}
+
+// imageFileName is the stored file an image link of a post points at, or ""
+// for a post without image.
+func imageFileName(isImage bool, imagePath string) string {
+	if !isImage || imagePath == "" {
+		return ""
+	}
+	return path.Base(imagePath)
 }
 
 func (ph *PostHandler) CreatePostHandler(w http.ResponseWriter, r *http.Request) {
@@ -85,21 +147,22 @@ func (ph *PostHandler) CreatePostHandler(w http.ResponseWriter, r *http.Request)
 
 func (ph *PostHandler) CreatePostHandlerFunc(w http.ResponseWriter, r *http.Request) {
 	var (
-		input   models.InputPost
-		post    models.Post
-		newPost *models.Post
-		now     = time.Now().Unix()
-		status  int
-		err     error
-		cookie  *http.Cookie
-		user    *models.User
+		input    models.InputPost
+		post     models.Post
+		newPost  *models.Post
+		now      = time.Now().Unix()
+		status   int
+		err      error
+		cookie   *http.Cookie
+		user     *models.User
+		fileName string
 	)
 	if err = json.NewDecoder(r.Body).Decode(&input); err != nil {
 		response.Error(w, http.StatusBadRequest, err)
 		return
 	}
 	cookie, _ = r.Cookie(config.SessionCookieName)
-	if user, status, err = ph.userUcase.ValidateSession(cookie.Value); err != nil {
+	if user, status, err = ph.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 		response.Error(w, status, err)
 		return
 	}
@@ -112,14 +175,41 @@ func (ph *PostHandler) CreatePostHandlerFunc(w http.ResponseWriter, r *http.Requ
 		PostRating: 0,
 		EditedAt:   0,
 		IsImage:    input.IsImage,
-		ImagePath:  input.ImagePath,
 		IsApproved: true,
 	}
-	if newPost, status, err = ph.postUcase.Create(&post, input.Categories); err != nil {
+	// Only the author's own, unused uploads can be attached, and the link is
+	// rebuilt from the file name so it points at this server
+	fileName = imageFileName(input.IsImage, input.ImagePath)
+	if fileName != "" {
+		if status, err = ph.uploadUcase.CanAttach(r.Context(), 0, user.ID, fileName); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		post.ImagePath = ph.imageURL(fileName)
+	}
+	if status, err = ph.categoryUcase.CanUseCategories(r.Context(), user, input.Categories, nil); err != nil {
 		response.Error(w, status, err)
 		return
 	}
-	if err = ph.userUcase.UpdateActivity(user.ID); err != nil {
+	if status, err = ph.tagUcase.CanUseTags(input.Tags); err != nil {
+		response.Error(w, status, err)
+		return
+	}
+	if status, err = ph.attachmentUcase.CanSetPostAttachments(r.Context(), 0, user.ID, input.Attachments); err != nil {
+		response.Error(w, status, err)
+		return
+	}
+	if newPost, status, err = ph.postUcase.Create(r.Context(), &post, &input, fileName); err != nil {
+		response.Error(w, status, err)
+		return
+	}
+	if len(input.Attachments) > 0 {
+		if newPost.Attachments, err = ph.attachmentUcase.GetAttachmentsByPostID(r.Context(), newPost.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	if err = ph.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 		response.Error(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -140,11 +230,11 @@ func (ph *PostHandler) GetPostsHandler(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			user = &models.User{ID: -1}
 		} else {
-			if user, status, err = ph.userUcase.ValidateSession(cookie.Value); err != nil {
+			if user, status, err = ph.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 				user = &models.User{ID: -1}
 			}
 		}
-		posts, status, err = ph.postUcase.GetAllPosts(user.ID)
+		posts, status, err = ph.postUcase.GetAllPosts(r.Context(), user.ID)
 		if err != nil {
 			response.Error(w, http.StatusBadRequest, err)
 			return
@@ -167,15 +257,14 @@ func (ph *PostHandler) RatePostHandler(w http.ResponseWriter, r *http.Request) {
 
 func (ph *PostHandler) RatePostHandlerFunc(w http.ResponseWriter, r *http.Request) {
 	var (
-		input         models.InputRate
-		rating        models.Rating
-		err           error
-		status        int
-		user          *models.User
-		cookie        *http.Cookie
-		isRatedBefore bool
-		rateID        int64
-		post          *models.Post
+		input       models.InputRate
+		rating      models.Rating
+		err         error
+		status      int
+		user        *models.User
+		cookie      *http.Cookie
+		isCancelled bool
+		post        *models.Post
 	)
 	if err = json.NewDecoder(r.Body).Decode(&input); err != nil {
 		response.Error(w, http.StatusBadRequest, err)
@@ -186,53 +275,27 @@ func (ph *PostHandler) RatePostHandlerFunc(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	cookie, _ = r.Cookie(config.SessionCookieName)
-	if user, status, err = ph.userUcase.ValidateSession(cookie.Value); err != nil {
+	if user, status, err = ph.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 		response.Error(w, status, err)
 		return
 	}
-	isRatedBefore, err = ph.rateUcase.IsRatedBefore(input.ID, user.ID, input.Reaction)
-	if err != nil {
-		response.Error(w, http.StatusInternalServerError, err)
-		return
-	}
-	if isRatedBefore {
-		if err = ph.rateUcase.DeleteRateFromPost(input.ID, user.ID, input.Reaction); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
-			return
-		}
-		response.Success(w, "rate cancelled due to re-voting", http.StatusOK, nil)
+	if post, status, err = ph.postUcase.GetPostByID(r.Context(), user.ID, input.ID); err != nil {
+		response.Error(w, status, err)
 		return
 	}
-	if rateID, err = ph.rateUcase.RatePost(input.ID, user.ID, input.Reaction); err != nil {
+	if isCancelled, err = ph.rateUcase.Vote(r.Context(), post, user.ID, input.Reaction); err != nil {
 		response.Error(w, http.StatusInternalServerError, err)
 		return
 	}
-	if rating.Rating, rating.UserRating, err = ph.rateUcase.GetRating(input.ID, user.ID); err != nil {
-		response.Error(w, http.StatusInternalServerError, err)
+	if isCancelled {
+		response.Success(w, "rate cancelled due to re-voting", http.StatusOK, nil)
 		return
 	}
-	if err = ph.notificationUcase.DeleteNotificationsByRateID(rateID); err != nil {
+	if rating.Rating, rating.UserRating, err = ph.rateUcase.GetRating(r.Context(), input.ID, user.ID); err != nil {
 		response.Error(w, http.StatusInternalServerError, err)
 		return
 	}
-	if post, status, err = ph.postUcase.GetPostByID(user.ID, input.ID); err != nil {
-		response.Error(w, status, err)
-		return
-	}
-	if user.ID != post.AuthorID {
-		notification := models.Notification{
-			PostID:        input.ID,
-			RateID:        rateID,
-			CommentID:     0,
-			CommentRateID: 0,
-			ReceiverID:    post.AuthorID,
-		}
-		if _, status, err = ph.notificationUcase.Create(&notification); err != nil {
-			response.Error(w, status, err)
-			return
-		}
-	}
-	if err = ph.userUcase.UpdateActivity(user.ID); err != nil {
+	if err = ph.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 		response.Error(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -247,7 +310,7 @@ func (ph *PostHandler) GetAllCategoriesHandler(w http.ResponseWriter, r *http.Re
 			err        error
 			categories []models.Category
 		)
-		categories, status, err = ph.categoryUcase.GetAllCategories()
+		categories, status, err = ph.categoryUcase.GetAllCategories(r.Context())
 		if err != nil {
 			response.Error(w, http.StatusBadRequest, err)
 			return
@@ -259,6 +322,52 @@ func (ph *PostHandler) GetAllCategoriesHandler(w http.ResponseWriter, r *http.Re
 	}
 }
 
+// GetTagSuggestionsHandler completes the tag started in the q parameter.
+func (ph *PostHandler) GetTagSuggestionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		var (
+			status      int
+			err         error
+			suggestions []models.Tag
+		)
+		if suggestions, status, err = ph.tagUcase.GetTagSuggestions(r.Context(), r.URL.Query().Get("q")); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		response.Success(w, "tag suggestions", status, suggestions)
+	} else {
+		http.Error(w, "Only GET method allowed, return to main page", 405)
+		return
+	}
+}
+
+// GetPopularTagsHandler lists the most used tags, as many as the optional
+// limit parameter asks for.
+func (ph *PostHandler) GetPopularTagsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		var (
+			status  int
+			err     error
+			limit   int
+			popular []models.Tag
+		)
+		if _limit := r.URL.Query().Get("limit"); _limit != "" {
+			if limit, err = strconv.Atoi(_limit); err != nil {
+				response.Error(w, http.StatusBadRequest, errors.New("limit must be a number"))
+				return
+			}
+		}
+		if popular, status, err = ph.tagUcase.GetPopularTags(r.Context(), limit); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		response.Success(w, "popular tags", status, popular)
+	} else {
+		http.Error(w, "Only GET method allowed, return to main page", 405)
+		return
+	}
+}
+
 func (ph *PostHandler) GetPostHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
 		var (
@@ -278,11 +387,11 @@ func (ph *PostHandler) GetPostHandler(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			user = &models.User{ID: -1}
 		} else {
-			if user, status, err = ph.userUcase.ValidateSession(cookie.Value); err != nil {
+			if user, status, err = ph.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 				user = &models.User{ID: -1}
 			}
 		}
-		post, status, err = ph.postUcase.GetPostByID(user.ID, int64(postID))
+		post, status, err = ph.postUcase.GetPostByID(r.Context(), user.ID, int64(postID))
 		if err != nil {
 			response.Error(w, http.StatusBadRequest, err)
 			return
@@ -320,33 +429,38 @@ func (ph *PostHandler) FilterPostsFunc(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		user = &models.User{ID: -1}
 	} else {
-		if user, status, err = ph.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = ph.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			user = &models.User{ID: -1}
 		}
 	}
 	switch input.Option {
-	case "categories":
-		if posts, status, err = ph.postUcase.GetPostsByCategories(input.Categories, user.ID); err != nil {
+	case "categories", "tags":
+		var tagIDs []int64
+		if tagIDs, status, err = ph.tagUcase.GetTagIDs(r.Context(), input.Tags); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if posts, status, err = ph.postUcase.GetPostsByCategoriesAndTags(r.Context(), input.Categories, tagIDs, input.Match, user.ID); err != nil {
 			response.Error(w, status, err)
 			return
 		}
 	case "date":
-		if posts, status, err = ph.postUcase.GetPostsByDate(input.Date, user.ID); err != nil {
+		if posts, status, err = ph.postUcase.GetPostsByDate(r.Context(), input.Date, user.ID); err != nil {
 			response.Error(w, status, err)
 			return
 		}
 	case "rating":
-		if posts, status, err = ph.postUcase.GetPostsByRating(input.Rating, user.ID); err != nil {
+		if posts, status, err = ph.postUcase.GetPostsByRating(r.Context(), input.Rating, user.ID); err != nil {
 			response.Error(w, status, err)
 			return
 		}
 	case "author":
-		if posts, status, err = ph.postUcase.GetAllPostsByAuthorID(input.AuthorID, user.ID); err != nil {
+		if posts, status, err = ph.postUcase.GetAllPostsByAuthorID(r.Context(), input.AuthorID, user.ID); err != nil {
 			response.Error(w, status, err)
 			return
 		}
 	case "user":
-		if posts, status, err = ph.postUcase.GetRatedPostsByUser(input.UserID, input.UserRating, user.ID); err != nil {
+		if posts, status, err = ph.postUcase.GetRatedPostsByUser(r.Context(), input.UserID, input.UserRating, user.ID); err != nil {
 			response.Error(w, status, err)
 			return
 		}
@@ -355,10 +469,22 @@ func (ph *PostHandler) FilterPostsFunc(w http.ResponseWriter, r *http.Request) {
 			response.Error(w, http.StatusForbidden, errors.New("only moderator users can filter banned posts"))
 			return
 		}
-		if posts, status, err = ph.postUcase.GetBannedPostsByCategories(input.Categories); err != nil {
+		if posts, status, err = ph.postUcase.GetBannedPostsByCategories(r.Context(), input.Categories); err != nil {
 			response.Error(w, status, err)
 			return
 		}
+		inScope := posts[:0]
+		for _, post := range posts {
+			var can bool
+			if can, err = ph.categoryUcase.CanModeratePost(r.Context(), user.ID, post.ID); err != nil {
+				response.Error(w, http.StatusInternalServerError, err)
+				return
+			}
+			if can {
+				inScope = append(inScope, post)
+			}
+		}
+		posts = inScope
 	default:
 		response.Error(w, http.StatusBadRequest, errors.New("option error in filter"))
 		return
@@ -393,7 +519,7 @@ func (ph *PostHandler) CreateCommentHandlerFunc(w http.ResponseWriter, r *http.R
 		return
 	}
 	cookie, _ = r.Cookie(config.SessionCookieName)
-	if user, status, err = ph.userUcase.ValidateSession(cookie.Value); err != nil {
+	if user, status, err = ph.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 		response.Error(w, status, err)
 		return
 	}
@@ -404,11 +530,11 @@ func (ph *PostHandler) CreateCommentHandlerFunc(w http.ResponseWriter, r *http.R
 		CreatedAt: now,
 		EditedAt:  0,
 	}
-	if newComment, status, err = ph.commentUcase.Create(user.ID, &comment); err != nil {
+	if newComment, status, err = ph.commentUcase.Create(r.Context(), user.ID, &comment); err != nil {
 		response.Error(w, status, err)
 		return
 	}
-	if post, status, err = ph.postUcase.GetPostByID(user.ID, newComment.PostID); err != nil {
+	if post, status, err = ph.postUcase.GetPostByID(r.Context(), user.ID, newComment.PostID); err != nil {
 		response.Error(w, status, err)
 		return
 	}
@@ -420,12 +546,12 @@ func (ph *PostHandler) CreateCommentHandlerFunc(w http.ResponseWriter, r *http.R
 			CommentRateID: 0,
 			ReceiverID:    post.AuthorID,
 		}
-		if _, status, err = ph.notificationUcase.Create(&notification); err != nil {
+		if _, status, err = ph.notificationUcase.Create(r.Context(), &notification); err != nil {
 			response.Error(w, status, err)
 			return
 		}
 	}
-	if err = ph.userUcase.UpdateActivity(user.ID); err != nil {
+	if err = ph.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 		response.Error(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -459,18 +585,18 @@ func (ph *PostHandler) FilterCommentsFunc(w http.ResponseWriter, r *http.Request
 	if err != nil {
 		user = &models.User{ID: -1}
 	} else {
-		if user, status, err = ph.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = ph.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			user = &models.User{ID: -1}
 		}
 	}
 	switch input.Option {
 	case "post":
-		if comments, status, err = ph.commentUcase.GetCommentsByPostID(user.ID, input.PostID); err != nil {
+		if comments, status, err = ph.commentUcase.GetCommentsByPostID(r.Context(), user.ID, input.PostID); err != nil {
 			response.Error(w, status, err)
 			return
 		}
 	case "user":
-		if comments, status, err = ph.commentUcase.GetCommentsByAuthorID(user.ID, input.UserID); err != nil {
+		if comments, status, err = ph.commentUcase.GetCommentsByAuthorID(r.Context(), user.ID, input.UserID); err != nil {
 			response.Error(w, status, err)
 			return
 		}
@@ -488,18 +614,20 @@ func (ph *PostHandler) EditPostHandler(w http.ResponseWriter, r *http.Request) {
 			input      models.InputPost
 			post       models.Post
 			editedPost *models.Post
+			oldPost    *models.Post
 			now        = time.Now().Unix()
 			status     int
 			err        error
 			cookie     *http.Cookie
 			user       *models.User
+			fileName   string
 		)
 		if err = json.NewDecoder(r.Body).Decode(&input); err != nil {
 			response.Error(w, http.StatusBadRequest, err)
 			return
 		}
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = ph.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = ph.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
@@ -507,25 +635,58 @@ func (ph *PostHandler) EditPostHandler(w http.ResponseWriter, r *http.Request) {
 			response.Error(w, http.StatusForbidden, errors.New("can't edit another user's post"))
 			return
 		}
+		if oldPost, status, err = ph.postUcase.GetPostByID(r.Context(), user.ID, input.ID); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if oldPost.AuthorID != user.ID {
+			response.Error(w, http.StatusForbidden, errors.New("can't edit another user's post"))
+			return
+		}
 		post = models.Post{
-			ID:        input.ID,
-			AuthorID:  input.AuthorID,
-			Author:    user,
-			Title:     input.Title,
-			Content:   input.Content,
-			EditedAt:  now,
-			IsImage:   input.IsImage,
-			ImagePath: input.ImagePath,
-		}
-		if err = ph.categoryUcase.Update(post.ID, input.Categories); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
+			ID:       input.ID,
+			AuthorID: input.AuthorID,
+			Author:   user,
+			Title:    input.Title,
+			Content:  input.Content,
+			EditedAt: now,
+			IsImage:  input.IsImage,
+		}
+		// An unchanged image is kept as it is; a new one must be one of the
+		// author's unused uploads
+		fileName = imageFileName(input.IsImage, input.ImagePath)
+		if fileName == imageFileName(oldPost.IsImage, oldPost.ImagePath) {
+			post.ImagePath = oldPost.ImagePath
+		} else if fileName != "" {
+			if status, err = ph.uploadUcase.CanAttach(r.Context(), post.ID, user.ID, fileName); err != nil {
+				response.Error(w, status, err)
+				return
+			}
+			post.ImagePath = ph.imageURL(fileName)
+		}
+		if status, err = ph.categoryUcase.CanUseCategories(r.Context(), user, input.Categories, oldPost.Categories); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if status, err = ph.tagUcase.CanUseTags(input.Tags); err != nil {
+			response.Error(w, status, err)
 			return
 		}
-		if editedPost, status, err = ph.postUcase.Update(&post); err != nil {
+		if input.Attachments != nil {
+			if status, err = ph.attachmentUcase.CanSetPostAttachments(r.Context(), post.ID, user.ID, input.Attachments); err != nil {
+				response.Error(w, status, err)
+				return
+			}
+		}
+		if editedPost, status, err = ph.postUcase.Update(r.Context(), &post, oldPost, &input, fileName); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if err = ph.userUcase.UpdateActivity(user.ID); err != nil {
+		if editedPost.Attachments, err = ph.attachmentUcase.GetAttachmentsByPostID(r.Context(), post.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		if err = ph.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
@@ -552,11 +713,11 @@ func (ph *PostHandler) DeletePostHandler(w http.ResponseWriter, r *http.Request)
 			return
 		}
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = ph.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = ph.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		post, status, err = ph.postUcase.GetPostByID(user.ID, int64(postID))
+		post, status, err = ph.postUcase.GetPostByID(r.Context(), user.ID, int64(postID))
 		if err != nil {
 			response.Error(w, http.StatusBadRequest, err)
 			return
@@ -565,35 +726,11 @@ func (ph *PostHandler) DeletePostHandler(w http.ResponseWriter, r *http.Request)
 			response.Error(w, http.StatusForbidden, errors.New("can't delete another user's post"))
 			return
 		}
-		if err = ph.categoryUcase.DeleteFromPostCategoriesBridge(post.ID); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
-			return
-		}
-		if err = ph.rateUcase.DeleteRatesByPostID(post.ID); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
-			return
-		}
-		if err = ph.commentUcase.DeleteCommentsByPostID(post.ID); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
-			return
-		}
-		if err = ph.notificationUcase.DeleteNotificationsByPostID(post.ID); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
-			return
-		}
-		if err = ph.commentRateUcase.DeleteCommentsRateByPostID(post.ID); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
-			return
-		}
-		if err = ph.postUcase.DeletePostReportByPostID(post.ID); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
-			return
-		}
-		if status, err = ph.postUcase.Delete(post.ID); err != nil {
+		if status, err = ph.postUcase.Delete(r.Context(), post.ID); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if err = ph.userUcase.UpdateActivity(user.ID); err != nil {
+		if err = ph.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
@@ -621,7 +758,7 @@ func (ph *PostHandler) EditCommentHandler(w http.ResponseWriter, r *http.Request
 			return
 		}
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = ph.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = ph.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
@@ -637,11 +774,11 @@ func (ph *PostHandler) EditCommentHandler(w http.ResponseWriter, r *http.Request
 			Content:  input.Content,
 			EditedAt: now,
 		}
-		if editedComment, status, err = ph.commentUcase.Update(&comment); err != nil {
+		if editedComment, status, err = ph.commentUcase.Update(r.Context(), &comment); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if err = ph.userUcase.UpdateActivity(user.ID); err != nil {
+		if err = ph.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
@@ -668,11 +805,11 @@ func (ph *PostHandler) DeleteCommentHandler(w http.ResponseWriter, r *http.Reque
 			return
 		}
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = ph.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = ph.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if comment, status, err = ph.commentUcase.GetCommentByID(user.ID, int64(commentID)); err != nil {
+		if comment, status, err = ph.commentUcase.GetCommentByID(r.Context(), user.ID, int64(commentID)); err != nil {
 			response.Error(w, status, err)
 			return
 		}
@@ -680,19 +817,11 @@ func (ph *PostHandler) DeleteCommentHandler(w http.ResponseWriter, r *http.Reque
 			response.Error(w, http.StatusForbidden, errors.New("can't delete another user's comment"))
 			return
 		}
-		if err = ph.notificationUcase.DeleteNotificationsByCommentID(comment.ID); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
-			return
-		}
-		if err = ph.commentRateUcase.DeleteRatesByCommentID(comment.ID); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
-			return
-		}
-		if err = ph.commentUcase.Delete(comment.ID); err != nil {
+		if err = ph.commentUcase.Delete(r.Context(), comment.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		if err = ph.userUcase.UpdateActivity(user.ID); err != nil {
+		if err = ph.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
@@ -713,16 +842,16 @@ func (ph *PostHandler) GetAllNotificationsHandler(w http.ResponseWriter, r *http
 			notifications []models.Notification
 		)
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = ph.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = ph.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		notifications, status, err = ph.notificationUcase.GetAllNotifications(user.ID)
+		notifications, status, err = ph.notificationUcase.GetAllNotifications(r.Context(), user.ID)
 		if err != nil {
 			response.Error(w, http.StatusBadRequest, err)
 			return
 		}
-		if err = ph.userUcase.UpdateActivity(user.ID); err != nil {
+		if err = ph.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
@@ -742,15 +871,15 @@ func (ph *PostHandler) DeleteNotificationsHandler(w http.ResponseWriter, r *http
 			user   *models.User
 		)
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = ph.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = ph.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if err = ph.notificationUcase.DeleteAllNotifications(user.ID); err != nil {
+		if err = ph.notificationUcase.DeleteAllNotifications(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		if err = ph.userUcase.UpdateActivity(user.ID); err != nil {
+		if err = ph.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
@@ -768,54 +897,126 @@ func (ph *PostHandler) UploadImageHandler(w http.ResponseWriter, r *http.Request
 			err          error
 			cookie       *http.Cookie
 			user         *models.User
-			maxImageSize int64 = config.MaxImageSize
+			maxImageSize int64 = ph.cfg.Images.MaxSize
 			image        multipart.File
-			fileHeader   *multipart.FileHeader
-			file         *os.File
-			regex        = regexp.MustCompile(`^.*\.(jpg|JPG|jpeg|JPEG|gif|GIF|png|PNG|svg|SVG)$`)
+			processed    *imaging.Image
 			fileName     string
+			hash         [sha256.Size]byte
 		)
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = ph.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = ph.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
 		if r.ContentLength > maxImageSize {
-			response.Error(w, http.StatusExpectationFailed, errors.New("image too heavy,limit size to 20MB"))
+			response.Error(w, http.StatusExpectationFailed, fmt.Errorf("image too heavy,limit size to %dMB", maxImageSize/(1024*1024)))
+			return
+		}
+		if image, _, err = r.FormFile("image"); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		defer image.Close()
+		// The stored file is what the pipeline produced, named after the
+		// detected type; the client's filename is ignored
+		if processed, status, err = ph.imagePipeline.Process(image); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		fileName = fmt.Sprintf("%s.%s", uuid.NewV4(), processed.Ext())
+		if err = ph.imageStore.Put(r.Context(), fileName, processed.Data, processed.ContentType()); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		hash = sha256.Sum256(processed.Data)
+		if err = ph.uploadUcase.Create(r.Context(), &models.Upload{
+			FileName: fileName,
+			OwnerID:  user.ID,
+			Size:     int64(len(processed.Data)),
+			Hash:     hex.EncodeToString(hash[:]),
+		}); err != nil {
+			ph.imageStore.Delete(r.Context(), fileName)
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		ph.imageVariants.Enqueue(fileName)
+		if err = ph.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "image uploaded", http.StatusCreated, ph.imageURL(fileName))
+	} else {
+		http.Error(w, "Only POST method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (ph *PostHandler) UploadAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		var (
+			status     int
+			err        error
+			cookie     *http.Cookie
+			user       *models.User
+			maxSize    int64 = ph.cfg.Attachments.MaxSize
+			file       multipart.File
+			header     *multipart.FileHeader
+			processed  *attachments.File
+			attachment models.Attachment
+			newBlob    bool
+			exists     bool
+		)
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = ph.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
 			return
 		}
-		if image, fileHeader, err = r.FormFile("image"); err != nil {
+		if r.ContentLength > maxSize {
+			response.Error(w, http.StatusExpectationFailed, fmt.Errorf("file too heavy,limit size to %dMB", maxSize/(1024*1024)))
+			return
+		}
+		if file, header, err = r.FormFile("file"); err != nil {
 			response.Error(w, http.StatusBadRequest, err)
 			return
 		}
-		if fileHeader != nil {
-			defer image.Close()
+		defer file.Close()
+		if processed, status, err = ph.attachments.Process(file); err != nil {
+			response.Error(w, status, err)
+			return
 		}
-		if fileHeader != nil && !regex.MatchString(fileHeader.Filename) {
-			response.Error(w, http.StatusUnprocessableEntity, errors.New("invalid file type"))
+		// Files are stored under their content hash, so identical uploads
+		// share one; the client's filename is only kept for display
+		fileName := processed.Key()
+		attachment = models.Attachment{
+			OwnerID:     user.ID,
+			Name:        path.Base(header.Filename),
+			Caption:     r.FormValue("caption"),
+			ContentType: processed.ContentType,
+			Size:        int64(len(processed.Data)),
+			Hash:        processed.Hash(),
+		}
+		if newBlob, status, err = ph.attachmentUcase.Create(r.Context(), &attachment, fileName); err != nil {
+			response.Error(w, status, err)
 			return
 		}
-		fileNameArr := strings.Split(fileHeader.Filename, ".")
-		fileExtension := fileNameArr[len(fileNameArr)-1]
-		fileName = fmt.Sprint(uuid.NewV4())
-		if fileHeader != nil {
-			file, err = os.Create(fmt.Sprintf("./images/%s.%s", fileName, fileExtension))
-			if err != nil {
+		if !newBlob {
+			exists, err = ph.imageStore.Exists(r.Context(), fileName)
+		}
+		if newBlob || err != nil || !exists {
+			if err = ph.imageStore.Put(r.Context(), fileName, processed.Data, processed.ContentType); err != nil {
+				ph.attachmentUcase.Delete(r.Context(), attachment.ID)
 				response.Error(w, http.StatusInternalServerError, err)
 				return
 			}
-			defer file.Close()
-			_, err = io.Copy(file, image)
-			if err != nil {
-				response.Error(w, http.StatusInternalServerError, err)
-				return
+			if processed.IsImage {
+				ph.imageVariants.Enqueue(fileName)
 			}
 		}
-		if err = ph.userUcase.UpdateActivity(user.ID); err != nil {
+		if err = ph.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "image uploaded", http.StatusCreated, fmt.Sprintf("%s/images/%s.%s", config.APIURLDev, fileName, fileExtension))
+		response.Success(w, "attachment uploaded", http.StatusCreated, attachment)
 	} else {
 		http.Error(w, "Only POST method allowed, return to main page", 405)
 		return
@@ -833,15 +1034,14 @@ func (ph *PostHandler) RateCommentHandler(w http.ResponseWriter, r *http.Request
 
 func (ph *PostHandler) RateCommentHandlerFunc(w http.ResponseWriter, r *http.Request) {
 	var (
-		input         models.InputCommentRate
-		rating        models.Rating
-		err           error
-		status        int
-		user          *models.User
-		cookie        *http.Cookie
-		isRatedBefore bool
-		commentRateID int64
-		comment       *models.Comment
+		input       models.InputCommentRate
+		rating      models.Rating
+		err         error
+		status      int
+		user        *models.User
+		cookie      *http.Cookie
+		isCancelled bool
+		comment     *models.Comment
 	)
 	if err = json.NewDecoder(r.Body).Decode(&input); err != nil {
 		response.Error(w, http.StatusBadRequest, err)
@@ -852,53 +1052,27 @@ func (ph *PostHandler) RateCommentHandlerFunc(w http.ResponseWriter, r *http.Req
 		return
 	}
 	cookie, _ = r.Cookie(config.SessionCookieName)
-	if user, status, err = ph.userUcase.ValidateSession(cookie.Value); err != nil {
+	if user, status, err = ph.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 		response.Error(w, status, err)
 		return
 	}
-	isRatedBefore, err = ph.commentRateUcase.IsRatedBefore(input.CommentID, user.ID, input.Reaction)
-	if err != nil {
-		response.Error(w, http.StatusInternalServerError, err)
-		return
-	}
-	if isRatedBefore {
-		if err = ph.commentRateUcase.DeleteRateFromComment(input.CommentID, user.ID, input.Reaction); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
-			return
-		}
-		response.Success(w, "rate cancelled due to re-voting", http.StatusOK, nil)
+	if comment, status, err = ph.commentUcase.GetCommentByID(r.Context(), user.ID, input.CommentID); err != nil {
+		response.Error(w, status, err)
 		return
 	}
-	if commentRateID, err = ph.commentRateUcase.RateComment(input.CommentID, user.ID, input.Reaction, input.PostID); err != nil {
+	if isCancelled, err = ph.commentRateUcase.Vote(r.Context(), comment, user.ID, input.Reaction); err != nil {
 		response.Error(w, http.StatusInternalServerError, err)
 		return
 	}
-	if rating.Rating, rating.UserRating, err = ph.commentRateUcase.GetCommentRating(input.CommentID, user.ID); err != nil {
-		response.Error(w, http.StatusInternalServerError, err)
+	if isCancelled {
+		response.Success(w, "rate cancelled due to re-voting", http.StatusOK, nil)
 		return
 	}
-	if err = ph.notificationUcase.DeleteNotificationsByCommentRateID(commentRateID); err != nil {
+	if rating.Rating, rating.UserRating, err = ph.commentRateUcase.GetCommentRating(r.Context(), input.CommentID, user.ID); err != nil {
 		response.Error(w, http.StatusInternalServerError, err)
 		return
 	}
-	if comment, status, err = ph.commentUcase.GetCommentByID(user.ID, input.CommentID); err != nil {
-		response.Error(w, status, err)
-		return
-	}
-	if user.ID != comment.AuthorID {
-		notification := models.Notification{
-			PostID:        comment.PostID,
-			CommentRateID: commentRateID,
-			CommentID:     comment.ID,
-			RateID:        0,
-			ReceiverID:    comment.AuthorID,
-		}
-		if _, status, err = ph.notificationUcase.Create(&notification); err != nil {
-			response.Error(w, status, err)
-			return
-		}
-	}
-	if err = ph.userUcase.UpdateActivity(user.ID); err != nil {
+	if err = ph.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 		response.Error(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -915,6 +1089,7 @@ func (ph *PostHandler) DeleteImageHandler(w http.ResponseWriter, r *http.Request
 			user   *models.User
 			postID int
 			post   *models.Post
+			upload *models.Upload
 		)
 		_id := r.URL.Path[len("/api/image/delete/"):]
 		if postID, err = strconv.Atoi(_id); err != nil {
@@ -922,27 +1097,52 @@ func (ph *PostHandler) DeleteImageHandler(w http.ResponseWriter, r *http.Request
 			return
 		}
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = ph.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = ph.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		post, status, err = ph.postUcase.GetPostByID(user.ID, int64(postID))
+		post, status, err = ph.postUcase.GetPostByID(r.Context(), user.ID, int64(postID))
 		if err != nil {
 			response.Error(w, http.StatusBadRequest, err)
 			return
 		}
-		if post.AuthorID != user.ID {
-			response.Error(w, http.StatusForbidden, errors.New("can't delete another user's post's image"))
+		fileName := imageFileName(post.IsImage, post.ImagePath)
+		if fileName == "" {
+			response.Error(w, http.StatusNotFound, errors.New("post has no image"))
 			return
 		}
-		fileNameArr := strings.Split(post.ImagePath, "/")
-		fileName := fileNameArr[len(fileNameArr)-1]
-		err = os.Remove(fmt.Sprintf("./images/%s", fileName))
-		if err != nil {
+		// The file is deleted for whoever uploaded it or a moderator, not
+		// for any post that happens to link it
+		if upload, status, err = ph.uploadUcase.GetByFileName(r.Context(), fileName); err != nil && status != http.StatusNotFound {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role < config.RoleModerator && (upload == nil || upload.OwnerID != user.ID) {
+			response.Error(w, http.StatusForbidden, errors.New("can't delete another user's image"))
+			return
+		}
+		if user.Role == config.RoleModerator && (upload == nil || upload.OwnerID != user.ID) {
+			var can bool
+			if can, err = ph.categoryUcase.CanModeratePost(r.Context(), user.ID, post.ID); err != nil {
+				response.Error(w, http.StatusInternalServerError, err)
+				return
+			}
+			if !can {
+				response.Error(w, http.StatusForbidden, errors.New("post is outside the categories you moderate"))
+				return
+			}
+		}
+		for _, key := range imaging.VariantKeys(fileName) {
+			if err = ph.imageStore.Delete(r.Context(), key); err != nil {
+				response.Error(w, http.StatusInternalServerError, err)
+				return
+			}
+		}
+		if err = ph.uploadUcase.Delete(r.Context(), fileName); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		if err = ph.userUcase.UpdateActivity(user.ID); err != nil {
+		if err = ph.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
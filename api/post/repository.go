@@ -1,87 +1,163 @@
 package post
 
 import (
+	"context"
+	"database/sql"
+
 	"github.com/innovember/forum/api/models"
 )
 
 type PostRepository interface {
-	Create(post *models.Post, categories []string) (newPost *models.Post, status int, err error)
-	GetAllPosts(userID int64) (posts []models.Post, status int, err error)
-	GetPostByID(userID int64, postID int64) (post *models.Post, status int, err error)
-	GetCategories(post *models.Post) (status int, err error)
-	GetAuthor(post *models.Post) (status int, err error)
-	GetPostsByCategories(categories []string, userID int64) (posts []models.Post, status int, err error)
-	GetPostsByRating(orderBy string, userID int64) (posts []models.Post, status int, err error)
-	GetPostsByDate(orderBy string, userID int64) (posts []models.Post, status int, err error)
-	GetAllPostsByAuthorID(authorID int64, userID int64) (posts []models.Post, status int, err error)
-	GetRatedPostsByUser(userID int64, orderBy string, requestorID int64) (posts []models.Post, status int, err error)
-	Update(post *models.Post) (editedPost *models.Post, status int, err error)
-	Delete(postID int64) (status int, err error)
-	GetBannedPostsByCategories(categories []string) (posts []models.Post, status int, err error)
-	DeletePostReportByPostID(postID int64) (err error)
+	Create(ctx context.Context, post *models.Post, categoryIDs []int64) (newPost *models.Post, status int, err error)
+	CreateTx(ctx context.Context, tx *sql.Tx, post *models.Post, categoryIDs []int64) (newPost *models.Post, status int, err error)
+	GetAllPosts(ctx context.Context, userID int64) (posts []models.Post, status int, err error)
+	GetPostByID(ctx context.Context, userID int64, postID int64) (post *models.Post, status int, err error)
+	GetCategories(ctx context.Context, post *models.Post) (status int, err error)
+	GetTags(ctx context.Context, post *models.Post) (status int, err error)
+	GetAttachments(ctx context.Context, post *models.Post) (status int, err error)
+	GetAuthor(ctx context.Context, post *models.Post) (status int, err error)
+	GetPostsByCategoriesAndTags(ctx context.Context, categories []string, tagIDs []int64, matchAny bool, userID int64) (posts []models.Post, status int, err error)
+	GetPostsByRating(ctx context.Context, orderBy string, userID int64) (posts []models.Post, status int, err error)
+	GetPostsByDate(ctx context.Context, orderBy string, userID int64) (posts []models.Post, status int, err error)
+	GetAllPostsByAuthorID(ctx context.Context, authorID int64, userID int64) (posts []models.Post, status int, err error)
+	GetRatedPostsByUser(ctx context.Context, userID int64, orderBy string, requestorID int64) (posts []models.Post, status int, err error)
+	Update(ctx context.Context, post *models.Post) (editedPost *models.Post, status int, err error)
+	UpdateTx(ctx context.Context, tx *sql.Tx, post *models.Post) (editedPost *models.Post, status int, err error)
+	Delete(ctx context.Context, postID int64) (status int, err error)
+	DeleteTx(ctx context.Context, tx *sql.Tx, postID int64) (status int, err error)
+	Restore(ctx context.Context, postID int64) (status int, err error)
+	GetDeletedPosts(ctx context.Context) (posts []models.Post, status int, err error)
+	GetBannedPostsByCategories(ctx context.Context, categories []string) (posts []models.Post, status int, err error)
+	DeletePostReportByPostID(ctx context.Context, postID int64) (err error)
+	Purge(ctx context.Context, before int64) (purged int64, err error)
 }
 
 type CategoryRepository interface {
-	Create(postID int64, categories []string) (err error)
-	GetAllCategories() (categories []models.Category, status int, err error)
-	GetCategoryIDByName(name string) (id int64, err error)
-	IsCategoryExist(category string) (bool, error)
-	Update(postID int64, categories []string) (err error)
-	DeleteFromPostCategoriesBridge(postID int64) (err error)
-	DeleteCategoryByID(categoryID int64) (err error)
-	CreateNewCategory(category string) (err error)
+	Create(ctx context.Context, postID int64, categoryIDs []int64) (err error)
+	GetAllCategories(ctx context.Context) (categories []models.Category, status int, err error)
+	GetCategoryByID(ctx context.Context, categoryID int64) (category *models.Category, err error)
+	GetCategoryBySlug(ctx context.Context, slug string) (category *models.Category, err error)
+	GetCategoryIDByName(ctx context.Context, name string) (id int64, err error)
+	IsCategoryExist(ctx context.Context, category string) (bool, error)
+	Update(ctx context.Context, postID int64, categoryIDs []int64) (err error)
+	UpdateTx(ctx context.Context, tx *sql.Tx, postID int64, categoryIDs []int64) (err error)
+	DeleteFromPostCategoriesBridge(ctx context.Context, postID int64) (err error)
+	DeleteCategoryByID(ctx context.Context, categoryID int64) (err error)
+	CreateNewCategory(ctx context.Context, category *models.Category) (err error)
+	UpdateCategory(ctx context.Context, category *models.Category) (err error)
+	GetPostsNumberByCategoryID(ctx context.Context, categoryID int64) (number int64, err error)
+	MergeCategories(ctx context.Context, sourceID int64, targetID int64) (err error)
+	AssignModerator(ctx context.Context, categoryID int64, moderatorID int64) (err error)
+	UnassignModerator(ctx context.Context, categoryID int64, moderatorID int64) (err error)
+	SetModeratorScoped(ctx context.Context, moderatorID int64, scoped bool) (err error)
+	GetModerators(ctx context.Context, categoryID int64) (moderators []models.User, err error)
+	CanModeratePost(ctx context.Context, moderatorID int64, postID int64) (can bool, err error)
 }
 
 type RateRepository interface {
-	RatePost(postID int64, userID int64, vote int) (int64, error)
-	GetPostRating(postID int64, userID int64) (rating int, userRating int, err error)
-	IsRatedBefore(postID int64, userID int64, vote int) (bool, error)
-	DeleteRateFromPost(postID int64, userID int64, vote int) error
-	GetPostRatingByID(rateID int64) (postRating *models.PostRating, status int, err error)
-	GetAuthor(postRating *models.PostRating) (status int, err error)
-	DeleteRatesByPostID(postID int64) (err error)
+	RatePost(ctx context.Context, postID int64, userID int64, vote int) (int64, error)
+	RatePostTx(ctx context.Context, tx *sql.Tx, postID int64, userID int64, vote int) (int64, error)
+	GetPostRating(ctx context.Context, postID int64, userID int64) (rating int, userRating int, err error)
+	IsRatedBefore(ctx context.Context, postID int64, userID int64, vote int) (bool, error)
+	IsRatedBeforeTx(ctx context.Context, tx *sql.Tx, postID int64, userID int64, vote int) (bool, error)
+	DeleteRateFromPost(ctx context.Context, postID int64, userID int64, vote int) error
+	DeleteRateFromPostTx(ctx context.Context, tx *sql.Tx, postID int64, userID int64, vote int) error
+	GetPostRatingByID(ctx context.Context, rateID int64) (postRating *models.PostRating, status int, err error)
+	GetAuthor(ctx context.Context, postRating *models.PostRating) (status int, err error)
+	DeleteRatesByPostID(ctx context.Context, postID int64) (err error)
 }
 
 type CommentRepository interface {
-	Create(userID int64, comment *models.Comment) (newComment *models.Comment, status int, err error)
-	GetCommentsByPostID(userID, postID int64) (comments []models.Comment, status int, err error)
-	GetAuthor(comment *models.Comment) (status int, err error)
-	GetCommentsByAuthorID(userID, authorID int64) (comments []models.Comment, status int, err error)
-	GetCommentsNumberByPostID(postID int64) (commentsNumber int, err error)
-	Update(comment *models.Comment) (editedComment *models.Comment, status int, err error)
-	GetCommentByID(userID, commentID int64) (comment *models.Comment, status int, err error)
-	Delete(commentID int64) (err error)
-	DeleteCommentsByPostID(postID int64) (err error)
+	Create(ctx context.Context, userID int64, comment *models.Comment) (newComment *models.Comment, status int, err error)
+	CreateTx(ctx context.Context, tx *sql.Tx, userID int64, comment *models.Comment) (newComment *models.Comment, status int, err error)
+	GetCommentsByPostID(ctx context.Context, userID, postID int64) (comments []models.Comment, status int, err error)
+	GetAuthor(ctx context.Context, comment *models.Comment) (status int, err error)
+	GetCommentsByAuthorID(ctx context.Context, userID, authorID int64) (comments []models.Comment, status int, err error)
+	GetCommentsNumberByPostID(ctx context.Context, postID int64) (commentsNumber int, err error)
+	Update(ctx context.Context, comment *models.Comment) (editedComment *models.Comment, status int, err error)
+	UpdateTx(ctx context.Context, tx *sql.Tx, comment *models.Comment) (editedComment *models.Comment, status int, err error)
+	GetCommentByID(ctx context.Context, userID, commentID int64) (comment *models.Comment, status int, err error)
+	Delete(ctx context.Context, commentID int64) (err error)
+	Restore(ctx context.Context, commentID int64) (err error)
+	GetDeletedComments(ctx context.Context) (comments []models.Comment, status int, err error)
+	DeleteCommentsByPostID(ctx context.Context, postID int64) (err error)
+	Purge(ctx context.Context, before int64) (purged int64, err error)
 }
 
 type NotificationRepository interface {
-	Create(notification *models.Notification) (newNotification *models.Notification, status int, err error)
-	DeleteAllNotifications(receiverID int64) (err error)
-	GetAllNotifications(receiverID int64) (notifications []models.Notification, status int, err error)
-	DeleteNotificationsByPostID(postID int64) (err error)
-	DeleteNotificationsByRateID(rateID int64) (err error)
-	DeleteNotificationsByCommentID(commentID int64) (err error)
-	DeleteNotificationsByCommentRateID(commentRateID int64) (err error)
+	Create(ctx context.Context, notification *models.Notification) (newNotification *models.Notification, status int, err error)
+	CreateTx(ctx context.Context, tx *sql.Tx, notification *models.Notification) (newNotification *models.Notification, status int, err error)
+	DeleteAllNotifications(ctx context.Context, receiverID int64) (err error)
+	GetAllNotifications(ctx context.Context, receiverID int64) (notifications []models.Notification, status int, err error)
+	DeleteNotificationsByPostID(ctx context.Context, postID int64) (err error)
+	DeleteNotificationsByRateID(ctx context.Context, rateID int64) (err error)
+	DeleteNotificationsByRateIDTx(ctx context.Context, tx *sql.Tx, rateID int64) (err error)
+	DeleteNotificationsByCommentID(ctx context.Context, commentID int64) (err error)
+	DeleteNotificationsByCommentRateID(ctx context.Context, commentRateID int64) (err error)
+	DeleteNotificationsByCommentRateIDTx(ctx context.Context, tx *sql.Tx, commentRateID int64) (err error)
 }
 
 type RateCommentRepository interface {
-	RateComment(commentID int64, userID int64, vote int, postID int64) (int64, error)
-	GetCommentRating(commentID int64, userID int64) (rating int, userRating int, err error)
-	IsRatedBefore(commentID int64, userID int64, vote int) (bool, error)
-	DeleteRateFromComment(commentID int64, userID int64, vote int) error
-	GetCommentRatingByID(commentRateID int64) (commentRating *models.CommentRating, status int, err error)
-	GetAuthor(commentRating *models.CommentRating) (status int, err error)
-	DeleteRatesByCommentID(commentID int64) (err error)
-	DeleteCommentsRateByPostID(postID int64) (err error)
+	RateComment(ctx context.Context, commentID int64, userID int64, vote int, postID int64) (int64, error)
+	RateCommentTx(ctx context.Context, tx *sql.Tx, commentID int64, userID int64, vote int, postID int64) (int64, error)
+	GetCommentRating(ctx context.Context, commentID int64, userID int64) (rating int, userRating int, err error)
+	IsRatedBefore(ctx context.Context, commentID int64, userID int64, vote int) (bool, error)
+	IsRatedBeforeTx(ctx context.Context, tx *sql.Tx, commentID int64, userID int64, vote int) (bool, error)
+	DeleteRateFromComment(ctx context.Context, commentID int64, userID int64, vote int) error
+	DeleteRateFromCommentTx(ctx context.Context, tx *sql.Tx, commentID int64, userID int64, vote int) error
+	GetCommentRatingByID(ctx context.Context, commentRateID int64) (commentRating *models.CommentRating, status int, err error)
+	GetAuthor(ctx context.Context, commentRating *models.CommentRating) (status int, err error)
+	DeleteRatesByCommentID(ctx context.Context, commentID int64) (err error)
+	DeleteCommentsRateByPostID(ctx context.Context, postID int64) (err error)
+}
+
+type UploadRepository interface {
+	Create(ctx context.Context, upload *models.Upload) (err error)
+	GetByFileName(ctx context.Context, fileName string) (upload *models.Upload, status int, err error)
+	AttachToPost(ctx context.Context, postID int64, ownerID int64, fileName string) (status int, err error)
+	AttachToPostTx(ctx context.Context, tx *sql.Tx, postID int64, ownerID int64, fileName string) (status int, err error)
+	Delete(ctx context.Context, fileName string) (err error)
+	GetOrphans(ctx context.Context, before int64) (uploads []models.Upload, err error)
+	DeleteOrphan(ctx context.Context, upload *models.Upload) (deleted bool, err error)
+}
+
+type AttachmentRepository interface {
+	Create(ctx context.Context, attachment *models.Attachment, fileName string) (newBlob bool, status int, err error)
+	GetAttachmentByID(ctx context.Context, attachmentID int64) (attachment *models.Attachment, status int, err error)
+	GetAttachmentsByPostID(ctx context.Context, postID int64) (attachments []models.Attachment, err error)
+	SetPostAttachments(ctx context.Context, postID int64, ownerID int64, attachments []models.InputAttachment) (status int, err error)
+	SetPostAttachmentsTx(ctx context.Context, tx *sql.Tx, postID int64, ownerID int64, attachments []models.InputAttachment) (status int, err error)
+	Delete(ctx context.Context, attachmentID int64) (err error)
+	DeleteAbandoned(ctx context.Context, before int64) (deleted int64, err error)
+	GetReleasedBlobs(ctx context.Context, before int64) (blobs []models.Blob, err error)
+	TombstoneBlob(ctx context.Context, hash string) (tombstoned bool, err error)
+	DeleteBlob(ctx context.Context, hash string) (err error)
+}
+
+type TagRepository interface {
+	SetPostTags(ctx context.Context, postID int64, names []string) (tagNames []string, err error)
+	SetPostTagsTx(ctx context.Context, tx *sql.Tx, postID int64, names []string) (tagNames []string, err error)
+	GetTagByName(ctx context.Context, name string) (tag *models.Tag, err error)
+	GetTagsByPrefix(ctx context.Context, prefix string, limit int) (tags []models.Tag, err error)
+	GetPopularTags(ctx context.Context, limit int) (tags []models.Tag, err error)
+	GetAllSynonyms(ctx context.Context) (synonyms []models.TagSynonym, err error)
+	AddSynonym(ctx context.Context, synonym *models.TagSynonym) (err error)
+	DeleteSynonym(ctx context.Context, name string) (deleted bool, err error)
+}
+
+type MentionRepository interface {
+	SetMentions(ctx context.Context, authorID int64, postID int64, commentID int64, usernames []string) (err error)
+	SetMentionsTx(ctx context.Context, tx *sql.Tx, authorID int64, postID int64, commentID int64, usernames []string) (err error)
 }
 
 type BanRepository interface {
-	Create(postID int64, categories []string) (err error)
-	GetAllCategories() (categories []models.Category, status int, err error)
-	GetCategoryIDByName(name string) (id int64, err error)
-	IsCategoryExist(category string) (bool, error)
-	Update(postID int64, categories []string) (err error)
-	DeleteFromPostCategoriesBridge(postID int64) (err error)
-	DeleteCategoryByID(categoryID int64) (err error)
-	CreateNewCategory(category string) (err error)
+	Create(ctx context.Context, postID int64, categories []string) (err error)
+	CreateTx(ctx context.Context, tx *sql.Tx, postID int64, categories []string) (err error)
+	GetAllCategories(ctx context.Context) (categories []models.Category, status int, err error)
+	GetCategoryIDByName(ctx context.Context, name string) (id int64, err error)
+	IsCategoryExist(ctx context.Context, category string) (bool, error)
+	Update(ctx context.Context, postID int64, categories []string) (err error)
+	DeleteFromPostCategoriesBridge(ctx context.Context, postID int64) (err error)
+	DeleteCategoryByID(ctx context.Context, categoryID int64) (err error)
+	CreateNewCategory(ctx context.Context, category string) (err error)
 }
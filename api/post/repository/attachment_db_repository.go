@@ -0,0 +1,354 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/db"
+	"github.com/innovember/forum/api/models"
+	"github.com/innovember/forum/api/post"
+	"github.com/innovember/forum/api/services/imaging"
+)
+
+type AttachmentDBRepository struct {
+	dbConn    *sql.DB
+	publicURL string
+}
+
+// NewAttachmentDBRepository returns attachments linked under publicURL, the
+// server's URL; jobs that only delete attachments pass "".
+func NewAttachmentDBRepository(conn *sql.DB, publicURL string) post.AttachmentRepository {
+	return &AttachmentDBRepository{dbConn: conn, publicURL: publicURL}
+}
+
+// Create stores an attachment no post uses yet and takes a reference on the
+// blob stored under fileName. It reports newBlob for content without a blob,
+// whose file the caller must store. A blob being deleted by the orphan
+// collector is not revived: Create fails with a conflict, and the upload
+// can be retried once the file is gone.
+func (ar *AttachmentDBRepository) Create(ctx context.Context, attachment *models.Attachment, fileName string) (newBlob bool, status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx           *sql.Tx
+		result       sql.Result
+		rowsAffected int64
+		deleting     bool
+		now          = time.Now().Unix()
+	)
+	if tx, err = ar.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return false, http.StatusInternalServerError, err
+	}
+	if err = tx.QueryRowContext(ctx, `SELECT deleting
+						  FROM blobs
+						  WHERE hash = ?`, attachment.Hash).Scan(&deleting); err != nil && err != sql.ErrNoRows {
+		tx.Rollback()
+		return false, http.StatusInternalServerError, err
+	}
+	if deleting {
+		tx.Rollback()
+		return false, http.StatusConflict, errors.New("this file is being deleted, upload it again in a moment")
+	}
+	if result, err = tx.ExecContext(ctx, `UPDATE blobs
+							  SET ref_count = ref_count + 1,
+							  released_at = 0
+							  WHERE hash = ?
+							  AND deleting = 0`, attachment.Hash); err != nil {
+		tx.Rollback()
+		return false, http.StatusInternalServerError, err
+	}
+	if rowsAffected, err = result.RowsAffected(); err != nil {
+		tx.Rollback()
+		return false, http.StatusInternalServerError, err
+	}
+	if newBlob = rowsAffected == 0; newBlob {
+		if _, err = tx.ExecContext(ctx, `INSERT INTO blobs(hash, file_name, content_type,
+			size, ref_count, released_at, created_at)
+		VALUES(?,?,?,?,1,0,?)`, attachment.Hash, fileName,
+			attachment.ContentType, attachment.Size, now); err != nil {
+			tx.Rollback()
+			return false, http.StatusInternalServerError, err
+		}
+	}
+	if result, err = tx.ExecContext(ctx, `INSERT INTO attachments(post_id, owner_id,
+		blob_hash, name, caption, position, created_at)
+	VALUES(0,?,?,?,?,0,?)`, attachment.OwnerID, attachment.Hash,
+		attachment.Name, attachment.Caption, now); err != nil {
+		tx.Rollback()
+		return false, http.StatusInternalServerError, err
+	}
+	if attachment.ID, err = result.LastInsertId(); err != nil {
+		tx.Rollback()
+		return false, http.StatusInternalServerError, err
+	}
+	if err = tx.Commit(); err != nil {
+		return false, http.StatusInternalServerError, err
+	}
+	attachment.CreatedAt = now
+	ar.setLinks(attachment, fileName)
+	return newBlob, http.StatusCreated, nil
+}
+
+func (ar *AttachmentDBRepository) GetAttachmentByID(ctx context.Context, attachmentID int64) (*models.Attachment, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		a        models.Attachment
+		fileName string
+		err      error
+	)
+	if err = ar.dbConn.QueryRowContext(ctx, `SELECT a.id, a.post_id, a.owner_id,
+							 a.name, a.caption, a.position, b.content_type,
+							 b.size, a.blob_hash, b.file_name, a.created_at
+							 FROM attachments a
+							 JOIN blobs b ON b.hash = a.blob_hash
+							 WHERE a.id = ?`, attachmentID).Scan(&a.ID,
+		&a.PostID, &a.OwnerID, &a.Name, &a.Caption, &a.Position,
+		&a.ContentType, &a.Size, &a.Hash, &fileName, &a.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, http.StatusNotFound, errors.New("attachment not found")
+		}
+		return nil, http.StatusInternalServerError, err
+	}
+	ar.setLinks(&a, fileName)
+	return &a, http.StatusOK, nil
+}
+
+func (ar *AttachmentDBRepository) GetAttachmentsByPostID(ctx context.Context, postID int64) (attachments []models.Attachment, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var rows *sql.Rows
+	if rows, err = ar.dbConn.QueryContext(ctx, `
+		SELECT a.id, a.post_id, a.owner_id,
+		a.name, a.caption, a.position, b.content_type,
+		b.size, a.blob_hash, b.file_name, a.created_at
+		FROM attachments a
+		JOIN blobs b ON b.hash = a.blob_hash
+		WHERE a.post_id = ?
+		ORDER BY a.position, a.id
+		`, postID); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			a        models.Attachment
+			fileName string
+		)
+		if err = rows.Scan(&a.ID, &a.PostID, &a.OwnerID, &a.Name,
+			&a.Caption, &a.Position, &a.ContentType, &a.Size,
+			&a.Hash, &fileName, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		ar.setLinks(&a, fileName)
+		attachments = append(attachments, a)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// SetPostAttachments makes the listed attachments those of the post, in
+// order, and deletes the ones it had that are no longer listed. Every listed
+// attachment must belong to ownerID and to no other post.
+func (ar *AttachmentDBRepository) SetPostAttachments(ctx context.Context, postID int64, ownerID int64, attachments []models.InputAttachment) (status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx *sql.Tx
+	)
+	if tx, err = ar.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if status, err = ar.SetPostAttachmentsTx(ctx, tx, postID, ownerID, attachments); err != nil {
+		tx.Rollback()
+		return status, err
+	}
+	if err = tx.Commit(); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return status, nil
+}
+
+func (ar *AttachmentDBRepository) SetPostAttachmentsTx(ctx context.Context, tx *sql.Tx, postID int64, ownerID int64, attachments []models.InputAttachment) (status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		result       sql.Result
+		rowsAffected int64
+		ids          = make([]interface{}, 0, len(attachments)+1)
+	)
+	for position, attachment := range attachments {
+		if result, err = tx.ExecContext(ctx, `UPDATE attachments
+								  SET post_id = ?,
+								  position = ?,
+								  caption = ?
+								  WHERE id = ?
+								  AND owner_id = ?
+								  AND post_id IN (0, ?)`,
+			postID, position, attachment.Caption,
+			attachment.ID, ownerID, postID); err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if rowsAffected, err = result.RowsAffected(); err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if rowsAffected == 0 {
+			return http.StatusForbidden, errors.New("attachment belongs to another user or post")
+		}
+		ids = append(ids, attachment.ID)
+	}
+	where := `attachments.post_id = ?`
+	if len(attachments) > 0 {
+		where += ` AND attachments.id NOT IN (?` + strings.Repeat(`,?`, len(attachments)-1) + `)`
+	}
+	if _, err = releaseAttachments(ctx, tx, where, append([]interface{}{postID}, ids...)...); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// Delete removes an attachment and drops its reference on the blob.
+func (ar *AttachmentDBRepository) Delete(ctx context.Context, attachmentID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var tx *sql.Tx
+	if tx, err = ar.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return err
+	}
+	if _, err = releaseAttachments(ctx, tx, `attachments.id = ?`, attachmentID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteAbandoned removes the attachments created before the given unix
+// time that no post uses, as GetOrphans finds uploads.
+func (ar *AttachmentDBRepository) DeleteAbandoned(ctx context.Context, before int64) (deleted int64, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var tx *sql.Tx
+	if tx, err = ar.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return 0, err
+	}
+	if deleted, err = releaseAttachments(ctx, tx, `attachments.created_at < ?
+		AND NOT EXISTS (SELECT 1 FROM posts p WHERE p.id = attachments.post_id)
+		AND NOT EXISTS (SELECT 1 FROM moderation_actions m WHERE m.post_id = attachments.post_id)`,
+		before); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return deleted, tx.Commit()
+}
+
+// GetReleasedBlobs returns the blobs no attachment has used since before
+// the given unix time, and those whose deletion was interrupted.
+func (ar *AttachmentDBRepository) GetReleasedBlobs(ctx context.Context, before int64) (blobs []models.Blob, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var rows *sql.Rows
+	if rows, err = ar.dbConn.QueryContext(ctx, `
+		SELECT hash, file_name, content_type, size, ref_count, released_at
+		FROM blobs
+		WHERE ref_count <= 0
+		AND released_at > 0
+		AND (released_at < ? OR deleting = 1)
+		`, before); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var b models.Blob
+		if err = rows.Scan(&b.Hash, &b.FileName, &b.ContentType,
+			&b.Size, &b.RefCount, &b.ReleasedAt); err != nil {
+			return nil, err
+		}
+		blobs = append(blobs, b)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return blobs, nil
+}
+
+// TombstoneBlob marks a released blob as being deleted, unless an upload
+// has taken a reference on it again. Until DeleteBlob removes the record,
+// uploads of the same content are refused rather than pointed at a file
+// about to disappear. The caller deletes the stored file when it reports
+// true.
+func (ar *AttachmentDBRepository) TombstoneBlob(ctx context.Context, hash string) (tombstoned bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		result       sql.Result
+		rowsAffected int64
+	)
+	if result, err = ar.dbConn.ExecContext(ctx, `UPDATE blobs
+							SET deleting = 1
+							WHERE hash = ?
+							AND ref_count <= 0`, hash); err != nil {
+		return false, err
+	}
+	if rowsAffected, err = result.RowsAffected(); err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// DeleteBlob removes the record of a tombstoned blob once its file is gone.
+func (ar *AttachmentDBRepository) DeleteBlob(ctx context.Context, hash string) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if _, err = ar.dbConn.ExecContext(ctx, `DELETE FROM blobs
+							WHERE hash = ?
+							AND deleting = 1`, hash); err != nil {
+		return err
+	}
+	return nil
+}
+
+// releaseAttachments deletes the attachments matching where, a condition on
+// the attachments table, and drops their references on their blobs. Blobs
+// left without any are stamped released for the orphan collector.
+func releaseAttachments(ctx context.Context, exec db.Executor, where string, args ...interface{}) (released int64, err error) {
+	var (
+		result sql.Result
+		now    = time.Now().Unix()
+	)
+	if _, err = exec.ExecContext(ctx, `UPDATE blobs
+						   SET ref_count = ref_count - (
+							   SELECT COUNT(*) FROM attachments
+							   WHERE attachments.blob_hash = blobs.hash
+							   AND `+where+`)
+						   WHERE hash IN (
+							   SELECT blob_hash FROM attachments
+							   WHERE `+where+`)`,
+		append(append([]interface{}{}, args...), args...)...); err != nil {
+		return 0, err
+	}
+	if _, err = exec.ExecContext(ctx, `UPDATE blobs
+						   SET released_at = ?
+						   WHERE ref_count <= 0
+						   AND released_at = 0`, now); err != nil {
+		return 0, err
+	}
+	if result, err = exec.ExecContext(ctx, `DELETE FROM attachments WHERE `+where, args...); err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// setLinks builds the links of an attachment from the name its blob is
+// stored under, so they follow the server's URL: its Path and, for images,
+// the scaled copies.
+func (ar *AttachmentDBRepository) setLinks(attachment *models.Attachment, fileName string) {
+	attachment.Path = ar.publicURL + "/attachments/" + fileName
+	attachment.Variants = imaging.Variants(strings.HasPrefix(attachment.ContentType, "image/"), attachment.Path)
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"github.com/innovember/forum/api/config"
 	"github.com/innovember/forum/api/models"
 	"github.com/innovember/forum/api/post"
 	"net/http"
@@ -16,29 +17,45 @@ func NewBanDBRepository(conn *sql.DB) post.BanRepository {
 	return &BanDBRepository{dbConn: conn}
 }
 
-func (br *BanDBRepository) Create(postID int64, categories []string) (err error) {
+func (br *BanDBRepository) Create(ctx context.Context, postID int64, categories []string) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx *sql.Tx
+	)
+	if tx, err = br.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return err
+	}
+	if err = br.CreateTx(ctx, tx, postID, categories); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (br *BanDBRepository) CreateTx(ctx context.Context, tx *sql.Tx, postID int64, categories []string) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		categoryID int64
 		result     sql.Result
-		isExist    bool
 	)
 	for _, category := range categories {
-		if isExist, err = br.IsCategoryExist(category); err != nil {
-			return err
-		}
-		if !isExist {
-			if result, err = br.dbConn.Exec(`INSERT INTO bans(name) VALUES(?)`, category); err != nil {
+		if err = tx.QueryRowContext(ctx, `SELECT id FROM bans WHERE name=?`, category).Scan(
+			&categoryID); err == sql.ErrNoRows {
+			if result, err = tx.ExecContext(ctx, `INSERT INTO bans(name) VALUES(?)`, category); err != nil {
 				return err
 			}
 			if categoryID, err = result.LastInsertId(); err != nil {
 				return err
 			}
-		} else {
-			if categoryID, err = br.GetCategoryIDByName(category); err != nil {
-				return err
-			}
+		} else if err != nil {
+			return err
 		}
-		if _, err = br.dbConn.Exec(
+		if _, err = tx.ExecContext(ctx,
 			`INSERT INTO posts_bans_bridge (post_id, ban_id)
 			VALUES (?, ?)`,
 			postID, categoryID,
@@ -49,12 +66,14 @@ func (br *BanDBRepository) Create(postID int64, categories []string) (err error)
 	return nil
 }
 
-func (br *BanDBRepository) IsCategoryExist(category string) (bool, error) {
+func (br *BanDBRepository) IsCategoryExist(ctx context.Context, category string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		id  int64
 		err error
 	)
-	if err = br.dbConn.QueryRow(`SELECT id FROM bans WHERE name=?`, category).Scan(
+	if err = br.dbConn.QueryRowContext(ctx, `SELECT id FROM bans WHERE name=?`, category).Scan(
 		&id); err != nil {
 		if err == sql.ErrNoRows {
 			return false, nil
@@ -64,8 +83,10 @@ func (br *BanDBRepository) IsCategoryExist(category string) (bool, error) {
 	return true, nil
 }
 
-func (br *BanDBRepository) GetCategoryIDByName(name string) (id int64, err error) {
-	if err = br.dbConn.QueryRow(`SELECT id FROM bans WHERE name=?`, name).Scan(
+func (br *BanDBRepository) GetCategoryIDByName(ctx context.Context, name string) (id int64, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if err = br.dbConn.QueryRowContext(ctx, `SELECT id FROM bans WHERE name=?`, name).Scan(
 		&id); err != nil {
 		if err == sql.ErrNoRows {
 			return 0, err
@@ -75,9 +96,11 @@ func (br *BanDBRepository) GetCategoryIDByName(name string) (id int64, err error
 	return id, nil
 }
 
-func (br *BanDBRepository) GetAllCategories() (categories []models.Category, status int, err error) {
+func (br *BanDBRepository) GetAllCategories(ctx context.Context) (categories []models.Category, status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var rows *sql.Rows
-	if rows, err = br.dbConn.Query(`SELECT * FROM bans`); err != nil {
+	if rows, err = br.dbConn.QueryContext(ctx, `SELECT * FROM bans`); err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
 	defer rows.Close()
@@ -96,31 +119,33 @@ func (br *BanDBRepository) GetAllCategories() (categories []models.Category, sta
 	return categories, http.StatusOK, nil
 }
 
-func (br *BanDBRepository) Update(postID int64, categories []string) (err error) {
-	if err = br.DeleteFromPostCategoriesBridge(postID); err != nil {
+func (br *BanDBRepository) Update(ctx context.Context, postID int64, categories []string) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if err = br.DeleteFromPostCategoriesBridge(ctx, postID); err != nil {
 		return err
 	}
-	if err = br.Create(postID, categories); err != nil {
+	if err = br.Create(ctx, postID, categories); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (br *BanDBRepository) DeleteFromPostCategoriesBridge(postID int64) (err error) {
+func (br *BanDBRepository) DeleteFromPostCategoriesBridge(ctx context.Context, postID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
-		tx  *sql.Tx
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = br.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM posts_bans_bridge
+	if _, err = tx.ExecContext(ctx, `DELETE FROM posts_bans_bridge
 						WHERE post_id = ?`, postID); err != nil {
 		tx.Rollback()
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM bans
+	if _, err = tx.ExecContext(ctx, `DELETE FROM bans
 						 WHERE id IN
 						(SELECT b.id FROM bans AS b 
 						LEFT JOIN posts_bans_bridge AS pbb
@@ -136,16 +161,16 @@ func (br *BanDBRepository) DeleteFromPostCategoriesBridge(postID int64) (err err
 	return nil
 }
 
-func (br *BanDBRepository) DeleteCategoryByID(categoryID int64) (err error) {
+func (br *BanDBRepository) DeleteCategoryByID(ctx context.Context, categoryID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
-		tx  *sql.Tx
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = br.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM bans
+	if _, err = tx.ExecContext(ctx, `DELETE FROM bans
 						 WHERE id = ?
  						)`, categoryID); err != nil {
 		tx.Rollback()
@@ -157,16 +182,16 @@ func (br *BanDBRepository) DeleteCategoryByID(categoryID int64) (err error) {
 	return nil
 }
 
-func (br *BanDBRepository) CreateNewCategory(category string) (err error) {
+func (br *BanDBRepository) CreateNewCategory(ctx context.Context, category string) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
-		tx  *sql.Tx
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = br.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`INSERT INTO bans(name) VALUES(?)`, category); err != nil {
+	if _, err = tx.ExecContext(ctx, `INSERT INTO bans(name) VALUES(?)`, category); err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -3,8 +3,12 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"net/http"
+	"time"
 
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/db"
 	"github.com/innovember/forum/api/models"
 	"github.com/innovember/forum/api/post"
 )
@@ -17,29 +21,24 @@ func NewCategoryDBRepository(conn *sql.DB) post.CategoryRepository {
 	return &CategoryDBRepository{dbConn: conn}
 }
 
-func (cr *CategoryDBRepository) Create(postID int64, categories []string) (err error) {
-	var (
-		categoryID int64
-		result     sql.Result
-		isExist    bool
-	)
-	for _, category := range categories {
-		if isExist, err = cr.IsCategoryExist(category); err != nil {
-			return err
-		}
-		if !isExist {
-			if result, err = cr.dbConn.Exec(`INSERT INTO categories(name) VALUES(?)`, category); err != nil {
-				return err
-			}
-			if categoryID, err = result.LastInsertId(); err != nil {
-				return err
-			}
-		} else {
-			if categoryID, err = cr.GetCategoryIDByName(category); err != nil {
-				return err
-			}
-		}
-		if _, err = cr.dbConn.Exec(
+// categoryColumns are the columns scanCategory reads, in its order.
+const categoryColumns = `c.id, c.parent_id, c.name, c.slug, c.description, c.position, c.locked`
+
+func scanCategory(row interface{ Scan(...interface{}) error }, c *models.Category) error {
+	return row.Scan(&c.ID, &c.ParentID, &c.Name, &c.Slug, &c.Description, &c.Position, &c.Locked)
+}
+
+// Create files a post under existing categories, checked beforehand by
+// CategoryUsecase.CanUseCategories.
+func (cr *CategoryDBRepository) Create(ctx context.Context, postID int64, categoryIDs []int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	return createPostCategories(ctx, cr.dbConn, postID, categoryIDs)
+}
+
+func createPostCategories(ctx context.Context, exec db.Executor, postID int64, categoryIDs []int64) (err error) {
+	for _, categoryID := range categoryIDs {
+		if _, err = exec.ExecContext(ctx,
 			`INSERT INTO posts_categories_bridge (post_id, category_id)
 			VALUES (?, ?)`,
 			postID, categoryID,
@@ -50,12 +49,14 @@ func (cr *CategoryDBRepository) Create(postID int64, categories []string) (err e
 	return nil
 }
 
-func (cr *CategoryDBRepository) IsCategoryExist(category string) (bool, error) {
+func (cr *CategoryDBRepository) IsCategoryExist(ctx context.Context, category string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		id  int64
 		err error
 	)
-	if err = cr.dbConn.QueryRow(`SELECT id FROM categories WHERE name=?`, category).Scan(
+	if err = cr.dbConn.QueryRowContext(ctx, `SELECT id FROM categories WHERE name=?`, category).Scan(
 		&id); err != nil {
 		if err == sql.ErrNoRows {
 			return false, nil
@@ -65,8 +66,10 @@ func (cr *CategoryDBRepository) IsCategoryExist(category string) (bool, error) {
 	return true, nil
 }
 
-func (cr *CategoryDBRepository) GetCategoryIDByName(name string) (id int64, err error) {
-	if err = cr.dbConn.QueryRow(`SELECT id FROM categories WHERE name=?`, name).Scan(
+func (cr *CategoryDBRepository) GetCategoryIDByName(ctx context.Context, name string) (id int64, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if err = cr.dbConn.QueryRowContext(ctx, `SELECT id FROM categories WHERE name=?`, name).Scan(
 		&id); err != nil {
 		if err == sql.ErrNoRows {
 			return 0, err
@@ -76,15 +79,45 @@ func (cr *CategoryDBRepository) GetCategoryIDByName(name string) (id int64, err
 	return id, nil
 }
 
-func (cr *CategoryDBRepository) GetAllCategories() (categories []models.Category, status int, err error) {
+func (cr *CategoryDBRepository) GetCategoryByID(ctx context.Context, categoryID int64) (category *models.Category, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	category = &models.Category{}
+	if err = scanCategory(cr.dbConn.QueryRowContext(ctx, `SELECT `+categoryColumns+`
+		FROM categories AS c
+		WHERE c.id = ?`, categoryID), category); err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+func (cr *CategoryDBRepository) GetCategoryBySlug(ctx context.Context, slug string) (category *models.Category, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	category = &models.Category{}
+	if err = scanCategory(cr.dbConn.QueryRowContext(ctx, `SELECT `+categoryColumns+`
+		FROM categories AS c
+		WHERE c.slug = ?`, slug), category); err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// GetAllCategories returns every category, siblings in their order; the
+// hierarchy is built from their parentId.
+func (cr *CategoryDBRepository) GetAllCategories(ctx context.Context) (categories []models.Category, status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var rows *sql.Rows
-	if rows, err = cr.dbConn.Query(`SELECT * FROM categories`); err != nil {
+	if rows, err = cr.dbConn.QueryContext(ctx, `SELECT `+categoryColumns+`
+		FROM categories AS c
+		ORDER BY c.parent_id, c.position, c.name`); err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
 	defer rows.Close()
 	for rows.Next() {
 		var c models.Category
-		err = rows.Scan(&c.ID, &c.Name)
+		err = scanCategory(rows, &c)
 		if err != nil {
 			return nil, http.StatusInternalServerError, err
 		}
@@ -97,37 +130,86 @@ func (cr *CategoryDBRepository) GetAllCategories() (categories []models.Category
 	return categories, http.StatusOK, nil
 }
 
-func (cr *CategoryDBRepository) Update(postID int64, categories []string) (err error) {
-	if err = cr.DeleteFromPostCategoriesBridge(postID); err != nil {
+func (cr *CategoryDBRepository) Update(ctx context.Context, postID int64, categoryIDs []int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx *sql.Tx
+	)
+	if tx, err = cr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if err = cr.Create(postID, categories); err != nil {
+	if err = cr.UpdateTx(ctx, tx, postID, categoryIDs); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (cr *CategoryDBRepository) DeleteFromPostCategoriesBridge(postID int64) (err error) {
+func (cr *CategoryDBRepository) UpdateTx(ctx context.Context, tx *sql.Tx, postID int64, categoryIDs []int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if _, err = tx.ExecContext(ctx, `DELETE FROM posts_categories_bridge
+						WHERE post_id = ?`, postID); err != nil {
+		return err
+	}
+	return createPostCategories(ctx, tx, postID, categoryIDs)
+}
+
+func (cr *CategoryDBRepository) DeleteFromPostCategoriesBridge(ctx context.Context, postID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if _, err = cr.dbConn.ExecContext(ctx, `DELETE FROM posts_categories_bridge
+						WHERE post_id = ?`, postID); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cr *CategoryDBRepository) GetPostsNumberByCategoryID(ctx context.Context, categoryID int64) (number int64, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if err = cr.dbConn.QueryRowContext(ctx, `SELECT COUNT(*)
+		FROM posts_categories_bridge
+		WHERE category_id = ?`, categoryID).Scan(&number); err != nil {
+		return 0, err
+	}
+	return number, nil
+}
+
+// DeleteCategoryByID deletes a category. Its posts stay under their other
+// categories, and its children move up to its parent.
+func (cr *CategoryDBRepository) DeleteCategoryByID(ctx context.Context, categoryID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
-		tx  *sql.Tx
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = cr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM posts_categories_bridge
-						WHERE post_id = ?`, postID); err != nil {
+	if _, err = tx.ExecContext(ctx, `UPDATE categories
+						 SET parent_id = (SELECT parent_id FROM categories WHERE id = ?)
+						 WHERE parent_id = ?`, categoryID, categoryID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM posts_categories_bridge
+						 WHERE category_id = ?`, categoryID); err != nil {
 		tx.Rollback()
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM categories
-						 WHERE id IN
-						(SELECT c.id FROM categories AS c 
-						LEFT JOIN posts_categories_bridge AS pcb
-						ON c.id = pcb.category_id
-						WHERE pcb.category_id IS NULL
- 						)`); err != nil {
+	if _, err = tx.ExecContext(ctx, `DELETE FROM category_moderators
+						 WHERE category_id = ?`, categoryID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM categories
+						 WHERE id = ?
+ 						`, categoryID); err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -137,18 +219,110 @@ func (cr *CategoryDBRepository) DeleteFromPostCategoriesBridge(postID int64) (er
 	return nil
 }
 
-func (cr *CategoryDBRepository) DeleteCategoryByID(categoryID int64) (err error) {
+func (cr *CategoryDBRepository) CreateNewCategory(ctx context.Context, category *models.Category) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
-		tx  *sql.Tx
+		result sql.Result
+		id     int64
+	)
+	if result, err = cr.dbConn.ExecContext(ctx, `INSERT INTO categories(parent_id, name, slug,
+		description, position, locked)
+		VALUES(?,?,?,?,?,?)`, category.ParentID, category.Name, category.Slug,
+		category.Description, category.Position, category.Locked); err != nil {
+		return err
+	}
+	if id, err = result.LastInsertId(); err != nil {
+		return err
+	}
+	category.ID = int(id)
+	return nil
+}
+
+// UpdateCategory saves every setting of a category. A new name is also
+// written into the snapshots of moderated posts, which restoring a purged
+// post files it under.
+func (cr *CategoryDBRepository) UpdateCategory(ctx context.Context, category *models.Category) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx      *sql.Tx
+		oldName string
 	)
-	ctx = context.Background()
 	if tx, err = cr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM categories
-						 WHERE id = ?
- 						`, categoryID); err != nil {
+	if err = tx.QueryRowContext(ctx, `SELECT name FROM categories WHERE id = ?`, category.ID).Scan(&oldName); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE categories
+						 SET parent_id = ?,
+						 name = ?,
+						 slug = ?,
+						 description = ?,
+						 position = ?,
+						 locked = ?
+						 WHERE id = ?`, category.ParentID, category.Name, category.Slug,
+		category.Description, category.Position, category.Locked, category.ID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if oldName != category.Name {
+		if err = renameInSnapshots(ctx, tx, oldName, category.Name); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// MergeCategories moves everything of the source category to the target,
+// its posts, subcategories and moderators, then deletes it, in one
+// transaction. Posts already under both keep a single link to the target.
+func (cr *CategoryDBRepository) MergeCategories(ctx context.Context, sourceID int64, targetID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx         *sql.Tx
+		sourceName string
+		targetName string
+	)
+	if tx, err = cr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return err
+	}
+	if err = tx.QueryRowContext(ctx, `SELECT name FROM categories WHERE id = ?`, sourceID).Scan(&sourceName); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.QueryRowContext(ctx, `SELECT name FROM categories WHERE id = ?`, targetID).Scan(&targetName); err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, statement := range []string{
+		`INSERT INTO posts_categories_bridge (post_id, category_id)
+		SELECT DISTINCT post_id, @target
+		FROM posts_categories_bridge
+		WHERE category_id = @source
+		AND post_id NOT IN (SELECT post_id FROM posts_categories_bridge WHERE category_id = @target)`,
+		`DELETE FROM posts_categories_bridge WHERE category_id = @source`,
+		`UPDATE categories SET parent_id = @target WHERE parent_id = @source`,
+		`INSERT OR IGNORE INTO category_moderators (category_id, user_id, created_at)
+		SELECT @target, user_id, created_at
+		FROM category_moderators
+		WHERE category_id = @source`,
+		`DELETE FROM category_moderators WHERE category_id = @source`,
+		`DELETE FROM categories WHERE id = @source`,
+	} {
+		if _, err = tx.ExecContext(ctx, statement, sql.Named("source", sourceID), sql.Named("target", targetID)); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err = renameInSnapshots(ctx, tx, sourceName, targetName); err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -158,16 +332,87 @@ func (cr *CategoryDBRepository) DeleteCategoryByID(categoryID int64) (err error)
 	return nil
 }
 
-func (cr *CategoryDBRepository) CreateNewCategory(category string) (err error) {
+// renameInSnapshots replaces a category name in the category lists of the
+// moderation_actions snapshots.
+func renameInSnapshots(ctx context.Context, tx *sql.Tx, from string, to string) (err error) {
 	var (
-		ctx context.Context
-		tx  *sql.Tx
+		rows      *sql.Rows
+		quoted    []byte
+		ids       []int64
+		snapshots []string
 	)
-	ctx = context.Background()
+	if quoted, err = json.Marshal(from); err != nil {
+		return err
+	}
+	if rows, err = tx.QueryContext(ctx, `SELECT id, post_categories
+		FROM moderation_actions
+		WHERE instr(post_categories, ?) > 0`, string(quoted)); err != nil {
+		return err
+	}
+	for rows.Next() {
+		var (
+			id       int64
+			snapshot string
+		)
+		if err = rows.Scan(&id, &snapshot); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+		snapshots = append(snapshots, snapshot)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	for i, id := range ids {
+		var (
+			categories []string
+			renamed    []string
+			seen       = map[string]bool{}
+			snapshot   []byte
+		)
+		if err = json.Unmarshal([]byte(snapshots[i]), &categories); err != nil {
+			return err
+		}
+		for _, category := range categories {
+			if category == from {
+				category = to
+			}
+			if !seen[category] {
+				seen[category] = true
+				renamed = append(renamed, category)
+			}
+		}
+		if snapshot, err = json.Marshal(renamed); err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx, `UPDATE moderation_actions
+			SET post_categories = ?
+			WHERE id = ?`, string(snapshot), id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AssignModerator also scopes the moderator, who from then on moderates
+// only the categories assigned.
+func (cr *CategoryDBRepository) AssignModerator(ctx context.Context, categoryID int64, moderatorID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var tx *sql.Tx
 	if tx, err = cr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`INSERT INTO categories(name) VALUES(?)`, category); err != nil {
+	if _, err = tx.ExecContext(ctx, `INSERT OR IGNORE INTO category_moderators(category_id, user_id, created_at)
+		VALUES(?,?,?)`, categoryID, moderatorID, time.Now().Unix()); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE users
+		SET moderator_scoped = 1
+		WHERE id = ?`, moderatorID); err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -176,3 +421,79 @@ func (cr *CategoryDBRepository) CreateNewCategory(category string) (err error) {
 	}
 	return nil
 }
+
+func (cr *CategoryDBRepository) SetModeratorScoped(ctx context.Context, moderatorID int64, scoped bool) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if _, err = cr.dbConn.ExecContext(ctx, `UPDATE users
+		SET moderator_scoped = ?
+		WHERE id = ?`, scoped, moderatorID); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cr *CategoryDBRepository) UnassignModerator(ctx context.Context, categoryID int64, moderatorID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if _, err = cr.dbConn.ExecContext(ctx, `DELETE FROM category_moderators
+		WHERE category_id = ?
+		AND user_id = ?`, categoryID, moderatorID); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cr *CategoryDBRepository) GetModerators(ctx context.Context, categoryID int64) (moderators []models.User, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var rows *sql.Rows
+	if rows, err = cr.dbConn.QueryContext(ctx, `SELECT u.id, u.username, u.email, u.created_at,
+		u.last_active, u.role
+		FROM category_moderators AS cm
+		INNER JOIN users AS u
+		ON u.id = cm.user_id
+		WHERE cm.category_id = ?
+		ORDER BY u.username`, categoryID); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var u models.User
+		if err = rows.Scan(&u.ID, &u.Username, &u.Email, &u.CreatedAt,
+			&u.LastActive, &u.Role); err != nil {
+			return nil, err
+		}
+		moderators = append(moderators, u)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return moderators, nil
+}
+
+// CanModeratePost reports whether a moderator's scope covers a post: a
+// scoped moderator moderates the posts in one of the categories assigned,
+// and none without assignments; a global one moderates every post.
+func (cr *CategoryDBRepository) CanModeratePost(ctx context.Context, moderatorID int64, postID int64) (can bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if err = cr.dbConn.QueryRowContext(ctx, `SELECT
+		NOT u.moderator_scoped
+		OR EXISTS (
+			SELECT 1
+			FROM category_moderators AS cm
+			INNER JOIN posts_categories_bridge AS pcb
+			ON pcb.category_id = cm.category_id
+			WHERE cm.user_id = u.id
+			AND pcb.post_id = @post
+		)
+		FROM users AS u
+		WHERE u.id = @moderator`, sql.Named("moderator", moderatorID), sql.Named("post", postID)).Scan(&can); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return can, nil
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"github.com/innovember/forum/api/config"
 	"github.com/innovember/forum/api/models"
 	"github.com/innovember/forum/api/post"
+	"github.com/innovember/forum/api/services/markdown"
 	"net/http"
+	"time"
 )
 
 type CommentDBRepository struct {
@@ -17,15 +20,40 @@ func NewCommentDBRepository(conn *sql.DB) post.CommentRepository {
 	return &CommentDBRepository{dbConn: conn}
 }
 
-func (cr *CommentDBRepository) Create(userID int64, comment *models.Comment) (*models.Comment, int, error) {
+func (cr *CommentDBRepository) Create(ctx context.Context, userID int64, comment *models.Comment) (newComment *models.Comment, status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx *sql.Tx
+	)
+	if tx, err = cr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if newComment, status, err = cr.CreateTx(ctx, tx, userID, comment); err != nil {
+		tx.Rollback()
+		return nil, status, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return newComment, status, nil
+}
+
+func (cr *CommentDBRepository) CreateTx(ctx context.Context, tx *sql.Tx, userID int64, comment *models.Comment) (*models.Comment, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		result       sql.Result
 		rowsAffected int64
 		err          error
 	)
-	if result, err = cr.dbConn.Exec(`
-	INSERT INTO comments(author_id,post_id,content, created_at,edited_at)
-	VALUES(?,?,?,?,?)`, comment.AuthorID, comment.PostID, comment.Content, comment.CreatedAt, comment.EditedAt); err != nil {
+	comment.ContentHTML = markdown.Render(comment.Content)
+	if result, err = tx.ExecContext(ctx, `
+	INSERT INTO comments(author_id,post_id,content, content_html, created_at,edited_at)
+	SELECT ?,?,?,?,?,?
+	WHERE EXISTS (SELECT id FROM posts WHERE id = ? AND deleted_at = 0)`,
+		comment.AuthorID, comment.PostID, comment.Content, comment.ContentHTML,
+		comment.CreatedAt, comment.EditedAt, comment.PostID); err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
 	if comment.ID, err = result.LastInsertId(); err != nil {
@@ -40,14 +68,18 @@ func (cr *CommentDBRepository) Create(userID int64, comment *models.Comment) (*m
 	return nil, http.StatusBadRequest, errors.New("comment hasn't been created")
 }
 
-func (cr *CommentDBRepository) GetCommentsByPostID(userID, postID int64) (comments []models.Comment, status int, err error) {
+func (cr *CommentDBRepository) GetCommentsByPostID(ctx context.Context, userID, postID int64) (comments []models.Comment, status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		rows            *sql.Rows
 		commentRateRepo = NewRateCommentDBRepository(cr.dbConn)
 	)
-	if rows, err = cr.dbConn.Query(`
-	SELECT * FROM comments 
+	if rows, err = cr.dbConn.QueryContext(ctx, `
+	SELECT id, author_id, post_id, content, content_html, created_at, edited_at
+	FROM comments
 	WHERE post_id = ?
+	AND deleted_at = 0
 	ORDER BY created_at DESC`, postID,
 	); err != nil {
 		return nil, http.StatusInternalServerError, err
@@ -55,11 +87,11 @@ func (cr *CommentDBRepository) GetCommentsByPostID(userID, postID int64) (commen
 	defer rows.Close()
 	for rows.Next() {
 		var c models.Comment
-		rows.Scan(&c.ID, &c.AuthorID, &c.PostID, &c.Content, &c.CreatedAt, &c.EditedAt)
-		if status, err = cr.GetAuthor(&c); err != nil {
+		rows.Scan(&c.ID, &c.AuthorID, &c.PostID, &c.Content, &c.ContentHTML, &c.CreatedAt, &c.EditedAt)
+		if status, err = cr.GetAuthor(ctx, &c); err != nil {
 			return nil, status, err
 		}
-		if c.CommentRating, c.UserRating, err = commentRateRepo.GetCommentRating(c.ID, userID); err != nil {
+		if c.CommentRating, c.UserRating, err = commentRateRepo.GetCommentRating(ctx, c.ID, userID); err != nil {
 			return nil, status, err
 		}
 		comments = append(comments, c)
@@ -71,11 +103,13 @@ func (cr *CommentDBRepository) GetCommentsByPostID(userID, postID int64) (commen
 	return comments, http.StatusOK, nil
 }
 
-func (cr *CommentDBRepository) GetAuthor(comment *models.Comment) (status int, err error) {
+func (cr *CommentDBRepository) GetAuthor(ctx context.Context, comment *models.Comment) (status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		user models.User
 	)
-	if err = cr.dbConn.QueryRow(`
+	if err = cr.dbConn.QueryRowContext(ctx, `
 	SELECT id,username,email,created_at,last_active FROM users WHERE id = ?`, comment.AuthorID).Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt, &user.LastActive); err != nil {
 		if err == sql.ErrNoRows {
 			return http.StatusNotFound, errors.New("cant find author of post")
@@ -86,15 +120,18 @@ func (cr *CommentDBRepository) GetAuthor(comment *models.Comment) (status int, e
 	return http.StatusOK, nil
 }
 
-func (cr *CommentDBRepository) GetCommentsByAuthorID(userID, authorID int64) (comments []models.Comment, status int, err error) {
+func (cr *CommentDBRepository) GetCommentsByAuthorID(ctx context.Context, userID, authorID int64) (comments []models.Comment, status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		rows            *sql.Rows
 		commentRateRepo = NewRateCommentDBRepository(cr.dbConn)
 	)
-	if rows, err = cr.dbConn.Query(`
-		SELECT *
+	if rows, err = cr.dbConn.QueryContext(ctx, `
+		SELECT id, author_id, post_id, content, content_html, created_at, edited_at
 		FROM comments
 		WHERE author_id = $1
+		AND deleted_at = 0
 		ORDER BY created_at DESC
 		`, authorID); err != nil {
 		return nil, http.StatusInternalServerError, err
@@ -102,11 +139,11 @@ func (cr *CommentDBRepository) GetCommentsByAuthorID(userID, authorID int64) (co
 	defer rows.Close()
 	for rows.Next() {
 		var c models.Comment
-		rows.Scan(&c.ID, &c.AuthorID, &c.PostID, &c.Content, &c.CreatedAt, &c.EditedAt)
-		if status, err = cr.GetAuthor(&c); err != nil {
+		rows.Scan(&c.ID, &c.AuthorID, &c.PostID, &c.Content, &c.ContentHTML, &c.CreatedAt, &c.EditedAt)
+		if status, err = cr.GetAuthor(ctx, &c); err != nil {
 			return nil, status, err
 		}
-		if c.CommentRating, c.UserRating, err = commentRateRepo.GetCommentRating(c.ID, userID); err != nil {
+		if c.CommentRating, c.UserRating, err = commentRateRepo.GetCommentRating(ctx, c.ID, userID); err != nil {
 			return nil, status, err
 		}
 		comments = append(comments, c)
@@ -118,11 +155,14 @@ func (cr *CommentDBRepository) GetCommentsByAuthorID(userID, authorID int64) (co
 	return comments, http.StatusOK, nil
 }
 
-func (cr *CommentDBRepository) GetCommentsNumberByPostID(postID int64) (commentsNumber int, err error) {
-	if err = cr.dbConn.QueryRow(`
+func (cr *CommentDBRepository) GetCommentsNumberByPostID(ctx context.Context, postID int64) (commentsNumber int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if err = cr.dbConn.QueryRowContext(ctx, `
 	SELECT COUNT(id)
 	FROM comments
-	WHERE post_id = ?`, postID).Scan(&commentsNumber); err != nil {
+	WHERE post_id = ?
+	AND deleted_at = 0`, postID).Scan(&commentsNumber); err != nil {
 		if err == sql.ErrNoRows {
 			return 0, nil
 		}
@@ -131,97 +171,129 @@ func (cr *CommentDBRepository) GetCommentsNumberByPostID(postID int64) (comments
 	return commentsNumber, nil
 }
 
-func (cr *CommentDBRepository) Update(comment *models.Comment) (editedComment *models.Comment, status int, err error) {
+func (cr *CommentDBRepository) Update(ctx context.Context, comment *models.Comment) (editedComment *models.Comment, status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx          context.Context
-		tx           *sql.Tx
-		result       sql.Result
-		rowsAffected int64
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = cr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
-	if result, err = tx.Exec(`UPDATE comments
+	if editedComment, status, err = cr.UpdateTx(ctx, tx, comment); err != nil {
+		tx.Rollback()
+		return nil, status, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return editedComment, status, nil
+}
+
+func (cr *CommentDBRepository) UpdateTx(ctx context.Context, tx *sql.Tx, comment *models.Comment) (editedComment *models.Comment, status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		result       sql.Result
+		rowsAffected int64
+	)
+	// the cached HTML is replaced with the content it renders
+	comment.ContentHTML = markdown.Render(comment.Content)
+	if result, err = tx.ExecContext(ctx, `UPDATE comments
 							SET content = ?,
+							content_html = ?,
 							edited_at = ?
 							WHERE post_id = ?
-							and id = ?`,
-		comment.Content, comment.EditedAt, comment.PostID, comment.ID); err != nil {
-		tx.Rollback()
-		if err == sql.ErrNoRows {
-			return nil, http.StatusInternalServerError, errors.New("comment not found")
-		}
+							AND id = ?
+							AND deleted_at = 0`,
+		comment.Content, comment.ContentHTML, comment.EditedAt, comment.PostID, comment.ID); err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
 	if rowsAffected, err = result.RowsAffected(); err != nil {
-		tx.Rollback()
 		return nil, http.StatusInternalServerError, err
 	}
 	if rowsAffected > 0 {
-		if err := tx.Commit(); err != nil {
-			return nil, http.StatusInternalServerError, err
-		}
 		return comment, http.StatusOK, nil
 	}
 	return nil, http.StatusNotModified, errors.New("could not update the comment")
 }
 
-func (cr *CommentDBRepository) GetCommentByID(userID, commentID int64) (comment *models.Comment, status int, err error) {
+func (cr *CommentDBRepository) GetCommentByID(ctx context.Context, userID, commentID int64) (comment *models.Comment, status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		c               models.Comment
 		commentRateRepo = NewRateCommentDBRepository(cr.dbConn)
 	)
-	if err = cr.dbConn.QueryRow(`
-	SELECT * FROM comments WHERE id = ?`, commentID,
-	).Scan(&c.ID, &c.AuthorID, &c.PostID, &c.Content, &c.CreatedAt, &c.EditedAt); err != nil {
+	if err = cr.dbConn.QueryRowContext(ctx, `
+	SELECT id, author_id, post_id, content, content_html, created_at, edited_at
+	FROM comments
+	WHERE id = ?
+	AND deleted_at = 0`, commentID,
+	).Scan(&c.ID, &c.AuthorID, &c.PostID, &c.Content, &c.ContentHTML, &c.CreatedAt, &c.EditedAt); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, http.StatusNotFound, errors.New("comment not found")
 		}
 		return nil, http.StatusInternalServerError, err
 	}
-	if status, err = cr.GetAuthor(&c); err != nil {
+	if status, err = cr.GetAuthor(ctx, &c); err != nil {
 		return nil, status, err
 	}
-	if c.CommentRating, c.UserRating, err = commentRateRepo.GetCommentRating(c.ID, userID); err != nil {
+	if c.CommentRating, c.UserRating, err = commentRateRepo.GetCommentRating(ctx, c.ID, userID); err != nil {
 		return nil, status, err
 	}
 	return &c, http.StatusOK, nil
 }
 
-func (cr *CommentDBRepository) Delete(commentID int64) (err error) {
+func (cr *CommentDBRepository) Delete(ctx context.Context, commentID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
-		tx  *sql.Tx
+		tx           *sql.Tx
+		result       sql.Result
+		rowsAffected int64
+		now          = time.Now().Unix()
 	)
-	ctx = context.Background()
 	if tx, err = cr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM comments
-								WHERE id = ?`,
-		commentID); err != nil {
+	if result, err = tx.ExecContext(ctx, `UPDATE comments
+							  SET deleted_at = ?
+							  WHERE id = ?
+							  AND deleted_at = 0`,
+		now, commentID); err != nil {
 		tx.Rollback()
 		return err
 	}
+	if rowsAffected, err = result.RowsAffected(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if rowsAffected == 0 {
+		tx.Rollback()
+		return errors.New("comment not found")
+	}
 	if err = tx.Commit(); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (cr *CommentDBRepository) DeleteCommentsByPostID(postID int64) (err error) {
+func (cr *CommentDBRepository) DeleteCommentsByPostID(ctx context.Context, postID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
 		tx  *sql.Tx
+		now = time.Now().Unix()
 	)
-	ctx = context.Background()
 	if tx, err = cr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM comments
-								WHERE post_id = ?`,
-		postID); err != nil {
+	if _, err = tx.ExecContext(ctx, `UPDATE comments
+						 SET deleted_at = ?
+						 WHERE post_id = ?
+						 AND deleted_at = 0`,
+		now, postID); err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -230,3 +302,122 @@ func (cr *CommentDBRepository) DeleteCommentsByPostID(postID int64) (err error)
 	}
 	return nil
 }
+
+func (cr *CommentDBRepository) Restore(ctx context.Context, commentID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx           *sql.Tx
+		result       sql.Result
+		rowsAffected int64
+	)
+	if tx, err = cr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return err
+	}
+	if result, err = tx.ExecContext(ctx, `UPDATE comments
+							  SET deleted_at = 0
+							  WHERE id = ?
+							  AND deleted_at > 0
+							  AND post_id IN (
+								  SELECT id
+								  FROM posts
+								  WHERE deleted_at = 0
+							  )`, commentID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if rowsAffected, err = result.RowsAffected(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if rowsAffected == 0 {
+		tx.Rollback()
+		return errors.New("deleted comment not found or its post is deleted")
+	}
+	return tx.Commit()
+}
+
+func (cr *CommentDBRepository) GetDeletedComments(ctx context.Context) (comments []models.Comment, status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var rows *sql.Rows
+	if rows, err = cr.dbConn.QueryContext(ctx, `
+		SELECT id, author_id, post_id, content, content_html, created_at, edited_at, deleted_at
+		FROM comments
+		WHERE deleted_at > 0
+		ORDER BY deleted_at DESC
+		`); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c models.Comment
+		if err = rows.Scan(&c.ID, &c.AuthorID, &c.PostID, &c.Content, &c.ContentHTML,
+			&c.CreatedAt, &c.EditedAt, &c.DeletedAt); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		comments = append(comments, c)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	for i := range comments {
+		if status, err = cr.GetAuthor(ctx, &comments[i]); err != nil {
+			return nil, status, err
+		}
+	}
+	return comments, http.StatusOK, nil
+}
+
+// Purge hard deletes comments soft deleted before the given unix time,
+// along with their ratings, notifications and mentions.
+func (cr *CommentDBRepository) Purge(ctx context.Context, before int64) (purged int64, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx     *sql.Tx
+		result sql.Result
+	)
+	if tx, err = cr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return 0, err
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM notifications
+						 WHERE comment_id IN (
+							 SELECT id FROM comments
+							 WHERE deleted_at > 0
+							 AND deleted_at < ?
+						 )`, before); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM comment_rating
+						 WHERE comment_id IN (
+							 SELECT id FROM comments
+							 WHERE deleted_at > 0
+							 AND deleted_at < ?
+						 )`, before); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM mentions
+						 WHERE comment_id IN (
+							 SELECT id FROM comments
+							 WHERE deleted_at > 0
+							 AND deleted_at < ?
+						 )`, before); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if result, err = tx.ExecContext(ctx, `DELETE FROM comments
+							  WHERE deleted_at > 0
+							  AND deleted_at < ?`, before); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if purged, err = result.RowsAffected(); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return purged, tx.Commit()
+}
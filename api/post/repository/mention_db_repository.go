@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/models"
+	"github.com/innovember/forum/api/post"
+)
+
+type MentionDBRepository struct {
+	dbConn *sql.DB
+}
+
+func NewMentionDBRepository(conn *sql.DB) post.MentionRepository {
+	return &MentionDBRepository{dbConn: conn}
+}
+
+// SetMentions makes the users named the mentions of a post, or of one of
+// its comments when commentID isn't 0. Unknown usernames and the author are
+// skipped. Only users mentioned there for the first time are notified, and
+// only if they haven't opted out; the author of the post isn't notified of
+// a comment's mention, as the comment already notifies them.
+func (mr *MentionDBRepository) SetMentions(ctx context.Context, authorID int64, postID int64, commentID int64, usernames []string) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx *sql.Tx
+	)
+	if tx, err = mr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return err
+	}
+	if err = mr.SetMentionsTx(ctx, tx, authorID, postID, commentID, usernames); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (mr *MentionDBRepository) SetMentionsTx(ctx context.Context, tx *sql.Tx, authorID int64, postID int64, commentID int64, usernames []string) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		result       sql.Result
+		rowsAffected int64
+		postAuthorID int64
+		userIDs      []interface{}
+		notify       = map[int64]bool{}
+		now          = time.Now().Unix()
+	)
+	if err = tx.QueryRowContext(ctx, `SELECT author_id FROM posts WHERE id = ?`, postID).Scan(&postAuthorID); err != nil {
+		return err
+	}
+	for _, username := range usernames {
+		var (
+			userID  int64
+			enabled bool
+		)
+		if err = tx.QueryRowContext(ctx, `SELECT id, mention_notifications
+							FROM users
+							WHERE username = ? COLLATE NOCASE`, username).Scan(&userID, &enabled); err != nil {
+			if err == sql.ErrNoRows {
+				continue
+			}
+			return err
+		}
+		if userID == authorID {
+			continue
+		}
+		userIDs = append(userIDs, userID)
+		notify[userID] = enabled && (commentID == 0 || userID != postAuthorID)
+	}
+	// mentions edited out are kept as removed, see the mentions table
+	query := `UPDATE mentions
+			SET removed_at = ?
+			WHERE post_id = ?
+			AND comment_id = ?
+			AND removed_at = 0`
+	args := []interface{}{now, postID, commentID}
+	if len(userIDs) > 0 {
+		query += ` AND user_id NOT IN (?` + strings.Repeat(",?", len(userIDs)-1) + `)`
+		args = append(args, userIDs...)
+	}
+	if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+	for _, id := range userIDs {
+		userID := id.(int64)
+		if result, err = tx.ExecContext(ctx, `INSERT OR IGNORE INTO mentions(user_id, author_id,
+			post_id, comment_id, created_at)
+			VALUES(?,?,?,?,?)`, userID, authorID, postID, commentID, now); err != nil {
+			return err
+		}
+		if rowsAffected, err = result.RowsAffected(); err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			// mentioned before, and notified then
+			if _, err = tx.ExecContext(ctx, `UPDATE mentions
+							SET removed_at = 0
+							WHERE user_id = ?
+							AND post_id = ?
+							AND comment_id = ?`, userID, postID, commentID); err != nil {
+				return err
+			}
+			continue
+		}
+		if !notify[userID] {
+			continue
+		}
+		if _, _, err = createNotification(ctx, tx, &models.Notification{
+			ReceiverID: userID,
+			PostID:     postID,
+			CommentID:  commentID,
+			Mention:    true,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/db"
 	"github.com/innovember/forum/api/models"
 	"github.com/innovember/forum/api/post"
 	"net/http"
@@ -11,26 +13,41 @@ import (
 )
 
 type NotificationDBRepository struct {
-	dbConn *sql.DB
+	dbConn    *sql.DB
+	publicURL string
 }
 
-func NewNotificationDBRepository(conn *sql.DB) post.NotificationRepository {
-	return &NotificationDBRepository{dbConn: conn}
+// NewNotificationDBRepository links the attachments of the notified posts
+// under publicURL, the server's URL.
+func NewNotificationDBRepository(conn *sql.DB, publicURL string) post.NotificationRepository {
+	return &NotificationDBRepository{dbConn: conn, publicURL: publicURL}
 }
 
-func (nr *NotificationDBRepository) Create(notification *models.Notification) (*models.Notification, int, error) {
+func (nr *NotificationDBRepository) Create(ctx context.Context, notification *models.Notification) (*models.Notification, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	return createNotification(ctx, nr.dbConn, notification)
+}
+
+func (nr *NotificationDBRepository) CreateTx(ctx context.Context, tx *sql.Tx, notification *models.Notification) (*models.Notification, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	return createNotification(ctx, tx, notification)
+}
+
+func createNotification(ctx context.Context, exec db.Executor, notification *models.Notification) (*models.Notification, int, error) {
 	var (
 		result       sql.Result
 		rowsAffected int64
 		now          = time.Now().Unix()
 		err          error
 	)
-	if result, err = nr.dbConn.Exec(`
+	if result, err = exec.ExecContext(ctx, `
 	INSERT INTO notifications(receiver_id, post_id,
-		rate_id,comment_id,comment_rate_id,created_at)
-	VALUES(?,?,?,?,?,?)`, notification.ReceiverID, notification.PostID,
+		rate_id,comment_id,comment_rate_id,mention,created_at)
+	VALUES(?,?,?,?,?,?,?)`, notification.ReceiverID, notification.PostID,
 		notification.RateID, notification.CommentID,
-		notification.CommentRateID, now); err != nil {
+		notification.CommentRateID, notification.Mention, now); err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
 	if notification.ID, err = result.LastInsertId(); err != nil {
@@ -45,16 +62,16 @@ func (nr *NotificationDBRepository) Create(notification *models.Notification) (*
 	return nil, http.StatusBadRequest, errors.New("notification hasn't been created")
 }
 
-func (nr *NotificationDBRepository) DeleteAllNotifications(receiverID int64) (err error) {
+func (nr *NotificationDBRepository) DeleteAllNotifications(ctx context.Context, receiverID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
-		tx  *sql.Tx
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = nr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM notifications
+	if _, err = tx.ExecContext(ctx, `DELETE FROM notifications
 								WHERE receiver_id = ?`,
 		receiverID); err != nil {
 		tx.Rollback()
@@ -66,19 +83,28 @@ func (nr *NotificationDBRepository) DeleteAllNotifications(receiverID int64) (er
 	return nil
 }
 
-func (nr *NotificationDBRepository) GetAllNotifications(receiverID int64) (notifications []models.Notification, status int, err error) {
+func (nr *NotificationDBRepository) GetAllNotifications(ctx context.Context, receiverID int64) (notifications []models.Notification, status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		rows            *sql.Rows
-		postRepo        = NewPostDBRepository(nr.dbConn)
+		postRepo        = NewPostDBRepository(nr.dbConn, nr.publicURL)
 		commentRepo     = NewCommentDBRepository(nr.dbConn)
 		rateRepo        = NewRateDBRepository(nr.dbConn)
 		commentRateRepo = NewRateCommentDBRepository(nr.dbConn)
 	)
-	if rows, err = nr.dbConn.Query(`
-		SELECT *
-		FROM notifications
-		WHERE receiver_id = ?
-		ORDER BY created_at DESC
+	if rows, err = nr.dbConn.QueryContext(ctx, `
+		SELECT n.id, n.receiver_id, n.post_id, n.rate_id,
+		n.comment_id, n.comment_rate_id, n.mention, n.created_at
+		FROM notifications AS n
+		INNER JOIN posts AS p
+		ON p.id = n.post_id
+		AND p.deleted_at = 0
+		LEFT JOIN comments AS c
+		ON c.id = n.comment_id
+		WHERE n.receiver_id = ?
+		AND (n.comment_id = 0 OR c.deleted_at = 0)
+		ORDER BY n.created_at DESC
 		`, receiverID); err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
@@ -86,22 +112,22 @@ func (nr *NotificationDBRepository) GetAllNotifications(receiverID int64) (notif
 	for rows.Next() {
 		var n models.Notification
 		rows.Scan(&n.ID, &n.ReceiverID, &n.PostID, &n.RateID,
-			&n.CommentID, &n.CommentRateID, &n.CreatedAt)
-		if n.Post, status, err = postRepo.GetPostByID(receiverID, n.PostID); err != nil {
+			&n.CommentID, &n.CommentRateID, &n.Mention, &n.CreatedAt)
+		if n.Post, status, err = postRepo.GetPostByID(ctx, receiverID, n.PostID); err != nil {
 			return nil, status, err
 		}
 		if n.RateID != 0 {
-			if n.PostRating, status, err = rateRepo.GetPostRatingByID(n.RateID); err != nil {
+			if n.PostRating, status, err = rateRepo.GetPostRatingByID(ctx, n.RateID); err != nil {
 				return nil, status, err
 			}
 		}
 		if n.CommentID != 0 {
-			if n.Comment, status, err = commentRepo.GetCommentByID(receiverID, n.CommentID); err != nil {
+			if n.Comment, status, err = commentRepo.GetCommentByID(ctx, receiverID, n.CommentID); err != nil {
 				return nil, status, err
 			}
 		}
 		if n.CommentRateID != 0 {
-			if n.CommentRating, status, err = commentRateRepo.GetCommentRatingByID(n.CommentRateID); err != nil {
+			if n.CommentRating, status, err = commentRateRepo.GetCommentRatingByID(ctx, n.CommentRateID); err != nil {
 				return nil, status, err
 			}
 		}
@@ -114,16 +140,16 @@ func (nr *NotificationDBRepository) GetAllNotifications(receiverID int64) (notif
 	return notifications, http.StatusOK, nil
 }
 
-func (nr *NotificationDBRepository) DeleteNotificationsByPostID(postID int64) (err error) {
+func (nr *NotificationDBRepository) DeleteNotificationsByPostID(ctx context.Context, postID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
-		tx  *sql.Tx
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = nr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM notifications
+	if _, err = tx.ExecContext(ctx, `DELETE FROM notifications
 								WHERE post_id = ?`,
 		postID); err != nil {
 		tx.Rollback()
@@ -135,18 +161,16 @@ func (nr *NotificationDBRepository) DeleteNotificationsByPostID(postID int64) (e
 	return nil
 }
 
-func (nr *NotificationDBRepository) DeleteNotificationsByRateID(rateID int64) (err error) {
+func (nr *NotificationDBRepository) DeleteNotificationsByRateID(ctx context.Context, rateID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
-		tx  *sql.Tx
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = nr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM notifications
-								WHERE rate_id = ?`,
-		rateID); err != nil {
+	if err = nr.DeleteNotificationsByRateIDTx(ctx, tx, rateID); err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -156,16 +180,27 @@ func (nr *NotificationDBRepository) DeleteNotificationsByRateID(rateID int64) (e
 	return nil
 }
 
-func (nr *NotificationDBRepository) DeleteNotificationsByCommentID(commentID int64) (err error) {
+func (nr *NotificationDBRepository) DeleteNotificationsByRateIDTx(ctx context.Context, tx *sql.Tx, rateID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if _, err = tx.ExecContext(ctx, `DELETE FROM notifications
+								WHERE rate_id = ?`,
+		rateID); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (nr *NotificationDBRepository) DeleteNotificationsByCommentID(ctx context.Context, commentID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
-		tx  *sql.Tx
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = nr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM notifications
+	if _, err = tx.ExecContext(ctx, `DELETE FROM notifications
 								WHERE comment_id = ?`,
 		commentID); err != nil {
 		tx.Rollback()
@@ -177,18 +212,16 @@ func (nr *NotificationDBRepository) DeleteNotificationsByCommentID(commentID int
 	return nil
 }
 
-func (nr *NotificationDBRepository) DeleteNotificationsByCommentRateID(commentRateID int64) (err error) {
+func (nr *NotificationDBRepository) DeleteNotificationsByCommentRateID(ctx context.Context, commentRateID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
-		tx  *sql.Tx
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = nr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM notifications
-								WHERE comment_rate_id = ?`,
-		commentRateID); err != nil {
+	if err = nr.DeleteNotificationsByCommentRateIDTx(ctx, tx, commentRateID); err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -197,3 +230,14 @@ func (nr *NotificationDBRepository) DeleteNotificationsByCommentRateID(commentRa
 	}
 	return nil
 }
+
+func (nr *NotificationDBRepository) DeleteNotificationsByCommentRateIDTx(ctx context.Context, tx *sql.Tx, commentRateID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if _, err = tx.ExecContext(ctx, `DELETE FROM notifications
+								WHERE comment_rate_id = ?`,
+		commentRateID); err != nil {
+		return err
+	}
+	return nil
+}
@@ -9,37 +9,64 @@ import (
 	"strings"
 	"time"
 
+	"github.com/innovember/forum/api/config"
 	"github.com/innovember/forum/api/models"
 	"github.com/innovember/forum/api/post"
+	"github.com/innovember/forum/api/services/imaging"
+	"github.com/innovember/forum/api/services/markdown"
 )
 
 type PostDBRepository struct {
-	dbConn *sql.DB
+	dbConn    *sql.DB
+	publicURL string
 }
 
-func NewPostDBRepository(conn *sql.DB) post.PostRepository {
-	return &PostDBRepository{dbConn: conn}
+// NewPostDBRepository returns posts whose attachments are linked under
+// publicURL, the server's URL; jobs that only delete posts pass "".
+func NewPostDBRepository(conn *sql.DB, publicURL string) post.PostRepository {
+	return &PostDBRepository{dbConn: conn, publicURL: publicURL}
 }
 
-func (pr *PostDBRepository) Create(post *models.Post, categories []string) (*models.Post, int, error) {
+func (pr *PostDBRepository) Create(ctx context.Context, post *models.Post, categoryIDs []int64) (newPost *models.Post, status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx *sql.Tx
+	)
+	if tx, err = pr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if newPost, status, err = pr.CreateTx(ctx, tx, post, categoryIDs); err != nil {
+		tx.Rollback()
+		return nil, status, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return newPost, status, nil
+}
+
+func (pr *PostDBRepository) CreateTx(ctx context.Context, tx *sql.Tx, post *models.Post, categoryIDs []int64) (*models.Post, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		result       sql.Result
 		rowsAffected int64
 		now          = time.Now().Unix()
-		categoryRepo = NewCategoryDBRepository(pr.dbConn)
 		err          error
 	)
-	if result, err = pr.dbConn.Exec(`
-	INSERT INTO posts(author_id,title, content, created_at,edited_at, is_image,image_path,is_approved)
-	VALUES(?,?,?,?,?,?,?,?)`, post.AuthorID, post.Title,
-		post.Content, now, post.EditedAt,
+	post.ContentHTML = markdown.Render(post.Content)
+	if result, err = tx.ExecContext(ctx, `
+	INSERT INTO posts(author_id,title, content, content_html, created_at,edited_at, is_image,image_path,is_approved)
+	VALUES(?,?,?,?,?,?,?,?,?)`, post.AuthorID, post.Title,
+		post.Content, post.ContentHTML, now, post.EditedAt,
 		post.IsImage, post.ImagePath, post.IsApproved); err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
 	if post.ID, err = result.LastInsertId(); err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
-	if err = categoryRepo.Create(post.ID, categories); err != nil {
+	if err = createPostCategories(ctx, tx, post.ID, categoryIDs); err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
 
@@ -47,18 +74,23 @@ func (pr *PostDBRepository) Create(post *models.Post, categories []string) (*mod
 		return nil, http.StatusInternalServerError, err
 	}
 	if rowsAffected > 0 {
+		post.Variants = imaging.Variants(post.IsImage, post.ImagePath)
 		return post, http.StatusCreated, nil
 	}
 	return nil, http.StatusBadRequest, errors.New("post hasn't been created")
 }
 
-func (pr *PostDBRepository) GetAllPosts(userID int64) (posts []models.Post, status int, err error) {
+func (pr *PostDBRepository) GetAllPosts(ctx context.Context, userID int64) (posts []models.Post, status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		rows        *sql.Rows
 		commentRepo = NewCommentDBRepository(pr.dbConn)
 	)
-	if rows, err = pr.dbConn.Query(`
-		SELECT *,
+	if rows, err = pr.dbConn.QueryContext(ctx, `
+		SELECT id, author_id, title, content, content_html,
+		created_at, edited_at, is_image,
+		image_path, is_approved, is_banned,
 		(SELECT TOTAL(rate)
 			FROM post_rating
 			WHERE post_id = posts.id) AS rating,
@@ -71,6 +103,7 @@ func (pr *PostDBRepository) GetAllPosts(userID int64) (posts []models.Post, stat
 					),0) AS userRating
 		FROM posts
 		WHERE is_approved = 1
+		AND deleted_at = 0
 		ORDER BY created_at DESC
 		`, userID); err != nil {
 		return nil, http.StatusInternalServerError, err
@@ -78,17 +111,24 @@ func (pr *PostDBRepository) GetAllPosts(userID int64) (posts []models.Post, stat
 	defer rows.Close()
 	for rows.Next() {
 		var p models.Post
-		rows.Scan(&p.ID, &p.AuthorID, &p.Title, &p.Content,
+		rows.Scan(&p.ID, &p.AuthorID, &p.Title, &p.Content, &p.ContentHTML,
 			&p.CreatedAt, &p.EditedAt, &p.IsImage,
 			&p.ImagePath, &p.IsApproved, &p.IsBanned,
 			&p.PostRating, &p.UserRating)
-		if status, err = pr.GetAuthor(&p); err != nil {
+		p.Variants = imaging.Variants(p.IsImage, p.ImagePath)
+		if status, err = pr.GetAuthor(ctx, &p); err != nil {
+			return nil, status, err
+		}
+		if status, err = pr.GetCategories(ctx, &p); err != nil {
 			return nil, status, err
 		}
-		if status, err = pr.GetCategories(&p); err != nil {
+		if status, err = pr.GetTags(ctx, &p); err != nil {
 			return nil, status, err
 		}
-		if p.CommentsNumber, err = commentRepo.GetCommentsNumberByPostID(p.ID); err != nil {
+		if status, err = pr.GetAttachments(ctx, &p); err != nil {
+			return nil, status, err
+		}
+		if p.CommentsNumber, err = commentRepo.GetCommentsNumberByPostID(ctx, p.ID); err != nil {
 			return nil, status, err
 		}
 		posts = append(posts, p)
@@ -100,11 +140,13 @@ func (pr *PostDBRepository) GetAllPosts(userID int64) (posts []models.Post, stat
 	return posts, http.StatusOK, nil
 }
 
-func (pr *PostDBRepository) GetAuthor(post *models.Post) (status int, err error) {
+func (pr *PostDBRepository) GetAuthor(ctx context.Context, post *models.Post) (status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		user models.User
 	)
-	if err = pr.dbConn.QueryRow(`
+	if err = pr.dbConn.QueryRowContext(ctx, `
 	SELECT id,username,email,created_at,last_active FROM users WHERE id = ?`, post.AuthorID).Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt, &user.LastActive); err != nil {
 		if err == sql.ErrNoRows {
 			return http.StatusNotFound, errors.New("cant find author of post")
@@ -115,24 +157,27 @@ func (pr *PostDBRepository) GetAuthor(post *models.Post) (status int, err error)
 	return http.StatusOK, nil
 }
 
-func (pr *PostDBRepository) GetCategories(post *models.Post) (status int, err error) {
+func (pr *PostDBRepository) GetCategories(ctx context.Context, post *models.Post) (status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		rows       *sql.Rows
 		categories []models.Category
 	)
-	if rows, err = pr.dbConn.Query(`
-		SELECT c.id,c.name
+	if rows, err = pr.dbConn.QueryContext(ctx, `
+		SELECT `+categoryColumns+`
 		FROM categories c
 		LEFT JOIN posts_categories_bridge pcb
 		ON pcb.post_id = ?
-		WHERE c.id = pcb.category_id`,
+		WHERE c.id = pcb.category_id
+		ORDER BY c.parent_id, c.position, c.name`,
 		post.ID); err != nil {
 		return http.StatusInternalServerError, err
 	}
 	defer rows.Close()
 	for rows.Next() {
 		var c models.Category
-		rows.Scan(&c.ID, &c.Name)
+		scanCategory(rows, &c)
 		categories = append(categories, c)
 	}
 	err = rows.Err()
@@ -143,17 +188,64 @@ func (pr *PostDBRepository) GetCategories(post *models.Post) (status int, err er
 	return http.StatusOK, nil
 }
 
-func (pr *PostDBRepository) GetPostByID(userID int64, postID int64) (post *models.Post, status int, err error) {
+func (pr *PostDBRepository) GetTags(ctx context.Context, post *models.Post) (status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		rows *sql.Rows
+		tags []string
+	)
+	if rows, err = pr.dbConn.QueryContext(ctx, `
+		SELECT t.name
+		FROM posts_tags_bridge AS ptb
+		INNER JOIN tags AS t
+		ON t.id = ptb.tag_id
+		WHERE ptb.post_id = ?
+		ORDER BY t.name`,
+		post.ID); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return http.StatusInternalServerError, err
+		}
+		tags = append(tags, name)
+	}
+	err = rows.Err()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	post.Tags = tags
+	return http.StatusOK, nil
+}
+
+func (pr *PostDBRepository) GetAttachments(ctx context.Context, post *models.Post) (status int, err error) {
+	attachmentRepo := NewAttachmentDBRepository(pr.dbConn, pr.publicURL)
+	if post.Attachments, err = attachmentRepo.GetAttachmentsByPostID(ctx, post.ID); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func (pr *PostDBRepository) GetPostByID(ctx context.Context, userID int64, postID int64) (post *models.Post, status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		p           models.Post
 		rateRepo    = NewRateDBRepository(pr.dbConn)
 		commentRepo = NewCommentDBRepository(pr.dbConn)
 	)
-	if err = pr.dbConn.QueryRow(`
-	SELECT * FROM posts WHERE id = ?
-	AND is_approved = 1`, postID,
+	if err = pr.dbConn.QueryRowContext(ctx, `
+	SELECT id, author_id, title, content, content_html,
+	created_at, edited_at, is_image,
+	image_path, is_approved, is_banned
+	FROM posts WHERE id = ?
+	AND is_approved = 1
+	AND deleted_at = 0`, postID,
 	).Scan(&p.ID, &p.AuthorID, &p.Title,
-		&p.Content, &p.CreatedAt,
+		&p.Content, &p.ContentHTML, &p.CreatedAt,
 		&p.EditedAt, &p.IsImage, &p.ImagePath,
 		&p.IsApproved, &p.IsBanned); err != nil {
 		if err == sql.ErrNoRows {
@@ -161,59 +253,105 @@ func (pr *PostDBRepository) GetPostByID(userID int64, postID int64) (post *model
 		}
 		return nil, http.StatusInternalServerError, err
 	}
-	if status, err = pr.GetAuthor(&p); err != nil {
+	p.Variants = imaging.Variants(p.IsImage, p.ImagePath)
+	if status, err = pr.GetAuthor(ctx, &p); err != nil {
 		return nil, status, err
 	}
-	if p.PostRating, p.UserRating, err = rateRepo.GetPostRating(postID, userID); err != nil {
+	if p.PostRating, p.UserRating, err = rateRepo.GetPostRating(ctx, postID, userID); err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
-	if status, err = pr.GetCategories(&p); err != nil {
+	if status, err = pr.GetCategories(ctx, &p); err != nil {
 		return nil, status, err
 	}
-	if p.CommentsNumber, err = commentRepo.GetCommentsNumberByPostID(p.ID); err != nil {
+	if status, err = pr.GetTags(ctx, &p); err != nil {
+		return nil, status, err
+	}
+	if status, err = pr.GetAttachments(ctx, &p); err != nil {
+		return nil, status, err
+	}
+	if p.CommentsNumber, err = commentRepo.GetCommentsNumberByPostID(ctx, p.ID); err != nil {
 		return nil, status, err
 	}
 	return &p, http.StatusOK, nil
 }
 
-func (pr *PostDBRepository) GetPostsByCategories(categories []string, userID int64) (posts []models.Post, status int, err error) {
+// GetPostsByCategoriesAndTags returns the approved posts filed under the
+// categories named and tagged with the tags given, all of them or, with
+// matchAny, at least one.
+func (pr *PostDBRepository) GetPostsByCategoriesAndTags(ctx context.Context, categories []string, tagIDs []int64, matchAny bool, userID int64) (posts []models.Post, status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		rows           *sql.Rows
-		categoriesList = fmt.Sprintf("\"%s\"", strings.Join(categories, "\", \""))
-		rateRepo       = NewRateDBRepository(pr.dbConn)
-		commentRepo    = NewCommentDBRepository(pr.dbConn)
+		rows        *sql.Rows
+		conditions  []string
+		args        []interface{}
+		rateRepo    = NewRateDBRepository(pr.dbConn)
+		commentRepo = NewCommentDBRepository(pr.dbConn)
 	)
-	query := fmt.Sprintf(`
-		SELECT p.*
-		FROM posts_categories_bridge as pcb
-		INNER JOIN posts as p
-		ON p.id = pcb.post_id
-		INNER JOIN categories as c
-		ON c.id=pcb.category_id
-		WHERE c.name in (%s)
-		AND p.is_approved = 1
-		GROUP BY p.id
-		HAVING COUNT(DISTINCT c.id) = %d
-		ORDER BY p.created_at DESC`, categoriesList, len(categories))
-	if rows, err = pr.dbConn.Query(query); err != nil {
+	if len(categories) > 0 {
+		conditions = append(conditions, fmt.Sprintf(`(SELECT COUNT(DISTINCT c.id)
+			FROM posts_categories_bridge AS pcb
+			INNER JOIN categories AS c
+			ON c.id = pcb.category_id
+			WHERE pcb.post_id = p.id
+			AND c.name IN (?%s)) >= ?`, strings.Repeat(",?", len(categories)-1)))
+		for _, category := range categories {
+			args = append(args, category)
+		}
+		args = append(args, matchCount(len(categories), matchAny))
+	}
+	if len(tagIDs) > 0 {
+		conditions = append(conditions, fmt.Sprintf(`(SELECT COUNT(*)
+			FROM posts_tags_bridge AS ptb
+			WHERE ptb.post_id = p.id
+			AND ptb.tag_id IN (?%s)) >= ?`, strings.Repeat(",?", len(tagIDs)-1)))
+		for _, tagID := range tagIDs {
+			args = append(args, tagID)
+		}
+		args = append(args, matchCount(len(tagIDs), matchAny))
+	}
+	if len(conditions) == 0 {
+		return nil, http.StatusOK, nil
+	}
+	operator := " AND "
+	if matchAny {
+		operator = " OR "
+	}
+	query := `
+		SELECT p.id, p.author_id, p.title, p.content, p.content_html,
+		p.created_at, p.edited_at, p.is_image,
+		p.image_path, p.is_approved, p.is_banned
+		FROM posts AS p
+		WHERE p.is_approved = 1
+		AND p.deleted_at = 0
+		AND (` + strings.Join(conditions, operator) + `)
+		ORDER BY p.created_at DESC`
+	if rows, err = pr.dbConn.QueryContext(ctx, query, args...); err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
 	defer rows.Close()
 	for rows.Next() {
 		var p models.Post
-		rows.Scan(&p.ID, &p.AuthorID, &p.Title, &p.Content,
+		rows.Scan(&p.ID, &p.AuthorID, &p.Title, &p.Content, &p.ContentHTML,
 			&p.CreatedAt, &p.EditedAt, &p.IsImage,
 			&p.ImagePath, &p.IsApproved, &p.IsBanned)
-		if status, err = pr.GetAuthor(&p); err != nil {
+		p.Variants = imaging.Variants(p.IsImage, p.ImagePath)
+		if status, err = pr.GetAuthor(ctx, &p); err != nil {
+			return nil, status, err
+		}
+		if status, err = pr.GetCategories(ctx, &p); err != nil {
 			return nil, status, err
 		}
-		if status, err = pr.GetCategories(&p); err != nil {
+		if status, err = pr.GetTags(ctx, &p); err != nil {
 			return nil, status, err
 		}
-		if p.PostRating, p.UserRating, err = rateRepo.GetPostRating(p.ID, userID); err != nil {
+		if status, err = pr.GetAttachments(ctx, &p); err != nil {
+			return nil, status, err
+		}
+		if p.PostRating, p.UserRating, err = rateRepo.GetPostRating(ctx, p.ID, userID); err != nil {
 			return nil, http.StatusInternalServerError, err
 		}
-		if p.CommentsNumber, err = commentRepo.GetCommentsNumberByPostID(p.ID); err != nil {
+		if p.CommentsNumber, err = commentRepo.GetCommentsNumberByPostID(ctx, p.ID); err != nil {
 			return nil, status, err
 		}
 		posts = append(posts, p)
@@ -225,13 +363,25 @@ func (pr *PostDBRepository) GetPostsByCategories(categories []string, userID int
 	return posts, http.StatusOK, nil
 }
 
-func (pr *PostDBRepository) GetPostsByRating(orderBy string, userID int64) (posts []models.Post, status int, err error) {
+// matchCount is how many of n categories or tags a post needs.
+func matchCount(n int, matchAny bool) int {
+	if matchAny {
+		return 1
+	}
+	return n
+}
+
+func (pr *PostDBRepository) GetPostsByRating(ctx context.Context, orderBy string, userID int64) (posts []models.Post, status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		rows        *sql.Rows
 		commentRepo = NewCommentDBRepository(pr.dbConn)
 	)
-	if rows, err = pr.dbConn.Query(`
-		SELECT *,
+	if rows, err = pr.dbConn.QueryContext(ctx, `
+		SELECT id, author_id, title, content, content_html,
+		created_at, edited_at, is_image,
+		image_path, is_approved, is_banned,
 		(SELECT TOTAL(rate)
 			FROM post_rating
 			WHERE post_id = posts.id) AS rating,
@@ -244,6 +394,7 @@ func (pr *PostDBRepository) GetPostsByRating(orderBy string, userID int64) (post
 					),0) AS userRating
 		FROM posts
 		WHERE is_approved = 1
+		AND deleted_at = 0
 		ORDER BY rating $2
 		`, userID, orderBy); err != nil {
 		return nil, http.StatusInternalServerError, err
@@ -251,17 +402,24 @@ func (pr *PostDBRepository) GetPostsByRating(orderBy string, userID int64) (post
 	defer rows.Close()
 	for rows.Next() {
 		var p models.Post
-		rows.Scan(&p.ID, &p.AuthorID, &p.Title, &p.Content,
+		rows.Scan(&p.ID, &p.AuthorID, &p.Title, &p.Content, &p.ContentHTML,
 			&p.CreatedAt, &p.EditedAt, &p.IsImage,
 			&p.ImagePath, &p.IsApproved, &p.IsBanned,
 			&p.PostRating, &p.UserRating)
-		if status, err = pr.GetAuthor(&p); err != nil {
+		p.Variants = imaging.Variants(p.IsImage, p.ImagePath)
+		if status, err = pr.GetAuthor(ctx, &p); err != nil {
 			return nil, status, err
 		}
-		if status, err = pr.GetCategories(&p); err != nil {
+		if status, err = pr.GetCategories(ctx, &p); err != nil {
 			return nil, status, err
 		}
-		if p.CommentsNumber, err = commentRepo.GetCommentsNumberByPostID(p.ID); err != nil {
+		if status, err = pr.GetTags(ctx, &p); err != nil {
+			return nil, status, err
+		}
+		if status, err = pr.GetAttachments(ctx, &p); err != nil {
+			return nil, status, err
+		}
+		if p.CommentsNumber, err = commentRepo.GetCommentsNumberByPostID(ctx, p.ID); err != nil {
 			return nil, status, err
 		}
 		posts = append(posts, p)
@@ -273,13 +431,17 @@ func (pr *PostDBRepository) GetPostsByRating(orderBy string, userID int64) (post
 	return posts, http.StatusOK, nil
 }
 
-func (pr *PostDBRepository) GetPostsByDate(orderBy string, userID int64) (posts []models.Post, status int, err error) {
+func (pr *PostDBRepository) GetPostsByDate(ctx context.Context, orderBy string, userID int64) (posts []models.Post, status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		rows        *sql.Rows
 		commentRepo = NewCommentDBRepository(pr.dbConn)
 	)
-	if rows, err = pr.dbConn.Query(`
-		SELECT *,
+	if rows, err = pr.dbConn.QueryContext(ctx, `
+		SELECT id, author_id, title, content, content_html,
+		created_at, edited_at, is_image,
+		image_path, is_approved, is_banned,
 		(SELECT TOTAL(rate)
 			FROM post_rating
 			WHERE post_id = posts.id) AS rating,
@@ -292,6 +454,7 @@ func (pr *PostDBRepository) GetPostsByDate(orderBy string, userID int64) (posts
 					),0) AS userRating
 		FROM posts
 		WHERE is_approved = 1
+		AND deleted_at = 0
 		ORDER BY created_at $2
 		`, userID, orderBy); err != nil {
 		return nil, http.StatusInternalServerError, err
@@ -299,17 +462,24 @@ func (pr *PostDBRepository) GetPostsByDate(orderBy string, userID int64) (posts
 	defer rows.Close()
 	for rows.Next() {
 		var p models.Post
-		rows.Scan(&p.ID, &p.AuthorID, &p.Title, &p.Content,
+		rows.Scan(&p.ID, &p.AuthorID, &p.Title, &p.Content, &p.ContentHTML,
 			&p.CreatedAt, &p.EditedAt, &p.IsImage,
 			&p.ImagePath, &p.IsApproved, &p.IsBanned,
 			&p.PostRating, &p.UserRating)
-		if status, err = pr.GetAuthor(&p); err != nil {
+		p.Variants = imaging.Variants(p.IsImage, p.ImagePath)
+		if status, err = pr.GetAuthor(ctx, &p); err != nil {
+			return nil, status, err
+		}
+		if status, err = pr.GetCategories(ctx, &p); err != nil {
 			return nil, status, err
 		}
-		if status, err = pr.GetCategories(&p); err != nil {
+		if status, err = pr.GetTags(ctx, &p); err != nil {
 			return nil, status, err
 		}
-		if p.CommentsNumber, err = commentRepo.GetCommentsNumberByPostID(p.ID); err != nil {
+		if status, err = pr.GetAttachments(ctx, &p); err != nil {
+			return nil, status, err
+		}
+		if p.CommentsNumber, err = commentRepo.GetCommentsNumberByPostID(ctx, p.ID); err != nil {
 			return nil, status, err
 		}
 		posts = append(posts, p)
@@ -321,17 +491,22 @@ func (pr *PostDBRepository) GetPostsByDate(orderBy string, userID int64) (posts
 	return posts, http.StatusOK, nil
 }
 
-func (pr *PostDBRepository) GetAllPostsByAuthorID(authorID int64, userID int64) (posts []models.Post, status int, err error) {
+func (pr *PostDBRepository) GetAllPostsByAuthorID(ctx context.Context, authorID int64, userID int64) (posts []models.Post, status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		rows        *sql.Rows
 		commentRepo = NewCommentDBRepository(pr.dbConn)
 		rateRepo    = NewRateDBRepository(pr.dbConn)
 	)
-	if rows, err = pr.dbConn.Query(`
-		SELECT *
+	if rows, err = pr.dbConn.QueryContext(ctx, `
+		SELECT id, author_id, title, content, content_html,
+		created_at, edited_at, is_image,
+		image_path, is_approved, is_banned
 		FROM posts
 		WHERE author_id = ?
 		AND is_approved = 1
+		AND deleted_at = 0
 		ORDER BY created_at DESC
 		`, authorID); err != nil {
 		return nil, http.StatusInternalServerError, err
@@ -339,19 +514,26 @@ func (pr *PostDBRepository) GetAllPostsByAuthorID(authorID int64, userID int64)
 	defer rows.Close()
 	for rows.Next() {
 		var p models.Post
-		rows.Scan(&p.ID, &p.AuthorID, &p.Title, &p.Content,
+		rows.Scan(&p.ID, &p.AuthorID, &p.Title, &p.Content, &p.ContentHTML,
 			&p.CreatedAt, &p.EditedAt, &p.IsImage,
 			&p.ImagePath, &p.IsApproved, &p.IsBanned)
-		if status, err = pr.GetAuthor(&p); err != nil {
+		p.Variants = imaging.Variants(p.IsImage, p.ImagePath)
+		if status, err = pr.GetAuthor(ctx, &p); err != nil {
+			return nil, status, err
+		}
+		if status, err = pr.GetCategories(ctx, &p); err != nil {
+			return nil, status, err
+		}
+		if status, err = pr.GetTags(ctx, &p); err != nil {
 			return nil, status, err
 		}
-		if status, err = pr.GetCategories(&p); err != nil {
+		if status, err = pr.GetAttachments(ctx, &p); err != nil {
 			return nil, status, err
 		}
-		if p.PostRating, p.UserRating, err = rateRepo.GetPostRating(p.ID, userID); err != nil {
+		if p.PostRating, p.UserRating, err = rateRepo.GetPostRating(ctx, p.ID, userID); err != nil {
 			return nil, http.StatusInternalServerError, err
 		}
-		if p.CommentsNumber, err = commentRepo.GetCommentsNumberByPostID(p.ID); err != nil {
+		if p.CommentsNumber, err = commentRepo.GetCommentsNumberByPostID(ctx, p.ID); err != nil {
 			return nil, status, err
 		}
 		posts = append(posts, p)
@@ -363,7 +545,9 @@ func (pr *PostDBRepository) GetAllPostsByAuthorID(authorID int64, userID int64)
 	return posts, http.StatusOK, nil
 }
 
-func (pr *PostDBRepository) GetRatedPostsByUser(userID int64, orderBy string, requestorID int64) (posts []models.Post, status int, err error) {
+func (pr *PostDBRepository) GetRatedPostsByUser(ctx context.Context, userID int64, orderBy string, requestorID int64) (posts []models.Post, status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		rows        *sql.Rows
 		vote        int
@@ -376,31 +560,42 @@ func (pr *PostDBRepository) GetRatedPostsByUser(userID int64, orderBy string, re
 		vote = -1
 	}
 	query := fmt.Sprintf(`
-		SELECT p.* FROM posts AS p
+		SELECT p.id, p.author_id, p.title, p.content, p.content_html,
+		p.created_at, p.edited_at, p.is_image,
+		p.image_path, p.is_approved, p.is_banned
+		FROM posts AS p
 		INNER JOIN post_rating AS pr ON p.id = pr.post_id
 		WHERE pr.user_id = %d AND pr.rate = %d
 		AND p.is_approved = 1
+		AND p.deleted_at = 0
 		ORDER BY p.created_at DESC
 		`, userID, vote)
-	if rows, err = pr.dbConn.Query(query); err != nil {
+	if rows, err = pr.dbConn.QueryContext(ctx, query); err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
 	defer rows.Close()
 	for rows.Next() {
 		var p models.Post
-		rows.Scan(&p.ID, &p.AuthorID, &p.Title, &p.Content,
+		rows.Scan(&p.ID, &p.AuthorID, &p.Title, &p.Content, &p.ContentHTML,
 			&p.CreatedAt, &p.EditedAt, &p.IsImage,
 			&p.ImagePath, &p.IsApproved, &p.IsBanned)
-		if status, err = pr.GetAuthor(&p); err != nil {
+		p.Variants = imaging.Variants(p.IsImage, p.ImagePath)
+		if status, err = pr.GetAuthor(ctx, &p); err != nil {
 			return nil, status, err
 		}
-		if status, err = pr.GetCategories(&p); err != nil {
+		if status, err = pr.GetCategories(ctx, &p); err != nil {
 			return nil, status, err
 		}
-		if p.PostRating, p.UserRating, err = rateRepo.GetPostRating(p.ID, requestorID); err != nil {
+		if status, err = pr.GetTags(ctx, &p); err != nil {
 			return nil, status, err
 		}
-		if p.CommentsNumber, err = commentRepo.GetCommentsNumberByPostID(p.ID); err != nil {
+		if status, err = pr.GetAttachments(ctx, &p); err != nil {
+			return nil, status, err
+		}
+		if p.PostRating, p.UserRating, err = rateRepo.GetPostRating(ctx, p.ID, requestorID); err != nil {
+			return nil, status, err
+		}
+		if p.CommentsNumber, err = commentRepo.GetCommentsNumberByPostID(ctx, p.ID); err != nil {
 			return nil, status, err
 		}
 		posts = append(posts, p)
@@ -413,85 +608,259 @@ func (pr *PostDBRepository) GetRatedPostsByUser(userID int64, orderBy string, re
 
 }
 
-func (pr *PostDBRepository) Update(post *models.Post) (editedPost *models.Post, status int, err error) {
+func (pr *PostDBRepository) Update(ctx context.Context, post *models.Post) (editedPost *models.Post, status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx          context.Context
-		tx           *sql.Tx
-		result       sql.Result
-		rowsAffected int64
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = pr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
-	if result, err = tx.Exec(`UPDATE posts
+	if editedPost, status, err = pr.UpdateTx(ctx, tx, post); err != nil {
+		tx.Rollback()
+		return nil, status, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return editedPost, status, nil
+}
+
+func (pr *PostDBRepository) UpdateTx(ctx context.Context, tx *sql.Tx, post *models.Post) (editedPost *models.Post, status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		result       sql.Result
+		rowsAffected int64
+	)
+	// the cached HTML is replaced with the content it renders
+	post.ContentHTML = markdown.Render(post.Content)
+	if result, err = tx.ExecContext(ctx, `UPDATE posts
 							SET title = ?,
 							content = ?,
+							content_html = ?,
 							edited_at = ?,
 							is_image = ?,
 							image_path = ?
-							WHERE id = ?`,
-		post.Title, post.Content, post.EditedAt,
+							WHERE id = ?
+							AND deleted_at = 0`,
+		post.Title, post.Content, post.ContentHTML, post.EditedAt,
 		post.IsImage, post.ImagePath, post.ID); err != nil {
-		tx.Rollback()
-		if err == sql.ErrNoRows {
-			return nil, http.StatusInternalServerError, errors.New("post not found")
-		}
 		return nil, http.StatusInternalServerError, err
 	}
 	if rowsAffected, err = result.RowsAffected(); err != nil {
-		tx.Rollback()
 		return nil, http.StatusInternalServerError, err
 	}
 	if rowsAffected > 0 {
-		if err := tx.Commit(); err != nil {
-			return nil, http.StatusInternalServerError, err
-		}
+		post.Variants = imaging.Variants(post.IsImage, post.ImagePath)
 		return post, http.StatusOK, nil
 	}
 	return nil, http.StatusNotModified, errors.New("could not update the post")
 }
 
-func (pr *PostDBRepository) Delete(postID int64) (status int, err error) {
+// Delete soft deletes the post together with its comments in one transaction.
+// Comments share the post's deleted_at so Restore can tell them apart from
+// comments that were deleted on their own.
+func (pr *PostDBRepository) Delete(ctx context.Context, postID int64) (status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx *sql.Tx
+	)
+	if tx, err = pr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if status, err = pr.DeleteTx(ctx, tx, postID); err != nil {
+		tx.Rollback()
+		return status, err
+	}
+	if err = tx.Commit(); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return status, nil
+}
+
+func (pr *PostDBRepository) DeleteTx(ctx context.Context, tx *sql.Tx, postID int64) (status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx          context.Context
-		tx           *sql.Tx
 		result       sql.Result
 		rowsAffected int64
+		now          = time.Now().Unix()
+	)
+	if result, err = tx.ExecContext(ctx, `UPDATE posts
+							  SET deleted_at = ?
+							  WHERE id = ?
+							  AND deleted_at = 0`,
+		now, postID); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if rowsAffected, err = result.RowsAffected(); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if rowsAffected == 0 {
+		return http.StatusNotFound, errors.New("post not found")
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE comments
+						 SET deleted_at = ?
+						 WHERE post_id = ?
+						 AND deleted_at = 0`,
+		now, postID); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func (pr *PostDBRepository) Restore(ctx context.Context, postID int64) (status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx        *sql.Tx
+		deletedAt int64
 	)
-	ctx = context.Background()
 	if tx, err = pr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return http.StatusInternalServerError, err
 	}
-	if result, err = tx.Exec(`DELETE FROM posts
-								WHERE id = ?`,
-		postID); err != nil {
+	if err = tx.QueryRowContext(ctx, `SELECT deleted_at
+						  FROM posts
+						  WHERE id = ?
+						  AND deleted_at > 0`, postID).Scan(&deletedAt); err != nil {
 		tx.Rollback()
 		if err == sql.ErrNoRows {
-			return http.StatusNotFound, errors.New("post not found")
+			return http.StatusNotFound, errors.New("deleted post not found")
 		}
 		return http.StatusInternalServerError, err
 	}
-	if rowsAffected, err = result.RowsAffected(); err != nil {
+	if _, err = tx.ExecContext(ctx, `UPDATE posts
+						 SET deleted_at = 0
+						 WHERE id = ?`, postID); err != nil {
 		tx.Rollback()
-		return http.StatusInternalServerError, nil
+		return http.StatusInternalServerError, err
 	}
-	if rowsAffected > 0 {
-		if err := tx.Commit(); err != nil {
-			return http.StatusInternalServerError, err
+	if _, err = tx.ExecContext(ctx, `UPDATE comments
+						 SET deleted_at = 0
+						 WHERE post_id = ?
+						 AND deleted_at = ?`, postID, deletedAt); err != nil {
+		tx.Rollback()
+		return http.StatusInternalServerError, err
+	}
+	if err = tx.Commit(); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func (pr *PostDBRepository) GetDeletedPosts(ctx context.Context) (posts []models.Post, status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var rows *sql.Rows
+	if rows, err = pr.dbConn.QueryContext(ctx, `
+		SELECT id, author_id, title, content, content_html,
+		created_at, edited_at, is_image,
+		image_path, is_approved, is_banned, deleted_at
+		FROM posts
+		WHERE deleted_at > 0
+		ORDER BY deleted_at DESC
+		`); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p models.Post
+		if err = rows.Scan(&p.ID, &p.AuthorID, &p.Title, &p.Content, &p.ContentHTML,
+			&p.CreatedAt, &p.EditedAt, &p.IsImage,
+			&p.ImagePath, &p.IsApproved, &p.IsBanned, &p.DeletedAt); err != nil {
+			return nil, http.StatusInternalServerError, err
 		}
-		return http.StatusOK, nil
+		posts = append(posts, p)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
 	}
-	return http.StatusNotModified, errors.New("could not delete the post")
+	for i := range posts {
+		posts[i].Variants = imaging.Variants(posts[i].IsImage, posts[i].ImagePath)
+		if status, err = pr.GetAuthor(ctx, &posts[i]); err != nil {
+			return nil, status, err
+		}
+		if status, err = pr.GetCategories(ctx, &posts[i]); err != nil {
+			return nil, status, err
+		}
+		if status, err = pr.GetTags(ctx, &posts[i]); err != nil {
+			return nil, status, err
+		}
+		if status, err = pr.GetAttachments(ctx, &posts[i]); err != nil {
+			return nil, status, err
+		}
+	}
+	return posts, http.StatusOK, nil
 }
 
-func (pr *PostDBRepository) GetBannedPostsByCategories(categories []string) (posts []models.Post, status int, err error) {
+// Purge hard deletes posts soft deleted before the given unix time, along
+// with everything that references them.
+func (pr *PostDBRepository) Purge(ctx context.Context, before int64) (purged int64, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx     *sql.Tx
+		result sql.Result
+	)
+	if tx, err = pr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return 0, err
+	}
+	// Attachments of a post a moderator deleted stay, as its image does,
+	// for an appeal to restore them
+	if _, err = releaseAttachments(ctx, tx, `attachments.post_id IN
+		(SELECT id FROM posts WHERE deleted_at > 0 AND deleted_at < ?)
+		AND attachments.post_id NOT IN (SELECT post_id FROM moderation_actions)`, before); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	for _, query := range []string{
+		`DELETE FROM notifications WHERE post_id IN (%s)`,
+		`DELETE FROM comment_rating WHERE post_id IN (%s)`,
+		`DELETE FROM comments WHERE post_id IN (%s)`,
+		`DELETE FROM post_rating WHERE post_id IN (%s)`,
+		`DELETE FROM posts_categories_bridge WHERE post_id IN (%s)`,
+		// restoring a post a moderator deleted brings its tags back
+		`DELETE FROM posts_tags_bridge WHERE post_id IN (%s)
+			AND post_id NOT IN (SELECT post_id FROM moderation_actions)`,
+		`DELETE FROM posts_bans_bridge WHERE post_id IN (%s)`,
+		`DELETE FROM post_reports WHERE post_id IN (%s)`,
+		// foreign keys are not enforced, so nothing cascades
+		`DELETE FROM mentions WHERE post_id IN (%s)`,
+	} {
+		if _, err = tx.ExecContext(ctx, fmt.Sprintf(query,
+			`SELECT id FROM posts WHERE deleted_at > 0 AND deleted_at < ?`), before); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	if result, err = tx.ExecContext(ctx, `DELETE FROM posts
+							  WHERE deleted_at > 0
+							  AND deleted_at < ?`, before); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if purged, err = result.RowsAffected(); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return purged, tx.Commit()
+}
+
+func (pr *PostDBRepository) GetBannedPostsByCategories(ctx context.Context, categories []string) (posts []models.Post, status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		rows           *sql.Rows
 		categoriesList string = fmt.Sprintf("\"%s\"", strings.Join(categories, "\", \""))
 	)
 	query := fmt.Sprintf(`
-		SELECT p.*
+		SELECT p.id, p.author_id, p.title, p.content, p.content_html,
+		p.created_at, p.edited_at, p.is_image,
+		p.image_path, p.is_approved, p.is_banned
 		FROM posts_bans_bridge as pbb
 		INNER JOIN posts as p
 		ON p.id = pbb.post_id
@@ -500,22 +869,30 @@ func (pr *PostDBRepository) GetBannedPostsByCategories(categories []string) (pos
 		WHERE b.name in (%s)
 		AND p.is_approved = 0
 		AND p.is_banned = 1
+		AND p.deleted_at = 0
 		GROUP BY p.id
 		HAVING COUNT(DISTINCT b.id) = %d
 		ORDER BY p.created_at DESC`, categoriesList, len(categories))
-	if rows, err = pr.dbConn.Query(query); err != nil {
+	if rows, err = pr.dbConn.QueryContext(ctx, query); err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
 	defer rows.Close()
 	for rows.Next() {
 		var p models.Post
-		rows.Scan(&p.ID, &p.AuthorID, &p.Title, &p.Content,
+		rows.Scan(&p.ID, &p.AuthorID, &p.Title, &p.Content, &p.ContentHTML,
 			&p.CreatedAt, &p.EditedAt, &p.IsImage,
 			&p.ImagePath, &p.IsApproved, &p.IsBanned)
-		if status, err = pr.GetAuthor(&p); err != nil {
+		p.Variants = imaging.Variants(p.IsImage, p.ImagePath)
+		if status, err = pr.GetAuthor(ctx, &p); err != nil {
+			return nil, status, err
+		}
+		if status, err = pr.GetCategories(ctx, &p); err != nil {
+			return nil, status, err
+		}
+		if status, err = pr.GetTags(ctx, &p); err != nil {
 			return nil, status, err
 		}
-		if status, err = pr.GetCategories(&p); err != nil {
+		if status, err = pr.GetAttachments(ctx, &p); err != nil {
 			return nil, status, err
 		}
 		posts = append(posts, p)
@@ -528,17 +905,17 @@ func (pr *PostDBRepository) GetBannedPostsByCategories(categories []string) (pos
 
 }
 
-func (pr *PostDBRepository) DeletePostReportByPostID(postID int64) error {
+func (pr *PostDBRepository) DeletePostReportByPostID(ctx context.Context, postID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
 		tx  *sql.Tx
 		err error
 	)
-	ctx = context.Background()
 	if tx, err = pr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM post_reports
+	if _, err = tx.ExecContext(ctx, `DELETE FROM post_reports
 						 WHERE post_id = ?
 		`, postID); err != nil {
 		tx.Rollback()
@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/db"
 	"github.com/innovember/forum/api/models"
 	"github.com/innovember/forum/api/post"
 	"net/http"
@@ -17,8 +19,10 @@ func NewRateCommentDBRepository(conn *sql.DB) post.RateCommentRepository {
 	return &RateCommentDBRepository{dbConn: conn}
 }
 
-func (rr *RateCommentDBRepository) GetCommentRating(commentID int64, userID int64) (rating int, userRating int, err error) {
-	if err = rr.dbConn.QueryRow(`
+func (rr *RateCommentDBRepository) GetCommentRating(ctx context.Context, commentID int64, userID int64) (rating int, userRating int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if err = rr.dbConn.QueryRowContext(ctx, `
 	SELECT TOTAL(rate) AS rating,
 	IFNULL ((SELECT rate
 		 	FROM comment_rating
@@ -37,21 +41,35 @@ func (rr *RateCommentDBRepository) GetCommentRating(commentID int64, userID int6
 
 }
 
-func (rr *RateCommentDBRepository) RateComment(commentID int64, userID int64, vote int, postID int64) (int64, error) {
+func (rr *RateCommentDBRepository) RateComment(ctx context.Context, commentID int64, userID int64, vote int, postID int64) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	return rateComment(ctx, rr.dbConn, commentID, userID, vote, postID)
+}
+
+func (rr *RateCommentDBRepository) RateCommentTx(ctx context.Context, tx *sql.Tx, commentID int64, userID int64, vote int, postID int64) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	return rateComment(ctx, tx, commentID, userID, vote, postID)
+}
+
+func rateComment(ctx context.Context, exec db.Executor, commentID int64, userID int64, vote int, postID int64) (int64, error) {
 	var (
 		result       sql.Result
 		rowsAffected int64
 		err          error
 		rateID       int64
 	)
-	if result, err = rr.dbConn.Exec(`
+	if result, err = exec.ExecContext(ctx, `
 		REPLACE INTO comment_rating(id, user_id,post_id, comment_id,rate)
-		VALUES(
+		SELECT
 			(SELECT id FROM comment_rating
-				WHERE user_id = $1 AND post_id = $2 
+				WHERE user_id = $1 AND post_id = $2
 				AND comment_id = $3
 			),
-			$1,$2,$3,$4)`,
+			$1,$2,$3,$4
+		WHERE EXISTS (SELECT id FROM comments
+			WHERE id = $3 AND post_id = $2 AND deleted_at = 0)`,
 		userID, postID, commentID, vote); err != nil {
 		return 0, err
 	}
@@ -67,12 +85,24 @@ func (rr *RateCommentDBRepository) RateComment(commentID int64, userID int64, vo
 	return 0, errors.New("cant set new rate for comment")
 }
 
-func (rr *RateCommentDBRepository) IsRatedBefore(commentID int64, userID int64, vote int) (bool, error) {
+func (rr *RateCommentDBRepository) IsRatedBefore(ctx context.Context, commentID int64, userID int64, vote int) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	return isCommentRatedBefore(ctx, rr.dbConn, commentID, userID, vote)
+}
+
+func (rr *RateCommentDBRepository) IsRatedBeforeTx(ctx context.Context, tx *sql.Tx, commentID int64, userID int64, vote int) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	return isCommentRatedBefore(ctx, tx, commentID, userID, vote)
+}
+
+func isCommentRatedBefore(ctx context.Context, exec db.Executor, commentID int64, userID int64, vote int) (bool, error) {
 	var (
 		err  error
 		rate int
 	)
-	if err = rr.dbConn.QueryRow(`SELECT rate FROM comment_rating
+	if err = exec.QueryRowContext(ctx, `SELECT rate FROM comment_rating
 								 WHERE comment_id = ?
 								 AND user_id = ?
 								 AND rate = ?`, commentID, userID, vote).Scan(
@@ -88,47 +118,58 @@ func (rr *RateCommentDBRepository) IsRatedBefore(commentID int64, userID int64,
 	return false, nil
 }
 
-func (rr *RateCommentDBRepository) DeleteRateFromComment(commentID int64, userID int64, vote int) (err error) {
+func (rr *RateCommentDBRepository) DeleteRateFromComment(ctx context.Context, commentID int64, userID int64, vote int) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx           context.Context
-		tx            *sql.Tx
-		commentRateID int64
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = rr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if err = tx.QueryRow(`SELECT id
+	if err = rr.DeleteRateFromCommentTx(ctx, tx, commentID, userID, vote); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (rr *RateCommentDBRepository) DeleteRateFromCommentTx(ctx context.Context, tx *sql.Tx, commentID int64, userID int64, vote int) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		commentRateID int64
+	)
+	if err = tx.QueryRowContext(ctx, `SELECT id
 							FROM comment_rating
 							WHERE comment_id = ?
 		AND user_id = ?
 		AND rate = ?`, commentID, userID, vote).Scan(&commentRateID); err != nil {
-		tx.Rollback()
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM notifications
+	if _, err = tx.ExecContext(ctx, `DELETE FROM notifications
     WHERE comment_rate_id = ?`, commentRateID); err != nil {
-		tx.Rollback()
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM comment_rating
+	if _, err = tx.ExecContext(ctx, `DELETE FROM comment_rating
 		WHERE comment_id = ?
 		AND user_id = ?
 		AND rate = ?`, commentID, userID, vote); err != nil {
-		tx.Rollback()
-		return err
-	}
-	if err = tx.Commit(); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (rr *RateCommentDBRepository) GetCommentRatingByID(commentRateID int64) (commentRating *models.CommentRating, status int, err error) {
+func (rr *RateCommentDBRepository) GetCommentRatingByID(ctx context.Context, commentRateID int64) (commentRating *models.CommentRating, status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		cr models.CommentRating
 	)
-	if err = rr.dbConn.QueryRow(`
+	if err = rr.dbConn.QueryRowContext(ctx, `
 		SELECT *
 		FROM comment_rating
 		WHERE id = ?
@@ -139,17 +180,19 @@ func (rr *RateCommentDBRepository) GetCommentRatingByID(commentRateID int64) (co
 		}
 		return nil, http.StatusInternalServerError, err
 	}
-	if status, err = rr.GetAuthor(&cr); err != nil {
+	if status, err = rr.GetAuthor(ctx, &cr); err != nil {
 		return nil, status, err
 	}
 	return &cr, http.StatusOK, nil
 }
 
-func (rr *RateCommentDBRepository) GetAuthor(commentRating *models.CommentRating) (status int, err error) {
+func (rr *RateCommentDBRepository) GetAuthor(ctx context.Context, commentRating *models.CommentRating) (status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		user models.User
 	)
-	if err = rr.dbConn.QueryRow(`
+	if err = rr.dbConn.QueryRowContext(ctx, `
 	SELECT id,username,email,created_at,last_active FROM users WHERE id = ?`, commentRating.UserID).Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt, &user.LastActive); err != nil {
 		if err == sql.ErrNoRows {
 			return http.StatusNotFound, errors.New("cant find author of rating")
@@ -160,16 +203,16 @@ func (rr *RateCommentDBRepository) GetAuthor(commentRating *models.CommentRating
 	return http.StatusOK, nil
 }
 
-func (rr *RateCommentDBRepository) DeleteRatesByCommentID(commentID int64) (err error) {
+func (rr *RateCommentDBRepository) DeleteRatesByCommentID(ctx context.Context, commentID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
-		tx  *sql.Tx
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = rr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM comment_rating
+	if _, err = tx.ExecContext(ctx, `DELETE FROM comment_rating
 								WHERE comment_id = ?`,
 		commentID); err != nil {
 		tx.Rollback()
@@ -181,16 +224,16 @@ func (rr *RateCommentDBRepository) DeleteRatesByCommentID(commentID int64) (err
 	return nil
 }
 
-func (rr *RateCommentDBRepository) DeleteCommentsRateByPostID(postID int64) (err error) {
+func (rr *RateCommentDBRepository) DeleteCommentsRateByPostID(ctx context.Context, postID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
-		tx  *sql.Tx
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = rr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM comment_rating
+	if _, err = tx.ExecContext(ctx, `DELETE FROM comment_rating
 								WHERE post_id = ?`,
 		postID); err != nil {
 		tx.Rollback()
@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/db"
 	"github.com/innovember/forum/api/models"
 	"github.com/innovember/forum/api/post"
 	"net/http"
@@ -17,8 +19,10 @@ func NewRateDBRepository(conn *sql.DB) post.RateRepository {
 	return &RateDBRepository{dbConn: conn}
 }
 
-func (rr *RateDBRepository) GetPostRating(postID int64, userID int64) (rating int, userRating int, err error) {
-	if err = rr.dbConn.QueryRow(`
+func (rr *RateDBRepository) GetPostRating(ctx context.Context, postID int64, userID int64) (rating int, userRating int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if err = rr.dbConn.QueryRowContext(ctx, `
 	SELECT TOTAL(rate) AS rating,
 	IFNULL ((SELECT rate
 		 	FROM post_rating
@@ -37,19 +41,33 @@ func (rr *RateDBRepository) GetPostRating(postID int64, userID int64) (rating in
 
 }
 
-func (rr *RateDBRepository) RatePost(postID int64, userID int64, vote int) (int64, error) {
+func (rr *RateDBRepository) RatePost(ctx context.Context, postID int64, userID int64, vote int) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	return ratePost(ctx, rr.dbConn, postID, userID, vote)
+}
+
+func (rr *RateDBRepository) RatePostTx(ctx context.Context, tx *sql.Tx, postID int64, userID int64, vote int) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	return ratePost(ctx, tx, postID, userID, vote)
+}
+
+func ratePost(ctx context.Context, exec db.Executor, postID int64, userID int64, vote int) (int64, error) {
 	var (
 		result       sql.Result
 		rowsAffected int64
 		err          error
 		rateID       int64
 	)
-	if result, err = rr.dbConn.Exec(`
+	if result, err = exec.ExecContext(ctx, `
 		REPLACE INTO post_rating(id, user_id, post_id,rate)
-		VALUES(
+		SELECT
 			(SELECT id FROM post_rating
 				WHERE user_id = $1 AND post_id = $2),
-			$1,$2,$3)`,
+			$1,$2,$3
+		WHERE EXISTS (SELECT id FROM posts
+			WHERE id = $2 AND deleted_at = 0)`,
 		userID, postID, vote); err != nil {
 		return 0, err
 	}
@@ -65,12 +83,24 @@ func (rr *RateDBRepository) RatePost(postID int64, userID int64, vote int) (int6
 	return 0, errors.New("cant set new rate for post")
 }
 
-func (rr *RateDBRepository) IsRatedBefore(postID int64, userID int64, vote int) (bool, error) {
+func (rr *RateDBRepository) IsRatedBefore(ctx context.Context, postID int64, userID int64, vote int) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	return isPostRatedBefore(ctx, rr.dbConn, postID, userID, vote)
+}
+
+func (rr *RateDBRepository) IsRatedBeforeTx(ctx context.Context, tx *sql.Tx, postID int64, userID int64, vote int) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	return isPostRatedBefore(ctx, tx, postID, userID, vote)
+}
+
+func isPostRatedBefore(ctx context.Context, exec db.Executor, postID int64, userID int64, vote int) (bool, error) {
 	var (
 		err  error
 		rate int
 	)
-	if err = rr.dbConn.QueryRow(`SELECT rate FROM post_rating
+	if err = exec.QueryRowContext(ctx, `SELECT rate FROM post_rating
 								 WHERE post_id = ?
 								 AND user_id = ?
 								 AND rate = ?`, postID, userID, vote).Scan(
@@ -86,47 +116,58 @@ func (rr *RateDBRepository) IsRatedBefore(postID int64, userID int64, vote int)
 	return false, nil
 }
 
-func (rr *RateDBRepository) DeleteRateFromPost(postID int64, userID int64, vote int) (err error) {
+func (rr *RateDBRepository) DeleteRateFromPost(ctx context.Context, postID int64, userID int64, vote int) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx    context.Context
-		tx     *sql.Tx
-		rateID int64
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = rr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if err = tx.QueryRow(`SELECT id
+	if err = rr.DeleteRateFromPostTx(ctx, tx, postID, userID, vote); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (rr *RateDBRepository) DeleteRateFromPostTx(ctx context.Context, tx *sql.Tx, postID int64, userID int64, vote int) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		rateID int64
+	)
+	if err = tx.QueryRowContext(ctx, `SELECT id
 							FROM post_rating
 							WHERE post_id = ?
 		AND user_id = ?
 		AND rate = ?`, postID, userID, vote).Scan(&rateID); err != nil {
-		tx.Rollback()
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM notifications
+	if _, err = tx.ExecContext(ctx, `DELETE FROM notifications
     WHERE rate_id = ?`, rateID); err != nil {
-		tx.Rollback()
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM post_rating
+	if _, err = tx.ExecContext(ctx, `DELETE FROM post_rating
 		WHERE post_id = ?
 		AND user_id = ?
 		AND rate = ?`, postID, userID, vote); err != nil {
-		tx.Rollback()
-		return err
-	}
-	if err = tx.Commit(); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (rr *RateDBRepository) GetPostRatingByID(rateID int64) (postRating *models.PostRating, status int, err error) {
+func (rr *RateDBRepository) GetPostRatingByID(ctx context.Context, rateID int64) (postRating *models.PostRating, status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		pr models.PostRating
 	)
-	if err = rr.dbConn.QueryRow(`
+	if err = rr.dbConn.QueryRowContext(ctx, `
 		SELECT *
 		FROM post_rating
 		WHERE id = ?
@@ -137,17 +178,19 @@ func (rr *RateDBRepository) GetPostRatingByID(rateID int64) (postRating *models.
 		}
 		return nil, http.StatusInternalServerError, err
 	}
-	if status, err = rr.GetAuthor(&pr); err != nil {
+	if status, err = rr.GetAuthor(ctx, &pr); err != nil {
 		return nil, status, err
 	}
 	return &pr, http.StatusOK, nil
 }
 
-func (rr *RateDBRepository) GetAuthor(postRating *models.PostRating) (status int, err error) {
+func (rr *RateDBRepository) GetAuthor(ctx context.Context, postRating *models.PostRating) (status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		user models.User
 	)
-	if err = rr.dbConn.QueryRow(`
+	if err = rr.dbConn.QueryRowContext(ctx, `
 	SELECT id,username,email,created_at,last_active FROM users WHERE id = ?`, postRating.UserID).Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt, &user.LastActive); err != nil {
 		if err == sql.ErrNoRows {
 			return http.StatusNotFound, errors.New("cant find author of rating")
@@ -158,16 +201,16 @@ func (rr *RateDBRepository) GetAuthor(postRating *models.PostRating) (status int
 	return http.StatusOK, nil
 }
 
-func (rr *RateDBRepository) DeleteRatesByPostID(postID int64) (err error) {
+func (rr *RateDBRepository) DeleteRatesByPostID(ctx context.Context, postID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
-		tx  *sql.Tx
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = rr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM post_rating
+	if _, err = tx.ExecContext(ctx, `DELETE FROM post_rating
 								WHERE post_id = ?`,
 		postID); err != nil {
 		tx.Rollback()
@@ -0,0 +1,278 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/db"
+	"github.com/innovember/forum/api/models"
+	"github.com/innovember/forum/api/post"
+)
+
+type TagDBRepository struct {
+	dbConn *sql.DB
+}
+
+func NewTagDBRepository(conn *sql.DB) post.TagRepository {
+	return &TagDBRepository{dbConn: conn}
+}
+
+// tagColumns are the columns scanned into a models.Tag, in its order.
+const tagColumns = `t.id, t.name,
+	(SELECT COUNT(*)
+		FROM posts_tags_bridge AS ptb
+		INNER JOIN posts AS p
+		ON p.id = ptb.post_id
+		WHERE ptb.tag_id = t.id
+		AND p.is_approved = 1
+		AND p.deleted_at = 0) AS posts_number`
+
+// SetPostTags replaces the tags of a post with the tags named, creating
+// those that don't exist yet. A synonym tags the post with its tag. It
+// returns the names the post ends up tagged with, in order.
+func (tr *TagDBRepository) SetPostTags(ctx context.Context, postID int64, names []string) (tagNames []string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx *sql.Tx
+	)
+	if tx, err = tr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return nil, err
+	}
+	if tagNames, err = tr.SetPostTagsTx(ctx, tx, postID, names); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return tagNames, nil
+}
+
+func (tr *TagDBRepository) SetPostTagsTx(ctx context.Context, tx *sql.Tx, postID int64, names []string) (tagNames []string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		seen = map[int64]bool{}
+	)
+	if _, err = tx.ExecContext(ctx, `DELETE FROM posts_tags_bridge
+						WHERE post_id = ?`, postID); err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		var (
+			tagID   int64
+			tagName string
+		)
+		if tagID, tagName, err = resolveTag(ctx, tx, name); err != nil {
+			return nil, err
+		}
+		if seen[tagID] {
+			continue
+		}
+		seen[tagID] = true
+		if _, err = tx.ExecContext(ctx, `INSERT INTO posts_tags_bridge (post_id, tag_id)
+			VALUES (?, ?)`, postID, tagID); err != nil {
+			return nil, err
+		}
+		tagNames = append(tagNames, tagName)
+	}
+	sort.Strings(tagNames)
+	return tagNames, nil
+}
+
+// resolveTag returns the tag a name stands for, its own or, for a
+// synonym, the one it is a synonym of. A new name is made a tag.
+func resolveTag(ctx context.Context, exec db.Executor, name string) (tagID int64, tagName string, err error) {
+	if err = exec.QueryRowContext(ctx, `SELECT t.id, t.name
+		FROM tag_synonyms AS ts
+		INNER JOIN tags AS t
+		ON t.id = ts.tag_id
+		WHERE ts.name = ?`, name).Scan(&tagID, &tagName); err != sql.ErrNoRows {
+		return tagID, tagName, err
+	}
+	if _, err = exec.ExecContext(ctx, `INSERT OR IGNORE INTO tags (name, created_at)
+		VALUES (?, ?)`, name, time.Now().Unix()); err != nil {
+		return 0, "", err
+	}
+	if err = exec.QueryRowContext(ctx, `SELECT id FROM tags WHERE name = ?`, name).Scan(&tagID); err != nil {
+		return 0, "", err
+	}
+	return tagID, name, nil
+}
+
+// GetTagByName returns the tag a name stands for, as resolveTag does, or
+// sql.ErrNoRows.
+func (tr *TagDBRepository) GetTagByName(ctx context.Context, name string) (tag *models.Tag, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	tag = &models.Tag{}
+	if err = tr.dbConn.QueryRowContext(ctx, `SELECT `+tagColumns+`
+		FROM tags AS t
+		WHERE t.name = $1
+		OR t.id IN (SELECT tag_id FROM tag_synonyms WHERE name = $1)`, name).Scan(
+		&tag.ID, &tag.Name, &tag.PostsNumber); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// GetTagsByPrefix returns the tags whose name, or the name of one of their
+// synonyms, starts with prefix, the most used first.
+func (tr *TagDBRepository) GetTagsByPrefix(ctx context.Context, prefix string, limit int) (tags []models.Tag, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var rows *sql.Rows
+	// substr rather than LIKE, which would take the _ and % of a prefix as
+	// wildcards
+	if rows, err = tr.dbConn.QueryContext(ctx, `SELECT `+tagColumns+`
+		FROM tags AS t
+		WHERE substr(t.name, 1, length($1)) = $1
+		OR t.id IN (
+			SELECT tag_id
+			FROM tag_synonyms
+			WHERE substr(name, 1, length($1)) = $1
+		)
+		ORDER BY posts_number DESC, t.name
+		LIMIT $2`, prefix, limit); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t models.Tag
+		if err = rows.Scan(&t.ID, &t.Name, &t.PostsNumber); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// GetPopularTags returns the tags of the most approved posts, the most
+// used first.
+func (tr *TagDBRepository) GetPopularTags(ctx context.Context, limit int) (tags []models.Tag, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var rows *sql.Rows
+	if rows, err = tr.dbConn.QueryContext(ctx, `SELECT t.id, t.name, COUNT(*) AS posts_number
+		FROM tags AS t
+		INNER JOIN posts_tags_bridge AS ptb
+		ON ptb.tag_id = t.id
+		INNER JOIN posts AS p
+		ON p.id = ptb.post_id
+		WHERE p.is_approved = 1
+		AND p.deleted_at = 0
+		GROUP BY t.id
+		ORDER BY posts_number DESC, t.name
+		LIMIT ?`, limit); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t models.Tag
+		if err = rows.Scan(&t.ID, &t.Name, &t.PostsNumber); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (tr *TagDBRepository) GetAllSynonyms(ctx context.Context) (synonyms []models.TagSynonym, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var rows *sql.Rows
+	if rows, err = tr.dbConn.QueryContext(ctx, `SELECT ts.name, t.name
+		FROM tag_synonyms AS ts
+		INNER JOIN tags AS t
+		ON t.id = ts.tag_id
+		ORDER BY t.name, ts.name`); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s models.TagSynonym
+		if err = rows.Scan(&s.Name, &s.Tag); err != nil {
+			return nil, err
+		}
+		synonyms = append(synonyms, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return synonyms, nil
+}
+
+// AddSynonym makes a name a synonym of a tag, in one transaction. A tag of
+// that name is merged into the tag: its posts and synonyms move to it.
+func (tr *TagDBRepository) AddSynonym(ctx context.Context, synonym *models.TagSynonym) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx       *sql.Tx
+		targetID int64
+		sourceID int64
+	)
+	if tx, err = tr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return err
+	}
+	if targetID, synonym.Tag, err = resolveTag(ctx, tx, synonym.Tag); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.QueryRowContext(ctx, `SELECT id FROM tags WHERE name = ?`, synonym.Name).Scan(&sourceID); err != nil && err != sql.ErrNoRows {
+		tx.Rollback()
+		return err
+	}
+	if sourceID != 0 && sourceID != targetID {
+		for _, statement := range []string{
+			`INSERT OR IGNORE INTO posts_tags_bridge (post_id, tag_id)
+			SELECT post_id, @target
+			FROM posts_tags_bridge
+			WHERE tag_id = @source`,
+			`DELETE FROM posts_tags_bridge WHERE tag_id = @source`,
+			`UPDATE tag_synonyms SET tag_id = @target WHERE tag_id = @source`,
+			`DELETE FROM tags WHERE id = @source`,
+		} {
+			if _, err = tx.ExecContext(ctx, statement, sql.Named("source", sourceID), sql.Named("target", targetID)); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+	if _, err = tx.ExecContext(ctx, `INSERT OR REPLACE INTO tag_synonyms (name, tag_id)
+		VALUES (?, ?)`, synonym.Name, targetID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (tr *TagDBRepository) DeleteSynonym(ctx context.Context, name string) (deleted bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		result       sql.Result
+		rowsAffected int64
+	)
+	if result, err = tr.dbConn.ExecContext(ctx, `DELETE FROM tag_synonyms
+		WHERE name = ?`, name); err != nil {
+		return false, err
+	}
+	if rowsAffected, err = result.RowsAffected(); err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/models"
+	"github.com/innovember/forum/api/post"
+)
+
+type UploadDBRepository struct {
+	dbConn *sql.DB
+}
+
+func NewUploadDBRepository(conn *sql.DB) post.UploadRepository {
+	return &UploadDBRepository{dbConn: conn}
+}
+
+func (ur *UploadDBRepository) Create(ctx context.Context, upload *models.Upload) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		result sql.Result
+		now    = time.Now().Unix()
+	)
+	if result, err = ur.dbConn.ExecContext(ctx, `INSERT INTO uploads(file_name, owner_id,
+		post_id, size, hash, created_at)
+	VALUES(?,?,0,?,?,?)`, upload.FileName, upload.OwnerID,
+		upload.Size, upload.Hash, now); err != nil {
+		return err
+	}
+	if upload.ID, err = result.LastInsertId(); err != nil {
+		return err
+	}
+	upload.CreatedAt = now
+	return nil
+}
+
+func (ur *UploadDBRepository) GetByFileName(ctx context.Context, fileName string) (*models.Upload, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		upload models.Upload
+		err    error
+	)
+	if err = ur.dbConn.QueryRowContext(ctx, `SELECT id, file_name, owner_id,
+							 post_id, size, hash, created_at
+							 FROM uploads
+							 WHERE file_name = ?`, fileName).Scan(&upload.ID,
+		&upload.FileName, &upload.OwnerID, &upload.PostID,
+		&upload.Size, &upload.Hash, &upload.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, http.StatusNotFound, errors.New("upload not found")
+		}
+		return nil, http.StatusInternalServerError, err
+	}
+	return &upload, http.StatusOK, nil
+}
+
+// AttachToPost makes fileName the image of the post and releases the one
+// it had before, if any, to the orphan collector. An empty fileName only
+// releases. The upload must belong to ownerID and to no other post.
+func (ur *UploadDBRepository) AttachToPost(ctx context.Context, postID int64, ownerID int64, fileName string) (status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx *sql.Tx
+	)
+	if tx, err = ur.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if status, err = ur.AttachToPostTx(ctx, tx, postID, ownerID, fileName); err != nil {
+		tx.Rollback()
+		return status, err
+	}
+	if err = tx.Commit(); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return status, nil
+}
+
+func (ur *UploadDBRepository) AttachToPostTx(ctx context.Context, tx *sql.Tx, postID int64, ownerID int64, fileName string) (status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		result       sql.Result
+		rowsAffected int64
+	)
+	if _, err = tx.ExecContext(ctx, `UPDATE uploads
+						 SET post_id = 0
+						 WHERE post_id = ?
+						 AND file_name != ?`, postID, fileName); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if fileName != "" {
+		if result, err = tx.ExecContext(ctx, `UPDATE uploads
+								  SET post_id = ?
+								  WHERE file_name = ?
+								  AND owner_id = ?
+								  AND post_id IN (0, ?)`,
+			postID, fileName, ownerID, postID); err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if rowsAffected, err = result.RowsAffected(); err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if rowsAffected == 0 {
+			return http.StatusForbidden, errors.New("image belongs to another user or post")
+		}
+	}
+	return http.StatusOK, nil
+}
+
+func (ur *UploadDBRepository) Delete(ctx context.Context, fileName string) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if _, err = ur.dbConn.ExecContext(ctx, `DELETE FROM uploads
+							WHERE file_name = ?`, fileName); err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetOrphans returns the uploads created before the given unix time that no
+// post uses: never attached, released by an edit, or left by a purged
+// post. A post a moderator deleted keeps its image while the moderation
+// action remains, since reversing an appeal restores the post.
+func (ur *UploadDBRepository) GetOrphans(ctx context.Context, before int64) (uploads []models.Upload, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var rows *sql.Rows
+	if rows, err = ur.dbConn.QueryContext(ctx, `
+		SELECT u.id, u.file_name, u.owner_id, u.post_id,
+		u.size, u.hash, u.created_at
+		FROM uploads u
+		WHERE u.created_at < ?
+		AND NOT EXISTS (SELECT 1 FROM posts p WHERE p.id = u.post_id)
+		AND NOT EXISTS (SELECT 1 FROM moderation_actions m WHERE m.post_id = u.post_id)
+		`, before); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var u models.Upload
+		if err = rows.Scan(&u.ID, &u.FileName, &u.OwnerID, &u.PostID,
+			&u.Size, &u.Hash, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, u)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return uploads, nil
+}
+
+// DeleteOrphan deletes the record of an upload GetOrphans returned, unless
+// it has been attached to a post since. deleted tells the caller that the
+// files are its to remove.
+func (ur *UploadDBRepository) DeleteOrphan(ctx context.Context, upload *models.Upload) (deleted bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		result       sql.Result
+		rowsAffected int64
+	)
+	if result, err = ur.dbConn.ExecContext(ctx, `DELETE FROM uploads
+							WHERE file_name = $1
+							AND post_id = $2
+							AND NOT EXISTS (SELECT 1 FROM posts p WHERE p.id = $2)
+							AND NOT EXISTS (SELECT 1 FROM moderation_actions m WHERE m.post_id = $2)`,
+		upload.FileName, upload.PostID); err != nil {
+		return false, err
+	}
+	if rowsAffected, err = result.RowsAffected(); err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
@@ -1,73 +1,116 @@
 package post
 
 import (
+	"context"
 	"github.com/innovember/forum/api/models"
 )
 
 type PostUsecase interface {
-	Create(post *models.Post, categories []string) (newPost *models.Post, status int, err error)
-	GetAllPosts(userID int64) (posts []models.Post, status int, err error)
-	GetPostByID(userID int64, postID int64) (post *models.Post, status int, err error)
-	GetPostsByCategories(categories []string, userID int64) (posts []models.Post, status int, err error)
-	GetPostsByRating(orderBy string, userID int64) (posts []models.Post, status int, err error)
-	GetPostsByDate(orderBy string, userID int64) (posts []models.Post, status int, err error)
-	GetAllPostsByAuthorID(authorID int64, userID int64) (posts []models.Post, status int, err error)
-	GetRatedPostsByUser(userID int64, orderBy string, requestorID int64) (posts []models.Post, status int, err error)
-	Update(post *models.Post) (editedPost *models.Post, status int, err error)
-	Delete(postID int64) (status int, err error)
-	GetBannedPostsByCategories(categories []string) (posts []models.Post, status int, err error)
-	DeletePostReportByPostID(postID int64) (err error)
+	Create(ctx context.Context, post *models.Post, input *models.InputPost, fileName string) (newPost *models.Post, status int, err error)
+	GetAllPosts(ctx context.Context, userID int64) (posts []models.Post, status int, err error)
+	GetPostByID(ctx context.Context, userID int64, postID int64) (post *models.Post, status int, err error)
+	GetPostsByCategoriesAndTags(ctx context.Context, categories []string, tagIDs []int64, match string, userID int64) (posts []models.Post, status int, err error)
+	GetPostsByRating(ctx context.Context, orderBy string, userID int64) (posts []models.Post, status int, err error)
+	GetPostsByDate(ctx context.Context, orderBy string, userID int64) (posts []models.Post, status int, err error)
+	GetAllPostsByAuthorID(ctx context.Context, authorID int64, userID int64) (posts []models.Post, status int, err error)
+	GetRatedPostsByUser(ctx context.Context, userID int64, orderBy string, requestorID int64) (posts []models.Post, status int, err error)
+	Update(ctx context.Context, post *models.Post, oldPost *models.Post, input *models.InputPost, fileName string) (editedPost *models.Post, status int, err error)
+	Delete(ctx context.Context, postID int64) (status int, err error)
+	Restore(ctx context.Context, postID int64) (status int, err error)
+	GetDeletedPosts(ctx context.Context) (posts []models.Post, status int, err error)
+	GetBannedPostsByCategories(ctx context.Context, categories []string) (posts []models.Post, status int, err error)
+	DeletePostReportByPostID(ctx context.Context, postID int64) (err error)
 }
 
 type CategoryUsecase interface {
-	GetAllCategories() (categories []models.Category, status int, err error)
-	Update(postID int64, categories []string) (err error)
-	DeleteFromPostCategoriesBridge(postID int64) (err error)
-	DeleteCategoryByID(categoryID int64) (err error)
-	CreateNewCategory(category string) (err error)
+	GetAllCategories(ctx context.Context) (categories []models.Category, status int, err error)
+	CanUseCategories(ctx context.Context, user *models.User, categoryIDs []int64, current []models.Category) (status int, err error)
+	Update(ctx context.Context, postID int64, categoryIDs []int64) (err error)
+	DeleteFromPostCategoriesBridge(ctx context.Context, postID int64) (err error)
+	DeleteCategoryByID(ctx context.Context, categoryID int64) (status int, err error)
+	CreateNewCategory(ctx context.Context, category *models.Category) (status int, err error)
+	UpdateCategory(ctx context.Context, category *models.Category) (status int, err error)
+	RenameCategory(ctx context.Context, category *models.Category) (status int, err error)
+	MergeCategories(ctx context.Context, sourceID int64, targetID int64) (status int, err error)
+	AssignModerator(ctx context.Context, categoryID int64, moderatorID int64) (status int, err error)
+	UnassignModerator(ctx context.Context, categoryID int64, moderatorID int64) (err error)
+	SetModeratorScoped(ctx context.Context, moderatorID int64, scoped bool) (err error)
+	GetModerators(ctx context.Context, categoryID int64) (moderators []models.User, status int, err error)
+	CanModeratePost(ctx context.Context, moderatorID int64, postID int64) (can bool, err error)
 }
 
 type RateUsecase interface {
-	RatePost(postID int64, userID int64, vote int) (int64, error)
-	GetRating(postID int64, userID int64) (rating int, userRating int, err error)
-	IsRatedBefore(postID int64, userID int64, vote int) (bool, error)
-	DeleteRateFromPost(postID int64, userID int64, vote int) error
-	DeleteRatesByPostID(postID int64) (err error)
+	Vote(ctx context.Context, post *models.Post, userID int64, vote int) (cancelled bool, err error)
+	RatePost(ctx context.Context, postID int64, userID int64, vote int) (int64, error)
+	GetRating(ctx context.Context, postID int64, userID int64) (rating int, userRating int, err error)
+	IsRatedBefore(ctx context.Context, postID int64, userID int64, vote int) (bool, error)
+	DeleteRateFromPost(ctx context.Context, postID int64, userID int64, vote int) error
+	DeleteRatesByPostID(ctx context.Context, postID int64) (err error)
 }
 
 type CommentUsecase interface {
-	Create(userID int64, comment *models.Comment) (newComment *models.Comment, status int, err error)
-	GetCommentsByPostID(userID, postID int64) (comments []models.Comment, status int, err error)
-	GetCommentsByAuthorID(userID, authorID int64) (comments []models.Comment, status int, err error)
-	Update(comment *models.Comment) (editedComment *models.Comment, status int, err error)
-	GetCommentByID(userID, commentID int64) (comment *models.Comment, status int, err error)
-	Delete(commentID int64) (err error)
-	DeleteCommentsByPostID(postID int64) (err error)
+	Create(ctx context.Context, userID int64, comment *models.Comment) (newComment *models.Comment, status int, err error)
+	GetCommentsByPostID(ctx context.Context, userID, postID int64) (comments []models.Comment, status int, err error)
+	GetCommentsByAuthorID(ctx context.Context, userID, authorID int64) (comments []models.Comment, status int, err error)
+	Update(ctx context.Context, comment *models.Comment) (editedComment *models.Comment, status int, err error)
+	GetCommentByID(ctx context.Context, userID, commentID int64) (comment *models.Comment, status int, err error)
+	Delete(ctx context.Context, commentID int64) (err error)
+	Restore(ctx context.Context, commentID int64) (err error)
+	GetDeletedComments(ctx context.Context) (comments []models.Comment, status int, err error)
+	DeleteCommentsByPostID(ctx context.Context, postID int64) (err error)
 }
 
 type NotificationUsecase interface {
-	Create(notification *models.Notification) (newNotification *models.Notification, status int, err error)
-	DeleteAllNotifications(receiverID int64) (err error)
-	GetAllNotifications(receiverID int64) (notifications []models.Notification, status int, err error)
-	DeleteNotificationsByPostID(postID int64) (err error)
-	DeleteNotificationsByRateID(rateID int64) (err error)
-	DeleteNotificationsByCommentID(commentID int64) (err error)
-	DeleteNotificationsByCommentRateID(commentRateID int64) (err error)
+	Create(ctx context.Context, notification *models.Notification) (newNotification *models.Notification, status int, err error)
+	DeleteAllNotifications(ctx context.Context, receiverID int64) (err error)
+	GetAllNotifications(ctx context.Context, receiverID int64) (notifications []models.Notification, status int, err error)
+	DeleteNotificationsByPostID(ctx context.Context, postID int64) (err error)
+	DeleteNotificationsByRateID(ctx context.Context, rateID int64) (err error)
+	DeleteNotificationsByCommentID(ctx context.Context, commentID int64) (err error)
+	DeleteNotificationsByCommentRateID(ctx context.Context, commentRateID int64) (err error)
 }
 
 type RateCommentUsecase interface {
-	RateComment(commentID int64, userID int64, vote int, postID int64) (int64, error)
-	GetCommentRating(commentID int64, userID int64) (rating int, userRating int, err error)
-	IsRatedBefore(commentID int64, userID int64, vote int) (bool, error)
-	DeleteRateFromComment(commentID int64, userID int64, vote int) error
-	DeleteRatesByCommentID(commentID int64) (err error)
-	DeleteCommentsRateByPostID(postID int64) (err error)
+	Vote(ctx context.Context, comment *models.Comment, userID int64, vote int) (cancelled bool, err error)
+	RateComment(ctx context.Context, commentID int64, userID int64, vote int, postID int64) (int64, error)
+	GetCommentRating(ctx context.Context, commentID int64, userID int64) (rating int, userRating int, err error)
+	IsRatedBefore(ctx context.Context, commentID int64, userID int64, vote int) (bool, error)
+	DeleteRateFromComment(ctx context.Context, commentID int64, userID int64, vote int) error
+	DeleteRatesByCommentID(ctx context.Context, commentID int64) (err error)
+	DeleteCommentsRateByPostID(ctx context.Context, postID int64) (err error)
+}
+
+type UploadUsecase interface {
+	Create(ctx context.Context, upload *models.Upload) (err error)
+	GetByFileName(ctx context.Context, fileName string) (upload *models.Upload, status int, err error)
+	CanAttach(ctx context.Context, postID int64, userID int64, fileName string) (status int, err error)
+	AttachToPost(ctx context.Context, postID int64, userID int64, fileName string) (status int, err error)
+	Delete(ctx context.Context, fileName string) (err error)
+}
+
+type AttachmentUsecase interface {
+	Create(ctx context.Context, attachment *models.Attachment, fileName string) (newBlob bool, status int, err error)
+	GetAttachmentsByPostID(ctx context.Context, postID int64) (attachments []models.Attachment, err error)
+	CanSetPostAttachments(ctx context.Context, postID int64, userID int64, attachments []models.InputAttachment) (status int, err error)
+	SetPostAttachments(ctx context.Context, postID int64, userID int64, attachments []models.InputAttachment) (status int, err error)
+	Delete(ctx context.Context, attachmentID int64) (err error)
+}
+
+type TagUsecase interface {
+	CanUseTags(names []string) (status int, err error)
+	SetPostTags(ctx context.Context, postID int64, names []string) (tagNames []string, status int, err error)
+	GetTagIDs(ctx context.Context, names []string) (tagIDs []int64, status int, err error)
+	GetTagSuggestions(ctx context.Context, prefix string) (suggestions []models.Tag, status int, err error)
+	GetPopularTags(ctx context.Context, limit int) (popular []models.Tag, status int, err error)
+	GetAllSynonyms(ctx context.Context) (synonyms []models.TagSynonym, status int, err error)
+	AddSynonym(ctx context.Context, synonym *models.TagSynonym) (status int, err error)
+	DeleteSynonym(ctx context.Context, name string) (status int, err error)
 }
 
 type BanUsecase interface {
-	GetAllCategories() (categories []models.Category, status int, err error)
-	Update(postID int64, categories []string) (err error)
-	DeleteFromPostCategoriesBridge(postID int64) (err error)
-	DeleteCategoryByID(categoryID int64) (err error)
-	CreateNewCategory(category string) (err error)
+	GetAllCategories(ctx context.Context) (categories []models.Category, status int, err error)
+	Update(ctx context.Context, postID int64, categories []string) (err error)
+	DeleteFromPostCategoriesBridge(ctx context.Context, postID int64) (err error)
+	DeleteCategoryByID(ctx context.Context, categoryID int64) (err error)
+	CreateNewCategory(ctx context.Context, category string) (err error)
 }
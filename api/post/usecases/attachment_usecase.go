@@ -0,0 +1,79 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/innovember/forum/api/models"
+	"github.com/innovember/forum/api/post"
+)
+
+type AttachmentUsecase struct {
+	attachmentRepo post.AttachmentRepository
+	maxPerPost     int
+}
+
+func NewAttachmentUsecase(repo post.AttachmentRepository, maxPerPost int) post.AttachmentUsecase {
+	return &AttachmentUsecase{attachmentRepo: repo, maxPerPost: maxPerPost}
+}
+
+func (au *AttachmentUsecase) Create(ctx context.Context, attachment *models.Attachment, fileName string) (newBlob bool, status int, err error) {
+	if newBlob, status, err = au.attachmentRepo.Create(ctx, attachment, fileName); err != nil {
+		return false, status, err
+	}
+	return newBlob, status, nil
+}
+
+func (au *AttachmentUsecase) GetAttachmentsByPostID(ctx context.Context, postID int64) (attachments []models.Attachment, err error) {
+	if attachments, err = au.attachmentRepo.GetAttachmentsByPostID(ctx, postID); err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// CanSetPostAttachments reports whether userID may give postID, 0 for a
+// post not created yet, these attachments, before anything is written.
+func (au *AttachmentUsecase) CanSetPostAttachments(ctx context.Context, postID int64, userID int64, attachments []models.InputAttachment) (status int, err error) {
+	var (
+		attachment *models.Attachment
+		seen       = make(map[int64]bool, len(attachments))
+	)
+	if len(attachments) > au.maxPerPost {
+		return http.StatusBadRequest, fmt.Errorf("too many attachments, limit is %d", au.maxPerPost)
+	}
+	for _, input := range attachments {
+		if seen[input.ID] {
+			return http.StatusBadRequest, errors.New("attachment listed twice")
+		}
+		seen[input.ID] = true
+		if attachment, status, err = au.attachmentRepo.GetAttachmentByID(ctx, input.ID); err != nil {
+			if status == http.StatusNotFound {
+				return http.StatusBadRequest, errors.New("attachment has not been uploaded")
+			}
+			return status, err
+		}
+		if attachment.OwnerID != userID {
+			return http.StatusForbidden, errors.New("can't use another user's attachment")
+		}
+		if attachment.PostID != 0 && attachment.PostID != postID {
+			return http.StatusConflict, errors.New("attachment is already used by another post")
+		}
+	}
+	return http.StatusOK, nil
+}
+
+func (au *AttachmentUsecase) SetPostAttachments(ctx context.Context, postID int64, userID int64, attachments []models.InputAttachment) (status int, err error) {
+	if status, err = au.attachmentRepo.SetPostAttachments(ctx, postID, userID, attachments); err != nil {
+		return status, err
+	}
+	return status, nil
+}
+
+func (au *AttachmentUsecase) Delete(ctx context.Context, attachmentID int64) (err error) {
+	if err = au.attachmentRepo.Delete(ctx, attachmentID); err != nil {
+		return err
+	}
+	return nil
+}
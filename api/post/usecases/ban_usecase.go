@@ -1,6 +1,7 @@
 package usecases
 
 import (
+	"context"
 	"github.com/innovember/forum/api/models"
 	"github.com/innovember/forum/api/post"
 )
@@ -13,36 +14,36 @@ func NewBanUsecase(repo post.BanRepository) post.BanUsecase {
 	return &BanUsecase{banRepo: repo}
 }
 
-func (bu *BanUsecase) GetAllCategories() (categories []models.Category, status int, err error) {
-	if categories, status, err = bu.banRepo.GetAllCategories(); err != nil {
+func (bu *BanUsecase) GetAllCategories(ctx context.Context) (categories []models.Category, status int, err error) {
+	if categories, status, err = bu.banRepo.GetAllCategories(ctx); err != nil {
 		return nil, status, err
 	}
 	return categories, status, nil
 }
 
-func (bu *BanUsecase) Update(postID int64, categories []string) (err error) {
-	if err = bu.banRepo.Update(postID, categories); err != nil {
+func (bu *BanUsecase) Update(ctx context.Context, postID int64, categories []string) (err error) {
+	if err = bu.banRepo.Update(ctx, postID, categories); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (bu *BanUsecase) DeleteFromPostCategoriesBridge(postID int64) (err error) {
-	if err = bu.banRepo.DeleteFromPostCategoriesBridge(postID); err != nil {
+func (bu *BanUsecase) DeleteFromPostCategoriesBridge(ctx context.Context, postID int64) (err error) {
+	if err = bu.banRepo.DeleteFromPostCategoriesBridge(ctx, postID); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (bu *BanUsecase) DeleteCategoryByID(categoryID int64) (err error) {
-	if err = bu.banRepo.DeleteCategoryByID(categoryID); err != nil {
+func (bu *BanUsecase) DeleteCategoryByID(ctx context.Context, categoryID int64) (err error) {
+	if err = bu.banRepo.DeleteCategoryByID(ctx, categoryID); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (bu *BanUsecase) CreateNewCategory(category string) (err error) {
-	if err = bu.banRepo.CreateNewCategory(category); err != nil {
+func (bu *BanUsecase) CreateNewCategory(ctx context.Context, category string) (err error) {
+	if err = bu.banRepo.CreateNewCategory(ctx, category); err != nil {
 		return err
 	}
 	return nil
@@ -1,8 +1,17 @@
 package usecases
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/innovember/forum/api/config"
 	"github.com/innovember/forum/api/models"
 	"github.com/innovember/forum/api/post"
+	"github.com/innovember/forum/api/services/slug"
 )
 
 type CategoryUsecase struct {
@@ -13,37 +22,253 @@ func NewCategoryUsecase(repo post.CategoryRepository) post.CategoryUsecase {
 	return &CategoryUsecase{categoryRepo: repo}
 }
 
-func (cu *CategoryUsecase) GetAllCategories() (categories []models.Category, status int, err error) {
-	if categories, status, err = cu.categoryRepo.GetAllCategories(); err != nil {
+func (cu *CategoryUsecase) GetAllCategories(ctx context.Context) (categories []models.Category, status int, err error) {
+	if categories, status, err = cu.categoryRepo.GetAllCategories(ctx); err != nil {
 		return nil, status, err
 	}
 	return categories, status, nil
 }
 
-func (cu *CategoryUsecase) Update(postID int64, categories []string) (err error) {
-	if err = cu.categoryRepo.Update(postID, categories); err != nil {
+// CanUseCategories reports whether user may file a post under categoryIDs,
+// which must all exist. Locked categories take new posts from moderators
+// and admins only; a post already in one, listed in current, may stay.
+func (cu *CategoryUsecase) CanUseCategories(ctx context.Context, user *models.User, categoryIDs []int64, current []models.Category) (status int, err error) {
+	var (
+		category *models.Category
+		seen     = map[int64]bool{}
+	)
+	for _, categoryID := range categoryIDs {
+		if seen[categoryID] {
+			return http.StatusBadRequest, fmt.Errorf("category %d is listed twice", categoryID)
+		}
+		seen[categoryID] = true
+		if category, err = cu.categoryRepo.GetCategoryByID(ctx, categoryID); err != nil {
+			if err == sql.ErrNoRows {
+				return http.StatusBadRequest, fmt.Errorf("category %d doesn't exist", categoryID)
+			}
+			return http.StatusInternalServerError, err
+		}
+		if !category.Locked || user.Role >= config.RoleModerator || hasCategory(current, categoryID) {
+			continue
+		}
+		return http.StatusForbidden, fmt.Errorf("category %q is locked", category.Name)
+	}
+	return http.StatusOK, nil
+}
+
+func hasCategory(categories []models.Category, categoryID int64) bool {
+	for _, category := range categories {
+		if int64(category.ID) == categoryID {
+			return true
+		}
+	}
+	return false
+}
+
+func (cu *CategoryUsecase) Update(ctx context.Context, postID int64, categoryIDs []int64) (err error) {
+	if err = cu.categoryRepo.Update(ctx, postID, categoryIDs); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (cu *CategoryUsecase) DeleteFromPostCategoriesBridge(postID int64) (err error) {
-	if err = cu.categoryRepo.DeleteFromPostCategoriesBridge(postID); err != nil {
+func (cu *CategoryUsecase) DeleteFromPostCategoriesBridge(ctx context.Context, postID int64) (err error) {
+	if err = cu.categoryRepo.DeleteFromPostCategoriesBridge(ctx, postID); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (cu *CategoryUsecase) DeleteCategoryByID(categoryID int64) (err error) {
-	if err = cu.categoryRepo.DeleteCategoryByID(categoryID); err != nil {
+// DeleteCategoryByID deletes a category without posts; one with posts is
+// merged into another instead, so that they keep a category.
+func (cu *CategoryUsecase) DeleteCategoryByID(ctx context.Context, categoryID int64) (status int, err error) {
+	var number int64
+	if status, err = cu.exists(ctx, categoryID); err != nil {
+		return status, err
+	}
+	if number, err = cu.categoryRepo.GetPostsNumberByCategoryID(ctx, categoryID); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if number > 0 {
+		return http.StatusConflict, fmt.Errorf("category has %d posts, merge it into another category first", number)
+	}
+	if err = cu.categoryRepo.DeleteCategoryByID(ctx, categoryID); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func (cu *CategoryUsecase) CreateNewCategory(ctx context.Context, category *models.Category) (status int, err error) {
+	if status, err = cu.validate(ctx, category); err != nil {
+		return status, err
+	}
+	if err = cu.categoryRepo.CreateNewCategory(ctx, category); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusCreated, nil
+}
+
+func (cu *CategoryUsecase) UpdateCategory(ctx context.Context, category *models.Category) (status int, err error) {
+	if status, err = cu.exists(ctx, int64(category.ID)); err != nil {
+		return status, err
+	}
+	if status, err = cu.validate(ctx, category); err != nil {
+		return status, err
+	}
+	if err = cu.categoryRepo.UpdateCategory(ctx, category); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// RenameCategory gives a category the name of category, keeping its other
+// settings, slug included, and fills category with them.
+func (cu *CategoryUsecase) RenameCategory(ctx context.Context, category *models.Category) (status int, err error) {
+	var existing *models.Category
+	if existing, err = cu.categoryRepo.GetCategoryByID(ctx, int64(category.ID)); err != nil {
+		if err == sql.ErrNoRows {
+			return http.StatusNotFound, errors.New("category doesn't exist")
+		}
+		return http.StatusInternalServerError, err
+	}
+	existing.Name = category.Name
+	if status, err = cu.validate(ctx, existing); err != nil {
+		return status, err
+	}
+	if err = cu.categoryRepo.UpdateCategory(ctx, existing); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	*category = *existing
+	return http.StatusOK, nil
+}
+
+// MergeCategories files the posts of the source category under the target
+// and deletes the source. The target can't be under the source, which
+// would leave it its own parent.
+func (cu *CategoryUsecase) MergeCategories(ctx context.Context, sourceID int64, targetID int64) (status int, err error) {
+	var category *models.Category
+	if sourceID == targetID {
+		return http.StatusBadRequest, errors.New("a category can't be merged into itself")
+	}
+	if status, err = cu.exists(ctx, sourceID); err != nil {
+		return status, err
+	}
+	for parentID := targetID; parentID != 0; parentID = int64(category.ParentID) {
+		if parentID == sourceID {
+			return http.StatusBadRequest, errors.New("a category can't be merged into its subcategories")
+		}
+		if category, err = cu.categoryRepo.GetCategoryByID(ctx, parentID); err != nil {
+			if err == sql.ErrNoRows {
+				return http.StatusNotFound, errors.New("category doesn't exist")
+			}
+			return http.StatusInternalServerError, err
+		}
+	}
+	if err = cu.categoryRepo.MergeCategories(ctx, sourceID, targetID); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// AssignModerator scopes a moderator to a category. Once assigned to any
+// category, the moderator approves and bans only the posts in those, and
+// none after being unassigned from all of them, until made global again.
+func (cu *CategoryUsecase) AssignModerator(ctx context.Context, categoryID int64, moderatorID int64) (status int, err error) {
+	if status, err = cu.exists(ctx, categoryID); err != nil {
+		return status, err
+	}
+	if err = cu.categoryRepo.AssignModerator(ctx, categoryID, moderatorID); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func (cu *CategoryUsecase) UnassignModerator(ctx context.Context, categoryID int64, moderatorID int64) (err error) {
+	if err = cu.categoryRepo.UnassignModerator(ctx, categoryID, moderatorID); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (cu *CategoryUsecase) CreateNewCategory(category string) (err error) {
-	if err = cu.categoryRepo.CreateNewCategory(category); err != nil {
+// SetModeratorScoped makes a moderator global, or scoped to the categories
+// assigned.
+func (cu *CategoryUsecase) SetModeratorScoped(ctx context.Context, moderatorID int64, scoped bool) (err error) {
+	if err = cu.categoryRepo.SetModeratorScoped(ctx, moderatorID, scoped); err != nil {
 		return err
 	}
 	return nil
 }
+
+func (cu *CategoryUsecase) GetModerators(ctx context.Context, categoryID int64) (moderators []models.User, status int, err error) {
+	if status, err = cu.exists(ctx, categoryID); err != nil {
+		return nil, status, err
+	}
+	if moderators, err = cu.categoryRepo.GetModerators(ctx, categoryID); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return moderators, http.StatusOK, nil
+}
+
+func (cu *CategoryUsecase) CanModeratePost(ctx context.Context, moderatorID int64, postID int64) (can bool, err error) {
+	if can, err = cu.categoryRepo.CanModeratePost(ctx, moderatorID, postID); err != nil {
+		return false, err
+	}
+	return can, nil
+}
+
+func (cu *CategoryUsecase) exists(ctx context.Context, categoryID int64) (status int, err error) {
+	if _, err = cu.categoryRepo.GetCategoryByID(ctx, categoryID); err != nil {
+		if err == sql.ErrNoRows {
+			return http.StatusNotFound, errors.New("category doesn't exist")
+		}
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// validate normalizes a new or edited category, giving it a slug made from
+// its name unless it has one, and checks that its name and slug are unique
+// and that its parent exists and isn't itself or one of its descendants.
+func (cu *CategoryUsecase) validate(ctx context.Context, category *models.Category) (status int, err error) {
+	var (
+		id       int64
+		existing *models.Category
+		parent   *models.Category
+	)
+	category.Name = strings.TrimSpace(category.Name)
+	category.Description = strings.TrimSpace(category.Description)
+	category.Slug = strings.TrimSpace(category.Slug)
+	if category.Name == "" {
+		return http.StatusBadRequest, errors.New("category name is required")
+	}
+	if category.Slug == "" {
+		category.Slug = slug.Make(category.Name)
+	}
+	if !slug.Valid(category.Slug) {
+		return http.StatusBadRequest, errors.New("category slug must be lowercase letters and digits separated by single dashes")
+	}
+	if id, err = cu.categoryRepo.GetCategoryIDByName(ctx, category.Name); err == nil && id != int64(category.ID) {
+		return http.StatusConflict, fmt.Errorf("category %q already exists", category.Name)
+	} else if err != nil && err != sql.ErrNoRows {
+		return http.StatusInternalServerError, err
+	}
+	if existing, err = cu.categoryRepo.GetCategoryBySlug(ctx, category.Slug); err == nil && existing.ID != category.ID {
+		return http.StatusConflict, fmt.Errorf("slug %q is taken by category %q", category.Slug, existing.Name)
+	} else if err != nil && err != sql.ErrNoRows {
+		return http.StatusInternalServerError, err
+	}
+	// walking up from the parent must reach the top without meeting the
+	// category
+	for parentID := category.ParentID; parentID != 0; parentID = parent.ParentID {
+		if category.ID != 0 && parentID == category.ID {
+			return http.StatusBadRequest, errors.New("a category can't be nested under itself or its subcategories")
+		}
+		if parent, err = cu.categoryRepo.GetCategoryByID(ctx, int64(parentID)); err != nil {
+			if err == sql.ErrNoRows {
+				return http.StatusBadRequest, errors.New("parent category doesn't exist")
+			}
+			return http.StatusInternalServerError, err
+		}
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,262 @@
+package usecases
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/models"
+	"github.com/innovember/forum/api/post/repository"
+)
+
+// seedCategories stores general > news > local, plus a locked top-level
+// announcements category.
+func seedCategories(t *testing.T, conn *sql.DB) {
+	t.Helper()
+	if _, err := conn.Exec(`INSERT INTO categories(id, name, slug, parent_id, locked)
+		VALUES (1, 'General', 'general', 0, 0),
+			(2, 'News', 'news', 1, 0),
+			(3, 'Local', 'local', 2, 0),
+			(4, 'Announcements', 'announcements', 0, 1)`); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateNewCategory(t *testing.T) {
+	tests := []struct {
+		name     string
+		category models.Category
+		want     int
+		wantSlug string
+	}{
+		{"slug from name", models.Category{Name: "  Off Topic  "}, http.StatusCreated, "off-topic"},
+		{"own slug", models.Category{Name: "Help", Slug: "support"}, http.StatusCreated, "support"},
+		{"nested", models.Category{Name: "Sports", ParentID: 2}, http.StatusCreated, "sports"},
+		{"no name", models.Category{Name: "  "}, http.StatusBadRequest, ""},
+		{"invalid slug", models.Category{Name: "Help", Slug: "Help Me"}, http.StatusBadRequest, ""},
+		{"name taken", models.Category{Name: "News"}, http.StatusConflict, ""},
+		{"slug taken", models.Category{Name: "Latest", Slug: "news"}, http.StatusConflict, ""},
+		{"missing parent", models.Category{Name: "Sports", ParentID: 99}, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := testDB(t)
+			seedCategories(t, conn)
+			cu := NewCategoryUsecase(repository.NewCategoryDBRepository(conn))
+			category := tt.category
+			status, err := cu.CreateNewCategory(context.Background(), &category)
+			if status != tt.want {
+				t.Fatalf("status = %d, %v, want %d", status, err, tt.want)
+			}
+			if tt.want == http.StatusCreated && category.Slug != tt.wantSlug {
+				t.Errorf("slug = %q, want %q", category.Slug, tt.wantSlug)
+			}
+			if n := count(t, conn, `SELECT COUNT(*) FROM categories`); (n == 5) != (tt.want == http.StatusCreated) {
+				t.Errorf("categories = %d after status %d", n, status)
+			}
+		})
+	}
+}
+
+func TestUpdateCategory(t *testing.T) {
+	tests := []struct {
+		name       string
+		category   models.Category
+		want       int
+		wantParent int // of the category afterwards
+	}{
+		{"move", models.Category{ID: 3, Name: "Local", Slug: "local", ParentID: 1}, http.StatusOK, 1},
+		{"keep own name and slug", models.Category{ID: 2, Name: "News", Slug: "news", ParentID: 1, Description: "What's new"}, http.StatusOK, 1},
+		{"under itself", models.Category{ID: 2, Name: "News", Slug: "news", ParentID: 2}, http.StatusBadRequest, 1},
+		{"under a descendant", models.Category{ID: 1, Name: "General", Slug: "general", ParentID: 3}, http.StatusBadRequest, 0},
+		{"another's name", models.Category{ID: 3, Name: "General", Slug: "local", ParentID: 1}, http.StatusConflict, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := testDB(t)
+			seedCategories(t, conn)
+			cu := NewCategoryUsecase(repository.NewCategoryDBRepository(conn))
+			category := tt.category
+			if status, err := cu.UpdateCategory(context.Background(), &category); status != tt.want {
+				t.Fatalf("status = %d, %v, want %d", status, err, tt.want)
+			}
+			if parentID := count(t, conn, `SELECT parent_id FROM categories WHERE id = ?`, tt.category.ID); parentID != tt.wantParent {
+				t.Errorf("parent = %d, want %d", parentID, tt.wantParent)
+			}
+		})
+	}
+	conn := testDB(t)
+	cu := NewCategoryUsecase(repository.NewCategoryDBRepository(conn))
+	if status, _ := cu.UpdateCategory(context.Background(), &models.Category{ID: 99, Name: "Sports"}); status != http.StatusNotFound {
+		t.Errorf("missing category: status = %d, want 404", status)
+	}
+}
+
+func TestCanUseCategories(t *testing.T) {
+	member := &models.User{ID: 5, Role: config.RoleUser}
+	moderator := &models.User{ID: 6, Role: config.RoleModerator}
+	tests := []struct {
+		name        string
+		user        *models.User
+		categoryIDs []int64
+		current     []models.Category
+		want        int
+	}{
+		{"existing", member, []int64{1, 3}, nil, http.StatusOK},
+		{"none", member, nil, nil, http.StatusOK},
+		{"unknown", member, []int64{1, 99}, nil, http.StatusBadRequest},
+		{"listed twice", member, []int64{1, 1}, nil, http.StatusBadRequest},
+		{"locked", member, []int64{4}, nil, http.StatusForbidden},
+		{"locked for a moderator", moderator, []int64{4}, nil, http.StatusOK},
+		{"already in the locked one", member, []int64{1, 4}, []models.Category{{ID: 4}}, http.StatusOK},
+	}
+	conn := testDB(t)
+	seedCategories(t, conn)
+	cu := NewCategoryUsecase(repository.NewCategoryDBRepository(conn))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, err := cu.CanUseCategories(context.Background(), tt.user, tt.categoryIDs, tt.current); status != tt.want {
+				t.Errorf("status = %d, %v, want %d", status, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeCategories(t *testing.T) {
+	tests := []struct {
+		name            string
+		source, target  int64
+		want            int
+		wantTargetPosts int
+		wantLocalParent int
+		wantModerators  int
+	}{
+		// post 10 is in both, so it's filed under the target once
+		{"into a sibling", 2, 4, http.StatusOK, 3, 4, 1},
+		{"into its parent", 2, 1, http.StatusOK, 2, 1, 1},
+		{"into itself", 2, 2, http.StatusBadRequest, 0, 2, 0},
+		{"into a subcategory", 1, 3, http.StatusBadRequest, 0, 2, 0},
+		{"missing source", 99, 1, http.StatusNotFound, 0, 2, 0},
+		{"missing target", 2, 99, http.StatusNotFound, 0, 2, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := testDB(t)
+			seedCategories(t, conn)
+			if _, err := conn.Exec(`INSERT INTO posts_categories_bridge(post_id, category_id)
+				VALUES (10, 2), (11, 2), (10, 4), (12, 4), (10, 1)`); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := conn.Exec(`INSERT INTO category_moderators(category_id, user_id) VALUES (2, 6)`); err != nil {
+				t.Fatal(err)
+			}
+			cu := NewCategoryUsecase(repository.NewCategoryDBRepository(conn))
+			if status, err := cu.MergeCategories(context.Background(), tt.source, tt.target); status != tt.want {
+				t.Fatalf("status = %d, %v, want %d", status, err, tt.want)
+			}
+			if tt.want != http.StatusOK {
+				if n := count(t, conn, `SELECT COUNT(*) FROM posts_categories_bridge`); n != 5 {
+					t.Errorf("bridge rows = %d after a refused merge, want 5", n)
+				}
+				return
+			}
+			if n := count(t, conn, `SELECT COUNT(*) FROM categories WHERE id = ?`, tt.source); n != 0 {
+				t.Error("source category kept")
+			}
+			if n := count(t, conn, `SELECT COUNT(*) FROM posts_categories_bridge WHERE category_id = ?`, tt.source); n != 0 {
+				t.Errorf("%d posts left in the source", n)
+			}
+			if n := count(t, conn, `SELECT COUNT(*) FROM posts_categories_bridge WHERE category_id = ?`, tt.target); n != tt.wantTargetPosts {
+				t.Errorf("target posts = %d, want %d", n, tt.wantTargetPosts)
+			}
+			if n := count(t, conn, `SELECT parent_id FROM categories WHERE id = 3`); n != tt.wantLocalParent {
+				t.Errorf("subcategory parent = %d, want %d", n, tt.wantLocalParent)
+			}
+			if n := count(t, conn, `SELECT COUNT(*) FROM category_moderators WHERE category_id = ?`, tt.target); n != tt.wantModerators {
+				t.Errorf("target moderators = %d, want %d", n, tt.wantModerators)
+			}
+		})
+	}
+}
+
+func TestRenameCategory(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      int
+		newName string
+		want    int
+	}{
+		{"rename", 2, "Headlines", http.StatusOK},
+		{"name taken", 2, "General", http.StatusConflict},
+		{"empty", 2, " ", http.StatusBadRequest},
+		{"missing", 99, "Headlines", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := testDB(t)
+			seedCategories(t, conn)
+			cu := NewCategoryUsecase(repository.NewCategoryDBRepository(conn))
+			category := models.Category{ID: tt.id, Name: tt.newName}
+			if status, err := cu.RenameCategory(context.Background(), &category); status != tt.want {
+				t.Fatalf("status = %d, %v, want %d", status, err, tt.want)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			// the slug and the rest of the settings are kept
+			if category.Name != tt.newName || category.Slug != "news" || category.ParentID != 1 {
+				t.Errorf("category = %+v", category)
+			}
+		})
+	}
+}
+
+func TestModeratorScope(t *testing.T) {
+	conn := testDB(t)
+	seedCategories(t, conn)
+	if _, err := conn.Exec(`INSERT INTO users(id, username, role) VALUES (6, 'mod', 1)`); err != nil {
+		t.Fatal(err)
+	}
+	// post 10 is in news, post 11 in local
+	if _, err := conn.Exec(`INSERT INTO posts_categories_bridge(post_id, category_id) VALUES (10, 2), (11, 3)`); err != nil {
+		t.Fatal(err)
+	}
+	cu := NewCategoryUsecase(repository.NewCategoryDBRepository(conn))
+	ctx := context.Background()
+	steps := []struct {
+		name           string
+		do             func() error
+		want10, want11 bool
+	}{
+		{"global", func() error { return nil }, true, true},
+		{"assigned to news", func() error {
+			_, err := cu.AssignModerator(ctx, 2, 6)
+			return err
+		}, true, false},
+		{"assigned to local too", func() error {
+			_, err := cu.AssignModerator(ctx, 3, 6)
+			return err
+		}, true, true},
+		{"unassigned from news", func() error { return cu.UnassignModerator(ctx, 2, 6) }, false, true},
+		{"unassigned from all", func() error { return cu.UnassignModerator(ctx, 3, 6) }, false, false},
+		{"made global", func() error { return cu.SetModeratorScoped(ctx, 6, false) }, true, true},
+	}
+	for _, step := range steps {
+		if err := step.do(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		for postID, want := range map[int64]bool{10: step.want10, 11: step.want11} {
+			if can, err := cu.CanModeratePost(ctx, 6, postID); err != nil || can != want {
+				t.Errorf("%s: CanModeratePost(%d) = %v, %v, want %v", step.name, postID, can, err, want)
+			}
+		}
+	}
+	if status, _ := cu.AssignModerator(ctx, 99, 6); status != http.StatusNotFound {
+		t.Errorf("assigning to a missing category: status = %d, want 404", status)
+	}
+	if can, err := cu.CanModeratePost(ctx, 99, 10); can || err != nil {
+		t.Errorf("unknown moderator: CanModeratePost = %v, %v", can, err)
+	}
+}
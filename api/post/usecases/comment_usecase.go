@@ -1,61 +1,112 @@
 package usecases
 
 import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/innovember/forum/api/db"
 	"github.com/innovember/forum/api/models"
 	"github.com/innovember/forum/api/post"
+	"github.com/innovember/forum/api/services/mentions"
 )
 
 type CommentUsecase struct {
 	commentRepo post.CommentRepository
+	mentionRepo post.MentionRepository
+	uow         db.UnitOfWork
 }
 
-func NewCommentUsecase(repo post.CommentRepository) post.CommentUsecase {
-	return &CommentUsecase{commentRepo: repo}
+func NewCommentUsecase(repo post.CommentRepository, mentionRepo post.MentionRepository,
+	uow db.UnitOfWork) post.CommentUsecase {
+	return &CommentUsecase{commentRepo: repo, mentionRepo: mentionRepo, uow: uow}
 }
-func (cu *CommentUsecase) Create(userID int64, comment *models.Comment) (newComment *models.Comment, status int, err error) {
-	if newComment, status, err = cu.commentRepo.Create(userID, comment); err != nil {
+
+// Create stores the comment with its @mentions, notifying the users it
+// mentions, in one transaction.
+func (cu *CommentUsecase) Create(ctx context.Context, userID int64, comment *models.Comment) (newComment *models.Comment, status int, err error) {
+	status = http.StatusInternalServerError
+	err = cu.uow.Do(ctx, func(tx *sql.Tx) (err error) {
+		if newComment, status, err = cu.commentRepo.CreateTx(ctx, tx, userID, comment); err != nil {
+			return err
+		}
+		if err = cu.mentionRepo.SetMentionsTx(ctx, tx, newComment.AuthorID, newComment.PostID, newComment.ID,
+			mentions.Parse(newComment.Content)); err != nil {
+			status = http.StatusInternalServerError
+			return err
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, status, err
 	}
-	return newComment, status, err
+	return newComment, status, nil
 }
-func (cu *CommentUsecase) GetCommentsByPostID(userID, postID int64) (comments []models.Comment, status int, err error) {
-	if comments, status, err = cu.commentRepo.GetCommentsByPostID(userID, postID); err != nil {
+func (cu *CommentUsecase) GetCommentsByPostID(ctx context.Context, userID, postID int64) (comments []models.Comment, status int, err error) {
+	if comments, status, err = cu.commentRepo.GetCommentsByPostID(ctx, userID, postID); err != nil {
 		return nil, status, err
 	}
 	return comments, status, err
 }
 
-func (cu *CommentUsecase) GetCommentsByAuthorID(userID, authorID int64) (comments []models.Comment, status int, err error) {
-	if comments, status, err = cu.commentRepo.GetCommentsByAuthorID(userID, authorID); err != nil {
+func (cu *CommentUsecase) GetCommentsByAuthorID(ctx context.Context, userID, authorID int64) (comments []models.Comment, status int, err error) {
+	if comments, status, err = cu.commentRepo.GetCommentsByAuthorID(ctx, userID, authorID); err != nil {
 		return nil, status, err
 	}
 	return comments, status, err
 }
 
-func (cu *CommentUsecase) Update(comment *models.Comment) (editedComment *models.Comment, status int, err error) {
-	if editedComment, status, err = cu.commentRepo.Update(comment); err != nil {
+// Update edits the comment and its @mentions in one transaction.
+func (cu *CommentUsecase) Update(ctx context.Context, comment *models.Comment) (editedComment *models.Comment, status int, err error) {
+	status = http.StatusInternalServerError
+	err = cu.uow.Do(ctx, func(tx *sql.Tx) (err error) {
+		if editedComment, status, err = cu.commentRepo.UpdateTx(ctx, tx, comment); err != nil {
+			return err
+		}
+		if err = cu.mentionRepo.SetMentionsTx(ctx, tx, editedComment.AuthorID, editedComment.PostID, editedComment.ID,
+			mentions.Parse(editedComment.Content)); err != nil {
+			status = http.StatusInternalServerError
+			return err
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, status, err
 	}
-	return editedComment, status, err
+	return editedComment, status, nil
 }
 
-func (cu *CommentUsecase) GetCommentByID(userID, commentID int64) (comment *models.Comment, status int, err error) {
-	if comment, status, err = cu.commentRepo.GetCommentByID(userID, commentID); err != nil {
+func (cu *CommentUsecase) GetCommentByID(ctx context.Context, userID, commentID int64) (comment *models.Comment, status int, err error) {
+	if comment, status, err = cu.commentRepo.GetCommentByID(ctx, userID, commentID); err != nil {
 		return nil, status, err
 	}
 	return comment, status, err
 }
 
-func (cu *CommentUsecase) Delete(commentID int64) (err error) {
-	if err = cu.commentRepo.Delete(commentID); err != nil {
+func (cu *CommentUsecase) Delete(ctx context.Context, commentID int64) (err error) {
+	if err = cu.commentRepo.Delete(ctx, commentID); err != nil {
 		return err
 	}
 	return err
 }
 
-func (cu *CommentUsecase) DeleteCommentsByPostID(postID int64) (err error) {
-	if err = cu.commentRepo.DeleteCommentsByPostID(postID); err != nil {
+func (cu *CommentUsecase) DeleteCommentsByPostID(ctx context.Context, postID int64) (err error) {
+	if err = cu.commentRepo.DeleteCommentsByPostID(ctx, postID); err != nil {
 		return err
 	}
 	return err
 }
+
+func (cu *CommentUsecase) Restore(ctx context.Context, commentID int64) (err error) {
+	if err = cu.commentRepo.Restore(ctx, commentID); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cu *CommentUsecase) GetDeletedComments(ctx context.Context) (comments []models.Comment, status int, err error) {
+	if comments, status, err = cu.commentRepo.GetDeletedComments(ctx); err != nil {
+		return nil, status, err
+	}
+	return comments, status, nil
+}
@@ -1,6 +1,7 @@
 package usecases
 
 import (
+	"context"
 	"github.com/innovember/forum/api/models"
 	"github.com/innovember/forum/api/post"
 )
@@ -13,47 +14,47 @@ func NewNotificationUsecase(repo post.NotificationRepository) post.NotificationU
 	return &NotificationUsecase{notificationRepo: repo}
 }
 
-func (nu *NotificationUsecase) Create(notification *models.Notification) (newNotification *models.Notification, status int, err error) {
-	if newNotification, status, err = nu.notificationRepo.Create(notification); err != nil {
+func (nu *NotificationUsecase) Create(ctx context.Context, notification *models.Notification) (newNotification *models.Notification, status int, err error) {
+	if newNotification, status, err = nu.notificationRepo.Create(ctx, notification); err != nil {
 		return nil, status, err
 	}
 	return newNotification, status, err
 }
-func (nu *NotificationUsecase) DeleteAllNotifications(receiverID int64) (err error) {
-	if err = nu.notificationRepo.DeleteAllNotifications(receiverID); err != nil {
+func (nu *NotificationUsecase) DeleteAllNotifications(ctx context.Context, receiverID int64) (err error) {
+	if err = nu.notificationRepo.DeleteAllNotifications(ctx, receiverID); err != nil {
 		return err
 	}
 	return err
 }
-func (nu *NotificationUsecase) GetAllNotifications(receiverID int64) (notifications []models.Notification, status int, err error) {
-	if notifications, status, err = nu.notificationRepo.GetAllNotifications(receiverID); err != nil {
+func (nu *NotificationUsecase) GetAllNotifications(ctx context.Context, receiverID int64) (notifications []models.Notification, status int, err error) {
+	if notifications, status, err = nu.notificationRepo.GetAllNotifications(ctx, receiverID); err != nil {
 		return nil, status, err
 	}
 	return notifications, status, nil
 }
-func (nu *NotificationUsecase) DeleteNotificationsByPostID(postID int64) (err error) {
-	if err = nu.notificationRepo.DeleteNotificationsByPostID(postID); err != nil {
+func (nu *NotificationUsecase) DeleteNotificationsByPostID(ctx context.Context, postID int64) (err error) {
+	if err = nu.notificationRepo.DeleteNotificationsByPostID(ctx, postID); err != nil {
 		return err
 	}
 	return err
 }
 
-func (nu *NotificationUsecase) DeleteNotificationsByRateID(rateID int64) (err error) {
-	if err = nu.notificationRepo.DeleteNotificationsByRateID(rateID); err != nil {
+func (nu *NotificationUsecase) DeleteNotificationsByRateID(ctx context.Context, rateID int64) (err error) {
+	if err = nu.notificationRepo.DeleteNotificationsByRateID(ctx, rateID); err != nil {
 		return err
 	}
 	return err
 }
 
-func (nu *NotificationUsecase) DeleteNotificationsByCommentID(commentID int64) (err error) {
-	if err = nu.notificationRepo.DeleteNotificationsByCommentID(commentID); err != nil {
+func (nu *NotificationUsecase) DeleteNotificationsByCommentID(ctx context.Context, commentID int64) (err error) {
+	if err = nu.notificationRepo.DeleteNotificationsByCommentID(ctx, commentID); err != nil {
 		return err
 	}
 	return err
 }
 
-func (nu *NotificationUsecase) DeleteNotificationsByCommentRateID(commentRateID int64) (err error) {
-	if err = nu.notificationRepo.DeleteNotificationsByCommentRateID(commentRateID); err != nil {
+func (nu *NotificationUsecase) DeleteNotificationsByCommentRateID(ctx context.Context, commentRateID int64) (err error) {
+	if err = nu.notificationRepo.DeleteNotificationsByCommentRateID(ctx, commentRateID); err != nil {
 		return err
 	}
 	return err
@@ -1,96 +1,227 @@
 package usecases
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/db"
 	"github.com/innovember/forum/api/models"
 	"github.com/innovember/forum/api/post"
+	"github.com/innovember/forum/api/services/mentions"
 )
 
 type PostUsecase struct {
-	postRepo post.PostRepository
+	postRepo       post.PostRepository
+	categoryRepo   post.CategoryRepository
+	tagRepo        post.TagRepository
+	uploadRepo     post.UploadRepository
+	attachmentRepo post.AttachmentRepository
+	mentionRepo    post.MentionRepository
+	uow            db.UnitOfWork
 }
 
-func NewPostUsecase(repo post.PostRepository) post.PostUsecase {
-	return &PostUsecase{postRepo: repo}
+func NewPostUsecase(repo post.PostRepository,
+	categoryRepo post.CategoryRepository,
+	tagRepo post.TagRepository,
+	uploadRepo post.UploadRepository,
+	attachmentRepo post.AttachmentRepository,
+	mentionRepo post.MentionRepository,
+	uow db.UnitOfWork) post.PostUsecase {
+	return &PostUsecase{postRepo: repo, categoryRepo: categoryRepo, tagRepo: tagRepo,
+		uploadRepo: uploadRepo, attachmentRepo: attachmentRepo, mentionRepo: mentionRepo, uow: uow}
 }
 
-func (pu *PostUsecase) Create(post *models.Post, categories []string) (newPost *models.Post, status int, err error) {
-	if newPost, status, err = pu.postRepo.Create(post, categories); err != nil {
+// Create saves a post with its categories, tags, image, attachments and
+// mentions in one transaction. fileName is the upload to use as the image,
+// if any; the input is expected to have been checked already.
+func (pu *PostUsecase) Create(ctx context.Context, post *models.Post, input *models.InputPost, fileName string) (newPost *models.Post, status int, err error) {
+	var (
+		tagNames []string
+	)
+	if tagNames, err = normalizeTags(input.Tags, config.MaxTagsPerPost); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	status = http.StatusInternalServerError
+	err = pu.uow.Do(ctx, func(tx *sql.Tx) (err error) {
+		if newPost, status, err = pu.postRepo.CreateTx(ctx, tx, post, input.Categories); err != nil {
+			return err
+		}
+		if len(tagNames) > 0 {
+			if newPost.Tags, err = pu.tagRepo.SetPostTagsTx(ctx, tx, newPost.ID, tagNames); err != nil {
+				status = http.StatusInternalServerError
+				return err
+			}
+		}
+		if fileName != "" {
+			if status, err = pu.uploadRepo.AttachToPostTx(ctx, tx, newPost.ID, post.AuthorID, fileName); err != nil {
+				return err
+			}
+		}
+		if len(input.Attachments) > 0 {
+			if status, err = pu.attachmentRepo.SetPostAttachmentsTx(ctx, tx, newPost.ID, post.AuthorID, input.Attachments); err != nil {
+				return err
+			}
+		}
+		if err = pu.mentionRepo.SetMentionsTx(ctx, tx, post.AuthorID, newPost.ID, 0, mentions.Parse(post.Content)); err != nil {
+			status = http.StatusInternalServerError
+			return err
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, status, err
 	}
-	return newPost, status, err
+	return newPost, http.StatusCreated, nil
 }
 
-func (pu *PostUsecase) GetAllPosts(userID int64) (posts []models.Post, status int, err error) {
-	if posts, status, err = pu.postRepo.GetAllPosts(userID); err != nil {
+func (pu *PostUsecase) GetAllPosts(ctx context.Context, userID int64) (posts []models.Post, status int, err error) {
+	if posts, status, err = pu.postRepo.GetAllPosts(ctx, userID); err != nil {
 		return nil, status, err
 	}
 	return posts, status, nil
 }
 
-func (pu *PostUsecase) GetPostByID(userID int64, postID int64) (post *models.Post, status int, err error) {
-	if post, status, err = pu.postRepo.GetPostByID(userID, postID); err != nil {
+func (pu *PostUsecase) GetPostByID(ctx context.Context, userID int64, postID int64) (post *models.Post, status int, err error) {
+	if post, status, err = pu.postRepo.GetPostByID(ctx, userID, postID); err != nil {
 		return nil, status, err
 	}
 	return post, status, nil
 }
 
-func (pu *PostUsecase) GetPostsByCategories(categories []string, userID int64) (posts []models.Post, status int, err error) {
-	if posts, status, err = pu.postRepo.GetPostsByCategories(categories, userID); err != nil {
+// GetPostsByCategoriesAndTags filters posts by categories and tags, those
+// of config.MatchAll or config.MatchAny, the default being all.
+func (pu *PostUsecase) GetPostsByCategoriesAndTags(ctx context.Context, categories []string, tagIDs []int64, match string, userID int64) (posts []models.Post, status int, err error) {
+	var (
+		unique []string
+		seen   = map[string]bool{}
+	)
+	if match != "" && match != config.MatchAll && match != config.MatchAny {
+		return nil, http.StatusBadRequest, errors.New("match must be all or any")
+	}
+	if len(categories) == 0 && len(tagIDs) == 0 {
+		return nil, http.StatusBadRequest, errors.New("choose at least one category or tag")
+	}
+	for _, category := range categories {
+		if !seen[category] {
+			seen[category] = true
+			unique = append(unique, category)
+		}
+	}
+	if posts, status, err = pu.postRepo.GetPostsByCategoriesAndTags(ctx, unique, tagIDs, match == config.MatchAny, userID); err != nil {
 		return nil, status, err
 	}
 	return posts, status, nil
 }
 
-func (pu *PostUsecase) GetPostsByRating(orderBy string, userID int64) (posts []models.Post, status int, err error) {
-	if posts, status, err = pu.postRepo.GetPostsByRating(orderBy, userID); err != nil {
+func (pu *PostUsecase) GetPostsByRating(ctx context.Context, orderBy string, userID int64) (posts []models.Post, status int, err error) {
+	if posts, status, err = pu.postRepo.GetPostsByRating(ctx, orderBy, userID); err != nil {
 		return nil, status, err
 	}
 	return posts, status, nil
 }
 
-func (pu *PostUsecase) GetPostsByDate(orderBy string, userID int64) (posts []models.Post, status int, err error) {
-	if posts, status, err = pu.postRepo.GetPostsByDate(orderBy, userID); err != nil {
+func (pu *PostUsecase) GetPostsByDate(ctx context.Context, orderBy string, userID int64) (posts []models.Post, status int, err error) {
+	if posts, status, err = pu.postRepo.GetPostsByDate(ctx, orderBy, userID); err != nil {
 		return nil, status, err
 	}
 	return posts, status, nil
 }
 
-func (pu *PostUsecase) GetAllPostsByAuthorID(authorID int64, userID int64) (posts []models.Post, status int, err error) {
-	if posts, status, err = pu.postRepo.GetAllPostsByAuthorID(authorID, userID); err != nil {
+func (pu *PostUsecase) GetAllPostsByAuthorID(ctx context.Context, authorID int64, userID int64) (posts []models.Post, status int, err error) {
+	if posts, status, err = pu.postRepo.GetAllPostsByAuthorID(ctx, authorID, userID); err != nil {
 		return nil, status, err
 	}
 	return posts, status, nil
 }
-func (pu *PostUsecase) GetRatedPostsByUser(userID int64, orderBy string, requestorID int64) (posts []models.Post, status int, err error) {
-	if posts, status, err = pu.postRepo.GetRatedPostsByUser(userID, orderBy, requestorID); err != nil {
+func (pu *PostUsecase) GetRatedPostsByUser(ctx context.Context, userID int64, orderBy string, requestorID int64) (posts []models.Post, status int, err error) {
+	if posts, status, err = pu.postRepo.GetRatedPostsByUser(ctx, userID, orderBy, requestorID); err != nil {
 		return nil, status, err
 	}
 	return posts, status, nil
 }
 
-func (pu *PostUsecase) Update(post *models.Post) (editedPost *models.Post, status int, err error) {
-	if editedPost, status, err = pu.postRepo.Update(post); err != nil {
+// Update saves an edited post with its categories, tags, image, attachments
+// and mentions in one transaction. Tags or attachments left nil in the input
+// are kept as those of oldPost, and the image is only reattached when it
+// changed, the previous one being left to the orphan collector.
+func (pu *PostUsecase) Update(ctx context.Context, post *models.Post, oldPost *models.Post, input *models.InputPost, fileName string) (editedPost *models.Post, status int, err error) {
+	var (
+		tagNames []string
+	)
+	if tagNames, err = normalizeTags(input.Tags, config.MaxTagsPerPost); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	post.Tags = oldPost.Tags
+	status = http.StatusInternalServerError
+	err = pu.uow.Do(ctx, func(tx *sql.Tx) (err error) {
+		if err = pu.categoryRepo.UpdateTx(ctx, tx, post.ID, input.Categories); err != nil {
+			status = http.StatusInternalServerError
+			return err
+		}
+		if input.Tags != nil {
+			if post.Tags, err = pu.tagRepo.SetPostTagsTx(ctx, tx, post.ID, tagNames); err != nil {
+				status = http.StatusInternalServerError
+				return err
+			}
+		}
+		if editedPost, status, err = pu.postRepo.UpdateTx(ctx, tx, post); err != nil {
+			return err
+		}
+		if post.ImagePath != oldPost.ImagePath {
+			if status, err = pu.uploadRepo.AttachToPostTx(ctx, tx, post.ID, post.AuthorID, fileName); err != nil {
+				return err
+			}
+		}
+		if input.Attachments != nil {
+			if status, err = pu.attachmentRepo.SetPostAttachmentsTx(ctx, tx, post.ID, post.AuthorID, input.Attachments); err != nil {
+				return err
+			}
+		}
+		if err = pu.mentionRepo.SetMentionsTx(ctx, tx, post.AuthorID, post.ID, 0, mentions.Parse(post.Content)); err != nil {
+			status = http.StatusInternalServerError
+			return err
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, status, err
 	}
-	return editedPost, status, err
+	return editedPost, http.StatusOK, nil
 }
-func (pu *PostUsecase) Delete(postID int64) (status int, err error) {
-	if status, err = pu.postRepo.Delete(postID); err != nil {
+func (pu *PostUsecase) Delete(ctx context.Context, postID int64) (status int, err error) {
+	if status, err = pu.postRepo.Delete(ctx, postID); err != nil {
 		return status, err
 	}
 	return status, nil
 }
 
-func (pu *PostUsecase) GetBannedPostsByCategories(categories []string) (posts []models.Post, status int, err error) {
-	if posts, status, err = pu.postRepo.GetBannedPostsByCategories(categories); err != nil {
+func (pu *PostUsecase) GetBannedPostsByCategories(ctx context.Context, categories []string) (posts []models.Post, status int, err error) {
+	if posts, status, err = pu.postRepo.GetBannedPostsByCategories(ctx, categories); err != nil {
 		return nil, status, err
 	}
 	return posts, status, err
 }
 
-func (pu *PostUsecase) DeletePostReportByPostID(postID int64) (err error) {
-	if err = pu.postRepo.DeletePostReportByPostID(postID); err != nil {
+func (pu *PostUsecase) DeletePostReportByPostID(ctx context.Context, postID int64) (err error) {
+	if err = pu.postRepo.DeletePostReportByPostID(ctx, postID); err != nil {
 		return err
 	}
 	return nil
 }
+
+func (pu *PostUsecase) Restore(ctx context.Context, postID int64) (status int, err error) {
+	if status, err = pu.postRepo.Restore(ctx, postID); err != nil {
+		return status, err
+	}
+	return status, nil
+}
+
+func (pu *PostUsecase) GetDeletedPosts(ctx context.Context) (posts []models.Post, status int, err error) {
+	if posts, status, err = pu.postRepo.GetDeletedPosts(ctx); err != nil {
+		return nil, status, err
+	}
+	return posts, status, nil
+}
@@ -1,35 +1,85 @@
 package usecases
 
 import (
+	"context"
+	"database/sql"
+
+	"github.com/innovember/forum/api/db"
+	"github.com/innovember/forum/api/models"
 	"github.com/innovember/forum/api/post"
 )
 
 type RateCommentUsecase struct {
-	rateCommentRepo post.RateCommentRepository
+	rateCommentRepo  post.RateCommentRepository
+	notificationRepo post.NotificationRepository
+	uow              db.UnitOfWork
+}
+
+func NewRateCommentUsecase(repo post.RateCommentRepository,
+	notificationRepo post.NotificationRepository,
+	uow db.UnitOfWork) post.RateCommentUsecase {
+	return &RateCommentUsecase{rateCommentRepo: repo, notificationRepo: notificationRepo, uow: uow}
 }
 
-func NewRateCommentUsecase(repo post.RateCommentRepository) post.RateCommentUsecase {
-	return &RateCommentUsecase{rateCommentRepo: repo}
+// Vote toggles the user's vote on a comment and notifies the author, all in
+// one transaction. Repeating the same vote cancels it.
+func (ru *RateCommentUsecase) Vote(ctx context.Context, comment *models.Comment, userID int64, vote int) (cancelled bool, err error) {
+	err = ru.uow.Do(ctx, func(tx *sql.Tx) (err error) {
+		var (
+			isRatedBefore bool
+			commentRateID int64
+		)
+		if isRatedBefore, err = ru.rateCommentRepo.IsRatedBeforeTx(ctx, tx, comment.ID, userID, vote); err != nil {
+			return err
+		}
+		if isRatedBefore {
+			cancelled = true
+			return ru.rateCommentRepo.DeleteRateFromCommentTx(ctx, tx, comment.ID, userID, vote)
+		}
+		if commentRateID, err = ru.rateCommentRepo.RateCommentTx(ctx, tx, comment.ID, userID, vote, comment.PostID); err != nil {
+			return err
+		}
+		if err = ru.notificationRepo.DeleteNotificationsByCommentRateIDTx(ctx, tx, commentRateID); err != nil {
+			return err
+		}
+		if userID != comment.AuthorID {
+			notification := models.Notification{
+				PostID:        comment.PostID,
+				CommentRateID: commentRateID,
+				CommentID:     comment.ID,
+				RateID:        0,
+				ReceiverID:    comment.AuthorID,
+			}
+			if _, _, err = ru.notificationRepo.CreateTx(ctx, tx, &notification); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return cancelled, nil
 }
 
-func (ru *RateCommentUsecase) RateComment(commentID int64, userID int64, vote int, postID int64) (rateID int64, err error) {
-	if rateID, err = ru.rateCommentRepo.RateComment(commentID, userID, vote, postID); err != nil {
+func (ru *RateCommentUsecase) RateComment(ctx context.Context, commentID int64, userID int64, vote int, postID int64) (rateID int64, err error) {
+	if rateID, err = ru.rateCommentRepo.RateComment(ctx, commentID, userID, vote, postID); err != nil {
 		return 0, err
 	}
 	return rateID, nil
 }
-func (ru *RateCommentUsecase) GetCommentRating(commentID int64, userID int64) (rating int, userRating int, err error) {
-	if rating, userRating, err = ru.rateCommentRepo.GetCommentRating(commentID, userID); err != nil {
+func (ru *RateCommentUsecase) GetCommentRating(ctx context.Context, commentID int64, userID int64) (rating int, userRating int, err error) {
+	if rating, userRating, err = ru.rateCommentRepo.GetCommentRating(ctx, commentID, userID); err != nil {
 		return 0, 0, err
 	}
 	return rating, userRating, nil
 }
-func (ru *RateCommentUsecase) IsRatedBefore(commentID int64, userID int64, vote int) (bool, error) {
+func (ru *RateCommentUsecase) IsRatedBefore(ctx context.Context, commentID int64, userID int64, vote int) (bool, error) {
 	var (
 		isRated bool
 		err     error
 	)
-	if isRated, err = ru.rateCommentRepo.IsRatedBefore(commentID, userID, vote); err != nil {
+	if isRated, err = ru.rateCommentRepo.IsRatedBefore(ctx, commentID, userID, vote); err != nil {
 		return false, err
 	}
 	if isRated {
@@ -38,22 +88,22 @@ func (ru *RateCommentUsecase) IsRatedBefore(commentID int64, userID int64, vote
 	return false, nil
 }
 
-func (ru *RateCommentUsecase) DeleteRateFromComment(commentID int64, userID int64, vote int) error {
-	if err := ru.rateCommentRepo.DeleteRateFromComment(commentID, userID, vote); err != nil {
+func (ru *RateCommentUsecase) DeleteRateFromComment(ctx context.Context, commentID int64, userID int64, vote int) error {
+	if err := ru.rateCommentRepo.DeleteRateFromComment(ctx, commentID, userID, vote); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (ru *RateCommentUsecase) DeleteRatesByCommentID(commentID int64) (err error) {
-	if err = ru.rateCommentRepo.DeleteRatesByCommentID(commentID); err != nil {
+func (ru *RateCommentUsecase) DeleteRatesByCommentID(ctx context.Context, commentID int64) (err error) {
+	if err = ru.rateCommentRepo.DeleteRatesByCommentID(ctx, commentID); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (ru *RateCommentUsecase) DeleteCommentsRateByPostID(postID int64) (err error) {
-	if err = ru.rateCommentRepo.DeleteCommentsRateByPostID(postID); err != nil {
+func (ru *RateCommentUsecase) DeleteCommentsRateByPostID(ctx context.Context, postID int64) (err error) {
+	if err = ru.rateCommentRepo.DeleteCommentsRateByPostID(ctx, postID); err != nil {
 		return err
 	}
 	return nil
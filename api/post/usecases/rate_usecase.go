@@ -1,35 +1,85 @@
 package usecases
 
 import (
+	"context"
+	"database/sql"
+
+	"github.com/innovember/forum/api/db"
+	"github.com/innovember/forum/api/models"
 	"github.com/innovember/forum/api/post"
 )
 
 type RateUsecase struct {
-	rateRepo post.RateRepository
+	rateRepo         post.RateRepository
+	notificationRepo post.NotificationRepository
+	uow              db.UnitOfWork
+}
+
+func NewRateUsecase(repo post.RateRepository,
+	notificationRepo post.NotificationRepository,
+	uow db.UnitOfWork) post.RateUsecase {
+	return &RateUsecase{rateRepo: repo, notificationRepo: notificationRepo, uow: uow}
 }
 
-func NewRateUsecase(repo post.RateRepository) post.RateUsecase {
-	return &RateUsecase{rateRepo: repo}
+// Vote toggles the user's vote on a post and notifies the author, all in one
+// transaction. Repeating the same vote cancels it.
+func (ru *RateUsecase) Vote(ctx context.Context, post *models.Post, userID int64, vote int) (cancelled bool, err error) {
+	err = ru.uow.Do(ctx, func(tx *sql.Tx) (err error) {
+		var (
+			isRatedBefore bool
+			rateID        int64
+		)
+		if isRatedBefore, err = ru.rateRepo.IsRatedBeforeTx(ctx, tx, post.ID, userID, vote); err != nil {
+			return err
+		}
+		if isRatedBefore {
+			cancelled = true
+			return ru.rateRepo.DeleteRateFromPostTx(ctx, tx, post.ID, userID, vote)
+		}
+		if rateID, err = ru.rateRepo.RatePostTx(ctx, tx, post.ID, userID, vote); err != nil {
+			return err
+		}
+		if err = ru.notificationRepo.DeleteNotificationsByRateIDTx(ctx, tx, rateID); err != nil {
+			return err
+		}
+		if userID != post.AuthorID {
+			notification := models.Notification{
+				PostID:        post.ID,
+				RateID:        rateID,
+				CommentID:     0,
+				CommentRateID: 0,
+				ReceiverID:    post.AuthorID,
+			}
+			if _, _, err = ru.notificationRepo.CreateTx(ctx, tx, &notification); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return cancelled, nil
 }
 
-func (ru *RateUsecase) RatePost(postID int64, userID int64, vote int) (rateID int64, err error) {
-	if rateID, err = ru.rateRepo.RatePost(postID, userID, vote); err != nil {
+func (ru *RateUsecase) RatePost(ctx context.Context, postID int64, userID int64, vote int) (rateID int64, err error) {
+	if rateID, err = ru.rateRepo.RatePost(ctx, postID, userID, vote); err != nil {
 		return 0, err
 	}
 	return rateID, nil
 }
-func (ru *RateUsecase) GetRating(postID int64, userID int64) (rating int, userRating int, err error) {
-	if rating, userRating, err = ru.rateRepo.GetPostRating(postID, userID); err != nil {
+func (ru *RateUsecase) GetRating(ctx context.Context, postID int64, userID int64) (rating int, userRating int, err error) {
+	if rating, userRating, err = ru.rateRepo.GetPostRating(ctx, postID, userID); err != nil {
 		return 0, 0, err
 	}
 	return rating, userRating, nil
 }
-func (ru *RateUsecase) IsRatedBefore(postID int64, userID int64, vote int) (bool, error) {
+func (ru *RateUsecase) IsRatedBefore(ctx context.Context, postID int64, userID int64, vote int) (bool, error) {
 	var (
 		isRated bool
 		err     error
 	)
-	if isRated, err = ru.rateRepo.IsRatedBefore(postID, userID, vote); err != nil {
+	if isRated, err = ru.rateRepo.IsRatedBefore(ctx, postID, userID, vote); err != nil {
 		return false, err
 	}
 	if isRated {
@@ -38,15 +88,15 @@ func (ru *RateUsecase) IsRatedBefore(postID int64, userID int64, vote int) (bool
 	return false, nil
 }
 
-func (ru *RateUsecase) DeleteRateFromPost(postID int64, userID int64, vote int) error {
-	if err := ru.rateRepo.DeleteRateFromPost(postID, userID, vote); err != nil {
+func (ru *RateUsecase) DeleteRateFromPost(ctx context.Context, postID int64, userID int64, vote int) error {
+	if err := ru.rateRepo.DeleteRateFromPost(ctx, postID, userID, vote); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (ru *RateUsecase) DeleteRatesByPostID(postID int64) (err error) {
-	if err = ru.rateRepo.DeleteRatesByPostID(postID); err != nil {
+func (ru *RateUsecase) DeleteRatesByPostID(ctx context.Context, postID int64) (err error) {
+	if err = ru.rateRepo.DeleteRatesByPostID(ctx, postID); err != nil {
 		return err
 	}
 	return nil
@@ -0,0 +1,104 @@
+package usecases
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"testing"
+
+	"github.com/innovember/forum/api/db"
+	"github.com/innovember/forum/api/models"
+	"github.com/innovember/forum/api/post/repository"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// testDB opens an in-memory database with the schema and migrations. Post
+// lists query the author and ratings of each post while reading the list,
+// so the database is shared between connections, and one is kept open for
+// the test: the database goes with the last.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	conn, err := sql.Open("sqlite3", "file:"+url.PathEscape(t.Name())+"?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	pinned, err := conn.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pinned.Close() })
+	if err = db.CheckDB(conn, "../../db/schema.sql"); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func count(t *testing.T, conn *sql.DB, query string, args ...interface{}) (n int) {
+	t.Helper()
+	if err := conn.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestVote(t *testing.T) {
+	post := &models.Post{ID: 1, AuthorID: 5}
+	tests := []struct {
+		name string
+		// setup runs before the last vote
+		setup             string
+		votes             []int
+		voter             int64
+		wantErr           bool
+		wantCancelled     bool
+		wantRatings       int
+		wantNotifications int
+	}{
+		{name: "upvote", votes: []int{1}, voter: 6, wantRatings: 1, wantNotifications: 1},
+		{name: "own post", votes: []int{1}, voter: 5, wantRatings: 1},
+		{name: "repeat cancels", votes: []int{1, 1}, voter: 6, wantCancelled: true},
+		{name: "switch vote", votes: []int{1, -1}, voter: 6, wantRatings: 1, wantNotifications: 1},
+		{
+			name:    "failed notification rolls back the rating",
+			setup:   `DROP TABLE notifications`,
+			votes:   []int{1},
+			voter:   6,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := testDB(t)
+			if _, err := conn.Exec(`INSERT INTO posts(id, author_id, title, content) VALUES (1, 5, 't', 'c')`); err != nil {
+				t.Fatal(err)
+			}
+			ru := NewRateUsecase(repository.NewRateDBRepository(conn),
+				repository.NewNotificationDBRepository(conn, ""), db.NewUnitOfWork(conn))
+			ctx := context.Background()
+			var (
+				cancelled bool
+				err       error
+			)
+			for i, vote := range tt.votes {
+				if i == len(tt.votes)-1 && tt.setup != "" {
+					if _, err = conn.Exec(tt.setup); err != nil {
+						t.Fatal(err)
+					}
+				}
+				cancelled, err = ru.Vote(ctx, post, tt.voter, vote)
+			}
+			if (err != nil) != tt.wantErr || cancelled != tt.wantCancelled {
+				t.Fatalf("Vote = %v, %v, want cancelled %v, error %v", cancelled, err, tt.wantCancelled, tt.wantErr)
+			}
+			if n := count(t, conn, `SELECT COUNT(*) FROM post_rating WHERE post_id = 1`); n != tt.wantRatings {
+				t.Errorf("ratings = %d, want %d", n, tt.wantRatings)
+			}
+			if tt.setup == "" {
+				if n := count(t, conn, `SELECT COUNT(*) FROM notifications WHERE post_id = 1`); n != tt.wantNotifications {
+					t.Errorf("notifications = %d, want %d", n, tt.wantNotifications)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package usecases
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/models"
+	"github.com/innovember/forum/api/post"
+	"github.com/innovember/forum/api/services/tags"
+)
+
+type TagUsecase struct {
+	tagRepo post.TagRepository
+}
+
+func NewTagUsecase(repo post.TagRepository) post.TagUsecase {
+	return &TagUsecase{tagRepo: repo}
+}
+
+// CanUseTags checks the tags given for a post before it is saved.
+func (tu *TagUsecase) CanUseTags(names []string) (status int, err error) {
+	if _, err = normalizeTags(names, config.MaxTagsPerPost); err != nil {
+		return http.StatusBadRequest, err
+	}
+	return http.StatusOK, nil
+}
+
+// SetPostTags tags a post with the names given, once normalized, and
+// returns the tags it ends up with, synonyms resolved.
+func (tu *TagUsecase) SetPostTags(ctx context.Context, postID int64, names []string) (tagNames []string, status int, err error) {
+	if names, err = normalizeTags(names, config.MaxTagsPerPost); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if tagNames, err = tu.tagRepo.SetPostTags(ctx, postID, names); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return tagNames, http.StatusOK, nil
+}
+
+// GetTagIDs returns the IDs of the tags named, for filtering posts. A name
+// no tag has gives 0, which no post is tagged with.
+func (tu *TagUsecase) GetTagIDs(ctx context.Context, names []string) (tagIDs []int64, status int, err error) {
+	var (
+		tag  *models.Tag
+		seen = map[int64]bool{}
+	)
+	if names, err = normalizeTags(names, 0); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	for _, name := range names {
+		var tagID int64
+		if tag, err = tu.tagRepo.GetTagByName(ctx, name); err == nil {
+			tagID = tag.ID
+		} else if err != sql.ErrNoRows {
+			return nil, http.StatusInternalServerError, err
+		}
+		if seen[tagID] {
+			continue
+		}
+		seen[tagID] = true
+		tagIDs = append(tagIDs, tagID)
+	}
+	return tagIDs, http.StatusOK, nil
+}
+
+// GetTagSuggestions completes the start of a tag, synonyms included.
+func (tu *TagUsecase) GetTagSuggestions(ctx context.Context, prefix string) (suggestions []models.Tag, status int, err error) {
+	if prefix = tags.Normalize(prefix); prefix == "" {
+		return nil, http.StatusBadRequest, errors.New("tag prefix must have a letter or digit")
+	}
+	if suggestions, err = tu.tagRepo.GetTagsByPrefix(ctx, prefix, config.TagSuggestionsLimit); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return suggestions, http.StatusOK, nil
+}
+
+// GetPopularTags returns up to limit of the most used tags, or
+// config.PopularTagsLimit for a limit out of range.
+func (tu *TagUsecase) GetPopularTags(ctx context.Context, limit int) (popular []models.Tag, status int, err error) {
+	if limit <= 0 || limit > config.PopularTagsLimit {
+		limit = config.PopularTagsLimit
+	}
+	if popular, err = tu.tagRepo.GetPopularTags(ctx, limit); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return popular, http.StatusOK, nil
+}
+
+func (tu *TagUsecase) GetAllSynonyms(ctx context.Context) (synonyms []models.TagSynonym, status int, err error) {
+	if synonyms, err = tu.tagRepo.GetAllSynonyms(ctx); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return synonyms, http.StatusOK, nil
+}
+
+// AddSynonym makes synonym.Name stand for synonym.Tag, merging an existing
+// tag of that name into it.
+func (tu *TagUsecase) AddSynonym(ctx context.Context, synonym *models.TagSynonym) (status int, err error) {
+	var source, target *models.Tag
+	synonym.Name = tags.Normalize(synonym.Name)
+	synonym.Tag = tags.Normalize(synonym.Tag)
+	if synonym.Name == "" || synonym.Tag == "" {
+		return http.StatusBadRequest, errors.New("a synonym and its tag must have a letter or digit")
+	}
+	if synonym.Name == synonym.Tag {
+		return http.StatusBadRequest, errors.New("a tag can't be its own synonym")
+	}
+	if source, err = tu.tagRepo.GetTagByName(ctx, synonym.Name); err != nil && err != sql.ErrNoRows {
+		return http.StatusInternalServerError, err
+	}
+	if target, err = tu.tagRepo.GetTagByName(ctx, synonym.Tag); err != nil && err != sql.ErrNoRows {
+		return http.StatusInternalServerError, err
+	}
+	if source != nil && target != nil && source.ID == target.ID {
+		return http.StatusConflict, fmt.Errorf("%q and %q are already the same tag", synonym.Name, synonym.Tag)
+	}
+	if err = tu.tagRepo.AddSynonym(ctx, synonym); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// DeleteSynonym makes a name no longer stand for its tag. Posts tagged
+// through it keep the tag.
+func (tu *TagUsecase) DeleteSynonym(ctx context.Context, name string) (status int, err error) {
+	var deleted bool
+	if deleted, err = tu.tagRepo.DeleteSynonym(ctx, tags.Normalize(name)); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !deleted {
+		return http.StatusNotFound, errors.New("synonym doesn't exist")
+	}
+	return http.StatusOK, nil
+}
+
+// normalizeTags normalizes the names given and drops duplicates. There
+// can be no more than max of them unless max is 0.
+func normalizeTags(names []string, max int) (normalized []string, err error) {
+	var seen = map[string]bool{}
+	for _, name := range names {
+		tag := tags.Normalize(name)
+		if tag == "" {
+			return nil, fmt.Errorf("tag %q must have a letter or digit", name)
+		}
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if max > 0 && len(normalized) > max {
+		return nil, fmt.Errorf("a post can have at most %d tags", max)
+	}
+	return normalized, nil
+}
@@ -0,0 +1,174 @@
+package usecases
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/db"
+	"github.com/innovember/forum/api/models"
+	"github.com/innovember/forum/api/post/repository"
+)
+
+func TestSetPostTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		synonyms []models.TagSynonym
+		tags     []string
+		want     []string
+		wantCode int
+	}{
+		{"normalized", nil, []string{"#Go", "Machine Learning"}, []string{"go", "machine-learning"}, http.StatusOK},
+		{"duplicates", nil, []string{"Go", "go", "#go"}, []string{"go"}, http.StatusOK},
+		{"synonym", []models.TagSynonym{{Name: "golang", Tag: "go"}}, []string{"Golang", "go"}, []string{"go"}, http.StatusOK},
+		{"no letter", nil, []string{"go", "++"}, nil, http.StatusBadRequest},
+		{"too many", nil, []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k"}, nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := testDB(t)
+			tu := NewTagUsecase(repository.NewTagDBRepository(conn))
+			ctx := context.Background()
+			for _, synonym := range tt.synonyms {
+				if status, err := tu.AddSynonym(ctx, &synonym); err != nil {
+					t.Fatal(status, err)
+				}
+			}
+			got, status, err := tu.SetPostTags(ctx, 1, tt.tags)
+			if status != tt.wantCode {
+				t.Fatalf("status = %d, %v, want %d", status, err, tt.wantCode)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tags = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddSynonym(t *testing.T) {
+	tests := []struct {
+		name     string
+		synonym  models.TagSynonym
+		want     int
+		wantTags []string // the tags of post 1 afterwards
+	}{
+		{"new name", models.TagSynonym{Name: "Golang", Tag: "go"}, http.StatusOK, []string{"go", "js"}},
+		{"merges a used tag", models.TagSynonym{Name: "js", Tag: "JavaScript"}, http.StatusOK, []string{"go", "javascript"}},
+		{"itself", models.TagSynonym{Name: "go", Tag: "#Go"}, http.StatusBadRequest, []string{"go", "js"}},
+		{"empty", models.TagSynonym{Name: "go", Tag: "--"}, http.StatusBadRequest, []string{"go", "js"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := testDB(t)
+			tu := NewTagUsecase(repository.NewTagDBRepository(conn))
+			ctx := context.Background()
+			if _, status, err := tu.SetPostTags(ctx, 1, []string{"go", "js"}); err != nil {
+				t.Fatal(status, err)
+			}
+			synonym := tt.synonym
+			if status, err := tu.AddSynonym(ctx, &synonym); status != tt.want {
+				t.Fatalf("status = %d, %v, want %d", status, err, tt.want)
+			}
+			rows, err := conn.Query(`SELECT t.name FROM posts_tags_bridge AS ptb
+				INNER JOIN tags AS t ON t.id = ptb.tag_id
+				WHERE ptb.post_id = 1 ORDER BY t.name`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+			var got []string
+			for rows.Next() {
+				var name string
+				rows.Scan(&name)
+				got = append(got, name)
+			}
+			if !reflect.DeepEqual(got, tt.wantTags) {
+				t.Errorf("post tags = %v, want %v", got, tt.wantTags)
+			}
+		})
+	}
+}
+
+func TestGetPostsByCategoriesAndTags(t *testing.T) {
+	conn := testDB(t)
+	seedCategories(t, conn)
+	if _, err := conn.Exec(`INSERT INTO users(id, username, email, created_at, last_active)
+		VALUES (5, 'alice', 'alice@example.com', 0, 0)`); err != nil {
+		t.Fatal(err)
+	}
+	// post 1 is in general and tagged go; post 2 in news and tagged go and
+	// js; post 3 in general and news, untagged; post 4 is tagged go but not
+	// approved
+	if _, err := conn.Exec(`INSERT INTO posts(id, author_id, title, content, content_html, created_at,
+			edited_at, is_image, image_path, is_approved, is_banned)
+		VALUES (1, 5, 't', 'c', '', 1, 0, 0, '', 1, 0),
+			(2, 5, 't', 'c', '', 2, 0, 0, '', 1, 0),
+			(3, 5, 't', 'c', '', 3, 0, 0, '', 1, 0),
+			(4, 5, 't', 'c', '', 4, 0, 0, '', 0, 0)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec(`INSERT INTO posts_categories_bridge(post_id, category_id)
+		VALUES (1, 1), (2, 2), (3, 1), (3, 2)`); err != nil {
+		t.Fatal(err)
+	}
+	tagRepo := repository.NewTagDBRepository(conn)
+	tu := NewTagUsecase(tagRepo)
+	ctx := context.Background()
+	for postID, names := range map[int64][]string{1: {"go"}, 2: {"go", "js"}, 4: {"go"}} {
+		if _, err := tagRepo.SetPostTags(ctx, postID, names); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if status, err := tu.AddSynonym(ctx, &models.TagSynonym{Name: "golang", Tag: "go"}); err != nil {
+		t.Fatal(status, err)
+	}
+	pu := NewPostUsecase(repository.NewPostDBRepository(conn, ""), repository.NewCategoryDBRepository(conn), tagRepo,
+		repository.NewUploadDBRepository(conn), repository.NewAttachmentDBRepository(conn, ""),
+		repository.NewMentionDBRepository(conn), db.NewUnitOfWork(conn))
+
+	tests := []struct {
+		name       string
+		categories []string
+		tags       []string
+		match      string
+		want       []int64
+		wantCode   int
+	}{
+		{"one category", []string{"General"}, nil, "", []int64{3, 1}, http.StatusOK},
+		{"all categories", []string{"General", "News"}, nil, config.MatchAll, []int64{3}, http.StatusOK},
+		{"any category", []string{"General", "News"}, nil, config.MatchAny, []int64{3, 2, 1}, http.StatusOK},
+		{"category listed twice", []string{"News", "News"}, nil, config.MatchAll, []int64{3, 2}, http.StatusOK},
+		{"all tags", nil, []string{"go", "js"}, config.MatchAll, []int64{2}, http.StatusOK},
+		{"any tag", nil, []string{"go", "js"}, config.MatchAny, []int64{2, 1}, http.StatusOK},
+		{"tag by synonym", nil, []string{"#Golang"}, "", []int64{2, 1}, http.StatusOK},
+		{"unknown tag, all", nil, []string{"go", "rust"}, config.MatchAll, nil, http.StatusOK},
+		{"unknown tag, any", nil, []string{"go", "rust"}, config.MatchAny, []int64{2, 1}, http.StatusOK},
+		{"category and tag, all", []string{"General"}, []string{"go"}, config.MatchAll, []int64{1}, http.StatusOK},
+		{"category or tag", []string{"General"}, []string{"js"}, config.MatchAny, []int64{3, 2, 1}, http.StatusOK},
+		{"bad match", []string{"General"}, nil, "some", nil, http.StatusBadRequest},
+		{"nothing chosen", nil, nil, "", nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tagIDs, status, err := tu.GetTagIDs(ctx, tt.tags)
+			if err != nil {
+				t.Fatal(status, err)
+			}
+			posts, status, err := pu.GetPostsByCategoriesAndTags(ctx, tt.categories, tagIDs, tt.match, 0)
+			if status != tt.wantCode {
+				t.Fatalf("status = %d, %v, want %d", status, err, tt.wantCode)
+			}
+			var got []int64
+			for _, post := range posts {
+				got = append(got, post.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("posts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/innovember/forum/api/models"
+	"github.com/innovember/forum/api/post"
+)
+
+type UploadUsecase struct {
+	uploadRepo post.UploadRepository
+}
+
+func NewUploadUsecase(repo post.UploadRepository) post.UploadUsecase {
+	return &UploadUsecase{uploadRepo: repo}
+}
+
+func (uu *UploadUsecase) Create(ctx context.Context, upload *models.Upload) (err error) {
+	if err = uu.uploadRepo.Create(ctx, upload); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (uu *UploadUsecase) GetByFileName(ctx context.Context, fileName string) (upload *models.Upload, status int, err error) {
+	if upload, status, err = uu.uploadRepo.GetByFileName(ctx, fileName); err != nil {
+		return nil, status, err
+	}
+	return upload, status, nil
+}
+
+// CanAttach reports whether userID may use fileName as the image of
+// postID, 0 for a post not created yet, before anything is written.
+func (uu *UploadUsecase) CanAttach(ctx context.Context, postID int64, userID int64, fileName string) (status int, err error) {
+	var upload *models.Upload
+	if upload, status, err = uu.uploadRepo.GetByFileName(ctx, fileName); err != nil {
+		if status == http.StatusNotFound {
+			return http.StatusBadRequest, errors.New("image has not been uploaded")
+		}
+		return status, err
+	}
+	if upload.OwnerID != userID {
+		return http.StatusForbidden, errors.New("can't use another user's image")
+	}
+	if upload.PostID != 0 && upload.PostID != postID {
+		return http.StatusConflict, errors.New("image is already used by another post")
+	}
+	return http.StatusOK, nil
+}
+
+func (uu *UploadUsecase) AttachToPost(ctx context.Context, postID int64, userID int64, fileName string) (status int, err error) {
+	if status, err = uu.uploadRepo.AttachToPost(ctx, postID, userID, fileName); err != nil {
+		return status, err
+	}
+	return status, nil
+}
+
+func (uu *UploadUsecase) Delete(ctx context.Context, fileName string) (err error) {
+	if err = uu.uploadRepo.Delete(ctx, fileName); err != nil {
+		return err
+	}
+	return nil
+}
@@ -5,6 +5,12 @@ import (
 	"net/http"
 )
 
+// ErrorRecorder is implemented by response writers that want to know the
+// error sent to the client, such as the access log.
+type ErrorRecorder interface {
+	RecordError(err error)
+}
+
 type Response struct {
 	Status  bool        `json:"status"`
 	Code    int         `json:"code"`
@@ -31,6 +37,9 @@ func Respond(w http.ResponseWriter, responseStatus bool, httpStatus int, message
 }
 
 func Error(w http.ResponseWriter, httpStatus int, err error) {
+	if recorder, ok := w.(ErrorRecorder); ok {
+		recorder.RecordError(err)
+	}
 	Respond(w, false, httpStatus, err.Error(), nil)
 }
 
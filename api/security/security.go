@@ -1,6 +1,8 @@
 package security
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"fmt"
 	"net/http"
 	"time"
@@ -11,7 +13,7 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-func GenerateCookie(cookie *http.Cookie, err error) (string, string) {
+func GenerateCookie(cookie *http.Cookie, err error, session config.SessionConfig) (string, string) {
 	var newUUID string
 	if err != nil {
 		newUUID = fmt.Sprint(uuid.NewV4())
@@ -21,13 +23,33 @@ func GenerateCookie(cookie *http.Cookie, err error) (string, string) {
 	newCookie := &http.Cookie{
 		Name:     config.SessionCookieName,
 		Value:    newUUID,
-		Expires:  time.Now().Add(config.SessionExpiration),
+		Expires:  time.Now().Add(session.Expiration),
 		Path:     "/",
 		HttpOnly: true,
+		SameSite: session.SameSiteMode(),
+		Secure:   session.SameSiteMode() == http.SameSiteNoneMode,
 	}
 	return newCookie.String(), newUUID
 }
 
+// GenerateCSRFCookie issues the token of the double-submit CSRF check: it is
+// sent back both as this cookie and in the X-CSRF-Token header.
+func GenerateCSRFCookie(session config.SessionConfig) (string, string) {
+	token := make([]byte, 32)
+	rand.Read(token)
+	value := base64.RawURLEncoding.EncodeToString(token)
+	newCookie := &http.Cookie{
+		Name:     config.CSRFCookieName,
+		Value:    value,
+		Expires:  time.Now().Add(session.Expiration),
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: session.SameSiteMode(),
+		Secure:   session.SameSiteMode() == http.SameSiteNoneMode,
+	}
+	return newCookie.String(), value
+}
+
 func Hash(password string) (string, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), 8)
 	return string(hash), err
@@ -36,3 +58,12 @@ func Hash(password string) (string, error) {
 func VerifyPassword(hash, password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
+
+// dummyHash stands in for the hash of accounts that do not exist.
+var dummyHash, _ = Hash("no such account")
+
+// FakeVerifyPassword costs as much as VerifyPassword, so a sign-in with an
+// unknown username takes as long as one with a wrong password.
+func FakeVerifyPassword(password string) {
+	bcrypt.CompareHashAndPassword([]byte(dummyHash), []byte(password))
+}
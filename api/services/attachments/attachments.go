@@ -0,0 +1,89 @@
+// Package attachments validates files attached to posts. Images go through
+// the imaging pipeline; documents of the allowed types are kept as sent.
+package attachments
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/services/imaging"
+)
+
+// extensions of the document types config.AttachmentsConfig may allow.
+var extensions = map[string]string{
+	config.AttachmentPDF:  "pdf",
+	config.AttachmentText: "txt",
+}
+
+// File is a processed attachment, ready to be stored.
+type File struct {
+	Data        []byte
+	Ext         string
+	ContentType string
+	IsImage     bool
+}
+
+// Hash is the hex SHA-256 of Data.
+func (f *File) Hash() string {
+	sum := sha256.Sum256(f.Data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Key is the storage key of the file. It is derived from the content, so
+// identical uploads share one stored file.
+func (f *File) Key() string {
+	return f.Hash() + "." + f.Ext
+}
+
+// Processor accepts images and the configured document types, detected
+// from the content.
+type Processor struct {
+	cfg    config.AttachmentsConfig
+	images *imaging.Pipeline
+}
+
+func NewProcessor(cfg *config.Config) *Processor {
+	return &Processor{
+		cfg:    cfg.Attachments,
+		images: imaging.NewPipeline(cfg.Images),
+	}
+}
+
+// Process reads an upload of at most MaxSize bytes. The status is the HTTP
+// status to answer with when err is not nil.
+func (p *Processor) Process(r io.Reader) (file *File, status int, err error) {
+	var (
+		data      []byte
+		mediaType string
+		img       *imaging.Image
+	)
+	if data, err = io.ReadAll(io.LimitReader(r, p.cfg.MaxSize+1)); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if int64(len(data)) > p.cfg.MaxSize {
+		return nil, http.StatusRequestEntityTooLarge, fmt.Errorf("file too heavy,limit size to %dMB", p.cfg.MaxSize/(1024*1024))
+	}
+	detected := http.DetectContentType(data)
+	if strings.HasPrefix(detected, "image/") {
+		if img, status, err = p.images.Process(bytes.NewReader(data)); err != nil {
+			return nil, status, err
+		}
+		return &File{Data: img.Data, Ext: img.Ext(), ContentType: img.ContentType(), IsImage: true}, http.StatusOK, nil
+	}
+	if mediaType, _, err = mime.ParseMediaType(detected); err != nil {
+		return nil, http.StatusUnsupportedMediaType, err
+	}
+	for _, allowed := range p.cfg.Types {
+		if mediaType == allowed {
+			return &File{Data: data, Ext: extensions[mediaType], ContentType: detected}, http.StatusOK, nil
+		}
+	}
+	return nil, http.StatusUnsupportedMediaType, fmt.Errorf("invalid file type %s, only images and %s are accepted", mediaType, strings.Join(p.cfg.Types, ", "))
+}
@@ -0,0 +1,115 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/db"
+	"github.com/innovember/forum/api/middleware"
+	"github.com/innovember/forum/api/response"
+	"github.com/innovember/forum/api/services/storage"
+	"github.com/innovember/forum/api/services/worker"
+)
+
+// Check is the outcome of one readiness check.
+type Check struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type HealthHandler struct {
+	cfg     *config.Config
+	dbConn  *sql.DB
+	workers *worker.Group
+	store   storage.Store
+}
+
+func NewHealthHandler(cfg *config.Config, dbConn *sql.DB, workers *worker.Group, store storage.Store) *HealthHandler {
+	return &HealthHandler{cfg: cfg, dbConn: dbConn, workers: workers, store: store}
+}
+
+func (hh *HealthHandler) Configure(mux *http.ServeMux, mw *middleware.MiddlewareManager) {
+	mux.HandleFunc("/healthz", mw.SetHeaders(hh.Live))
+	mux.HandleFunc("/readyz", mw.SetHeaders(hh.Ready))
+}
+
+// Live answers as long as the process serves HTTP; it checks no dependency
+// so a slow database never gets the server restarted.
+func (hh *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Only GET method allowed, return to main page", 405)
+		return
+	}
+	response.Success(w, "alive", http.StatusOK, nil)
+}
+
+// Ready reports whether the server can take traffic, with every check in the
+// body. It answers 503 when any check fails.
+func (hh *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Only GET method allowed, return to main page", 405)
+		return
+	}
+	checks := []Check{
+		hh.checkDB(r.Context()),
+		hh.checkSchema(),
+		hh.checkStorage(r.Context()),
+	}
+	checks = append(checks, hh.checkWorkers()...)
+	for _, check := range checks {
+		if !check.OK {
+			response.Respond(w, false, http.StatusServiceUnavailable, "not ready", checks)
+			return
+		}
+	}
+	response.Success(w, "ready", http.StatusOK, checks)
+}
+
+func (hh *HealthHandler) checkDB(ctx context.Context) Check {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if err := hh.dbConn.PingContext(ctx); err != nil {
+		return Check{Name: "db", Detail: err.Error()}
+	}
+	return Check{Name: "db", OK: true}
+}
+
+func (hh *HealthHandler) checkSchema() Check {
+	current, err := db.CurrentVersion(hh.dbConn)
+	if err != nil {
+		return Check{Name: "schema", Detail: err.Error()}
+	}
+	detail := fmt.Sprintf("version %d of %d", current, db.LatestVersion())
+	return Check{Name: "schema", OK: current == db.LatestVersion(), Detail: detail}
+}
+
+func (hh *HealthHandler) checkStorage(ctx context.Context) Check {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if err := hh.store.Check(ctx); err != nil {
+		return Check{Name: "images", Detail: err.Error()}
+	}
+	return Check{Name: "images", OK: true, Detail: hh.store.Name()}
+}
+
+func (hh *HealthHandler) checkWorkers() (checks []Check) {
+	status := hh.workers.Status()
+	names := make([]string, 0, len(status))
+	for name := range status {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		check := Check{Name: "worker " + name, OK: status[name]}
+		if !check.OK {
+			check.Detail = "stopped"
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
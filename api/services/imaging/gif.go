@@ -0,0 +1,54 @@
+package imaging
+
+// gifFrameCount counts the image descriptors of a GIF by walking its blocks,
+// without decompressing any frame. ok is false when the stream is truncated
+// or malformed.
+func gifFrameCount(data []byte) (frames int, ok bool) {
+	// header and logical screen descriptor
+	if len(data) < 13 {
+		return 0, false
+	}
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&0x07 + 1)
+	}
+	for i < len(data) {
+		switch data[i] {
+		case 0x21:
+			// extension: label, then data sub-blocks
+			if i += 2; i > len(data) {
+				return 0, false
+			}
+		case 0x2C:
+			// image descriptor, local color table, LZW code size, then
+			// data sub-blocks
+			if i+10 > len(data) {
+				return 0, false
+			}
+			flags := data[i+9]
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << (flags&0x07 + 1)
+			}
+			if i++; i > len(data) {
+				return 0, false
+			}
+			frames++
+		case 0x3B:
+			return frames, true
+		default:
+			return 0, false
+		}
+		for {
+			if i >= len(data) {
+				return 0, false
+			}
+			size := int(data[i])
+			i += 1 + size
+			if size == 0 {
+				break
+			}
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,221 @@
+// Package imaging validates uploaded images and rewrites them into a safe
+// form before they are stored and served back.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+
+	"github.com/innovember/forum/api/config"
+)
+
+// Formats the pipeline accepts, as detected from the content.
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatGIF  = "gif"
+	FormatSVG  = "svg"
+)
+
+// Image is an upload travelling through the pipeline. Steps read and may
+// replace Data; Format is set from the content, never from the filename.
+type Image struct {
+	Data   []byte
+	Format string
+	Width  int
+	Height int
+}
+
+// Ext is the file extension for the format.
+func (img *Image) Ext() string {
+	if img.Format == FormatJPEG {
+		return "jpg"
+	}
+	return img.Format
+}
+
+// ContentType is the MIME type for the format.
+func (img *Image) ContentType() string {
+	if img.Format == FormatSVG {
+		return "image/svg+xml"
+	}
+	return "image/" + img.Format
+}
+
+// Step is one stage of the pipeline.
+type Step func(img *Image) error
+
+// Pipeline runs its steps in order over every upload.
+type Pipeline struct {
+	cfg   config.ImagesConfig
+	steps []Step
+}
+
+// NewPipeline returns the upload pipeline: detect the type from magic
+// bytes, enforce dimension limits, then sanitize SVG or decode and re-encode
+// raster images, which drops EXIF and any other metadata.
+func NewPipeline(cfg config.ImagesConfig) *Pipeline {
+	p := &Pipeline{cfg: cfg}
+	p.steps = []Step{p.detect, p.checkDimensions, p.sanitizeSVG, p.reencode}
+	return p
+}
+
+// rejection is an upload refused for what it contains, as opposed to a
+// failure of the server.
+type rejection struct {
+	status int
+	msg    string
+}
+
+func (r *rejection) Error() string {
+	return r.msg
+}
+
+func reject(status int, format string, args ...interface{}) error {
+	return &rejection{status: status, msg: fmt.Sprintf(format, args...)}
+}
+
+// Process reads an upload of at most MaxSize bytes and runs the steps over
+// it. The status is the HTTP status to answer with when err is not nil.
+func (p *Pipeline) Process(r io.Reader) (img *Image, status int, err error) {
+	var data []byte
+	if data, err = io.ReadAll(io.LimitReader(r, p.cfg.MaxSize+1)); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if int64(len(data)) > p.cfg.MaxSize {
+		return nil, http.StatusRequestEntityTooLarge, fmt.Errorf("image too heavy,limit size to %dMB", p.cfg.MaxSize/(1024*1024))
+	}
+	img = &Image{Data: data}
+	for _, step := range p.steps {
+		if err = step(img); err != nil {
+			var rej *rejection
+			if errors.As(err, &rej) {
+				return nil, rej.status, rej
+			}
+			return nil, http.StatusInternalServerError, err
+		}
+	}
+	return img, http.StatusOK, nil
+}
+
+func (p *Pipeline) detect(img *Image) error {
+	switch http.DetectContentType(img.Data) {
+	case "image/jpeg":
+		img.Format = FormatJPEG
+	case "image/png":
+		img.Format = FormatPNG
+	case "image/gif":
+		img.Format = FormatGIF
+	default:
+		if !isSVG(img.Data) {
+			return reject(http.StatusUnsupportedMediaType, "invalid file type, only jpeg, png, gif and svg images are accepted")
+		}
+		if p.cfg.SVG == config.SVGReject {
+			return reject(http.StatusUnsupportedMediaType, "svg images are not accepted")
+		}
+		img.Format = FormatSVG
+	}
+	return nil
+}
+
+// checkDimensions reads only the header, so oversized images are refused
+// before they are decoded.
+func (p *Pipeline) checkDimensions(img *Image) error {
+	if img.Format == FormatSVG {
+		return nil
+	}
+	header, _, err := image.DecodeConfig(bytes.NewReader(img.Data))
+	if err != nil {
+		return reject(http.StatusUnprocessableEntity, "corrupt %s image", img.Format)
+	}
+	img.Width, img.Height = header.Width, header.Height
+	if img.Width <= 0 || img.Height <= 0 {
+		return reject(http.StatusUnprocessableEntity, "image has no pixels")
+	}
+	if img.Width > p.cfg.MaxWidth || img.Height > p.cfg.MaxHeight {
+		return reject(http.StatusUnprocessableEntity, "image too large, limit is %dx%d pixels", p.cfg.MaxWidth, p.cfg.MaxHeight)
+	}
+	if int64(img.Width)*int64(img.Height) > p.cfg.MaxPixels {
+		return reject(http.StatusUnprocessableEntity, "image too large, limit is %d pixels", p.cfg.MaxPixels)
+	}
+	if img.Format == FormatGIF {
+		return p.checkFrames(img)
+	}
+	return nil
+}
+
+// checkFrames bounds what decoding every frame of a GIF allocates: each one
+// may be as large as the logical screen, so the frame count times the screen
+// area must fit MaxTotalPixels.
+func (p *Pipeline) checkFrames(img *Image) error {
+	frames, ok := gifFrameCount(img.Data)
+	if !ok {
+		return reject(http.StatusUnprocessableEntity, "corrupt gif image")
+	}
+	if frames > p.cfg.MaxFrames {
+		return reject(http.StatusUnprocessableEntity, "gif has too many frames, limit is %d", p.cfg.MaxFrames)
+	}
+	if int64(frames)*int64(img.Width)*int64(img.Height) > p.cfg.MaxTotalPixels {
+		return reject(http.StatusUnprocessableEntity, "gif too large, limit is %d pixels over all frames", p.cfg.MaxTotalPixels)
+	}
+	return nil
+}
+
+func (p *Pipeline) sanitizeSVG(img *Image) error {
+	if img.Format != FormatSVG {
+		return nil
+	}
+	clean, err := sanitizeSVG(img.Data)
+	if err != nil {
+		return reject(http.StatusUnprocessableEntity, "invalid svg image: %v", err)
+	}
+	img.Data = clean
+	return nil
+}
+
+// reencode decodes the image and encodes it again, so only pixels survive:
+// EXIF, text chunks, comments and trailing data are dropped. The EXIF
+// orientation of JPEGs is applied to the pixels first.
+func (p *Pipeline) reencode(img *Image) error {
+	var (
+		out bytes.Buffer
+		err error
+	)
+	switch img.Format {
+	case FormatJPEG:
+		var decoded image.Image
+		if decoded, err = jpeg.Decode(bytes.NewReader(img.Data)); err != nil {
+			return reject(http.StatusUnprocessableEntity, "corrupt jpeg image")
+		}
+		decoded = orient(decoded, jpegOrientation(img.Data))
+		bounds := decoded.Bounds()
+		img.Width, img.Height = bounds.Dx(), bounds.Dy()
+		err = jpeg.Encode(&out, decoded, &jpeg.Options{Quality: p.cfg.JPEGQuality})
+	case FormatPNG:
+		var decoded image.Image
+		if decoded, err = png.Decode(bytes.NewReader(img.Data)); err != nil {
+			return reject(http.StatusUnprocessableEntity, "corrupt png image")
+		}
+		err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&out, decoded)
+	case FormatGIF:
+		var decoded *gif.GIF
+		if decoded, err = gif.DecodeAll(bytes.NewReader(img.Data)); err != nil {
+			return reject(http.StatusUnprocessableEntity, "corrupt gif image")
+		}
+		err = gif.EncodeAll(&out, decoded)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	img.Data = out.Bytes()
+	return nil
+}
@@ -0,0 +1,110 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/innovember/forum/api/config"
+)
+
+func testConfig() config.ImagesConfig {
+	return config.ImagesConfig{
+		MaxSize:        20 * 1024 * 1024,
+		MaxWidth:       8192,
+		MaxHeight:      8192,
+		MaxPixels:      40 * 1000 * 1000,
+		MaxFrames:      300,
+		MaxTotalPixels: 100 * 1000 * 1000,
+		JPEGQuality:    90,
+		SVG:            config.SVGSanitize,
+	}
+}
+
+// bombGIF returns a GIF of frames full-screen frames whose LZW data is a
+// single clear code, a few bytes each however large the screen.
+func bombGIF(width, height, frames int) []byte {
+	var b bytes.Buffer
+	b.WriteString("GIF89a")
+	binary.Write(&b, binary.LittleEndian, [2]uint16{uint16(width), uint16(height)})
+	// global color table of two entries
+	b.Write([]byte{0x80, 0, 0, 0, 0, 0, 0xFF, 0xFF, 0xFF})
+	for i := 0; i < frames; i++ {
+		// graphic control extension
+		b.Write([]byte{0x21, 0xF9, 4, 0, 0, 0, 0, 0})
+		b.WriteByte(0x2C)
+		binary.Write(&b, binary.LittleEndian, [4]uint16{0, 0, uint16(width), uint16(height)})
+		b.Write([]byte{0, 2, 1, 0x04, 0})
+	}
+	b.WriteByte(0x3B)
+	return b.Bytes()
+}
+
+func animatedGIF(t *testing.T, size, frames int) []byte {
+	t.Helper()
+	anim := &gif.GIF{}
+	palette := color.Palette{color.Black, color.White}
+	for i := 0; i < frames; i++ {
+		anim.Image = append(anim.Image, image.NewPaletted(image.Rect(0, 0, size, size), palette))
+		anim.Delay = append(anim.Delay, 10)
+	}
+	var b bytes.Buffer
+	if err := gif.EncodeAll(&b, anim); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestGIFFrameCount(t *testing.T) {
+	data := animatedGIF(t, 16, 7)
+	if frames, ok := gifFrameCount(data); !ok || frames != 7 {
+		t.Fatalf("gifFrameCount = %d, %v, want 7", frames, ok)
+	}
+	if frames, ok := gifFrameCount(bombGIF(100, 100, 3)); !ok || frames != 3 {
+		t.Fatalf("gifFrameCount = %d, %v, want 3", frames, ok)
+	}
+	if _, ok := gifFrameCount(data[:len(data)-5]); ok {
+		t.Fatal("truncated gif counted")
+	}
+}
+
+func TestProcessGIF(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		status  int
+		wantErr string
+	}{
+		{"small animation", nil, http.StatusOK, ""},
+		{"frames times screen over the budget", bombGIF(6000, 6000, 300), http.StatusUnprocessableEntity, "over all frames"},
+		{"budget reached with few frames", bombGIF(6000, 6000, 3), http.StatusUnprocessableEntity, "over all frames"},
+		{"too many frames", bombGIF(10, 10, 301), http.StatusUnprocessableEntity, "too many frames"},
+		{"truncated", bombGIF(10, 10, 2)[:40], http.StatusUnprocessableEntity, "corrupt gif"},
+	}
+	tests[0].data = animatedGIF(t, 64, 20)
+	p := NewPipeline(testConfig())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, status, err := p.Process(bytes.NewReader(tt.data))
+			if status != tt.status {
+				t.Fatalf("status = %d, err = %v, want %d", status, err, tt.status)
+			}
+			if tt.wantErr == "" {
+				if err != nil || img.Format != FormatGIF {
+					t.Fatalf("Process = %+v, %v", img, err)
+				}
+				return
+			}
+			var rej *rejection
+			if !errors.As(err, &rej) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
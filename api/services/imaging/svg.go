@@ -0,0 +1,142 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+const (
+	svgNS   = "http://www.w3.org/2000/svg"
+	xlinkNS = "http://www.w3.org/1999/xlink"
+	xmlNS   = "http://www.w3.org/XML/1998/namespace"
+)
+
+// svgElements are the elements kept by sanitizeSVG: shapes, text, paint
+// servers and filters. Anything that can run script, load a resource or
+// change an attribute later, such as script, style, foreignObject, image, a
+// and the animation elements, is dropped along with its content.
+var svgElements = map[string]bool{
+	"svg": true, "g": true, "defs": true, "symbol": true, "use": true,
+	"path": true, "rect": true, "circle": true, "ellipse": true, "line": true,
+	"polyline": true, "polygon": true, "text": true, "tspan": true, "textPath": true,
+	"title": true, "desc": true, "linearGradient": true, "radialGradient": true,
+	"stop": true, "clipPath": true, "mask": true, "pattern": true, "marker": true,
+	"filter": true, "feGaussianBlur": true, "feOffset": true, "feBlend": true,
+	"feColorMatrix": true, "feMerge": true, "feMergeNode": true, "feFlood": true,
+	"feComposite": true,
+}
+
+// isSVG reports whether data is an XML document whose root is an svg
+// element.
+func isSVG(data []byte) bool {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.RawToken()
+		if err != nil {
+			return false
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			return t.Name.Local == "svg"
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) > 0 {
+				return false
+			}
+		}
+	}
+}
+
+// sanitizeSVG rewrites an SVG document keeping only allowed elements and
+// safe attributes. Comments, processing instructions and DOCTYPEs are
+// dropped, and entity references other than the XML ones are refused.
+func sanitizeSVG(data []byte) ([]byte, error) {
+	var (
+		out     bytes.Buffer
+		skip    int
+		root    = true
+		decoder = xml.NewDecoder(bytes.NewReader(data))
+	)
+	decoder.Strict = true
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if skip > 0 || t.Name.Space != svgNS || !svgElements[t.Name.Local] {
+				skip++
+				continue
+			}
+			out.WriteString("<" + t.Name.Local)
+			if root {
+				out.WriteString(` xmlns="` + svgNS + `" xmlns:xlink="` + xlinkNS + `"`)
+				root = false
+			}
+			for _, attr := range t.Attr {
+				if name, ok := svgAttribute(attr); ok {
+					out.WriteString(" " + name + `="`)
+					xml.EscapeText(&out, []byte(attr.Value))
+					out.WriteString(`"`)
+				}
+			}
+			out.WriteString(">")
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			out.WriteString("</" + t.Name.Local + ">")
+		case xml.CharData:
+			if skip == 0 && !root {
+				xml.EscapeText(&out, t)
+			}
+		}
+	}
+	if root {
+		return nil, errors.New("no svg element")
+	}
+	return out.Bytes(), nil
+}
+
+// svgAttribute returns the name to write an attribute under, or false to
+// drop it: event handlers, namespace declarations (the root gets its own),
+// references other than to a fragment of the document, and values that
+// could run script or load a resource.
+func svgAttribute(attr xml.Attr) (string, bool) {
+	var name string
+	switch attr.Name.Space {
+	case "":
+		name = attr.Name.Local
+	case xlinkNS:
+		name = "xlink:" + attr.Name.Local
+	case xmlNS:
+		name = "xml:" + attr.Name.Local
+	default:
+		return "", false
+	}
+	lower := strings.ToLower(name)
+	value := strings.ToLower(strings.Join(strings.Fields(attr.Value), ""))
+	switch {
+	case strings.HasPrefix(lower, "on"), lower == "xmlns":
+		return "", false
+	case lower == "href" || lower == "xlink:href":
+		return name, strings.HasPrefix(value, "#")
+	case strings.Contains(value, "javascript:"), strings.Contains(value, "expression("),
+		strings.Contains(value, "@import"):
+		return "", false
+	}
+	for rest := value; strings.Contains(rest, "url("); {
+		rest = rest[strings.Index(rest, "url(")+len("url("):]
+		if !strings.HasPrefix(strings.TrimLeft(rest, `'"`), "#") {
+			return "", false
+		}
+	}
+	return name, true
+}
@@ -0,0 +1,342 @@
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/services/storage"
+)
+
+// Variant names. The original is the upload as the pipeline stored it; the
+// others are scaled down copies stored beside it as <name>_<variant>.<ext>.
+const (
+	VariantThumbnail = "thumbnail"
+	VariantMedium    = "medium"
+	VariantOriginal  = "original"
+)
+
+var scaledVariants = []string{VariantThumbnail, VariantMedium}
+
+// RetryInterval is how often the jobs a full queue deferred are retried.
+const RetryInterval = time.Minute
+
+// VariantPath returns the path or URL of a variant of the image at
+// original.
+func VariantPath(original, variant string) string {
+	if variant == VariantOriginal {
+		return original
+	}
+	ext := path.Ext(original)
+	return strings.TrimSuffix(original, ext) + "_" + variant + ext
+}
+
+// VariantKeys returns the keys of every file stored for an upload, the
+// original first.
+func VariantKeys(original string) []string {
+	keys := []string{original}
+	for _, variant := range scaledVariants {
+		keys = append(keys, VariantPath(original, variant))
+	}
+	return keys
+}
+
+// Variants maps every variant name to its URL, for an image post. SVGs
+// scale by themselves, so all their variants are the original.
+func Variants(isImage bool, imagePath string) map[string]string {
+	if !isImage || imagePath == "" {
+		return nil
+	}
+	variants := map[string]string{VariantOriginal: imagePath}
+	for _, variant := range scaledVariants {
+		if strings.EqualFold(path.Ext(imagePath), ".svg") {
+			variants[variant] = imagePath
+		} else {
+			variants[variant] = VariantPath(imagePath, variant)
+		}
+	}
+	return variants
+}
+
+// Generator writes the scaled variants of stored images in the background.
+// Until they exist, Server answers for them with the original.
+type Generator struct {
+	cfg   config.ImagesConfig
+	store storage.Store
+	jobs  chan string
+
+	mu       sync.Mutex
+	deferred []string
+}
+
+func NewGenerator(cfg config.ImagesConfig, store storage.Store) *Generator {
+	return &Generator{
+		cfg:   cfg,
+		store: store,
+		jobs:  make(chan string, 100),
+	}
+}
+
+// Enqueue schedules the variants of a stored file. A full queue defers the
+// job to the next retry, every RetryInterval.
+func (g *Generator) Enqueue(key string) {
+	select {
+	case g.jobs <- key:
+	default:
+		g.mu.Lock()
+		g.deferred = append(g.deferred, key)
+		g.mu.Unlock()
+		slog.Warn("image variant queue full, deferring", "file", key)
+	}
+}
+
+// Run generates variants until ctx is cancelled, starting with the images
+// stored without them, such as those of a previous run's queue.
+func (g *Generator) Run(ctx context.Context) {
+	g.backfill(ctx)
+	ticker := time.NewTicker(RetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case key := <-g.jobs:
+			g.generate(ctx, key)
+		case <-ticker.C:
+			g.retryDeferred(ctx)
+		}
+	}
+}
+
+// retryDeferred generates the variants of the jobs Enqueue deferred.
+func (g *Generator) retryDeferred(ctx context.Context) {
+	g.mu.Lock()
+	keys := g.deferred
+	g.deferred = nil
+	g.mu.Unlock()
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			return
+		}
+		g.generate(ctx, key)
+	}
+}
+
+func (g *Generator) generate(ctx context.Context, key string) {
+	if err := g.Generate(ctx, key); err != nil {
+		slog.Error("image variants failed", "file", key, "error", err)
+	}
+}
+
+func (g *Generator) backfill(ctx context.Context) {
+	keys, err := g.store.List(ctx)
+	if err != nil {
+		slog.Error("image variants backfill failed", "error", err)
+		return
+	}
+	stored := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		stored[key] = true
+	}
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			return
+		}
+		// documents attached to posts share the store and have no variants
+		if strings.Contains(key, "_") || stored[VariantPath(key, VariantThumbnail)] ||
+			!strings.HasPrefix(storage.ContentType(key), "image/") {
+			continue
+		}
+		g.generate(ctx, key)
+	}
+}
+
+// Generate writes every scaled variant of a stored file. Images already
+// smaller than a variant get a plain copy, so every variant file exists.
+func (g *Generator) Generate(ctx context.Context, key string) error {
+	var (
+		data    []byte
+		decoded image.Image
+		rgba    *image.RGBA
+		format  string
+		err     error
+	)
+	if data, err = storage.ReadAll(ctx, g.store, key); err != nil {
+		return err
+	}
+	if decoded, format, err = image.Decode(bytes.NewReader(data)); err != nil {
+		// SVGs and anything not decodable are served as the original
+		return nil
+	}
+	sizes := map[string]int{
+		VariantThumbnail: g.cfg.ThumbnailSize,
+		VariantMedium:    g.cfg.MediumSize,
+	}
+	for _, variant := range scaledVariants {
+		var out bytes.Buffer
+		scaled := decoded
+		if bounds := decoded.Bounds(); bounds.Dx() > sizes[variant] || bounds.Dy() > sizes[variant] {
+			// every variant scales from the one RGBA copy
+			if rgba == nil {
+				rgba = toRGBA(decoded)
+			}
+			scaled = fit(rgba, sizes[variant])
+		}
+		switch format {
+		case "jpeg":
+			err = jpeg.Encode(&out, scaled, &jpeg.Options{Quality: g.cfg.JPEGQuality})
+		case "png":
+			err = png.Encode(&out, scaled)
+		case "gif":
+			// only the first frame is kept
+			err = gif.Encode(&out, scaled, nil)
+		default:
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		variantKey := VariantPath(key, variant)
+		if err = g.store.Put(ctx, variantKey, out.Bytes(), storage.ContentType(variantKey)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// toRGBA returns src as an RGBA image with its origin at 0,0, copying it
+// only when it is not one already.
+func toRGBA(src image.Image) *image.RGBA {
+	if rgba, ok := src.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+	b := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	return rgba
+}
+
+// fit scales src down, keeping its aspect ratio, so its longer side is at
+// most maxSide. Each destination pixel averages the source pixels it
+// covers, which keeps thin lines and text legible.
+func fit(src *image.RGBA, maxSide int) *image.RGBA {
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	if sw <= maxSide && sh <= maxSide {
+		return src
+	}
+	dw, dh := maxSide, sh*maxSide/sw
+	if sh > sw {
+		dw, dh = sw*maxSide/sh, maxSide
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*sh/dh, (y+1)*sh/dh
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*sw/dw, (x+1)*sw/dw
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+			var r, g, bl, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					px := row[sx*4 : sx*4+4]
+					r += uint32(px[0])
+					g += uint32(px[1])
+					bl += uint32(px[2])
+					a += uint32(px[3])
+					n++
+				}
+			}
+			i := y*dst.Stride + x*4
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(bl / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// Server serves the files of store. A scaled variant not generated yet is
+// answered with its original. Raster images of stores with URLs of their
+// own are redirected to; anything else is streamed, so that it keeps the
+// headers the API serves it with, which a bucket can't send.
+func Server(store storage.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Only GET method allowed, return to main page", 405)
+			return
+		}
+		key := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		for _, variant := range scaledVariants {
+			suffix := "_" + variant + path.Ext(key)
+			if !strings.HasSuffix(key, suffix) {
+				continue
+			}
+			if exists, err := store.Exists(r.Context(), key); err == nil && !exists {
+				key = strings.TrimSuffix(key, suffix) + path.Ext(key)
+			}
+			break
+		}
+		if storage.Inline(key) {
+			location, err := store.URL(r.Context(), key)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if location != "" {
+				// signed URLs expire, so the redirect must not outlive them
+				w.Header().Set("Cache-Control", "private, max-age=60")
+				http.Redirect(w, r, location, http.StatusFound)
+				return
+			}
+		}
+		file, err := store.Open(r.Context(), key)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				http.NotFound(w, r)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer file.Close()
+		w.Header().Set("Content-Type", storage.ContentType(key))
+		seeker, ok := file.(io.ReadSeeker)
+		if !ok {
+			io.Copy(w, file)
+			return
+		}
+		var modTime time.Time
+		if stat, ok := file.(interface{ Stat() (fs.FileInfo, error) }); ok {
+			if info, err := stat.Stat(); err == nil {
+				modTime = info.ModTime()
+			}
+		}
+		http.ServeContent(w, r, key, modTime, seeker)
+	})
+}
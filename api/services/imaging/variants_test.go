@@ -0,0 +1,134 @@
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/innovember/forum/api/services/storage"
+)
+
+func testGenerator(t *testing.T) (*Generator, storage.Store) {
+	t.Helper()
+	store, err := storage.NewFilesystem(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := testConfig()
+	cfg.ThumbnailSize, cfg.MediumSize = 32, 128
+	return NewGenerator(cfg, store), store
+}
+
+func TestFit(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 400, 100))
+	for x := 0; x < 400; x++ {
+		for y := 0; y < 100; y++ {
+			if x%2 == 0 {
+				src.Set(x, y, color.White)
+			} else {
+				src.Set(x, y, color.Black)
+			}
+		}
+	}
+	dst := fit(src, 100)
+	if dst.Rect.Dx() != 100 || dst.Rect.Dy() != 25 {
+		t.Fatalf("fit = %v, want 100x25", dst.Rect)
+	}
+	// alternating columns average to grey
+	if r, _, _, _ := dst.At(50, 10).RGBA(); r>>8 < 120 || r>>8 > 135 {
+		t.Fatalf("pixel = %d, want grey", r>>8)
+	}
+	if small := fit(src, 500); small != src {
+		t.Fatal("an image within the size was copied")
+	}
+}
+
+func TestToRGBA(t *testing.T) {
+	rgba := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	if toRGBA(rgba) != rgba {
+		t.Fatal("an RGBA image was copied")
+	}
+	offset := image.NewRGBA(image.Rect(5, 5, 7, 7))
+	offset.Set(5, 5, color.White)
+	got := toRGBA(offset)
+	if got.Rect != image.Rect(0, 0, 2, 2) || got.RGBAAt(0, 0) != (color.RGBA{255, 255, 255, 255}) {
+		t.Fatalf("toRGBA = %v, %v", got.Rect, got.RGBAAt(0, 0))
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	encode := map[string]func(img image.Image) []byte{
+		"a.jpg": func(img image.Image) []byte {
+			var b bytes.Buffer
+			jpeg.Encode(&b, img, nil)
+			return b.Bytes()
+		},
+		"a.png": func(img image.Image) []byte {
+			var b bytes.Buffer
+			png.Encode(&b, img)
+			return b.Bytes()
+		},
+		"a.gif": func(img image.Image) []byte {
+			var b bytes.Buffer
+			gif.Encode(&b, img, nil)
+			return b.Bytes()
+		},
+	}
+	want := map[string]image.Point{
+		VariantThumbnail: {32, 16},
+		VariantMedium:    {100, 50},
+	}
+	for key, enc := range encode {
+		t.Run(key, func(t *testing.T) {
+			g, store := testGenerator(t)
+			ctx := context.Background()
+			if err := store.Put(ctx, key, enc(image.NewRGBA(image.Rect(0, 0, 100, 50))), storage.ContentType(key)); err != nil {
+				t.Fatal(err)
+			}
+			if err := g.Generate(ctx, key); err != nil {
+				t.Fatal(err)
+			}
+			for variant, size := range want {
+				data, err := storage.ReadAll(ctx, store, VariantPath(key, variant))
+				if err != nil {
+					t.Fatal(err)
+				}
+				header, _, err := image.DecodeConfig(bytes.NewReader(data))
+				if err != nil || header.Width != size.X || header.Height != size.Y {
+					t.Fatalf("%s = %dx%d, %v, want %v", variant, header.Width, header.Height, err, size)
+				}
+			}
+		})
+	}
+}
+
+func TestEnqueueDefersWhenFull(t *testing.T) {
+	g, store := testGenerator(t)
+	ctx := context.Background()
+	var data bytes.Buffer
+	png.Encode(&data, image.NewRGBA(image.Rect(0, 0, 100, 50)))
+	if err := store.Put(ctx, "late.png", data.Bytes(), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < cap(g.jobs); i++ {
+		g.Enqueue("queued.png")
+	}
+	g.Enqueue("late.png")
+	if len(g.deferred) != 1 {
+		t.Fatalf("deferred = %v, want late.png", g.deferred)
+	}
+	g.retryDeferred(ctx)
+	if len(g.deferred) != 0 {
+		t.Fatalf("deferred = %v after retry", g.deferred)
+	}
+	for _, key := range VariantKeys("late.png") {
+		if exists, err := store.Exists(ctx, key); err != nil || !exists {
+			t.Fatalf("%s exists = %v, %v", key, exists, err)
+		}
+	}
+}
@@ -10,14 +10,25 @@ import (
 func Load() (err error) {
 	var file *os.File
 	if file, err = os.Open("./.env"); err != nil {
-		return errors.New(".env file missing")
+		// .env is optional, the real environment and config file are enough
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
 	}
 	defer file.Close()
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
-		pair := strings.SplitN(scanner.Text(), "=", 2)
-		if os.Getenv(pair[0]) != "" {
-			break
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pair := strings.SplitN(line, "=", 2)
+		if len(pair) != 2 {
+			return errors.New("malformed .env line: " + line)
+		}
+		if _, ok := os.LookupEnv(pair[0]); ok {
+			continue
 		}
 		os.Setenv(pair[0], pair[1])
 	}
@@ -0,0 +1,367 @@
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// renderInline renders the spans of a paragraph or heading. Newlines
+// become line breaks.
+func renderInline(s string) string {
+	var out strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && isPunct(s[i+1]):
+			out.WriteString(escape(s[i+1 : i+2]))
+			i += 2
+		case c == '\n':
+			out.WriteString("<br>\n")
+			i++
+		case c == '&':
+			if text, n, ok := entityAt(s, i); ok {
+				out.WriteString(escape(text))
+				i += n
+			} else {
+				out.WriteString("&amp;")
+				i++
+			}
+		case c == '`':
+			i = codeSpan(&out, s, i)
+		case c == '[':
+			if end, ok := link(&out, s, i); ok {
+				i = end
+			} else {
+				out.WriteString("[")
+				i++
+			}
+		case c == '<':
+			if end, ok := autolink(&out, s, i); ok {
+				i = end
+			} else {
+				out.WriteString("&lt;")
+				i++
+			}
+		case c == 'h' && (i == 0 || isSpace(s[i-1]) || s[i-1] == '(') &&
+			(strings.HasPrefix(s[i:], "https://") || strings.HasPrefix(s[i:], "http://")):
+			i = bareURL(&out, s, i)
+		case c == '*' || c == '_' || c == '~':
+			i = emphasis(&out, s, i)
+		default:
+			out.WriteString(escape(s[i : i+1]))
+			i++
+		}
+	}
+	return out.String()
+}
+
+// codeSpan renders the code span opening at s[i], or the backticks as text
+// when nothing closes them.
+func codeSpan(out *strings.Builder, s string, i int) int {
+	run := runLength(s, i, '`')
+	delim := s[i : i+run]
+	for j := i + run; j < len(s); {
+		k := strings.Index(s[j:], delim)
+		if k < 0 {
+			break
+		}
+		k += j
+		if runLength(s, k, '`') != run {
+			j = k + runLength(s, k, '`')
+			continue
+		}
+		code := strings.ReplaceAll(s[i+run:k], "\n", " ")
+		if len(code) > 1 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+			code = code[1 : len(code)-1]
+		}
+		out.WriteString("<code>" + escape(code) + "</code>")
+		return k + run
+	}
+	out.WriteString(delim)
+	return i + run
+}
+
+// link renders [text](destination "title") at s[i]. Links to unsafe URLs
+// keep only their text.
+func link(out *strings.Builder, s string, i int) (int, bool) {
+	closing := matchingBracket(s, i)
+	if closing < 0 || closing+1 >= len(s) || s[closing+1] != '(' {
+		return i, false
+	}
+	// destinations may hold balanced parentheses
+	end, depth := -1, 0
+	for j := closing + 2; j < len(s) && end < 0; j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				end = j
+			}
+			depth--
+		}
+	}
+	if end < 0 {
+		return i, false
+	}
+	text := s[i+1 : closing]
+	target := strings.TrimSpace(s[closing+2 : end])
+	destination, title := target, ""
+	if space := strings.IndexAny(target, " \t\n"); space >= 0 {
+		destination = target[:space]
+		title = strings.TrimSpace(target[space:])
+		if len(title) < 2 || title[0] != title[len(title)-1] || (title[0] != '"' && title[0] != '\'') {
+			return i, false
+		}
+		title = title[1 : len(title)-1]
+	}
+	destination = decodeEntities(strings.TrimSuffix(strings.TrimPrefix(destination, "<"), ">"))
+	title = decodeEntities(title)
+	if !SafeURL(destination) {
+		out.WriteString(renderInline(text))
+		return end + 1, true
+	}
+	out.WriteString(`<a href="` + escape(destination) + `"`)
+	if title != "" {
+		out.WriteString(` title="` + escape(title) + `"`)
+	}
+	out.WriteString(">" + renderInline(text) + "</a>")
+	return end + 1, true
+}
+
+// matchingBracket returns the index of the ] closing the [ at s[i], or -1.
+func matchingBracket(s string, i int) int {
+	depth := 0
+	for j := i; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '`':
+			// brackets inside code don't count
+			if end := strings.Index(s[j+1:], "`"); end >= 0 {
+				j += end + 1
+			}
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// autolink renders <https://...> and <mailto:...> at s[i].
+func autolink(out *strings.Builder, s string, i int) (int, bool) {
+	end := strings.IndexAny(s[i+1:], "<> \t\n")
+	if end < 0 || s[i+1+end] != '>' {
+		return i, false
+	}
+	target := s[i+1 : i+1+end]
+	lower := strings.ToLower(target)
+	if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") && !strings.HasPrefix(lower, "mailto:") {
+		return i, false
+	}
+	if !SafeURL(target) {
+		return i, false
+	}
+	text := target
+	if strings.HasPrefix(lower, "mailto:") {
+		text = target[len("mailto:"):]
+	}
+	out.WriteString(`<a href="` + escape(target) + `">` + escape(text) + "</a>")
+	return i + end + 2, true
+}
+
+// bareURL links a URL written out in the text. Trailing punctuation is
+// left to the sentence, and so is a closing parenthesis it didn't open.
+func bareURL(out *strings.Builder, s string, i int) int {
+	end := i
+	for end < len(s) && !isSpace(s[end]) && s[end] != '<' {
+		end++
+	}
+	target := s[i:end]
+	for target != "" {
+		last := target[len(target)-1]
+		if strings.IndexByte(".,:;!?'\"*_~", last) >= 0 ||
+			(last == ')' && strings.Count(target, "(") < strings.Count(target, ")")) {
+			target = target[:len(target)-1]
+			continue
+		}
+		break
+	}
+	if !SafeURL(target) {
+		out.WriteString(escape(s[i : i+1]))
+		return i + 1
+	}
+	out.WriteString(`<a href="` + escape(target) + `">` + escape(target) + "</a>")
+	return i + len(target)
+}
+
+// emphasis renders the *, _ or ~~ delimited span opening at s[i]: one
+// delimiter for emphasis, two for strong emphasis, three for both, and
+// two tildes for strikethrough. Underscores inside words are text, as in
+// snake_case.
+func emphasis(out *strings.Builder, s string, i int) int {
+	c := s[i]
+	run := runLength(s, i, c)
+	if c == '~' && run != 2 {
+		out.WriteString(s[i : i+run])
+		return i + run
+	}
+	if run > 3 {
+		out.WriteString(escape(s[i : i+run]))
+		return i + run
+	}
+	opens := i+run < len(s) && !isSpace(s[i+run])
+	if c == '_' && i > 0 && isWord(s[i-1]) {
+		opens = false
+	}
+	if opens {
+		if closer := findCloser(s, i+run, c, run); closer >= 0 {
+			inner := renderInline(s[i+run : closer])
+			switch {
+			case c == '~':
+				out.WriteString("<del>" + inner + "</del>")
+			case run == 1:
+				out.WriteString("<em>" + inner + "</em>")
+			case run == 2:
+				out.WriteString("<strong>" + inner + "</strong>")
+			default:
+				out.WriteString("<em><strong>" + inner + "</strong></em>")
+			}
+			return closer + run
+		}
+	}
+	out.WriteString(escape(s[i : i+run]))
+	return i + run
+}
+
+// findCloser returns the index of the run of exactly run delimiters c that
+// closes a span opened before start, or -1. Code spans and escapes are
+// skipped.
+func findCloser(s string, start int, c byte, run int) int {
+	for j := start; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '`':
+			n := runLength(s, j, '`')
+			if end := strings.Index(s[j+n:], s[j:j+n]); end >= 0 {
+				j += n + end + n - 1
+			} else {
+				j += n - 1
+			}
+		case c:
+			n := runLength(s, j, c)
+			closes := j > start && !isSpace(s[j-1])
+			if c == '_' && j+n < len(s) && isWord(s[j+n]) {
+				closes = false
+			}
+			if closes && n == run {
+				return j
+			}
+			j += n - 1
+		}
+	}
+	return -1
+}
+
+// entity matches the character references CommonMark decodes: decimal,
+// hexadecimal and named ones.
+var entity = regexp.MustCompile(`^&(?:#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[A-Za-z][A-Za-z0-9]{1,31});`)
+
+// entityAt returns the text of the character reference at s[i] and its
+// length. An unknown name isn't a reference and stays as it is written.
+func entityAt(s string, i int) (text string, n int, ok bool) {
+	ref := entity.FindString(s[i:])
+	if ref == "" {
+		return "", 0, false
+	}
+	text = html.UnescapeString(ref)
+	// a known name is one or two characters; html also decodes the known
+	// prefix of an unknown name, as in &ampx;, which leaves more
+	if text == ref || utf8.RuneCountInString(text) > 2 {
+		return "", 0, false
+	}
+	return text, len(ref), true
+}
+
+// decodeEntities replaces the character references in s with their text.
+func decodeEntities(s string) string {
+	var out strings.Builder
+	for i := 0; i < len(s); {
+		if s[i] == '&' {
+			if text, n, ok := entityAt(s, i); ok {
+				out.WriteString(text)
+				i += n
+				continue
+			}
+		}
+		out.WriteByte(s[i])
+		i++
+	}
+	return out.String()
+}
+
+func runLength(s string, i int, c byte) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == c {
+		n++
+	}
+	return n
+}
+
+// SafeURL reports whether a link may point at u: http, https and mailto
+// URLs, and paths and fragments on the forum itself.
+func SafeURL(u string) bool {
+	if u == "" || strings.ContainsAny(u, " \t\n\r\x00\"'<>`") {
+		return false
+	}
+	if strings.HasPrefix(u, "#") || (strings.HasPrefix(u, "/") && !strings.HasPrefix(u, "//")) {
+		return true
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(parsed.Scheme) {
+	case "http", "https":
+		return parsed.Host != ""
+	case "mailto":
+		return parsed.Opaque != ""
+	}
+	return false
+}
+
+func isPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+func isWord(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// escape escapes text for HTML content and quoted attribute values.
+func escape(s string) string {
+	return htmlEscaper.Replace(s)
+}
+
+var htmlEscaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	`"`, "&#34;",
+	"'", "&#39;",
+)
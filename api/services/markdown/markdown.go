@@ -0,0 +1,360 @@
+// Package markdown renders the Markdown of posts and comments to HTML that
+// is safe to embed in a page. Raw HTML in the source is escaped as text, and
+// the rendered HTML still goes through Sanitize.
+//
+// The syntax covers what forum posts use: paragraphs, where a single
+// newline is a line break, ATX headings, fenced code blocks, block quotes,
+// ordered and unordered lists, horizontal rules, and inline code, links,
+// autolinks, emphasis, strong emphasis and strikethrough.
+package markdown
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Render converts src to sanitized HTML.
+func Render(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\r", "\n")
+	src = strings.ReplaceAll(src, "\x00", "�")
+	var out strings.Builder
+	renderBlocks(&out, strings.Split(src, "\n"), false)
+	return Sanitize(strings.TrimSuffix(out.String(), "\n"))
+}
+
+// renderBlocks renders lines as a sequence of blocks. Paragraphs of tight
+// list items are written without their <p>.
+func renderBlocks(out *strings.Builder, lines []string, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isBlank(line):
+			i++
+		case isFence(line):
+			i = renderFence(out, lines, i)
+		case headingLevel(line) > 0:
+			level := headingLevel(line)
+			text := strings.TrimSpace(strings.TrimSpace(line)[level:])
+			text = strings.TrimSpace(strings.TrimRight(text, "#"))
+			tag := "h" + strconv.Itoa(level)
+			out.WriteString("<" + tag + ">" + renderInline(text) + "</" + tag + ">\n")
+			i++
+		case isRule(line):
+			out.WriteString("<hr>\n")
+			i++
+		case isQuote(line):
+			i = renderQuote(out, lines, i)
+		case isListItem(line):
+			i = renderList(out, lines, i)
+		default:
+			i = renderParagraph(out, lines, i, tight)
+		}
+	}
+}
+
+func renderParagraph(out *strings.Builder, lines []string, i int, tight bool) int {
+	var text []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if isBlank(line) || (len(text) > 0 && startsBlock(line)) {
+			break
+		}
+		text = append(text, strings.TrimSpace(line))
+	}
+	if tight {
+		out.WriteString(renderInline(strings.Join(text, "\n")) + "\n")
+	} else {
+		out.WriteString("<p>" + renderInline(strings.Join(text, "\n")) + "</p>\n")
+	}
+	return i
+}
+
+// renderFence renders the code block opening at lines[i] up to its closing
+// fence, or to the end of the text when it has none.
+func renderFence(out *strings.Builder, lines []string, i int) int {
+	opener := lines[i]
+	indent := indentation(opener)
+	fence := strings.TrimLeft(opener, " ")
+	marker := fence[0]
+	length := len(fence) - len(strings.TrimLeft(fence, string(marker)))
+	language := ""
+	if info := strings.Fields(fence[length:]); len(info) > 0 {
+		language = info[0]
+	}
+	var code []string
+	for i++; i < len(lines); i++ {
+		line := lines[i]
+		closing := strings.TrimSpace(line)
+		if indentation(line) < 4 && len(closing) >= length &&
+			strings.Trim(closing, string(marker)) == "" && closing[0] == marker {
+			i++
+			break
+		}
+		code = append(code, dedent(line, indent))
+	}
+	out.WriteString("<pre><code")
+	if isLanguage(language) {
+		out.WriteString(` class="language-` + escape(language) + `"`)
+	}
+	out.WriteString(">")
+	for _, line := range code {
+		out.WriteString(escape(line) + "\n")
+	}
+	out.WriteString("</code></pre>\n")
+	return i
+}
+
+// renderQuote renders the block quote at lines[i]. Lines without a marker
+// still belong to a quoted paragraph they continue.
+func renderQuote(out *strings.Builder, lines []string, i int) int {
+	var inner []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if isQuote(line) {
+			text := strings.TrimPrefix(strings.TrimLeft(line, " "), ">")
+			if strings.HasPrefix(text, " ") {
+				text = text[1:]
+			}
+			inner = append(inner, text)
+			continue
+		}
+		if isBlank(line) || startsBlock(line) || len(inner) == 0 || isBlank(inner[len(inner)-1]) {
+			break
+		}
+		inner = append(inner, line)
+	}
+	out.WriteString("<blockquote>\n")
+	renderBlocks(out, inner, false)
+	out.WriteString("</blockquote>\n")
+	return i
+}
+
+// listItem is the parsed marker of a list item line.
+type listItem struct {
+	ordered bool
+	bullet  byte
+	start   int
+	// content is the column the item's text starts at; continuation lines
+	// are indented at least that much
+	content int
+}
+
+func parseListItem(line string) (item listItem, ok bool) {
+	indent := indentation(line)
+	if indent > 3 {
+		return item, false
+	}
+	rest := line[indent:]
+	markerEnd := 0
+	switch {
+	case rest != "" && strings.IndexByte("-*+", rest[0]) >= 0:
+		item.bullet = rest[0]
+		markerEnd = 1
+	default:
+		digits := 0
+		for digits < len(rest) && digits < 9 && rest[digits] >= '0' && rest[digits] <= '9' {
+			digits++
+		}
+		if digits == 0 || digits >= len(rest) || (rest[digits] != '.' && rest[digits] != ')') {
+			return item, false
+		}
+		item.ordered = true
+		item.bullet = rest[digits]
+		item.start, _ = strconv.Atoi(rest[:digits])
+		markerEnd = digits + 1
+	}
+	if markerEnd < len(rest) && rest[markerEnd] != ' ' {
+		return item, false
+	}
+	item.content = indent + markerEnd + 1
+	return item, true
+}
+
+func isListItem(line string) bool {
+	if isRule(line) {
+		return false
+	}
+	_, ok := parseListItem(line)
+	return ok
+}
+
+// renderList renders the list starting at lines[i]. Items are made of the
+// lines indented to their content column, and of lazy continuations of
+// their last paragraph; a blank line between or inside items makes the
+// list loose, its paragraphs then keep their <p>.
+func renderList(out *strings.Builder, lines []string, i int) int {
+	first, _ := parseListItem(lines[i])
+	var (
+		items [][]string
+		loose bool
+	)
+	current := first
+	for i < len(lines) {
+		line := lines[i]
+		if item, ok := parseListItem(line); ok && !isRule(line) && indentation(line) < current.content {
+			if item.ordered != first.ordered || item.bullet != first.bullet {
+				break
+			}
+			current = item
+			items = append(items, []string{strings.TrimLeft(line[min(item.content, len(line)):], " ")})
+			i++
+			continue
+		}
+		if isBlank(line) {
+			next := i + 1
+			for next < len(lines) && isBlank(lines[next]) {
+				next++
+			}
+			if next == len(lines) {
+				break
+			}
+			nextItem, isItem := parseListItem(lines[next])
+			continues := indentation(lines[next]) >= current.content
+			sibling := isItem && !isRule(lines[next]) && indentation(lines[next]) < current.content &&
+				nextItem.ordered == first.ordered && nextItem.bullet == first.bullet
+			if !continues && !sibling {
+				break
+			}
+			loose = true
+			items[len(items)-1] = append(items[len(items)-1], "")
+			i++
+			continue
+		}
+		last := items[len(items)-1]
+		switch {
+		case indentation(line) >= current.content:
+			items[len(items)-1] = append(last, dedent(line, current.content))
+		case !isBlank(last[len(last)-1]) && !startsBlock(line):
+			items[len(items)-1] = append(last, strings.TrimSpace(line))
+		default:
+			return closeList(out, first, items, loose, i)
+		}
+		i++
+	}
+	return closeList(out, first, items, loose, i)
+}
+
+func closeList(out *strings.Builder, first listItem, items [][]string, loose bool, i int) int {
+	tag := "ul"
+	if first.ordered {
+		tag = "ol"
+	}
+	out.WriteString("<" + tag)
+	if first.ordered && first.start != 1 {
+		out.WriteString(` start="` + strconv.Itoa(first.start) + `"`)
+	}
+	out.WriteString(">\n")
+	for _, item := range items {
+		// trailing blank lines only separate items
+		for len(item) > 0 && isBlank(item[len(item)-1]) {
+			item = item[:len(item)-1]
+		}
+		var inner strings.Builder
+		renderBlocks(&inner, item, !loose)
+		out.WriteString("<li>" + strings.TrimSuffix(inner.String(), "\n") + "</li>\n")
+	}
+	out.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// startsBlock reports whether line opens a block that interrupts a
+// paragraph.
+func startsBlock(line string) bool {
+	return isFence(line) || headingLevel(line) > 0 || isRule(line) || isQuote(line) || isListItem(line)
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func isFence(line string) bool {
+	if indentation(line) > 3 {
+		return false
+	}
+	trimmed := strings.TrimLeft(line, " ")
+	for _, fence := range []string{"```", "~~~"} {
+		if strings.HasPrefix(trimmed, fence) {
+			// a backtick fence's info string can't hold backticks, or it
+			// would be inline code
+			return fence == "~~~" || !strings.Contains(strings.TrimLeft(trimmed, "`"), "`")
+		}
+	}
+	return false
+}
+
+func headingLevel(line string) int {
+	if indentation(line) > 3 {
+		return 0
+	}
+	trimmed := strings.TrimLeft(line, " ")
+	level := len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+	if level == 0 || level > 6 || (level < len(trimmed) && trimmed[level] != ' ' && trimmed[level] != '\t') {
+		return 0
+	}
+	return level
+}
+
+// isRule reports whether line is three or more of the same -, * or _, with
+// optional spaces between them.
+func isRule(line string) bool {
+	if indentation(line) > 3 {
+		return false
+	}
+	compact := strings.ReplaceAll(strings.ReplaceAll(strings.TrimSpace(line), " ", ""), "\t", "")
+	if len(compact) < 3 || strings.IndexByte("-*_", compact[0]) < 0 {
+		return false
+	}
+	return strings.Trim(compact, compact[:1]) == ""
+}
+
+func isQuote(line string) bool {
+	return indentation(line) <= 3 && strings.HasPrefix(strings.TrimLeft(line, " "), ">")
+}
+
+// isLanguage accepts the info strings used as a code block's language
+// class.
+func isLanguage(language string) bool {
+	if language == "" || len(language) > 32 {
+		return false
+	}
+	for _, r := range language {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("+#-_.", r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// indentation is the number of leading columns of whitespace, tabs
+// counting as 4.
+func indentation(line string) int {
+	columns := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			columns++
+		case '\t':
+			columns += 4 - columns%4
+		default:
+			return columns
+		}
+	}
+	return columns
+}
+
+// dedent removes up to n columns of leading whitespace.
+func dedent(line string, n int) string {
+	columns := 0
+	for i, r := range line {
+		if columns >= n || (r != ' ' && r != '\t') {
+			return line[i:]
+		}
+		if r == '\t' {
+			columns += 4 - columns%4
+		} else {
+			columns++
+		}
+	}
+	return ""
+}
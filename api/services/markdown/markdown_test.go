@@ -0,0 +1,122 @@
+package markdown
+
+import "testing"
+
+const rel = ` rel="nofollow noopener noreferrer"`
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		// links to other schemes keep only their text
+		{"javascript link", "[x](javascript:alert(1))", "<p>x</p>"},
+		{"mixed case scheme", "[x](JaVaScRiPt:alert(1))", "<p>x</p>"},
+		{"scheme behind an entity", "[x](javascript&#58;alert(1))", "<p>x</p>"},
+		{"vbscript link", "[x](vbscript:msgbox(1))", "<p>x</p>"},
+		{"data link", "[x](data:text/html;base64,PHNjcmlwdD4=)", "<p>x</p>"},
+		{"protocol relative link", "[x](//evil.example)", "<p>x</p>"},
+		{"javascript autolink", "<javascript:alert(1)>", "<p>&lt;javascript:alert(1)&gt;</p>"},
+		{"safe link", "[x](https://example.com/a?b=1)", `<p><a href="https://example.com/a?b=1"` + rel + `>x</a></p>`},
+		{"local link", "[x](/post/1#c2)", `<p><a href="/post/1#c2"` + rel + `>x</a></p>`},
+		{"mailto autolink", "<mailto:a@example.com>", `<p><a href="mailto:a@example.com"` + rel + `>a@example.com</a></p>`},
+		{"scheme decoded from an entity", "[x](&#104;ttps://example.com)", `<p><a href="https://example.com"` + rel + `>x</a></p>`},
+
+		// nothing gets out of an attribute value
+		{"quote in destination", `[x](https://example.com/"onmouseover="alert(1))`, "<p>x</p>"},
+		{"quotes in title", `[x](https://example.com "a&quot; onclick=&quot;b")`,
+			`<p><a href="https://example.com" title="a&#34; onclick=&#34;b"` + rel + `>x</a></p>`},
+		{"ampersand in destination", "[x](/p?q=1&amp;r=2)", `<p><a href="/p?q=1&amp;r=2"` + rel + `>x</a></p>`},
+		{"language class", "```go\" onclick=\"x\nfmt.Println()\n```", "<pre><code>fmt.Println()\n</code></pre>"},
+
+		// raw HTML is text
+		{"script", "<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>"},
+		{"event handler", "<img src=x onerror=alert(1)>", "<p>&lt;img src=x onerror=alert(1)&gt;</p>"},
+		{"anchor", `<a href="javascript:x">y</a>`, "<p>&lt;a href=&#34;javascript:x&#34;&gt;y&lt;/a&gt;</p>"},
+		{"comment", "<!-- x -->", "<p>&lt;!-- x --&gt;</p>"},
+
+		// character references are decoded once, as in CommonMark
+		{"escaped tag", "&lt;b&gt;", "<p>&lt;b&gt;</p>"},
+		{"named and numeric", "&amp; &copy; &#35; &#x22;", "<p>&amp; © # &#34;</p>"},
+		{"unknown names", "&nosuch; &ampx;", "<p>&amp;nosuch; &amp;ampx;</p>"},
+		{"bare ampersand", "a & b", "<p>a &amp; b</p>"},
+		{"escaped reference", `\&lt;`, "<p>&amp;lt;</p>"},
+		{"code span keeps references", "`&lt;`", "<p><code>&amp;lt;</code></p>"},
+		{"code block keeps references", "```\n&lt;\n```", "<pre><code>&amp;lt;\n</code></pre>"},
+		{"decoded delimiters are text", "&#42;a&#42;", "<p>*a*</p>"},
+
+		// nesting
+		{"emphasis in strong", "**a *b* c**", "<p><strong>a <em>b</em> c</strong></p>"},
+		{"strong emphasis", "***x***", "<p><em><strong>x</strong></em></p>"},
+		{"underscores in words", "snake_case_name", "<p>snake_case_name</p>"},
+		{"emphasis in link", "[*a*](https://x.example)", `<p><a href="https://x.example"` + rel + `><em>a</em></a></p>`},
+		{"list in quote", "> - item **x**\n> - `y`",
+			"<blockquote>\n<ul>\n<li>item <strong>x</strong></li>\n<li><code>y</code></li>\n</ul>\n</blockquote>"},
+		{"nested lists", "- a\n  - b\n    1. c", "<ul>\n<li>a\n<ul>\n<li>b\n<ol>\n<li>c</li>\n</ol></li>\n</ul></li>\n</ul>"},
+		{"heading", "# h &amp; *e*", "<h1>h &amp; <em>e</em></h1>"},
+		{"bare url", "see https://x.example/a_(b)_c).", `<p>see <a href="https://x.example/a_(b)_c"` + rel + `>https://x.example/a_(b)_c</a>).</p>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.src); got != tt.want {
+				t.Errorf("Render(%q) =\n%q\nwant\n%q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name     string
+		fragment string
+		want     string
+	}{
+		{"unknown element", `<script>alert(1)</script>`, "alert(1)"},
+		{"unsafe href", `<a href="javascript:alert(1)">x</a>`, `<a` + rel + `>x</a>`},
+		{"entity encoded href", `<a href="jav&#x61;script:alert(1)">x</a>`, `<a` + rel + `>x</a>`},
+		{"event handler", `<p onclick="x">y</p>`, "<p>y</p>"},
+		{"class outside code", `<p class="x">y</p>`, "<p>y</p>"},
+		{"bad language class", `<code class="language-go x">y</code>`, "<code>y</code>"},
+		{"title breakout", `<a href="/x" title='a" onclick="b'>y</a>`, `<a href="/x" title="a&#34; onclick=&#34;b"` + rel + `>y</a>`},
+		{"unbalanced", "<strong><em>x</strong>", "<strong><em>x</em></strong>"},
+		{"unclosed", "<ul><li>x", "<ul><li>x</li></ul>"},
+		{"stray end tag", "x</p>", "x"},
+		{"lone angle bracket", "a < b", "a &lt; b"},
+		{"text entities", "&lt;b&gt; &amp;amp;", "&lt;b&gt; &amp;amp;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sanitize(tt.fragment); got != tt.want {
+				t.Errorf("Sanitize(%q) =\n%q\nwant\n%q", tt.fragment, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSafeURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://example.com", true},
+		{"HTTP://example.com", true},
+		{"mailto:a@example.com", true},
+		{"/post/1", true},
+		{"#top", true},
+		{"//example.com", false},
+		{"https://", false},
+		{"javascript:alert(1)", false},
+		{"JAVASCRIPT:alert(1)", false},
+		{" javascript:alert(1)", false},
+		{"java\nscript:alert(1)", false},
+		{"data:text/html,x", false},
+		{"relative/path", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := SafeURL(tt.url); got != tt.want {
+			t.Errorf("SafeURL(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
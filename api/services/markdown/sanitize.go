@@ -0,0 +1,167 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// allowedAttrs lists the elements Sanitize keeps and, for each, the
+// attributes kept on it with their check.
+var allowedAttrs = map[string]map[string]func(string) bool{
+	"p": nil, "br": nil, "hr": nil,
+	"h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil,
+	"strong": nil, "em": nil, "del": nil,
+	"pre": nil, "blockquote": nil, "ul": nil, "li": nil,
+	"code": {"class": regexp.MustCompile(`^language-[A-Za-z0-9+#._-]+$`).MatchString},
+	"ol":   {"start": regexp.MustCompile(`^[0-9]{1,9}$`).MatchString},
+	"a":    {"href": SafeURL, "title": func(string) bool { return true }},
+}
+
+var voidElements = map[string]bool{"br": true, "hr": true}
+
+// Sanitize keeps only the elements and attributes Render produces, with
+// safe values, from an HTML fragment. Anything else is dropped, leaving the
+// text inside, and unbalanced tags are closed. Links get rel="nofollow
+// noopener noreferrer".
+func Sanitize(fragment string) string {
+	var (
+		out  strings.Builder
+		open []string
+	)
+	for i := 0; i < len(fragment); {
+		lt := strings.IndexByte(fragment[i:], '<')
+		if lt < 0 {
+			out.WriteString(sanitizeText(fragment[i:]))
+			break
+		}
+		out.WriteString(sanitizeText(fragment[i : i+lt]))
+		i += lt
+		end, name, closing, attrs, ok := parseTag(fragment, i)
+		if !ok {
+			out.WriteString("&lt;")
+			i++
+			continue
+		}
+		i = end
+		checks, allowed := allowedAttrs[name]
+		switch {
+		case !allowed:
+		case closing:
+			// close up to the matching element; a stray end tag is dropped
+			for j := len(open) - 1; j >= 0; j-- {
+				if open[j] == name {
+					for len(open) > j {
+						out.WriteString("</" + open[len(open)-1] + ">")
+						open = open[:len(open)-1]
+					}
+					break
+				}
+			}
+		default:
+			out.WriteString("<" + name)
+			for _, attr := range attrs {
+				if check, ok := checks[attr[0]]; ok && check(attr[1]) {
+					out.WriteString(" " + attr[0] + `="` + escape(attr[1]) + `"`)
+				}
+			}
+			if name == "a" {
+				out.WriteString(` rel="nofollow noopener noreferrer"`)
+			}
+			out.WriteString(">")
+			if !voidElements[name] {
+				open = append(open, name)
+			}
+		}
+	}
+	for len(open) > 0 {
+		out.WriteString("</" + open[len(open)-1] + ">")
+		open = open[:len(open)-1]
+	}
+	return out.String()
+}
+
+// parseTag parses the tag, comment or declaration at s[i]. Attribute
+// values are returned unescaped.
+func parseTag(s string, i int) (end int, name string, closing bool, attrs [][2]string, ok bool) {
+	if strings.HasPrefix(s[i:], "<!--") {
+		if j := strings.Index(s[i+4:], "-->"); j >= 0 {
+			return i + 4 + j + 3, "!", false, nil, true
+		}
+		return len(s), "!", false, nil, true
+	}
+	j := i + 1
+	if j < len(s) && (s[j] == '!' || s[j] == '?') {
+		if k := strings.IndexByte(s[j:], '>'); k >= 0 {
+			return j + k + 1, "!", false, nil, true
+		}
+		return i, "", false, nil, false
+	}
+	if j < len(s) && s[j] == '/' {
+		closing = true
+		j++
+	}
+	start := j
+	for j < len(s) && isTagNameChar(s[j]) {
+		j++
+	}
+	if j == start || !isLetter(s[start]) {
+		return i, "", false, nil, false
+	}
+	name = strings.ToLower(s[start:j])
+	for {
+		for j < len(s) && (isSpace(s[j]) || s[j] == '\r' || s[j] == '\f' || s[j] == '/') {
+			j++
+		}
+		if j >= len(s) {
+			return i, "", false, nil, false
+		}
+		if s[j] == '>' {
+			return j + 1, name, closing, attrs, true
+		}
+		keyStart := j
+		for j < len(s) && !isSpace(s[j]) && strings.IndexByte("/>=\"'", s[j]) < 0 {
+			j++
+		}
+		key := strings.ToLower(s[keyStart:j])
+		if key == "" {
+			// a quote where a name should be
+			j++
+			continue
+		}
+		value := ""
+		if j < len(s) && s[j] == '=' {
+			j++
+			if j < len(s) && (s[j] == '"' || s[j] == '\'') {
+				quote := s[j]
+				k := strings.IndexByte(s[j+1:], quote)
+				if k < 0 {
+					return i, "", false, nil, false
+				}
+				value = s[j+1 : j+1+k]
+				j += k + 2
+			} else {
+				valueStart := j
+				for j < len(s) && !isSpace(s[j]) && s[j] != '>' {
+					j++
+				}
+				value = s[valueStart:j]
+			}
+		}
+		attrs = append(attrs, [2]string{key, html.UnescapeString(value)})
+	}
+}
+
+// sanitizeText re-escapes text so that it holds only well-formed entities
+// and no markup characters.
+func sanitizeText(text string) string {
+	return escape(html.UnescapeString(text))
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isTagNameChar(c byte) bool {
+	return isLetter(c) || c >= '0' && c <= '9' || c == '-'
+}
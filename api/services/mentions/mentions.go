@@ -0,0 +1,54 @@
+// Package mentions finds the @username mentions in the Markdown of posts
+// and comments.
+package mentions
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/innovember/forum/api/config"
+)
+
+var (
+	// An @ after a word character or slash is part of an email address or
+	// URL, not a mention
+	mention  = regexp.MustCompile(`(?:^|[^A-Za-z0-9_.@/-])@([A-Za-z0-9_.-]+)`)
+	codeSpan = regexp.MustCompile("(`+)[^`]*?(`+)")
+)
+
+// Parse returns the usernames mentioned in content, without duplicates and
+// in the order they first appear, up to config.MaxMentions. Mentions in
+// code blocks and code spans are only text.
+func Parse(content string) (usernames []string) {
+	var (
+		seen    = map[string]bool{}
+		inFence string
+	)
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if inFence != "" {
+			if strings.HasPrefix(trimmed, inFence) {
+				inFence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = trimmed[:3]
+			continue
+		}
+		line = codeSpan.ReplaceAllString(line, " ")
+		for _, match := range mention.FindAllStringSubmatch(line, -1) {
+			// a sentence can end right after a mention
+			username := strings.TrimRight(match[1], ".-")
+			if username == "" || seen[strings.ToLower(username)] {
+				continue
+			}
+			seen[strings.ToLower(username)] = true
+			usernames = append(usernames, username)
+			if len(usernames) == config.MaxMentions {
+				return usernames
+			}
+		}
+	}
+	return usernames
+}
@@ -0,0 +1,360 @@
+// Package oidc is the relying party side of the OpenID Connect authorization
+// code flow, with PKCE, against the providers of config.OIDCConfig.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/innovember/forum/api/config"
+)
+
+// Clock skew tolerated on the expiry and issue time of ID tokens.
+const leeway = time.Minute
+
+// Claims are the ID token claims the forum uses.
+type Claims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	AuthorizedParty   string   `json:"azp"`
+	Expiry            int64    `json:"exp"`
+	IssuedAt          int64    `json:"iat"`
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     flexBool `json:"email_verified"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username"`
+}
+
+// Provider is one configured identity provider. Its discovery document and
+// signing keys are fetched on first use and cached.
+type Provider struct {
+	Name        string
+	cfg         config.OIDCProviderConfig
+	redirectURL string
+	client      *http.Client
+
+	mu        sync.Mutex
+	discovery *discovery
+	keys      map[string]*rsa.PublicKey
+	keysAt    time.Time
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// NewProviders builds the configured providers, keyed by name.
+func NewProviders(cfg config.OIDCConfig) map[string]*Provider {
+	providers := make(map[string]*Provider, len(cfg.Providers))
+	for name, providerCfg := range cfg.Providers {
+		providers[name] = &Provider{
+			Name:        name,
+			cfg:         providerCfg,
+			redirectURL: strings.TrimSuffix(cfg.RedirectURL, "/") + "/" + name,
+			client:      &http.Client{Timeout: 10 * time.Second},
+		}
+	}
+	return providers
+}
+
+// Names lists the providers in a stable order.
+func Names(providers map[string]*Provider) []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AutoCreate reports whether first sign-ins without a matching account
+// create one.
+func (p *Provider) AutoCreate() bool {
+	return p.cfg.AutoCreate
+}
+
+// RandomToken returns a URL-safe random string for state, nonce and PKCE
+// verifier values.
+func RandomToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// AuthCodeURL is where the browser is sent to sign in at the provider.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+	values := url.Values{}
+	values.Set("response_type", "code")
+	values.Set("client_id", p.cfg.ClientID)
+	values.Set("redirect_uri", p.redirectURL)
+	values.Set("scope", strings.Join(p.scopes(), " "))
+	values.Set("state", state)
+	values.Set("nonce", nonce)
+	values.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	values.Set("code_challenge_method", "S256")
+	separator := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return d.AuthorizationEndpoint + separator + values.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the claims of the
+// verified ID token.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Claims, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.redirectURL)
+	form.Set("code_verifier", verifier)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	var token struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+	if err = p.doJSON(req, &token); err != nil {
+		return nil, fmt.Errorf("oidc %s token: %v", p.Name, err)
+	}
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("oidc %s token: no id_token in response", p.Name)
+	}
+	return p.verify(ctx, d, token.IDToken, nonce)
+}
+
+func (p *Provider) scopes() []string {
+	if len(p.cfg.Scopes) == 0 {
+		return []string{"openid", "email", "profile"}
+	}
+	return p.cfg.Scopes
+}
+
+// verify checks the RS256 signature of an ID token and its iss, aud, azp,
+// exp and nonce claims.
+func (p *Provider) verify(ctx context.Context, d *discovery, rawToken, nonce string) (*Claims, error) {
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("oidc: malformed id_token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("oidc: unsupported id_token algorithm %q", header.Alg)
+	}
+	key, err := p.key(ctx, d, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("oidc: malformed id_token signature")
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, errors.New("oidc: invalid id_token signature")
+	}
+	var claims Claims
+	if err = decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	switch {
+	case claims.Issuer != d.Issuer:
+		return nil, errors.New("oidc: id_token issuer mismatch")
+	case !claims.Audience.contains(p.cfg.ClientID):
+		return nil, errors.New("oidc: id_token audience mismatch")
+	case len(claims.Audience) > 1 && claims.AuthorizedParty != p.cfg.ClientID:
+		return nil, errors.New("oidc: id_token authorized party mismatch")
+	case now.Add(-leeway).Unix() >= claims.Expiry:
+		return nil, errors.New("oidc: id_token expired")
+	case claims.IssuedAt > now.Add(leeway).Unix():
+		return nil, errors.New("oidc: id_token issued in the future")
+	case claims.Nonce != nonce:
+		return nil, errors.New("oidc: id_token nonce mismatch")
+	case claims.Subject == "":
+		return nil, errors.New("oidc: id_token without subject")
+	}
+	return &claims, nil
+}
+
+func (p *Provider) getDiscovery(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+	issuer := strings.TrimSuffix(p.cfg.Issuer, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	var d discovery
+	if err = p.doJSON(req, &d); err != nil {
+		return nil, fmt.Errorf("oidc %s discovery: %v", p.Name, err)
+	}
+	if strings.TrimSuffix(d.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("oidc %s discovery: issuer %q does not match %q", p.Name, d.Issuer, p.cfg.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc %s discovery: missing endpoints", p.Name)
+	}
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+// key returns the signing key with kid. Keys are refetched when kid is
+// unknown, at most once a minute, so provider key rotation is picked up.
+func (p *Provider) key(ctx context.Context, d *discovery, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysAt) < time.Minute {
+		return nil, fmt.Errorf("oidc %s: unknown signing key %q", p.Name, kid)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err = p.doJSON(req, &set); err != nil {
+		return nil, fmt.Errorf("oidc %s jwks: %v", p.Name, err)
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range set.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		if errN != nil || errE != nil || len(e) > 4 {
+			continue
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	p.keys, p.keysAt = keys, time.Now()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("oidc %s: unknown signing key %q", p.Name, kid)
+}
+
+func (p *Provider) doJSON(req *http.Request, out interface{}) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, out)
+}
+
+func decodeSegment(segment string, out interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errors.New("oidc: malformed id_token segment")
+	}
+	if err = json.Unmarshal(raw, out); err != nil {
+		return errors.New("oidc: malformed id_token segment")
+	}
+	return nil
+}
+
+// audience is the aud claim, a single string or an array of them.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// flexBool accepts "true" as well as true, as some providers send
+// email_verified as a string.
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true":
+		*b = true
+	default:
+		*b = false
+	}
+	return nil
+}
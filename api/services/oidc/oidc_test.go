@@ -0,0 +1,321 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/innovember/forum/api/config"
+)
+
+const (
+	testClientID = "forum"
+	testKid      = "key-1"
+)
+
+// fakeProvider is an identity provider serving discovery, JWKS and the
+// token endpoint. The token endpoint checks the PKCE verifier against the
+// challenge of the last authorization URL and answers with idToken.
+type fakeProvider struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	issuer    string
+	challenge string
+	idToken   func(nonce string) string
+
+	mu        sync.Mutex
+	jwksCalls int
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp := &fakeProvider{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 fp.issuer,
+			"authorization_endpoint": fp.URL + "/authorize",
+			"token_endpoint":         fp.URL + "/token",
+			"jwks_uri":               fp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		fp.mu.Lock()
+		fp.jwksCalls++
+		fp.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": testKid,
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if clientID, _, _ := r.BasicAuth(); clientID != testClientID {
+			http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+			return
+		}
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(sum[:]) != fp.challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": fp.idToken(r.PostForm.Get("code"))})
+	})
+	fp.Server = httptest.NewServer(mux)
+	fp.issuer = fp.URL
+	t.Cleanup(fp.Close)
+	return fp
+}
+
+func (fp *fakeProvider) provider() *Provider {
+	return NewProviders(config.OIDCConfig{
+		RedirectURL: "https://forum.example/api/auth/oidc/callback/",
+		Providers: map[string]config.OIDCProviderConfig{
+			"fake": {Issuer: fp.URL, ClientID: testClientID, ClientSecret: "secret"},
+		},
+	})["fake"]
+}
+
+// sign returns an RS256 token of claims signed with key under kid.
+func sign(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// begin requests the authorization URL, as the handler does, and records
+// the PKCE challenge it carries at the fake provider.
+func begin(t *testing.T, fp *fakeProvider, p *Provider, nonce, verifier string) url.Values {
+	t.Helper()
+	authURL, err := p.AuthCodeURL(context.Background(), "state-1", nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp.challenge = u.Query().Get("code_challenge")
+	return u.Query()
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	fp := newFakeProvider(t)
+	query := begin(t, fp, fp.provider(), "nonce-1", "verifier-1")
+	sum := sha256.Sum256([]byte("verifier-1"))
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          "https://forum.example/api/auth/oidc/callback/fake",
+		"scope":                 "openid email profile",
+		"state":                 "state-1",
+		"nonce":                 "nonce-1",
+		"code_challenge":        base64.RawURLEncoding.EncodeToString(sum[:]),
+		"code_challenge_method": "S256",
+	}
+	for name, value := range want {
+		if got := query.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	fp := newFakeProvider(t)
+	fp.issuer = "https://evil.example"
+	if _, err := fp.provider().AuthCodeURL(context.Background(), "s", "n", "v"); err == nil ||
+		!strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("err = %v, want issuer mismatch", err)
+	}
+}
+
+func TestExchange(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Unix()
+	claims := func(fp *fakeProvider, change func(map[string]interface{})) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":            fp.issuer,
+			"sub":            "subject-1",
+			"aud":            testClientID,
+			"exp":            now + 300,
+			"iat":            now,
+			"nonce":          "nonce-1",
+			"email":          "user@example.com",
+			"email_verified": "true",
+		}
+		if change != nil {
+			change(c)
+		}
+		return c
+	}
+	tests := []struct {
+		name     string
+		verifier string
+		token    func(t *testing.T, fp *fakeProvider) string
+		wantErr  string
+	}{
+		{
+			name: "valid",
+			token: func(t *testing.T, fp *fakeProvider) string {
+				return sign(t, fp.key, testKid, claims(fp, nil))
+			},
+		},
+		{
+			name: "bad signature",
+			token: func(t *testing.T, fp *fakeProvider) string {
+				return sign(t, otherKey, testKid, claims(fp, nil))
+			},
+			wantErr: "invalid id_token signature",
+		},
+		{
+			name: "tampered claims",
+			token: func(t *testing.T, fp *fakeProvider) string {
+				parts := strings.Split(sign(t, fp.key, testKid, claims(fp, nil)), ".")
+				payload, _ := json.Marshal(claims(fp, func(c map[string]interface{}) { c["sub"] = "admin" }))
+				return parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + parts[2]
+			},
+			wantErr: "invalid id_token signature",
+		},
+		{
+			name: "unknown key",
+			token: func(t *testing.T, fp *fakeProvider) string {
+				return sign(t, fp.key, "key-2", claims(fp, nil))
+			},
+			wantErr: "unknown signing key",
+		},
+		{
+			name: "nonce mismatch",
+			token: func(t *testing.T, fp *fakeProvider) string {
+				return sign(t, fp.key, testKid, claims(fp, func(c map[string]interface{}) { c["nonce"] = "replayed" }))
+			},
+			wantErr: "nonce mismatch",
+		},
+		{
+			name: "issuer mismatch",
+			token: func(t *testing.T, fp *fakeProvider) string {
+				return sign(t, fp.key, testKid, claims(fp, func(c map[string]interface{}) { c["iss"] = "https://evil.example" }))
+			},
+			wantErr: "issuer mismatch",
+		},
+		{
+			name: "audience mismatch",
+			token: func(t *testing.T, fp *fakeProvider) string {
+				return sign(t, fp.key, testKid, claims(fp, func(c map[string]interface{}) { c["aud"] = "other-client" }))
+			},
+			wantErr: "audience mismatch",
+		},
+		{
+			name: "several audiences without azp",
+			token: func(t *testing.T, fp *fakeProvider) string {
+				return sign(t, fp.key, testKid, claims(fp, func(c map[string]interface{}) {
+					c["aud"] = []string{testClientID, "other-client"}
+				}))
+			},
+			wantErr: "authorized party mismatch",
+		},
+		{
+			name: "expired",
+			token: func(t *testing.T, fp *fakeProvider) string {
+				return sign(t, fp.key, testKid, claims(fp, func(c map[string]interface{}) { c["exp"] = now - 120 }))
+			},
+			wantErr: "expired",
+		},
+		{
+			name: "unsigned",
+			token: func(t *testing.T, fp *fakeProvider) string {
+				header, _ := json.Marshal(map[string]string{"alg": "none"})
+				payload, _ := json.Marshal(claims(fp, nil))
+				return base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+			},
+			wantErr: "unsupported id_token algorithm",
+		},
+		{
+			name:     "pkce verifier mismatch",
+			verifier: "other-verifier",
+			token: func(t *testing.T, fp *fakeProvider) string {
+				return sign(t, fp.key, testKid, claims(fp, nil))
+			},
+			wantErr: "invalid_grant",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fp := newFakeProvider(t)
+			fp.idToken = func(string) string { return tt.token(t, fp) }
+			p := fp.provider()
+			begin(t, fp, p, "nonce-1", "verifier-1")
+			verifier := "verifier-1"
+			if tt.verifier != "" {
+				verifier = tt.verifier
+			}
+			got, err := p.Exchange(context.Background(), "code-1", verifier, "nonce-1")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Subject != "subject-1" || got.Email != "user@example.com" || !bool(got.EmailVerified) {
+				t.Fatalf("claims = %+v", got)
+			}
+		})
+	}
+}
+
+func TestKeysAreCached(t *testing.T) {
+	fp := newFakeProvider(t)
+	fp.idToken = func(string) string {
+		now := time.Now().Unix()
+		return sign(t, fp.key, testKid, map[string]interface{}{
+			"iss": fp.issuer, "sub": "s", "aud": testClientID,
+			"exp": now + 300, "iat": now, "nonce": "n",
+		})
+	}
+	p := fp.provider()
+	begin(t, fp, p, "n", "v")
+	for i := 0; i < 3; i++ {
+		if _, err := p.Exchange(context.Background(), "code", "v", "n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fp.jwksCalls != 1 {
+		t.Fatalf("jwks fetched %d times, want 1", fp.jwksCalls)
+	}
+}
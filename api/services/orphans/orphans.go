@@ -0,0 +1,120 @@
+// Package orphans removes uploaded files and attachments no post uses any
+// more.
+package orphans
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/post"
+	postRepo "github.com/innovember/forum/api/post/repository"
+	"github.com/innovember/forum/api/services/imaging"
+	"github.com/innovember/forum/api/services/storage"
+	"github.com/innovember/forum/api/services/worker"
+)
+
+// Init starts the job that deletes, every cfg.Interval, the uploads and
+// attachments older than cfg.OrphanUploads that no post uses, then the
+// attachment files no attachment has referenced for as long.
+func Init(workers *worker.Group, dbConn *sql.DB, store storage.Store, cfg config.PurgeConfig) {
+	workers.Go("orphan-uploads", func(ctx context.Context) {
+		CollectOrphans(ctx, dbConn, store, cfg)
+	})
+}
+
+func CollectOrphans(ctx context.Context, dbConn *sql.DB, store storage.Store, cfg config.PurgeConfig) {
+	var (
+		uploadRepository     = postRepo.NewUploadDBRepository(dbConn)
+		attachmentRepository = postRepo.NewAttachmentDBRepository(dbConn, "")
+	)
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		before := time.Now().Add(-cfg.OrphanUploads).Unix()
+		collectAttachments(ctx, attachmentRepository, store, before)
+		uploads, err := uploadRepository.GetOrphans(ctx, before)
+		if err != nil {
+			slog.Error("collect orphan uploads failed", "error", err)
+			continue
+		}
+		collected := 0
+		for i := range uploads {
+			upload := &uploads[i]
+			// the record is claimed first, so an upload attached to a post
+			// since GetOrphans keeps its files
+			deleted, err := uploadRepository.DeleteOrphan(ctx, upload)
+			if err != nil {
+				slog.Error("delete orphan upload failed", "file", upload.FileName, "error", err)
+				continue
+			}
+			if !deleted {
+				continue
+			}
+			if err = deleteFiles(ctx, store, upload.FileName); err != nil {
+				slog.Error("delete orphan upload files failed", "file", upload.FileName, "error", err)
+				continue
+			}
+			collected++
+		}
+		if collected > 0 {
+			slog.Info("collected orphan uploads", "count", collected)
+		}
+	}
+}
+
+// collectAttachments releases abandoned attachments, then deletes the blobs
+// released before that. A blob is tombstoned before its file is deleted,
+// so an upload of the same content either revives it before that or is
+// refused until the record is gone, then stores the file again.
+func collectAttachments(ctx context.Context, attachmentRepository post.AttachmentRepository, store storage.Store, before int64) {
+	deleted, err := attachmentRepository.DeleteAbandoned(ctx, before)
+	if err != nil {
+		slog.Error("collect abandoned attachments failed", "error", err)
+	} else if deleted > 0 {
+		slog.Info("collected abandoned attachments", "count", deleted)
+	}
+	blobs, err := attachmentRepository.GetReleasedBlobs(ctx, before)
+	if err != nil {
+		slog.Error("collect released blobs failed", "error", err)
+		return
+	}
+	collected := 0
+	for _, blob := range blobs {
+		if tombstoned, err := attachmentRepository.TombstoneBlob(ctx, blob.Hash); err != nil || !tombstoned {
+			if err != nil {
+				slog.Error("delete released blob failed", "file", blob.FileName, "error", err)
+			}
+			continue
+		}
+		// a failed delete leaves the tombstone, and is retried next time
+		if err = deleteFiles(ctx, store, blob.FileName); err != nil {
+			slog.Error("delete released blob failed", "file", blob.FileName, "error", err)
+			continue
+		}
+		if err = attachmentRepository.DeleteBlob(ctx, blob.Hash); err != nil {
+			slog.Error("delete released blob failed", "file", blob.FileName, "error", err)
+			continue
+		}
+		collected++
+	}
+	if collected > 0 {
+		slog.Info("collected released blobs", "count", collected)
+	}
+}
+
+func deleteFiles(ctx context.Context, store storage.Store, fileName string) error {
+	for _, key := range imaging.VariantKeys(fileName) {
+		if err := store.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package purge
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/innovember/forum/api/config"
+	postRepo "github.com/innovember/forum/api/post/repository"
+	"github.com/innovember/forum/api/services/worker"
+	userRepo "github.com/innovember/forum/api/user/repository"
+)
+
+// Init starts the job that hard-deletes posts and comments once they have
+// been soft-deleted for longer than cfg.Retention, sign-in failures once
+// they are older than login.ResetAfter, and provider sign-ins never completed.
+func Init(workers *worker.Group, dbConn *sql.DB, cfg config.PurgeConfig, login config.LoginConfig) {
+	workers.Go("purge", func(ctx context.Context) {
+		PurgeDeleted(ctx, dbConn, cfg, login)
+	})
+}
+
+func PurgeDeleted(ctx context.Context, dbConn *sql.DB, cfg config.PurgeConfig, login config.LoginConfig) {
+	var (
+		postRepository         = postRepo.NewPostDBRepository(dbConn, "")
+		commentRepository      = postRepo.NewCommentDBRepository(dbConn)
+		loginAttemptRepository = userRepo.NewLoginAttemptDBRepository(dbConn)
+		identityRepository     = userRepo.NewIdentityDBRepository(dbConn)
+		purged                 int64
+		err                    error
+	)
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		before := time.Now().Add(-cfg.Retention).Unix()
+		if purged, err = commentRepository.Purge(ctx, before); err != nil {
+			slog.Error("purge comments failed", "error", err)
+		} else if purged > 0 {
+			slog.Info("purged deleted comments", "count", purged)
+		}
+		if purged, err = postRepository.Purge(ctx, before); err != nil {
+			slog.Error("purge posts failed", "error", err)
+		} else if purged > 0 {
+			slog.Info("purged deleted posts", "count", purged)
+		}
+		if purged, err = loginAttemptRepository.DeleteStaleAttempts(ctx, time.Now().Add(-login.ResetAfter).Unix()); err != nil {
+			slog.Error("purge login attempts failed", "error", err)
+		}
+		if purged, err = identityRepository.DeleteExpiredStates(ctx, time.Now().Unix()); err != nil {
+			slog.Error("purge oidc states failed", "error", err)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type counter struct {
+	hits  int
+	reset time.Time
+}
+
+// MemoryStore keeps counters in process; they are lost on restart.
+type MemoryStore struct {
+	mu       sync.Mutex
+	counters map[string]*counter
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: make(map[string]*counter)}
+}
+
+func (ms *MemoryStore) Hit(ctx context.Context, key string, window time.Duration, now time.Time) (int, time.Time, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	c, ok := ms.counters[key]
+	if !ok || !now.Before(c.reset) {
+		c = &counter{reset: now.Add(window)}
+		ms.counters[key] = c
+	}
+	c.hits++
+	return c.hits, c.reset, nil
+}
+
+func (ms *MemoryStore) Cleanup(ctx context.Context, now time.Time) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	for key, c := range ms.counters {
+		if !now.Before(c.reset) {
+			delete(ms.counters, key)
+		}
+	}
+	return nil
+}
+
+func (ms *MemoryStore) Len(ctx context.Context) (int, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return len(ms.counters), nil
+}
@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/metrics"
+)
+
+// CleanupInterval is how often expired counters are dropped from the store.
+const CleanupInterval = time.Minute
+
+// Store counts hits per key in fixed windows.
+type Store interface {
+	// Hit records one hit on key and returns the hits in the current window
+	// and when it ends. A window of the given length starts on the first
+	// hit after the previous one ended.
+	Hit(ctx context.Context, key string, window time.Duration, now time.Time) (hits int, reset time.Time, err error)
+	// Cleanup drops counters whose window ended before now.
+	Cleanup(ctx context.Context, now time.Time) error
+	// Len returns the number of live counters.
+	Len(ctx context.Context) (int, error)
+}
+
+// Result is the outcome of one request against a policy.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+type Limiter struct {
+	store Store
+}
+
+func NewLimiter(store Store) *Limiter {
+	return &Limiter{store: store}
+}
+
+// Allow counts a request by key under the named policy.
+func (l *Limiter) Allow(ctx context.Context, name string, policy config.RateLimitPolicy, key string) (result Result, err error) {
+	var hits int
+	if hits, result.Reset, err = l.store.Hit(ctx, name+"|"+key, policy.Window, time.Now()); err != nil {
+		return Result{}, err
+	}
+	result.Limit = policy.Requests
+	result.Allowed = hits <= policy.Requests
+	if result.Allowed {
+		result.Remaining = policy.Requests - hits
+	}
+	return result, nil
+}
+
+// Len returns the number of clients currently tracked.
+func (l *Limiter) Len(ctx context.Context) (int, error) {
+	return l.store.Len(ctx)
+}
+
+// Cleanup drops expired counters every CleanupInterval until ctx is
+// cancelled.
+func (l *Limiter) Cleanup(ctx context.Context) {
+	ticker := time.NewTicker(CleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := l.store.Cleanup(ctx, time.Now()); err != nil {
+			slog.Error("rate limit cleanup failed", "error", err)
+		}
+	}
+}
+
+// NewStore builds the store selected in the config.
+func NewStore(cfg config.RateLimitConfig, dbConn *sql.DB) Store {
+	if cfg.Store == config.RateLimitSQLite {
+		return NewSQLiteStore(dbConn)
+	}
+	return NewMemoryStore()
+}
+
+// RegisterMetrics exposes the number of clients l tracks.
+func RegisterMetrics(l *Limiter) {
+	metrics.NewGaugeFunc("forum_rate_limit_visitors", "Clients tracked by the rate limiter.", nil,
+		func(ctx context.Context) ([]metrics.Sample, error) {
+			tracked, err := l.Len(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return []metrics.Sample{{Value: float64(tracked)}}, nil
+		})
+}
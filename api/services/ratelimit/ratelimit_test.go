@@ -0,0 +1,113 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/db"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func testStores(t *testing.T) map[string]Store {
+	t.Helper()
+	conn, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetMaxOpenConns(1)
+	t.Cleanup(func() { conn.Close() })
+	if err = db.CheckDB(conn, "../../db/schema.sql"); err != nil {
+		t.Fatal(err)
+	}
+	return map[string]Store{
+		"memory": NewMemoryStore(),
+		"sqlite": NewSQLiteStore(conn),
+	}
+}
+
+func TestStoreHit(t *testing.T) {
+	start := time.Now().Truncate(time.Millisecond)
+	hits := []struct {
+		key      string
+		at       time.Duration
+		wantHits int
+		// reset is when the window ends, relative to start
+		wantReset time.Duration
+	}{
+		{"a", 0, 1, time.Minute},
+		{"a", time.Second, 2, time.Minute},
+		{"b", 2 * time.Second, 1, time.Minute + 2*time.Second},
+		{"a", 59 * time.Second, 3, time.Minute},
+		// the window ended, a new one starts on this hit
+		{"a", time.Minute, 1, 2 * time.Minute},
+		{"a", 90 * time.Second, 2, 2 * time.Minute},
+	}
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for i, hit := range hits {
+				got, reset, err := store.Hit(ctx, hit.key, time.Minute, start.Add(hit.at))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != hit.wantHits || !reset.Equal(start.Add(hit.wantReset)) {
+					t.Errorf("hit %d on %s = %d until %v, want %d until %v", i, hit.key,
+						got, reset.Sub(start), hit.wantHits, hit.wantReset)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreCleanup(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now()
+			store.Hit(ctx, "expired", time.Second, now.Add(-time.Minute))
+			store.Hit(ctx, "live", time.Hour, now)
+			if err := store.Cleanup(ctx, now); err != nil {
+				t.Fatal(err)
+			}
+			if n, err := store.Len(ctx); err != nil || n != 1 {
+				t.Fatalf("Len = %d, %v, want 1", n, err)
+			}
+			if hits, _, _ := store.Hit(ctx, "live", time.Hour, now); hits != 2 {
+				t.Fatalf("live hits = %d after cleanup, want 2", hits)
+			}
+		})
+	}
+}
+
+func TestLimiterAllow(t *testing.T) {
+	policy := config.RateLimitPolicy{Requests: 2, Window: time.Minute}
+	tests := []struct {
+		policy, key   string
+		wantAllowed   bool
+		wantRemaining int
+	}{
+		{"signin", "ip:1", true, 1},
+		{"signin", "ip:1", true, 0},
+		{"signin", "ip:1", false, 0},
+		// policies and keys are counted apart
+		{"signin", "ip:2", true, 1},
+		{"signup", "ip:1", true, 1},
+	}
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			limiter := NewLimiter(store)
+			for i, tt := range tests {
+				result, err := limiter.Allow(context.Background(), tt.policy, policy, tt.key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.Allowed != tt.wantAllowed || result.Remaining != tt.wantRemaining || result.Limit != 2 {
+					t.Errorf("request %d = %+v, want allowed %v with %d remaining", i, result, tt.wantAllowed, tt.wantRemaining)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/innovember/forum/api/config"
+)
+
+// SQLiteStore keeps counters in the rate_limits table, so limits survive
+// restarts. Windows are stored in unix milliseconds.
+type SQLiteStore struct {
+	dbConn *sql.DB
+}
+
+func NewSQLiteStore(conn *sql.DB) *SQLiteStore {
+	return &SQLiteStore{dbConn: conn}
+}
+
+func (ss *SQLiteStore) Hit(ctx context.Context, key string, window time.Duration, now time.Time) (hits int, reset time.Time, err error) {
+	var (
+		tx      *sql.Tx
+		resetAt int64
+		nowMs   = now.UnixNano() / int64(time.Millisecond)
+	)
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if tx, err = ss.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return 0, time.Time{}, err
+	}
+	if _, err = tx.ExecContext(ctx, `INSERT INTO rate_limits(key, hits, reset_at)
+						 VALUES (?, 1, ?)
+						 ON CONFLICT(key) DO UPDATE SET
+						 hits = CASE WHEN reset_at <= ? THEN 1 ELSE hits + 1 END,
+						 reset_at = CASE WHEN reset_at <= ? THEN excluded.reset_at ELSE reset_at END`,
+		key, nowMs+int64(window/time.Millisecond), nowMs, nowMs); err != nil {
+		tx.Rollback()
+		return 0, time.Time{}, err
+	}
+	if err = tx.QueryRowContext(ctx, `SELECT hits, reset_at
+							  FROM rate_limits
+							  WHERE key = ?`, key).Scan(&hits, &resetAt); err != nil {
+		tx.Rollback()
+		return 0, time.Time{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, time.Time{}, err
+	}
+	return hits, time.Unix(0, resetAt*int64(time.Millisecond)), nil
+}
+
+func (ss *SQLiteStore) Cleanup(ctx context.Context, now time.Time) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if _, err = ss.dbConn.ExecContext(ctx, `DELETE FROM rate_limits
+							  WHERE reset_at <= ?`, now.UnixNano()/int64(time.Millisecond)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ss *SQLiteStore) Len(ctx context.Context) (count int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if err = ss.dbConn.QueryRowContext(ctx, `SELECT COUNT(*)
+							  FROM rate_limits
+							  WHERE reset_at > ?`, time.Now().UnixNano()/int64(time.Millisecond)).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
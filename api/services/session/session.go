@@ -3,13 +3,17 @@ package session
 import (
 	"context"
 	"database/sql"
-	"log"
+	"log/slog"
 	// "github.com/innovember/forum/api/config"
 	"time"
+
+	"github.com/innovember/forum/api/services/worker"
 )
 
-func Init(dbConn *sql.DB) {
-	go CheckSessionExpiration(dbConn)
+func Init(workers *worker.Group, dbConn *sql.DB) {
+	workers.Go("sessions", func(ctx context.Context) {
+		CheckSessionExpiration(ctx, dbConn)
+	})
 }
 
 func ResetAll(dbConn *sql.DB) (err error) {
@@ -40,50 +44,66 @@ func ResetAll(dbConn *sql.DB) (err error) {
 	return nil
 }
 
-func CheckSessionExpiration(dbConn *sql.DB) {
-	var (
-		ctx    context.Context
-		tx     *sql.Tx
-		rows   *sql.Rows
-		err    error
-		userID int64
-	)
+// CheckSessionExpiration clears expired sessions every five minutes until
+// ctx is cancelled.
+func CheckSessionExpiration(ctx context.Context, dbConn *sql.DB) {
+	ticker := time.NewTicker(time.Minute * 5)
+	defer ticker.Stop()
 	for {
-		time.Sleep(time.Minute * 5)
-		now := time.Now().Unix()
-		ctx = context.Background()
-		if tx, err = dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
-			log.Println(err)
+		select {
+		case <-ctx.Done():
 			return
+		case <-ticker.C:
+			if err := expireSessions(ctx, dbConn); err != nil {
+				slog.Error("expire sessions failed", "error", err)
+			}
 		}
-		if rows, err = tx.Query(`SELECT id
+	}
+}
+
+func expireSessions(ctx context.Context, dbConn *sql.DB) (err error) {
+	var (
+		tx      *sql.Tx
+		rows    *sql.Rows
+		userID  int64
+		userIDs []int64
+		now     = time.Now().Unix()
+	)
+	if tx, err = dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return err
+	}
+	if rows, err = tx.QueryContext(ctx, `SELECT id
 								FROM users
 								WHERE expires_at < ?`,
-			now); err != nil {
+		now); err != nil {
+		tx.Rollback()
+		return err
+	}
+	for rows.Next() {
+		if err = rows.Scan(&userID); err != nil {
+			rows.Close()
 			tx.Rollback()
-			log.Println(err)
-			return
+			return err
 		}
-		for rows.Next() {
-			rows.Scan(&userID)
-			if _, err = tx.Exec(`
+		userIDs = append(userIDs, userID)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, userID = range userIDs {
+		if _, err = tx.ExecContext(ctx, `
 							UPDATE users
 							SET session_id = ?,
 							expires_at = ?
 							WHERE id = ?`, "", 0, userID); err != nil {
-				log.Println(err)
-				return
-			}
-		}
-		err = rows.Err()
-		if err != nil {
-			log.Println(err)
-			return
-		}
-		rows.Close()
-		if err = tx.Commit(); err != nil {
-			log.Println(err)
-			return
+			tx.Rollback()
+			return err
 		}
 	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,43 @@
+// Package slug makes the URL names of categories.
+package slug
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MaxLength is the longest slug Make returns, in runes.
+const MaxLength = 64
+
+// Make lowercases s and joins its runs of letters and digits with dashes,
+// so "Go & Rust: News" becomes "go-rust-news". Letters of any script are
+// kept. A name without any gives "".
+func Make(s string) string {
+	var (
+		out         []rune
+		pendingDash bool
+	)
+	for _, r := range strings.ToLower(s) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			pendingDash = len(out) > 0
+			continue
+		}
+		if pendingDash {
+			if len(out)+1 >= MaxLength {
+				break
+			}
+			out = append(out, '-')
+			pendingDash = false
+		}
+		if len(out) >= MaxLength {
+			break
+		}
+		out = append(out, r)
+	}
+	return string(out)
+}
+
+// Valid reports whether s is a slug, as Make returns them.
+func Valid(s string) bool {
+	return s != "" && Make(s) == s
+}
@@ -0,0 +1,81 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/metrics"
+)
+
+// Register exposes forum-wide gauges that are counted from the database on
+// every scrape.
+func Register(dbConn *sql.DB) {
+	metrics.NewGaugeFunc("forum_active_sessions", "Users with an unexpired session.", nil,
+		func(ctx context.Context) ([]metrics.Sample, error) {
+			return count(ctx, dbConn, nil, `SELECT COUNT(*) FROM users
+								  WHERE session_id != ''
+								  AND expires_at > ?`, time.Now().Unix())
+		})
+	metrics.NewGaugeFunc("forum_moderation_queue_depth", "Items waiting for a moderator or an admin.",
+		[]string{"queue"},
+		func(ctx context.Context) (samples []metrics.Sample, err error) {
+			queues := []struct {
+				name  string
+				query string
+				args  []interface{}
+			}{
+				{"posts", `SELECT COUNT(*) FROM posts WHERE is_approved = 0 AND deleted_at = 0`, nil},
+				{"reports", `SELECT COUNT(*) FROM post_reports WHERE pending = 1`, nil},
+				{"role_requests", `SELECT COUNT(*) FROM role_requests WHERE pending = 1`, nil},
+				{"appeals", `SELECT COUNT(*) FROM appeals WHERE status = ?`, []interface{}{config.AppealPending}},
+			}
+			for _, queue := range queues {
+				var queueSamples []metrics.Sample
+				if queueSamples, err = count(ctx, dbConn, []string{queue.name}, queue.query, queue.args...); err != nil {
+					return nil, err
+				}
+				samples = append(samples, queueSamples...)
+			}
+			return samples, nil
+		})
+	metrics.NewGaugeFunc("forum_notifications", "Notifications stored, per kind.", []string{"kind"},
+		func(ctx context.Context) (samples []metrics.Sample, err error) {
+			// post activity shares one table: mentions are flagged, and
+			// comment and comment rating notifications carry the comment
+			kinds := []struct {
+				name  string
+				query string
+			}{
+				{"post", `SELECT COUNT(*) FROM notifications WHERE COALESCE(mention, 0) = 0
+						  AND COALESCE(comment_id, 0) = 0 AND COALESCE(comment_rate_id, 0) = 0`},
+				{"comment", `SELECT COUNT(*) FROM notifications WHERE COALESCE(mention, 0) = 0
+						  AND (COALESCE(comment_id, 0) != 0 OR COALESCE(comment_rate_id, 0) != 0)`},
+				{"mention", `SELECT COUNT(*) FROM notifications WHERE mention = 1`},
+				{"role", `SELECT COUNT(*) FROM notifications_roles`},
+				{"report", `SELECT COUNT(*) FROM notifications_reports`},
+				{"moderation", `SELECT COUNT(*) FROM notifications_posts`},
+				{"appeal", `SELECT COUNT(*) FROM notifications_appeals`},
+				{"security", `SELECT COUNT(*) FROM notifications_security`},
+			}
+			for _, kind := range kinds {
+				var kindSamples []metrics.Sample
+				if kindSamples, err = count(ctx, dbConn, []string{kind.name}, kind.query); err != nil {
+					return nil, err
+				}
+				samples = append(samples, kindSamples...)
+			}
+			return samples, nil
+		})
+}
+
+func count(ctx context.Context, dbConn *sql.DB, labels []string, query string, args ...interface{}) ([]metrics.Sample, error) {
+	var total int64
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if err := dbConn.QueryRowContext(ctx, query, args...).Scan(&total); err != nil {
+		return nil, err
+	}
+	return []metrics.Sample{{Labels: labels, Value: float64(total)}}, nil
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Filesystem stores files in a local directory, served through the API.
+type Filesystem struct {
+	dir string
+}
+
+func NewFilesystem(dir string) (*Filesystem, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Filesystem{dir: dir}, nil
+}
+
+// path maps a key into the directory, refusing keys that would leave it.
+func (f *Filesystem) path(key string) (string, error) {
+	if key == "" || strings.ContainsAny(key, `/\`) || key == "." || key == ".." {
+		return "", &fs.PathError{Op: "open", Path: key, Err: fs.ErrNotExist}
+	}
+	return filepath.Join(f.dir, key), nil
+}
+
+// Put renames a temporary file into place, so a file is never served half
+// written.
+func (f *Filesystem) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	name, err := f.path(key)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(f.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// Open returns an *os.File, which callers may seek and stat.
+func (f *Filesystem) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	name, err := f.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(name)
+}
+
+func (f *Filesystem) Delete(ctx context.Context, key string) error {
+	name, err := f.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (f *Filesystem) Exists(ctx context.Context, key string) (bool, error) {
+	name, err := f.path(key)
+	if err != nil {
+		return false, nil
+	}
+	if _, err = os.Stat(name); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// List skips directories and hidden files, such as those Put is writing.
+func (f *Filesystem) List(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		keys = append(keys, entry.Name())
+	}
+	return keys, nil
+}
+
+func (f *Filesystem) URL(ctx context.Context, key string) (string, error) {
+	return "", nil
+}
+
+func (f *Filesystem) Check(ctx context.Context) error {
+	file, err := os.CreateTemp(f.dir, ".readyz-")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+func (f *Filesystem) Name() string {
+	return f.dir
+}
@@ -0,0 +1,350 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/innovember/forum/api/config"
+)
+
+// S3 stores files in a bucket of AWS S3 or a compatible server, signing
+// requests with AWS Signature Version 4.
+type S3 struct {
+	cfg    config.S3Config
+	client *http.Client
+	// now is replaced to check signatures against known ones
+	now func() time.Time
+}
+
+func NewS3(cfg config.S3Config) *S3 {
+	return &S3{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 30 * time.Second},
+		now:    time.Now,
+	}
+}
+
+// Put stores key with the Content-Disposition of ContentDisposition, which
+// the bucket serves it with.
+func (s *S3) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	resp, err := s.do(ctx, http.MethodPut, key, nil, data, http.Header{
+		"Content-Type":        {contentType},
+		"Content-Disposition": {ContentDisposition(key)},
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, nil, nil)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) Exists(ctx context.Context, key string) (bool, error) {
+	resp, err := s.do(ctx, http.MethodHead, key, nil, nil, nil)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	resp.Body.Close()
+	return true, nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List pages through ListObjectsV2.
+func (s *S3) List(ctx context.Context) ([]string, error) {
+	var (
+		keys  []string
+		token string
+	)
+	for {
+		query := url.Values{"list-type": {"2"}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range result.Contents {
+			keys = append(keys, object.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// URL is under PublicURL for a public bucket, or a presigned GET. The
+// presigned response has the type and disposition of the key even for
+// objects stored without them.
+func (s *S3) URL(ctx context.Context, key string) (string, error) {
+	if s.cfg.PublicURL != "" {
+		return strings.TrimSuffix(s.cfg.PublicURL, "/") + "/" + uriEncode(key, false), nil
+	}
+	return s.presign(key, s.cfg.SignedURLExpiry, url.Values{
+		"response-content-type":        {ContentType(key)},
+		"response-content-disposition": {ContentDisposition(key)},
+	}), nil
+}
+
+// Check asks for the bucket, which needs the endpoint up and the
+// credentials valid.
+func (s *S3) Check(ctx context.Context) error {
+	resp, err := s.do(ctx, http.MethodHead, "", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) Name() string {
+	return "s3://" + s.cfg.Bucket
+}
+
+// s3Error is an error response of the server, or its status when the body
+// says nothing, as for HEAD.
+type s3Error struct {
+	Status  int    `xml:"-"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+	Key     string `xml:"-"`
+}
+
+func (e *s3Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3 %s: %s", e.Key, http.StatusText(e.Status))
+	}
+	return fmt.Sprintf("s3 %s: %s: %s", e.Key, e.Code, e.Message)
+}
+
+func (e *s3Error) Is(target error) bool {
+	return target == fs.ErrNotExist && e.Status == http.StatusNotFound
+}
+
+// objectURL addresses key, or the bucket itself when key is empty.
+func (s *S3) objectURL(key string) *url.URL {
+	u, _ := url.Parse(s.cfg.Endpoint)
+	if s.cfg.PathStyle {
+		u.Path = "/" + s.cfg.Bucket
+		if key != "" {
+			u.Path += "/" + key
+		}
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+	u.RawPath = uriEncode(u.Path, false)
+	return u
+}
+
+// do sends a signed request, and turns any status but 2xx into an *s3Error.
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, body []byte, header http.Header) (*http.Response, error) {
+	u := s.objectURL(key)
+	u.RawQuery = canonicalQuery(query)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	s.sign(req, hashHex(body), s.now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	s3Err := &s3Error{Status: resp.StatusCode, Key: key}
+	if data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024)); len(data) > 0 {
+		xml.Unmarshal(data, s3Err)
+	}
+	return nil, s3Err
+}
+
+const (
+	sigAlgorithm    = "AWS4-HMAC-SHA256"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// sign adds the Authorization header, signing the host and every header
+// already set.
+func (s *S3) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		trimmed := make([]string, len(values))
+		for i, value := range values {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		headers[strings.ToLower(name)] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigAlgorithm, s.cfg.AccessKey, s.scope(amzDate), signedHeaders, s.signature(amzDate, canonicalRequest)))
+}
+
+// presign returns a GET URL for key that works without credentials until
+// expiry has passed. The parameters of query, such as response header
+// overrides, are signed with it.
+func (s *S3) presign(key string, expiry time.Duration, query url.Values) string {
+	amzDate := s.now().UTC().Format("20060102T150405Z")
+	u := s.objectURL(key)
+	signed := url.Values{
+		"X-Amz-Algorithm":     {sigAlgorithm},
+		"X-Amz-Credential":    {s.cfg.AccessKey + "/" + s.scope(amzDate)},
+		"X-Amz-Date":          {amzDate},
+		"X-Amz-Expires":       {strconv.Itoa(int(expiry / time.Second))},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+	for name, values := range query {
+		signed[name] = values
+	}
+	u.RawQuery = canonicalQuery(signed)
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		u.EscapedPath(),
+		u.RawQuery,
+		"host:" + u.Host + "\n",
+		"host",
+		unsignedPayload,
+	}, "\n")
+	u.RawQuery += "&X-Amz-Signature=" + s.signature(amzDate, canonicalRequest)
+	return u.String()
+}
+
+func (s *S3) scope(amzDate string) string {
+	return amzDate[:8] + "/" + s.cfg.Region + "/s3/aws4_request"
+}
+
+func (s *S3) signature(amzDate, canonicalRequest string) string {
+	stringToSign := strings.Join([]string{
+		sigAlgorithm,
+		amzDate,
+		s.scope(amzDate),
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), amzDate[:8])
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// canonicalQuery sorts and encodes a query the way signatures expect,
+// which url.Values.Encode does not: spaces are %20, not +.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes everything but unreserved characters and,
+// unless encodeSlash, slashes.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,113 @@
+// Package storage keeps uploaded files in a blob store, the local
+// filesystem or an S3-compatible bucket, behind one interface.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"path"
+
+	"github.com/innovember/forum/api/config"
+)
+
+// Store keeps files under flat keys such as "<uuid>.png". Missing keys are
+// reported with errors matching fs.ErrNotExist.
+type Store interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete succeeds when the key does not exist
+	Delete(ctx context.Context, key string) error
+	Exists(ctx context.Context, key string) (bool, error)
+	List(ctx context.Context) ([]string, error)
+	// URL returns a URL clients can fetch key from directly, public or
+	// signed, or "" when the store is only served through the API
+	URL(ctx context.Context, key string) (string, error)
+	// Check reports whether the store can be written to
+	Check(ctx context.Context) error
+	// Name describes the store in logs and readiness checks
+	Name() string
+}
+
+// New returns the store for a backend, StorageFilesystem storing under
+// images.path or StorageS3.
+func New(cfg *config.Config, backend string) (Store, error) {
+	switch backend {
+	case config.StorageFilesystem:
+		return NewFilesystem(cfg.Images.Path)
+	case config.StorageS3:
+		return NewS3(cfg.Storage.S3), nil
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", backend)
+}
+
+// Migrate copies every file of from missing in to, leaving from as it is.
+// Stored image URLs point at the API, so posts need no change once the
+// configured backend is switched to.
+func Migrate(ctx context.Context, from, to Store) (copied int, err error) {
+	var (
+		keys   []string
+		exists bool
+		data   []byte
+	)
+	if keys, err = from.List(ctx); err != nil {
+		return 0, err
+	}
+	for _, key := range keys {
+		if exists, err = to.Exists(ctx, key); err != nil {
+			return copied, err
+		}
+		if exists {
+			continue
+		}
+		if data, err = ReadAll(ctx, from, key); err != nil {
+			return copied, err
+		}
+		if err = to.Put(ctx, key, data, ContentType(key)); err != nil {
+			return copied, err
+		}
+		copied++
+		slog.Info("storage migrated", "key", key, "from", from.Name(), "to", to.Name())
+	}
+	return copied, nil
+}
+
+// ReadAll returns the content of key.
+func ReadAll(ctx context.Context, store Store, key string) ([]byte, error) {
+	file, err := store.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// Inline reports whether key is a raster image, which browsers may show in
+// place whatever headers it comes with. Anything else, SVG included, needs
+// the headers the API serves it with.
+func Inline(key string) bool {
+	switch ContentType(key) {
+	case "image/png", "image/jpeg", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
+
+// ContentDisposition has browsers show key in place when Inline, and
+// download it otherwise.
+func ContentDisposition(key string) string {
+	if Inline(key) {
+		return "inline"
+	}
+	return "attachment"
+}
+
+// ContentType is the MIME type of a key, from its extension.
+func ContentType(key string) string {
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,44 @@
+// Package tags normalizes the free-form tags of posts.
+package tags
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MaxLength is the longest tag Normalize returns, in runes.
+const MaxLength = 32
+
+// Normalize lowercases a tag, drops a leading #, and joins its words with
+// dashes, so "#Machine Learning" becomes "machine-learning". Besides
+// letters and digits it keeps + # and ., which tell c, c++ and c# apart,
+// but not a trailing dot. A tag without any letter or digit gives "".
+func Normalize(s string) string {
+	var (
+		out         []rune
+		pendingDash bool
+		hasAlnum    bool
+	)
+	for _, r := range strings.ToLower(strings.TrimPrefix(strings.TrimSpace(s), "#")) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '+' && r != '#' && r != '.' {
+			pendingDash = len(out) > 0
+			continue
+		}
+		if pendingDash {
+			if len(out)+1 >= MaxLength {
+				break
+			}
+			out = append(out, '-')
+			pendingDash = false
+		}
+		if len(out) >= MaxLength {
+			break
+		}
+		hasAlnum = hasAlnum || unicode.IsLetter(r) || unicode.IsDigit(r)
+		out = append(out, r)
+	}
+	if !hasAlnum {
+		return ""
+	}
+	return strings.TrimRight(string(out), ".-")
+}
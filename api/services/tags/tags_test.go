@@ -0,0 +1,36 @@
+package tags
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Go", "go"},
+		{"  #Machine Learning ", "machine-learning"},
+		{"machine_learning", "machine-learning"},
+		{"machine -- learning!", "machine-learning"},
+		{"C++", "c++"},
+		{"C#", "c#"},
+		{"#c#", "c#"},
+		{"node.js", "node.js"},
+		{"etc.", "etc"},
+		{"Ünïcödé Tag", "ünïcödé-tag"},
+		{"v1.2", "v1.2"},
+		{"", ""},
+		{"#", ""},
+		{"++", ""},
+		{" - ", ""},
+		{strings.Repeat("a", 40), strings.Repeat("a", MaxLength)},
+		// a dash is never left at the cut
+		{strings.Repeat("a", MaxLength-1) + " b", strings.Repeat("a", MaxLength-1)},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.in); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// Group runs the background workers of the server. Every worker gets the
+// group's context and must return once it is cancelled; Stop cancels it and
+// waits for all of them.
+type Group struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+func NewGroup(parent context.Context) *Group {
+	ctx, cancel := context.WithCancel(parent)
+	return &Group{
+		ctx:     ctx,
+		cancel:  cancel,
+		running: make(map[string]bool),
+	}
+}
+
+// Go starts fn as the worker called name.
+func (g *Group) Go(name string, fn func(ctx context.Context)) {
+	g.setRunning(name, true)
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer g.setRunning(name, false)
+		fn(g.ctx)
+		if g.ctx.Err() == nil {
+			slog.Error("worker stopped unexpectedly", "worker", name)
+		}
+	}()
+}
+
+// Stop signals every worker to return and blocks until they have.
+func (g *Group) Stop() {
+	g.cancel()
+	g.wg.Wait()
+}
+
+// Status reports for each started worker whether it is still running.
+func (g *Group) Status() map[string]bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	status := make(map[string]bool, len(g.running))
+	for name, running := range g.running {
+		status[name] = running
+	}
+	return status
+}
+
+func (g *Group) setRunning(name string, running bool) {
+	g.mu.Lock()
+	g.running[name] = running
+	g.mu.Unlock()
+}
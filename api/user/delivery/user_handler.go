@@ -3,8 +3,8 @@ package delivery
 import (
 	"encoding/json"
 	"errors"
+	"math"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
 
@@ -18,6 +18,7 @@ import (
 )
 
 type UserHandler struct {
+	cfg                   *config.Config
 	userUcase             user.UserUsecase
 	adminUcase            user.AdminUsecase
 	moderatorUcase        user.ModeratorUsecase
@@ -25,12 +26,18 @@ type UserHandler struct {
 	postUcase             post.PostUsecase
 	rateUcase             post.RateUsecase
 	categoryUcase         post.CategoryUsecase
+	tagUcase              post.TagUsecase
 	commentUcase          post.CommentUsecase
 	notificationUcase     post.NotificationUsecase
 	commentRateUcase      post.RateCommentUsecase
+	appealUcase           user.AppealUsecase
+	loginAttemptUcase     user.LoginAttemptUsecase
+	twoFactorUcase        user.TwoFactorUsecase
+	oidcUcase             user.OIDCUsecase
 }
 
 func NewUserHandler(
+	cfg *config.Config,
 	userUcase user.UserUsecase,
 	adminUcase user.AdminUsecase,
 	moderatorUcase user.ModeratorUsecase,
@@ -38,29 +45,55 @@ func NewUserHandler(
 	postUcase post.PostUsecase,
 	rateUcase post.RateUsecase,
 	categoryUcase post.CategoryUsecase,
+	tagUcase post.TagUsecase,
 	commentUcase post.CommentUsecase,
 	notificationUcase post.NotificationUsecase,
-	commentRateUcase post.RateCommentUsecase) *UserHandler {
+	commentRateUcase post.RateCommentUsecase,
+	appealUcase user.AppealUsecase,
+	loginAttemptUcase user.LoginAttemptUsecase,
+	twoFactorUcase user.TwoFactorUsecase,
+	oidcUcase user.OIDCUsecase) *UserHandler {
 	return &UserHandler{
+		cfg:                   cfg,
 		userUcase:             userUcase,
 		adminUcase:            adminUcase,
 		moderatorUcase:        moderatorUcase,
 		postUcase:             postUcase,
 		rateUcase:             rateUcase,
 		categoryUcase:         categoryUcase,
+		tagUcase:              tagUcase,
 		commentUcase:          commentUcase,
 		notificationUcase:     notificationUcase,
 		commentRateUcase:      commentRateUcase,
 		userNotificationUcase: userNotificationUcase,
+		appealUcase:           appealUcase,
+		loginAttemptUcase:     loginAttemptUcase,
+		twoFactorUcase:        twoFactorUcase,
+		oidcUcase:             oidcUcase,
 	}
 }
 
 func (uh *UserHandler) Configure(mux *http.ServeMux, mw *middleware.MiddlewareManager) {
 	// auth
-	mux.HandleFunc("/api/auth/signup", mw.SetHeaders(uh.CreateUserHandler))
-	mux.HandleFunc("/api/auth/signin", mw.SetHeaders(uh.SignIn))
+	// Signing in has no session to protect yet, but is held to the same
+	// CSRF check, so another site cannot sign a victim into its account
+	mux.HandleFunc("/api/auth/signup", mw.SetHeaders(mw.CSRF(uh.CreateUserHandler)))
+	mux.HandleFunc("/api/auth/signin", mw.SetHeaders(mw.CSRF(uh.SignIn)))
 	mux.HandleFunc("/api/auth/signout", mw.SetHeaders(mw.AuthorizedOnly(uh.SignOut)))
 	mux.HandleFunc("/api/auth/me", mw.SetHeaders(mw.AuthorizedOnly(uh.Me)))
+	mux.HandleFunc("/api/auth/csrf", mw.SetHeaders(uh.CSRFToken))
+	// sign-in with an OpenID Connect provider
+	mux.HandleFunc("/api/auth/oidc/providers", mw.SetHeaders(uh.GetOIDCProviders))
+	mux.HandleFunc("/api/auth/oidc/login/", mw.SetHeaders(uh.OIDCLogin))
+	mux.HandleFunc("/api/auth/oidc/callback/", mw.SetHeaders(uh.OIDCCallback))
+	mux.HandleFunc("/api/auth/oidc/2fa", mw.SetHeaders(mw.CSRF(uh.OIDCTwoFactor)))
+	mux.HandleFunc("/api/auth/oidc/link/", mw.SetHeaders(mw.AuthorizedOnly(uh.OIDCLink)))
+	// two-factor authentication
+	mux.HandleFunc("/api/auth/2fa", mw.SetHeaders(mw.AuthorizedOnly(uh.GetTwoFactorStatus)))
+	mux.HandleFunc("/api/auth/2fa/enroll", mw.SetHeaders(mw.AuthorizedOnly(uh.EnrollTwoFactor)))
+	mux.HandleFunc("/api/auth/2fa/confirm", mw.SetHeaders(mw.AuthorizedOnly(uh.ConfirmTwoFactor)))
+	mux.HandleFunc("/api/auth/2fa/recovery-codes", mw.SetHeaders(mw.AuthorizedOnly(uh.RegenerateRecoveryCodes)))
+	mux.HandleFunc("/api/auth/2fa/disable", mw.SetHeaders(mw.AuthorizedOnly(uh.DisableTwoFactor)))
 	// user's info
 	mux.HandleFunc("/api/users", mw.SetHeaders(uh.GetAllUsers))
 	mux.HandleFunc("/api/user/", mw.SetHeaders(uh.GetUserByID))
@@ -79,13 +112,28 @@ func (uh *UserHandler) Configure(mux *http.ServeMux, mw *middleware.MiddlewareMa
 
 	mux.HandleFunc("/api/admin/post/delete/", mw.SetHeaders(mw.AuthorizedOnly(uh.DeletePostByAdmin)))
 	mux.HandleFunc("/api/admin/comment/delete/", mw.SetHeaders(mw.AuthorizedOnly(uh.DeleteCommentByAdmin)))
+	mux.HandleFunc("/api/admin/posts/deleted", mw.SetHeaders(mw.AuthorizedOnly(uh.GetDeletedPosts)))
+	mux.HandleFunc("/api/admin/comments/deleted", mw.SetHeaders(mw.AuthorizedOnly(uh.GetDeletedComments)))
+	mux.HandleFunc("/api/admin/post/restore/", mw.SetHeaders(mw.AuthorizedOnly(uh.RestorePost)))
+	mux.HandleFunc("/api/admin/comment/restore/", mw.SetHeaders(mw.AuthorizedOnly(uh.RestoreComment)))
 
 	mux.HandleFunc("/api/admin/moderators", mw.SetHeaders(mw.AuthorizedOnly(uh.GetAllModerators)))
 	mux.HandleFunc("/api/admin/demote/moderator/", mw.SetHeaders(mw.AuthorizedOnly(uh.DemoteModerator)))
 
 	mux.HandleFunc("/api/admin/categories", mw.SetHeaders(mw.AuthorizedOnly(uh.GetAllCategories)))
 	mux.HandleFunc("/api/admin/category/add", mw.SetHeaders(mw.AuthorizedOnly(uh.CreateNewCategory)))
+	mux.HandleFunc("/api/admin/category/update", mw.SetHeaders(mw.AuthorizedOnly(uh.UpdateCategory)))
 	mux.HandleFunc("/api/admin/category/delete/", mw.SetHeaders(mw.AuthorizedOnly(uh.DeleteCategory)))
+	mux.HandleFunc("/api/admin/category/rename", mw.SetHeaders(mw.AuthorizedOnly(uh.RenameCategory)))
+	mux.HandleFunc("/api/admin/category/merge", mw.SetHeaders(mw.AuthorizedOnly(uh.MergeCategories)))
+	mux.HandleFunc("/api/admin/category/moderators/", mw.SetHeaders(mw.AuthorizedOnly(uh.GetCategoryModerators)))
+	mux.HandleFunc("/api/admin/category/moderator/assign", mw.SetHeaders(mw.AuthorizedOnly(uh.AssignCategoryModerator)))
+	mux.HandleFunc("/api/admin/category/moderator/unassign", mw.SetHeaders(mw.AuthorizedOnly(uh.UnassignCategoryModerator)))
+	mux.HandleFunc("/api/admin/moderator/scope", mw.SetHeaders(mw.AuthorizedOnly(uh.SetModeratorScope)))
+
+	mux.HandleFunc("/api/admin/tag/synonyms", mw.SetHeaders(mw.AuthorizedOnly(uh.GetAllTagSynonyms)))
+	mux.HandleFunc("/api/admin/tag/synonym/add", mw.SetHeaders(mw.AuthorizedOnly(uh.AddTagSynonym)))
+	mux.HandleFunc("/api/admin/tag/synonym/delete/", mw.SetHeaders(mw.AuthorizedOnly(uh.DeleteTagSynonym)))
 
 	// moderator
 	mux.HandleFunc("/api/moderator/reports", mw.SetHeaders(mw.AuthorizedOnly(uh.MyReports)))
@@ -105,6 +153,20 @@ func (uh *UserHandler) Configure(mux *http.ServeMux, mw *middleware.MiddlewareMa
 	mux.HandleFunc("/api/user/notifications/report/delete", mw.SetHeaders(mw.AuthorizedOnly(uh.DeletePostReportNotifications)))
 	mux.HandleFunc("/api/user/notifications/post", mw.SetHeaders(mw.AuthorizedOnly(uh.GetPostNotifications)))
 	mux.HandleFunc("/api/user/notifications/post/delete", mw.SetHeaders(mw.AuthorizedOnly(uh.DeletePostNotifications)))
+	mux.HandleFunc("/api/user/notifications/appeal", mw.SetHeaders(mw.AuthorizedOnly(uh.GetAppealNotifications)))
+	mux.HandleFunc("/api/user/notifications/appeal/delete", mw.SetHeaders(mw.AuthorizedOnly(uh.DeleteAppealNotifications)))
+	mux.HandleFunc("/api/user/notifications/security", mw.SetHeaders(mw.AuthorizedOnly(uh.GetSecurityNotifications)))
+	mux.HandleFunc("/api/user/notifications/security/delete", mw.SetHeaders(mw.AuthorizedOnly(uh.DeleteSecurityNotifications)))
+	mux.HandleFunc("/api/user/notifications/mention/settings", mw.SetHeaders(mw.AuthorizedOnly(uh.GetMentionSettings)))
+	mux.HandleFunc("/api/user/notifications/mention/settings/update", mw.SetHeaders(mw.AuthorizedOnly(uh.UpdateMentionSettings)))
+
+	// appeals
+	mux.HandleFunc("/api/appeal/actions", mw.SetHeaders(mw.AuthorizedOnly(uh.GetMyModerationActions)))
+	mux.HandleFunc("/api/appeal/create", mw.SetHeaders(mw.AuthorizedOnly(uh.CreateAppeal)))
+	mux.HandleFunc("/api/appeals", mw.SetHeaders(mw.AuthorizedOnly(uh.GetMyAppeals)))
+	mux.HandleFunc("/api/admin/appeals", mw.SetHeaders(mw.AuthorizedOnly(uh.GetPendingAppeals)))
+	mux.HandleFunc("/api/admin/appeal/reverse/", mw.SetHeaders(mw.AuthorizedOnly(uh.ReverseAppeal)))
+	mux.HandleFunc("/api/admin/appeal/uphold/", mw.SetHeaders(mw.AuthorizedOnly(uh.UpholdAppeal)))
 }
 
 func (uh *UserHandler) CreateUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -122,7 +184,7 @@ func (uh *UserHandler) CreateUserHandlerFunc(w http.ResponseWriter, r *http.Requ
 		hashedPassword string
 		status         int
 		err            error
-		adminAuthToken = os.Getenv("ADMIN_AUTH_TOKEN")
+		adminAuthToken = uh.cfg.Admin.AuthToken
 	)
 	if err = json.NewDecoder(r.Body).Decode(&input); err != nil {
 		response.Error(w, http.StatusBadRequest, err)
@@ -142,18 +204,18 @@ func (uh *UserHandler) CreateUserHandlerFunc(w http.ResponseWriter, r *http.Requ
 	if adminAuthToken != "" && adminAuthToken == input.AdminAuthToken {
 		user.Role = config.RoleAdmin
 	}
-	if status, err = uh.userUcase.Create(&user); err != nil {
+	if status, err = uh.userUcase.Create(r.Context(), &user); err != nil {
 		response.Error(w, http.StatusBadRequest, err)
 		return
 	}
 	if input.RegisterAsModerator {
 		var registeredUser *models.User
-		registeredUser, status, err = uh.userUcase.FindUserByUsername(user.Username)
+		registeredUser, status, err = uh.userUcase.FindUserByUsername(r.Context(), user.Username)
 		if err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		err = uh.userUcase.CreateRoleRequest(registeredUser.ID)
+		err = uh.userUcase.CreateRoleRequest(r.Context(), registeredUser.ID)
 		if err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
@@ -165,7 +227,7 @@ func (uh *UserHandler) CreateUserHandlerFunc(w http.ResponseWriter, r *http.Requ
 
 func (uh *UserHandler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
-		users, status, err := uh.userUcase.GetAllUsers()
+		users, status, err := uh.userUcase.GetAllUsers(r.Context())
 		if err != nil {
 			response.Error(w, http.StatusBadRequest, err)
 			return
@@ -191,41 +253,108 @@ func (uh *UserHandler) SignInFunc(w http.ResponseWriter, r *http.Request) {
 		input        models.InputUserSignIn
 		user         *models.User
 		userPassword string
-		cookie       string
-		newUUID      string
 		err          error
 		status       int
-		expiresAt    = time.Now().Add(config.SessionExpiration).Unix()
+		retryAfter   time.Duration
+		twoFactor    bool
+		codeOK       bool
+		ip           = middleware.ClientIP(r, uh.cfg.Server)
 	)
 	if err = json.NewDecoder(r.Body).Decode(&input); err != nil {
 		response.Error(w, http.StatusBadRequest, err)
 		return
 	}
-	if user, status, err = uh.userUcase.FindUserByUsername(input.Username); err != nil {
-		response.Error(w, status, err)
+	if retryAfter, err = uh.loginAttemptUcase.Check(r.Context(), input.Username, ip); err != nil {
+		response.Error(w, http.StatusInternalServerError, err)
 		return
 	}
-	if status, err = uh.userUcase.CheckSessionByUsername(user.Username); err != nil {
-		response.Error(w, status, err)
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		response.Error(w, http.StatusTooManyRequests, errors.New("too many failed sign-in attempts, try again later"))
+		return
+	}
+	if user, status, err = uh.userUcase.FindUserByUsername(r.Context(), input.Username); err != nil {
+		if status != http.StatusNotFound {
+			response.Error(w, status, err)
+			return
+		}
+		security.FakeVerifyPassword(input.Password)
+		uh.signInFailed(w, r, input.Username, nil, ip, "invalid username or password")
 		return
 	}
-	if userPassword, status, err = uh.userUcase.GetPassword(user.Username); err != nil {
+	if userPassword, status, err = uh.userUcase.GetPassword(r.Context(), user.Username); err != nil {
 		response.Error(w, status, err)
 		return
 	}
 	if err = security.VerifyPassword(userPassword, input.Password); err != nil {
-		response.Error(w, http.StatusBadRequest, err)
+		uh.signInFailed(w, r, input.Username, user, ip, "invalid username or password")
+		return
+	}
+	if status, err = uh.userUcase.CheckSessionByUsername(r.Context(), user.Username); err != nil {
+		response.Error(w, status, err)
+		return
+	}
+	// With 2FA enabled the password alone is not enough: the client is asked
+	// for a code and signs in again with it
+	if twoFactor, err = uh.twoFactorUcase.IsEnabled(r.Context(), user.ID); err != nil {
+		response.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if twoFactor {
+		if input.Code == "" {
+			response.Error(w, http.StatusUnauthorized, errors.New("two-factor code required"))
+			return
+		}
+		if codeOK, err = uh.twoFactorUcase.Verify(r.Context(), user.ID, input.Code); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !codeOK {
+			uh.signInFailed(w, r, input.Username, user, ip, "invalid two-factor code")
+			return
+		}
+	}
+	if err = uh.loginAttemptUcase.Succeed(r.Context(), input.Username); err != nil {
+		response.Error(w, http.StatusInternalServerError, err)
 		return
 	}
-	cookie, newUUID = security.GenerateCookie(r.Cookie(config.SessionCookieName))
-	if err = uh.userUcase.UpdateSession(user.ID, newUUID, expiresAt); err != nil {
+	if err = uh.startSession(w, r, user); err != nil {
 		response.Error(w, http.StatusInternalServerError, err)
 		return
 	}
-	w.Header().Set("Set-Cookie", cookie)
 	response.Success(w, "user logged in", http.StatusOK, user)
 }
 
+// startSession issues the session and CSRF cookies of a signed-in user.
+func (uh *UserHandler) startSession(w http.ResponseWriter, r *http.Request, user *models.User) (err error) {
+	var (
+		cookie    string
+		newUUID   string
+		expiresAt = time.Now().Add(uh.cfg.Session.Expiration).Unix()
+	)
+	sessionCookie, cookieErr := r.Cookie(config.SessionCookieName)
+	cookie, newUUID = security.GenerateCookie(sessionCookie, cookieErr, uh.cfg.Session)
+	if err = uh.userUcase.UpdateSession(r.Context(), user.ID, newUUID, expiresAt); err != nil {
+		return err
+	}
+	csrfCookie, csrfToken := security.GenerateCSRFCookie(uh.cfg.Session)
+	w.Header().Add("Set-Cookie", cookie)
+	w.Header().Add("Set-Cookie", csrfCookie)
+	w.Header().Set(config.CSRFHeaderName, csrfToken)
+	return nil
+}
+
+// signInFailed records the failure, a wrong two-factor code counting like a
+// wrong password. Callers pass the same message whether the username or the
+// password was wrong.
+func (uh *UserHandler) signInFailed(w http.ResponseWriter, r *http.Request, username string, user *models.User, ip string, message string) {
+	if err := uh.loginAttemptUcase.Fail(r.Context(), username, user, ip); err != nil {
+		response.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	response.Error(w, http.StatusUnauthorized, errors.New(message))
+}
+
 func (uh *UserHandler) SignOut(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
@@ -240,16 +369,16 @@ func (uh *UserHandler) SignOutFunc(w http.ResponseWriter, r *http.Request) {
 		err       error
 		status    int
 		cookie    *http.Cookie
-		expiresAt = time.Now().Add(config.SessionExpiration).Unix()
+		expiresAt = time.Now().Add(uh.cfg.Session.Expiration).Unix()
 	)
 	if cookie, err = r.Cookie(config.SessionCookieName); err != nil {
 		response.Error(w, http.StatusUnauthorized, err)
 		return
 	}
-	if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+	if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 		response.Error(w, status, err)
 	}
-	if err = uh.userUcase.UpdateSession(user.ID, "", expiresAt); err != nil {
+	if err = uh.userUcase.UpdateSession(r.Context(), user.ID, "", expiresAt); err != nil {
 		response.Error(w, http.StatusInternalServerError, err)
 	}
 	cookie = &http.Cookie{
@@ -260,381 +389,631 @@ func (uh *UserHandler) SignOutFunc(w http.ResponseWriter, r *http.Request) {
 		HttpOnly: true,
 	}
 	http.SetCookie(w, cookie)
+	http.SetCookie(w, &http.Cookie{
+		Name:     config.CSRFCookieName,
+		Value:    "",
+		MaxAge:   -1,
+		Path:     "/",
+		HttpOnly: true,
+	})
 	response.Success(w, "user logged out", http.StatusOK, nil)
 	return
 }
 
-func (uh *UserHandler) Me(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case "GET":
-		uh.MeFunc(w, r)
-	default:
+// CSRFToken issues a new CSRF token, returned in the body and the
+// X-CSRF-Token header; clients send it back in that header on every request
+// that changes state. Signed-out clients fetch one before signing up or in,
+// and get a new one with the session.
+func (uh *UserHandler) CSRFToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		csrfCookie, csrfToken := security.GenerateCSRFCookie(uh.cfg.Session)
+		w.Header().Add("Set-Cookie", csrfCookie)
+		w.Header().Set(config.CSRFHeaderName, csrfToken)
+		response.Success(w, "csrf token", http.StatusOK, map[string]string{"token": csrfToken})
+	} else {
 		http.Error(w, "Only GET method allowed, return to main page", 405)
+		return
 	}
 }
 
-func (uh *UserHandler) MeFunc(w http.ResponseWriter, r *http.Request) {
-	var (
-		user   *models.User
-		status int
-		err    error
-		cookie *http.Cookie
-	)
-	cookie, _ = r.Cookie(config.SessionCookieName)
-	if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
-		response.Error(w, status, err)
+func (uh *UserHandler) GetOIDCProviders(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		response.Success(w, "sign-in providers", http.StatusOK, uh.oidcUcase.Providers())
+	} else {
+		http.Error(w, "Only GET method allowed, return to main page", 405)
 		return
 	}
-	response.Success(w, "get user info successfully", status, user)
 }
 
-func (uh *UserHandler) GetUserByID(w http.ResponseWriter, r *http.Request) {
+// OIDCLogin sends the browser to the provider named in the path. The state
+// is also kept in a cookie, so the callback only completes in the browser
+// that started the sign-in.
+func (uh *UserHandler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
 		var (
-			user   *models.User
-			err    error
-			userID int
+			authURL string
+			state   string
+			status  int
+			err     error
 		)
-		_id := r.URL.Path[len("/api/user/"):]
-		if userID, err = strconv.Atoi(_id); err != nil {
-			response.Error(w, http.StatusBadRequest, errors.New("invalid user ID"))
-			return
-		}
-		user, err = uh.userUcase.GetUserByID(int64(userID))
-		if err != nil {
-			response.Error(w, http.StatusBadRequest, err)
+		provider := r.URL.Path[len("/api/auth/oidc/login/"):]
+		if authURL, state, status, err = uh.oidcUcase.Begin(r.Context(), provider, 0); err != nil {
+			response.Error(w, status, err)
 			return
 		}
-		response.Success(w, "fetch user by id", http.StatusOK, user)
+		http.SetCookie(w, uh.oidcCookie(config.OIDCCookieName, state, 600))
+		http.Redirect(w, r, authURL, http.StatusFound)
 	} else {
 		http.Error(w, "Only GET method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) CreateRoleRequest(w http.ResponseWriter, r *http.Request) {
+// OIDCLink starts linking the provider named in the path to the signed-in
+// user. It is a POST, so it passes the CSRF check, and the client sends the
+// browser to the returned URL itself.
+func (uh *UserHandler) OIDCLink(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "POST" {
 		var (
-			status int
-			err    error
-			cookie *http.Cookie
-			user   *models.User
+			user    *models.User
+			cookie  *http.Cookie
+			authURL string
+			state   string
+			status  int
+			err     error
 		)
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if err = uh.userUcase.CreateRoleRequest(user.ID); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
-			return
-		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
+		provider := r.URL.Path[len("/api/auth/oidc/link/"):]
+		if authURL, state, status, err = uh.oidcUcase.Begin(r.Context(), provider, user.ID); err != nil {
+			response.Error(w, status, err)
 			return
 		}
-		response.Success(w, "new role request created", http.StatusCreated, nil)
+		http.SetCookie(w, uh.oidcCookie(config.OIDCCookieName, state, 600))
+		response.Success(w, "continue at the provider", http.StatusOK, map[string]string{"url": authURL})
 	} else {
 		http.Error(w, "Only POST method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) DeleteRoleRequest(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "DELETE" {
+// OIDCCallback completes a provider sign-in with the same lockout and
+// session as SignInFunc and sends the browser back to the client. A user
+// with 2FA is sent back with the sign-in held as a challenge, which
+// OIDCTwoFactor completes with the code. A link only returns to the client.
+func (uh *UserHandler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
 		var (
-			status int
-			err    error
-			cookie *http.Cookie
-			user   *models.User
+			user       *models.User
+			cookie     *http.Cookie
+			linked     bool
+			twoFactor  bool
+			token      string
+			retryAfter time.Duration
+			status     int
+			err        error
+			query      = r.URL.Query()
+			ip         = middleware.ClientIP(r, uh.cfg.Server)
 		)
-		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+		provider := r.URL.Path[len("/api/auth/oidc/callback/"):]
+		http.SetCookie(w, uh.oidcCookie(config.OIDCCookieName, "", -1))
+		if query.Get("error") != "" {
+			response.Error(w, http.StatusUnauthorized, errors.New("sign-in refused by the provider: "+query.Get("error")))
+			return
+		}
+		if cookie, err = r.Cookie(config.OIDCCookieName); err != nil || cookie.Value == "" || cookie.Value != query.Get("state") {
+			response.Error(w, http.StatusBadRequest, errors.New("sign-in state mismatch, start again"))
+			return
+		}
+		if user, linked, status, err = uh.oidcUcase.Complete(r.Context(), provider, query.Get("state"), query.Get("code")); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if err = uh.userUcase.DeleteRoleRequest(user.ID); err != nil {
+		if linked {
+			http.Redirect(w, r, uh.cfg.ClientURL, http.StatusFound)
+			return
+		}
+		if retryAfter, err = uh.loginAttemptUcase.Check(r.Context(), user.Username, ip); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
+		if retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			response.Error(w, http.StatusTooManyRequests, errors.New("too many failed sign-in attempts, try again later"))
+			return
+		}
+		if status, err = uh.userUcase.CheckSessionByUsername(r.Context(), user.Username); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if twoFactor, err = uh.twoFactorUcase.IsEnabled(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "role request has been removed", http.StatusOK, nil)
+		if twoFactor {
+			if token, err = uh.oidcUcase.StartChallenge(r.Context(), user.ID); err != nil {
+				response.Error(w, http.StatusInternalServerError, err)
+				return
+			}
+			http.SetCookie(w, uh.oidcCookie(config.OIDC2FACookieName, token, 300))
+			http.Redirect(w, r, uh.cfg.ClientURL+"?signin=two-factor", http.StatusFound)
+			return
+		}
+		if err = uh.loginAttemptUcase.Succeed(r.Context(), user.Username); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		if err = uh.startSession(w, r, user); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		http.Redirect(w, r, uh.cfg.ClientURL, http.StatusFound)
 	} else {
-		http.Error(w, "Only DELETE method allowed, return to main page", 405)
+		http.Error(w, "Only GET method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) GetRoleRequest(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "GET" {
+// OIDCTwoFactor completes a provider sign-in held for the two-factor code.
+// The challenge is taken on the first try, so a wrong code means starting
+// the sign-in again, and counts like a wrong password.
+func (uh *UserHandler) OIDCTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
 		var (
-			status      int
-			err         error
-			cookie      *http.Cookie
-			user        *models.User
-			roleRequest *models.RoleRequest
+			input      models.InputTwoFactorCode
+			user       *models.User
+			cookie     *http.Cookie
+			userID     int64
+			retryAfter time.Duration
+			codeOK     bool
+			status     int
+			err        error
+			ip         = middleware.ClientIP(r, uh.cfg.Server)
 		)
-		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+		if err = json.NewDecoder(r.Body).Decode(&input); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		if cookie, err = r.Cookie(config.OIDC2FACookieName); err != nil || cookie.Value == "" {
+			response.Error(w, http.StatusUnauthorized, errors.New("sign-in expired or invalid, start again"))
+			return
+		}
+		http.SetCookie(w, uh.oidcCookie(config.OIDC2FACookieName, "", -1))
+		if userID, status, err = uh.oidcUcase.TakeChallenge(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if roleRequest, err = uh.userUcase.GetRoleRequestByUserID(user.ID); err != nil {
-			response.Error(w, http.StatusNotFound, err)
+		if user, err = uh.userUcase.GetUserByID(r.Context(), userID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
+		if retryAfter, err = uh.loginAttemptUcase.Check(r.Context(), user.Username, ip); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "get role request by user id", http.StatusOK, roleRequest)
+		if retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			response.Error(w, http.StatusTooManyRequests, errors.New("too many failed sign-in attempts, try again later"))
+			return
+		}
+		if codeOK, err = uh.twoFactorUcase.Verify(r.Context(), user.ID, input.Code); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !codeOK {
+			uh.signInFailed(w, r, user.Username, user, ip, "invalid two-factor code")
+			return
+		}
+		if err = uh.loginAttemptUcase.Succeed(r.Context(), user.Username); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		if err = uh.startSession(w, r, user); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "user logged in", http.StatusOK, user)
 	} else {
-		http.Error(w, "Only GET method allowed, return to main page", 405)
+		http.Error(w, "Only POST method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) GetRoleRequests(w http.ResponseWriter, r *http.Request) {
+// oidcCookie is Lax even with a strict session, since the provider sends
+// the browser back with a cross-site navigation.
+func (uh *UserHandler) oidcCookie(name, value string, maxAge int) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		MaxAge:   maxAge,
+		Path:     "/api/auth/oidc/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if uh.cfg.Session.SameSiteMode() == http.SameSiteNoneMode {
+		cookie.SameSite, cookie.Secure = http.SameSiteNoneMode, true
+	}
+	return cookie
+}
+
+func (uh *UserHandler) GetTwoFactorStatus(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
 		var (
-			status       int
-			err          error
-			cookie       *http.Cookie
-			user         *models.User
-			roleRequests []models.RoleRequest
+			status          int
+			err             error
+			cookie          *http.Cookie
+			user            *models.User
+			twoFactorStatus *models.TwoFactorStatus
 		)
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if user.Role != config.RoleAdmin {
-			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
-			return
-		}
-		if roleRequests, err = uh.adminUcase.GetAllRoleRequests(); err != nil {
+		if twoFactorStatus, err = uh.twoFactorUcase.GetStatus(r.Context(), user); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "get role requests", http.StatusOK, roleRequests)
+		response.Success(w, "two-factor status", http.StatusOK, twoFactorStatus)
 	} else {
 		http.Error(w, "Only GET method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) DismissRoleRequest(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "DELETE" {
+// EnrollTwoFactor returns a new secret and its otpauth URI. 2FA is only
+// enabled once ConfirmTwoFactor receives a code from it.
+func (uh *UserHandler) EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
 		var (
-			status        int
-			err           error
-			cookie        *http.Cookie
-			user          *models.User
-			roleRequestID int
-			roleRequest   *models.RoleRequest
+			status     int
+			err        error
+			cookie     *http.Cookie
+			user       *models.User
+			enrollment *models.TwoFactorEnrollment
 		)
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if user.Role != config.RoleAdmin {
-			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
-			return
-		}
-		_id := r.URL.Path[len("/api/admin/request/dismiss/"):]
-		if roleRequestID, err = strconv.Atoi(_id); err != nil {
-			response.Error(w, http.StatusBadRequest, errors.New("invalid requestID"))
-			return
-		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
-			return
-		}
-		if roleRequest, err = uh.userUcase.GetRoleRequestByID(int64(roleRequestID)); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
-			return
-		}
-		roleNotification := models.RoleNotification{
-			ReceiverID: roleRequest.UserID,
-			Accepted:   false,
-			Declined:   true,
-			Demoted:    false,
-		}
-		if err = uh.userNotificationUcase.CreateRoleNotification(&roleNotification); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
+		if enrollment, err = uh.twoFactorUcase.Enroll(r.Context(), user); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
 			return
 		}
-		if err = uh.adminUcase.DeleteRoleRequest(int64(roleRequestID)); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "role request has been removed", http.StatusOK, nil)
+		response.Success(w, "two-factor enrollment started", http.StatusOK, enrollment)
 	} else {
-		http.Error(w, "Only DELETE method allowed, return to main page", 405)
+		http.Error(w, "Only POST method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) AcceptRoleRequest(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "PUT" {
+// ConfirmTwoFactor enables 2FA and returns the recovery codes, which are
+// not shown again.
+func (uh *UserHandler) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
 		var (
+			input         models.InputTwoFactorCode
 			status        int
 			err           error
 			cookie        *http.Cookie
 			user          *models.User
-			roleRequestID int
-			roleRequest   *models.RoleRequest
+			recoveryCodes []string
 		)
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if user.Role != config.RoleAdmin {
-			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
-			return
-		}
-		_id := r.URL.Path[len("/api/admin/request/accept/"):]
-		if roleRequestID, err = strconv.Atoi(_id); err != nil {
-			response.Error(w, http.StatusBadRequest, errors.New("invalid requestID"))
+		if err = json.NewDecoder(r.Body).Decode(&input); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
 			return
 		}
-		if err = uh.adminUcase.UpgradeRole(int64(roleRequestID)); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
+		if recoveryCodes, err = uh.twoFactorUcase.Confirm(r.Context(), user.ID, input.Code); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
 			return
 		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
+		if recoveryCodes == nil {
+			response.Error(w, http.StatusBadRequest, errors.New("invalid two-factor code"))
 			return
 		}
-		if roleRequest, err = uh.userUcase.GetRoleRequestByID(int64(roleRequestID)); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		roleNotification := models.RoleNotification{
-			ReceiverID: roleRequest.UserID,
-			Accepted:   true,
-			Declined:   false,
-			Demoted:    false,
-		}
-		if err = uh.userNotificationUcase.CreateRoleNotification(&roleNotification); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
+		response.Success(w, "two-factor authentication enabled", http.StatusOK,
+			map[string][]string{"recoveryCodes": recoveryCodes})
+	} else {
+		http.Error(w, "Only POST method allowed, return to main page", 405)
+		return
+	}
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes, given a current code.
+func (uh *UserHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		var (
+			recoveryCodes []string
+			user          *models.User
+			err           error
+		)
+		if user = uh.verifyTwoFactorCode(w, r); user == nil {
 			return
 		}
-		if err = uh.adminUcase.DeleteRoleRequest(roleRequest.ID); err != nil {
+		if recoveryCodes, err = uh.twoFactorUcase.RegenerateRecoveryCodes(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "role request has been accepted", http.StatusOK, nil)
+		response.Success(w, "recovery codes regenerated", http.StatusOK,
+			map[string][]string{"recoveryCodes": recoveryCodes})
 	} else {
-		http.Error(w, "Only PUT method allowed, return to main page", 405)
+		http.Error(w, "Only POST method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) DeletePostByAdmin(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "DELETE" {
+// DisableTwoFactor turns 2FA off, given a current code, unless the policy
+// requires it for the user's role.
+func (uh *UserHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
 		var (
-			status int
-			err    error
-			cookie *http.Cookie
-			user   *models.User
-			postID int
-			post   *models.Post
+			user *models.User
+			err  error
 		)
-		_id := r.URL.Path[len("/api/admin/post/delete/"):]
-		if postID, err = strconv.Atoi(_id); err != nil {
-			response.Error(w, http.StatusBadRequest, errors.New("post id doesn't exist"))
+		if user = uh.verifyTwoFactorCode(w, r); user == nil {
 			return
 		}
-		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
-			response.Error(w, status, err)
+		if uh.twoFactorUcase.Required(user) {
+			response.Error(w, http.StatusForbidden, errors.New("two-factor authentication is required for your role"))
 			return
 		}
-		if user.Role != config.RoleAdmin {
-			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
+		if err = uh.twoFactorUcase.Disable(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		post, status, err = uh.postUcase.GetPostByID(user.ID, int64(postID))
+		response.Success(w, "two-factor authentication disabled", http.StatusOK, nil)
+	} else {
+		http.Error(w, "Only POST method allowed, return to main page", 405)
+		return
+	}
+}
+
+// verifyTwoFactorCode checks the code in the body against the session
+// user's 2FA. A wrong code counts like a failed sign-in, so a stolen session
+// can't guess codes past the lockout. It answers the request itself and
+// returns nil on failure.
+func (uh *UserHandler) verifyTwoFactorCode(w http.ResponseWriter, r *http.Request) *models.User {
+	var (
+		input      models.InputTwoFactorCode
+		status     int
+		err        error
+		cookie     *http.Cookie
+		user       *models.User
+		ok         bool
+		retryAfter time.Duration
+		ip         = middleware.ClientIP(r, uh.cfg.Server)
+	)
+	cookie, _ = r.Cookie(config.SessionCookieName)
+	if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+		response.Error(w, status, err)
+		return nil
+	}
+	if err = json.NewDecoder(r.Body).Decode(&input); err != nil {
+		response.Error(w, http.StatusBadRequest, err)
+		return nil
+	}
+	if retryAfter, err = uh.loginAttemptUcase.Check(r.Context(), user.Username, ip); err != nil {
+		response.Error(w, http.StatusInternalServerError, err)
+		return nil
+	}
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		response.Error(w, http.StatusTooManyRequests, errors.New("too many failed attempts, try again later"))
+		return nil
+	}
+	if ok, err = uh.twoFactorUcase.Verify(r.Context(), user.ID, input.Code); err != nil {
+		response.Error(w, http.StatusInternalServerError, err)
+		return nil
+	}
+	if !ok {
+		uh.signInFailed(w, r, user.Username, user, ip, "invalid two-factor code")
+		return nil
+	}
+	if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+		response.Error(w, http.StatusInternalServerError, err)
+		return nil
+	}
+	return user
+}
+
+func (uh *UserHandler) Me(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		uh.MeFunc(w, r)
+	default:
+		http.Error(w, "Only GET method allowed, return to main page", 405)
+	}
+}
+
+func (uh *UserHandler) MeFunc(w http.ResponseWriter, r *http.Request) {
+	var (
+		user   *models.User
+		status int
+		err    error
+		cookie *http.Cookie
+	)
+	cookie, _ = r.Cookie(config.SessionCookieName)
+	if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+		response.Error(w, status, err)
+		return
+	}
+	response.Success(w, "get user info successfully", status, user)
+}
+
+func (uh *UserHandler) GetUserByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		var (
+			user   *models.User
+			err    error
+			userID int
+		)
+		_id := r.URL.Path[len("/api/user/"):]
+		if userID, err = strconv.Atoi(_id); err != nil {
+			response.Error(w, http.StatusBadRequest, errors.New("invalid user ID"))
+			return
+		}
+		user, err = uh.userUcase.GetUserByID(r.Context(), int64(userID))
 		if err != nil {
 			response.Error(w, http.StatusBadRequest, err)
 			return
 		}
-		if err = uh.categoryUcase.DeleteFromPostCategoriesBridge(post.ID); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
+		response.Success(w, "fetch user by id", http.StatusOK, user)
+	} else {
+		http.Error(w, "Only GET method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) CreateRoleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		var (
+			status int
+			err    error
+			cookie *http.Cookie
+			user   *models.User
+		)
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
 			return
 		}
-		if err = uh.rateUcase.DeleteRatesByPostID(post.ID); err != nil {
+		if err = uh.userUcase.CreateRoleRequest(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		if err = uh.commentUcase.DeleteCommentsByPostID(post.ID); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		if err = uh.notificationUcase.DeleteNotificationsByPostID(post.ID); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
+		response.Success(w, "new role request created", http.StatusCreated, nil)
+	} else {
+		http.Error(w, "Only POST method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) DeleteRoleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "DELETE" {
+		var (
+			status int
+			err    error
+			cookie *http.Cookie
+			user   *models.User
+		)
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
 			return
 		}
-		if err = uh.commentRateUcase.DeleteCommentsRateByPostID(post.ID); err != nil {
+		if err = uh.userUcase.DeleteRoleRequest(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		if err = uh.adminUcase.DeletePostReportByPostID(post.ID); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		if status, err = uh.postUcase.Delete(post.ID); err != nil {
+		response.Success(w, "role request has been removed", http.StatusOK, nil)
+	} else {
+		http.Error(w, "Only DELETE method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) GetRoleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		var (
+			status      int
+			err         error
+			cookie      *http.Cookie
+			user        *models.User
+			roleRequest *models.RoleRequest
+		)
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
+		if roleRequest, err = uh.userUcase.GetRoleRequestByUserID(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusNotFound, err)
+			return
+		}
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		postNotification := models.PostNotification{
-			ReceiverID: post.AuthorID,
-			Approved:   false,
-			Banned:     false,
-			Deleted:    true,
+		response.Success(w, "get role request by user id", http.StatusOK, roleRequest)
+	} else {
+		http.Error(w, "Only GET method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) GetRoleRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		var (
+			status       int
+			err          error
+			cookie       *http.Cookie
+			user         *models.User
+			roleRequests []models.RoleRequest
+		)
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
+			return
 		}
-		if err = uh.userNotificationUcase.CreatePostNotification(&postNotification); err != nil {
+		if roleRequests, err = uh.adminUcase.GetAllRoleRequests(r.Context()); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "post has been deleted", http.StatusOK, nil)
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "get role requests", http.StatusOK, roleRequests)
 	} else {
-		http.Error(w, "Only DELETE method allowed, return to main page", 405)
+		http.Error(w, "Only GET method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) DeleteCommentByAdmin(w http.ResponseWriter, r *http.Request) {
+func (uh *UserHandler) DismissRoleRequest(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "DELETE" {
 		var (
-			status    int
-			err       error
-			cookie    *http.Cookie
-			user      *models.User
-			commentID int
-			comment   *models.Comment
+			status        int
+			err           error
+			cookie        *http.Cookie
+			user          *models.User
+			roleRequestID int
 		)
-		_id := r.URL.Path[len("/api/admin/comment/delete/"):]
-		if commentID, err = strconv.Atoi(_id); err != nil {
-			response.Error(w, http.StatusBadRequest, errors.New("comment id doesn't exist"))
-			return
-		}
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
@@ -642,34 +1021,65 @@ func (uh *UserHandler) DeleteCommentByAdmin(w http.ResponseWriter, r *http.Reque
 			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
 			return
 		}
-		if comment, status, err = uh.commentUcase.GetCommentByID(user.ID, int64(commentID)); err != nil {
-			response.Error(w, status, err)
+		_id := r.URL.Path[len("/api/admin/request/dismiss/"):]
+		if roleRequestID, err = strconv.Atoi(_id); err != nil {
+			response.Error(w, http.StatusBadRequest, errors.New("invalid requestID"))
 			return
 		}
-		if err = uh.notificationUcase.DeleteNotificationsByCommentID(comment.ID); err != nil {
+		if err = uh.adminUcase.DismissRoleRequest(r.Context(), int64(roleRequestID)); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		if err = uh.commentRateUcase.DeleteRatesByCommentID(comment.ID); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		if err = uh.commentUcase.Delete(comment.ID); err != nil {
+		response.Success(w, "role request has been removed", http.StatusOK, nil)
+	} else {
+		http.Error(w, "Only DELETE method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) AcceptRoleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "PUT" {
+		var (
+			status        int
+			err           error
+			cookie        *http.Cookie
+			user          *models.User
+			roleRequestID int
+		)
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
+			return
+		}
+		_id := r.URL.Path[len("/api/admin/request/accept/"):]
+		if roleRequestID, err = strconv.Atoi(_id); err != nil {
+			response.Error(w, http.StatusBadRequest, errors.New("invalid requestID"))
+			return
+		}
+		if err = uh.adminUcase.AcceptRoleRequest(r.Context(), int64(roleRequestID)); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "comment has been deleted", http.StatusOK, nil)
+		response.Success(w, "role request has been accepted", http.StatusOK, nil)
 	} else {
-		http.Error(w, "Only DELETE method allowed, return to main page", 405)
+		http.Error(w, "Only PUT method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) DeletePostByModerator(w http.ResponseWriter, r *http.Request) {
+func (uh *UserHandler) DeletePostByAdmin(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "DELETE" {
 		var (
 			status int
@@ -679,85 +1089,1071 @@ func (uh *UserHandler) DeletePostByModerator(w http.ResponseWriter, r *http.Requ
 			postID int
 			post   *models.Post
 		)
-		_id := r.URL.Path[len("/api/moderator/post/delete/"):]
+		_id := r.URL.Path[len("/api/admin/post/delete/"):]
 		if postID, err = strconv.Atoi(_id); err != nil {
 			response.Error(w, http.StatusBadRequest, errors.New("post id doesn't exist"))
 			return
 		}
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if user.Role != config.RoleModerator {
-			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only moderator users allowed"))
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
 			return
 		}
-		post, status, err = uh.postUcase.GetPostByID(user.ID, int64(postID))
+		post, status, err = uh.postUcase.GetPostByID(r.Context(), user.ID, int64(postID))
 		if err != nil {
 			response.Error(w, http.StatusBadRequest, err)
 			return
 		}
-		if err = uh.categoryUcase.DeleteFromPostCategoriesBridge(post.ID); err != nil {
+		if status, err = uh.appealUcase.DeletePost(r.Context(), newModerationAction(post, user.ID, config.ActionDeleted)); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "post has been deleted", http.StatusOK, nil)
+	} else {
+		http.Error(w, "Only DELETE method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) DeleteCommentByAdmin(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "DELETE" {
+		var (
+			status    int
+			err       error
+			cookie    *http.Cookie
+			user      *models.User
+			commentID int
+			comment   *models.Comment
+		)
+		_id := r.URL.Path[len("/api/admin/comment/delete/"):]
+		if commentID, err = strconv.Atoi(_id); err != nil {
+			response.Error(w, http.StatusBadRequest, errors.New("comment id doesn't exist"))
+			return
+		}
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
+			return
+		}
+		if comment, status, err = uh.commentUcase.GetCommentByID(r.Context(), user.ID, int64(commentID)); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if err = uh.commentUcase.Delete(r.Context(), comment.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		if err = uh.rateUcase.DeleteRatesByPostID(post.ID); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		if err = uh.commentUcase.DeleteCommentsByPostID(post.ID); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
+		response.Success(w, "comment has been deleted", http.StatusOK, nil)
+	} else {
+		http.Error(w, "Only DELETE method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) DeletePostByModerator(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "DELETE" {
+		var (
+			status int
+			err    error
+			cookie *http.Cookie
+			user   *models.User
+			postID int
+			post   *models.Post
+		)
+		_id := r.URL.Path[len("/api/moderator/post/delete/"):]
+		if postID, err = strconv.Atoi(_id); err != nil {
+			response.Error(w, http.StatusBadRequest, errors.New("post id doesn't exist"))
+			return
+		}
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleModerator {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only moderator users allowed"))
+			return
+		}
+		post, status, err = uh.postUcase.GetPostByID(r.Context(), user.ID, int64(postID))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		if !uh.canModeratePost(w, r, user.ID, post.ID) {
+			return
+		}
+		if status, err = uh.appealUcase.DeletePost(r.Context(), newModerationAction(post, user.ID, config.ActionDeleted)); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "post has been deleted", http.StatusOK, nil)
+	} else {
+		http.Error(w, "Only DELETE method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) CreatePostReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		var (
+			input  models.PostReport
+			status int
+			err    error
+			cookie *http.Cookie
+			user   *models.User
+		)
+		if err = json.NewDecoder(r.Body).Decode(&input); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleModerator {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only moderator users allowed"))
+			return
+		}
+		if !uh.canModeratePost(w, r, user.ID, input.PostID) {
+			return
+		}
+		if err = uh.moderatorUcase.CreatePostReport(r.Context(), &input); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "post report created", http.StatusCreated, input)
+	} else {
+		http.Error(w, "Only POST method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) DeletePostReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "DELETE" {
+		var (
+			status       int
+			err          error
+			cookie       *http.Cookie
+			user         *models.User
+			postReportID int
+		)
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleModerator {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only moderator users allowed"))
+			return
+		}
+		_id := r.URL.Path[len("/api/moderator/report/post/delete/"):]
+		if postReportID, err = strconv.Atoi(_id); err != nil {
+			response.Error(w, http.StatusBadRequest, errors.New("post report id doesn't exist"))
+			return
+		}
+		if err = uh.moderatorUcase.DeletePostReport(r.Context(), int64(postReportID)); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "post report has been removed", http.StatusOK, nil)
+	} else {
+		http.Error(w, "Only DELETE method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) MyReports(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		var (
+			status      int
+			err         error
+			cookie      *http.Cookie
+			user        *models.User
+			postReports []models.PostReport
+		)
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleModerator {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only moderator users allowed"))
+			return
+		}
+		if postReports, err = uh.moderatorUcase.GetMyReports(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "fetched all post reports by moderator id", http.StatusOK, postReports)
+	} else {
+		http.Error(w, "Only GET method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) GetAllCategories(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		var (
+			status     int
+			err        error
+			cookie     *http.Cookie
+			user       *models.User
+			categories []models.Category
+		)
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
+			return
+		}
+		if categories, status, err = uh.categoryUcase.GetAllCategories(r.Context()); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "all categories", status, categories)
+	} else {
+		http.Error(w, "Only GET method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) CreateNewCategory(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		var (
+			input  models.Category
+			status int
+			err    error
+			cookie *http.Cookie
+			user   *models.User
+		)
+		if err = json.NewDecoder(r.Body).Decode(&input); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
+			return
+		}
+		input.ID = 0
+		if status, err = uh.categoryUcase.CreateNewCategory(r.Context(), &input); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "new category has been created", http.StatusCreated, input)
+	} else {
+		http.Error(w, "Only POST method allowed, return to main page", 405)
+		return
+	}
+}
+
+// UpdateCategory changes every setting of a category, including its
+// parent and position.
+func (uh *UserHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "PUT" {
+		var (
+			input  models.Category
+			status int
+			err    error
+			cookie *http.Cookie
+			user   *models.User
+		)
+		if err = json.NewDecoder(r.Body).Decode(&input); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
+			return
+		}
+		if status, err = uh.categoryUcase.UpdateCategory(r.Context(), &input); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "category has been updated", http.StatusOK, input)
+	} else {
+		http.Error(w, "Only PUT method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "DELETE" {
+		var (
+			status     int
+			err        error
+			cookie     *http.Cookie
+			user       *models.User
+			categoryID int
+		)
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
+			return
+		}
+		_id := r.URL.Path[len("/api/admin/category/delete/"):]
+		if categoryID, err = strconv.Atoi(_id); err != nil {
+			response.Error(w, http.StatusBadRequest, errors.New("category id doesn't exist"))
+			return
+		}
+		if status, err = uh.categoryUcase.DeleteCategoryByID(r.Context(), int64(categoryID)); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "category has been removed", http.StatusOK, nil)
+	} else {
+		http.Error(w, "Only DELETE method allowed, return to main page", 405)
+		return
+	}
+}
+
+// RenameCategory changes only the name of a category, keeping its slug so
+// that its links still work.
+func (uh *UserHandler) RenameCategory(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "PUT" {
+		var (
+			input  models.Category
+			status int
+			err    error
+			cookie *http.Cookie
+			user   *models.User
+		)
+		if err = json.NewDecoder(r.Body).Decode(&input); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
+			return
+		}
+		if status, err = uh.categoryUcase.RenameCategory(r.Context(), &input); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "category has been renamed", http.StatusOK, input)
+	} else {
+		http.Error(w, "Only PUT method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) MergeCategories(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		var (
+			input  models.InputMergeCategories
+			status int
+			err    error
+			cookie *http.Cookie
+			user   *models.User
+		)
+		if err = json.NewDecoder(r.Body).Decode(&input); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
+			return
+		}
+		if status, err = uh.categoryUcase.MergeCategories(r.Context(), input.SourceID, input.TargetID); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "categories have been merged", http.StatusOK, nil)
+	} else {
+		http.Error(w, "Only POST method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) GetCategoryModerators(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		var (
+			status     int
+			err        error
+			cookie     *http.Cookie
+			user       *models.User
+			categoryID int
+			moderators []models.User
+		)
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
+			return
+		}
+		_id := r.URL.Path[len("/api/admin/category/moderators/"):]
+		if categoryID, err = strconv.Atoi(_id); err != nil {
+			response.Error(w, http.StatusBadRequest, errors.New("category id doesn't exist"))
+			return
+		}
+		if moderators, status, err = uh.categoryUcase.GetModerators(r.Context(), int64(categoryID)); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "category moderators", http.StatusOK, moderators)
+	} else {
+		http.Error(w, "Only GET method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) AssignCategoryModerator(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		var (
+			input     models.CategoryModerator
+			status    int
+			err       error
+			cookie    *http.Cookie
+			user      *models.User
+			moderator *models.User
+		)
+		if err = json.NewDecoder(r.Body).Decode(&input); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
+			return
+		}
+		if moderator, err = uh.userUcase.GetUserByID(r.Context(), input.ModeratorID); err != nil {
+			response.Error(w, http.StatusNotFound, errors.New("user doesn't exist"))
+			return
+		}
+		if moderator.Role != config.RoleModerator {
+			response.Error(w, http.StatusBadRequest, errors.New("only moderators can be assigned to categories"))
+			return
+		}
+		if status, err = uh.categoryUcase.AssignModerator(r.Context(), input.CategoryID, input.ModeratorID); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "moderator has been assigned", http.StatusOK, input)
+	} else {
+		http.Error(w, "Only POST method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) UnassignCategoryModerator(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		var (
+			input  models.CategoryModerator
+			status int
+			err    error
+			cookie *http.Cookie
+			user   *models.User
+		)
+		if err = json.NewDecoder(r.Body).Decode(&input); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
+			return
+		}
+		if err = uh.categoryUcase.UnassignModerator(r.Context(), input.CategoryID, input.ModeratorID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "moderator has been unassigned", http.StatusOK, nil)
+	} else {
+		http.Error(w, "Only POST method allowed, return to main page", 405)
+		return
+	}
+}
+
+// SetModeratorScope makes a moderator global, or scoped to the categories
+// assigned, which are none if all were unassigned.
+func (uh *UserHandler) SetModeratorScope(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "PUT" {
+		var (
+			input     models.ModeratorScope
+			status    int
+			err       error
+			cookie    *http.Cookie
+			user      *models.User
+			moderator *models.User
+		)
+		if err = json.NewDecoder(r.Body).Decode(&input); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
+			return
+		}
+		if moderator, err = uh.userUcase.GetUserByID(r.Context(), input.ModeratorID); err != nil {
+			response.Error(w, http.StatusNotFound, errors.New("user doesn't exist"))
+			return
+		}
+		if moderator.Role != config.RoleModerator {
+			response.Error(w, http.StatusBadRequest, errors.New("user is not a moderator"))
+			return
+		}
+		if err = uh.categoryUcase.SetModeratorScoped(r.Context(), input.ModeratorID, input.Scoped); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "moderator scope has been updated", http.StatusOK, input)
+	} else {
+		http.Error(w, "Only PUT method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) GetAllTagSynonyms(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		var (
+			status   int
+			err      error
+			cookie   *http.Cookie
+			user     *models.User
+			synonyms []models.TagSynonym
+		)
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
+			return
+		}
+		if synonyms, status, err = uh.tagUcase.GetAllSynonyms(r.Context()); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "all tag synonyms", status, synonyms)
+	} else {
+		http.Error(w, "Only GET method allowed, return to main page", 405)
+		return
+	}
+}
+
+// AddTagSynonym makes a name stand for a tag. A tag already named so is
+// merged into it, posts included.
+func (uh *UserHandler) AddTagSynonym(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		var (
+			input  models.TagSynonym
+			status int
+			err    error
+			cookie *http.Cookie
+			user   *models.User
+		)
+		if err = json.NewDecoder(r.Body).Decode(&input); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
+			return
+		}
+		if status, err = uh.tagUcase.AddSynonym(r.Context(), &input); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "tag synonym has been added", http.StatusOK, input)
+	} else {
+		http.Error(w, "Only POST method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) DeleteTagSynonym(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "DELETE" {
+		var (
+			status int
+			err    error
+			cookie *http.Cookie
+			user   *models.User
+		)
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
+			return
+		}
+		// the name is path escaped, # and + being common in tags
+		name := r.URL.Path[len("/api/admin/tag/synonym/delete/"):]
+		if status, err = uh.tagUcase.DeleteSynonym(r.Context(), name); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "tag synonym has been removed", http.StatusOK, nil)
+	} else {
+		http.Error(w, "Only DELETE method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) GetAllPostReports(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		var (
+			status      int
+			err         error
+			cookie      *http.Cookie
+			user        *models.User
+			postReports []models.PostReport
+		)
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
+			return
+		}
+		if postReports, err = uh.adminUcase.GetAllPostReports(r.Context()); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "get all post reports", http.StatusOK, postReports)
+	} else {
+		http.Error(w, "Only GET method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) DismissPostReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "DELETE" {
+		var (
+			status       int
+			err          error
+			cookie       *http.Cookie
+			user         *models.User
+			postReportID int
+			postReport   *models.PostReport
+		)
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
+			return
+		}
+		_id := r.URL.Path[len("/api/admin/post/report/dismiss/"):]
+		if postReportID, err = strconv.Atoi(_id); err != nil {
+			response.Error(w, http.StatusBadRequest, errors.New("post report id doesn't exist"))
+			return
+		}
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		if postReport, err = uh.moderatorUcase.GetPostReportByID(r.Context(), int64(postReportID)); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		postReportNotification := models.PostReportNotification{
+			ReceiverID: postReport.ModeratorID,
+			Approved:   false,
+			Deleted:    true,
+		}
+		if err = uh.userNotificationUcase.CreatePostReportNotification(r.Context(), &postReportNotification); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		if err = uh.adminUcase.DismissPostReport(r.Context(), int64(postReportID)); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "post report has been removed", http.StatusOK, nil)
+	} else {
+		http.Error(w, "Only DELETE method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) AcceptPostReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "PUT" {
+		var (
+			status       int
+			err          error
+			cookie       *http.Cookie
+			user         *models.User
+			postReportID int
+			postReport   *models.PostReport
+		)
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
+			return
+		}
+		_id := r.URL.Path[len("/api/admin/post/report/accept/"):]
+		if postReportID, err = strconv.Atoi(_id); err != nil {
+			response.Error(w, http.StatusBadRequest, errors.New("invalid request id"))
+			return
+		}
+
+		if postReport, err = uh.moderatorUcase.GetPostReportByID(r.Context(), int64(postReportID)); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		post, status, err := uh.postUcase.GetPostByID(r.Context(), user.ID, postReport.PostID)
+		if err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		// the report is accepted on behalf of the moderator who filed it,
+		// who may have lost the post's category since
+		if !uh.canModeratePost(w, r, postReport.ModeratorID, post.ID) {
+			return
+		}
+
+		if err = uh.adminUcase.AcceptPostReport(r.Context(), postReport, newModerationAction(post, user.ID, config.ActionDeleted)); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "post report has been accepted", http.StatusOK, nil)
+	} else {
+		http.Error(w, "Only PUT method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) GetAllModerators(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		var (
+			status     int
+			err        error
+			cookie     *http.Cookie
+			user       *models.User
+			moderators []models.User
+		)
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
+			return
+		}
+		if moderators, err = uh.adminUcase.GetAllModerators(r.Context()); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "get all moderators", http.StatusOK, moderators)
+	} else {
+		http.Error(w, "Only GET method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) DemoteModerator(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "PUT" {
+		var (
+			status      int
+			err         error
+			cookie      *http.Cookie
+			user        *models.User
+			moderatorID int
+		)
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
+			return
+		}
+		_id := r.URL.Path[len("/api/admin/demote/moderator/"):]
+		if moderatorID, err = strconv.Atoi(_id); err != nil {
+			response.Error(w, http.StatusBadRequest, errors.New("invalid userID"))
+			return
+		}
+		if err = uh.adminUcase.DemoteModerator(r.Context(), int64(moderatorID)); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "moderator has been demoted", http.StatusOK, nil)
+	} else {
+		http.Error(w, "Only PUT method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) GetAllUnapprovedPosts(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		var (
+			status int
+			err    error
+			cookie *http.Cookie
+			user   *models.User
+			posts  []models.Post
+		)
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleModerator {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only moderator users allowed"))
+			return
+		}
+		if posts, err = uh.moderatorUcase.GetAllUnapprovedPosts(r.Context()); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		inScope := posts[:0]
+		for _, post := range posts {
+			var can bool
+			if can, err = uh.categoryUcase.CanModeratePost(r.Context(), user.ID, post.ID); err != nil {
+				response.Error(w, http.StatusInternalServerError, err)
+				return
+			}
+			if can {
+				inScope = append(inScope, post)
+			}
+		}
+		posts = inScope
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "all unapproved posts", http.StatusOK, posts)
+	} else {
+		http.Error(w, "Only GET method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) ApprovePost(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "PUT" {
+		var (
+			status int
+			err    error
+			cookie *http.Cookie
+			user   *models.User
+			postID int
+			post   *models.Post
+		)
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleModerator {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only moderator users allowed"))
+			return
+		}
+		_id := r.URL.Path[len("/api/moderator/post/approve/"):]
+		if postID, err = strconv.Atoi(_id); err != nil {
+			response.Error(w, http.StatusBadRequest, errors.New("post id doesn't exist"))
+			return
+		}
+		if !uh.canModeratePost(w, r, user.ID, int64(postID)) {
 			return
 		}
-		if err = uh.notificationUcase.DeleteNotificationsByPostID(post.ID); err != nil {
+		if err = uh.moderatorUcase.ApprovePost(r.Context(), int64(postID)); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		if err = uh.commentRateUcase.DeleteCommentsRateByPostID(post.ID); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		if status, err = uh.postUcase.Delete(post.ID); err != nil {
+		if post, status, err = uh.postUcase.GetPostByID(r.Context(), user.ID, int64(postID)); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
-			return
-		}
 		postNotification := models.PostNotification{
 			ReceiverID: post.AuthorID,
-			Approved:   false,
+			Approved:   true,
 			Banned:     false,
-			Deleted:    true,
+			Deleted:    false,
 		}
-		if err = uh.userNotificationUcase.CreatePostNotification(&postNotification); err != nil {
+		if err = uh.userNotificationUcase.CreatePostNotification(r.Context(), &postNotification); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "post has been deleted", http.StatusOK, nil)
+		response.Success(w, "post has been approved", http.StatusOK, nil)
 	} else {
-		http.Error(w, "Only DELETE method allowed, return to main page", 405)
+		http.Error(w, "Only PUT method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) CreatePostReport(w http.ResponseWriter, r *http.Request) {
+func (uh *UserHandler) BanPost(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "POST" {
 		var (
-			input  models.PostReport
 			status int
 			err    error
 			cookie *http.Cookie
 			user   *models.User
+			postID int
+			post   *models.Post
+			input  models.InputPost
 		)
 		if err = json.NewDecoder(r.Body).Decode(&input); err != nil {
 			response.Error(w, http.StatusBadRequest, err)
 			return
 		}
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
@@ -765,744 +2161,776 @@ func (uh *UserHandler) CreatePostReport(w http.ResponseWriter, r *http.Request)
 			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only moderator users allowed"))
 			return
 		}
-		if err = uh.moderatorUcase.CreatePostReport(&input); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
-			return
-		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
-			return
+		_id := r.URL.Path[len("/api/moderator/post/ban/"):]
+		if postID, err = strconv.Atoi(_id); err != nil {
+			postID = int(input.ID)
 		}
-		response.Success(w, "post report created", http.StatusCreated, input)
-	} else {
-		http.Error(w, "Only POST method allowed, return to main page", 405)
-		return
-	}
-}
-
-func (uh *UserHandler) DeletePostReport(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "DELETE" {
-		var (
-			status       int
-			err          error
-			cookie       *http.Cookie
-			user         *models.User
-			postReportID int
-		)
-		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+		if post, status, err = uh.postUcase.GetPostByID(r.Context(), user.ID, int64(postID)); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if user.Role != config.RoleModerator {
-			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only moderator users allowed"))
-			return
-		}
-		_id := r.URL.Path[len("/api/moderator/report/post/delete/"):]
-		if postReportID, err = strconv.Atoi(_id); err != nil {
-			response.Error(w, http.StatusBadRequest, errors.New("post report id doesn't exist"))
+		if !uh.canModeratePost(w, r, user.ID, post.ID) {
 			return
 		}
-		if err = uh.moderatorUcase.DeletePostReport(int64(postReportID)); err != nil {
+		if err = uh.moderatorUcase.BanPost(r.Context(), newModerationAction(post, user.ID, config.ActionBanned), input.Bans); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "post report has been removed", http.StatusOK, nil)
+		response.Success(w, "post has been banned", http.StatusOK, nil)
 	} else {
-		http.Error(w, "Only DELETE method allowed, return to main page", 405)
+		http.Error(w, "Only POST method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) MyReports(w http.ResponseWriter, r *http.Request) {
+// canModeratePost checks that a post is in the categories a moderator is
+// assigned to, if any, writing the error response when it isn't.
+func (uh *UserHandler) canModeratePost(w http.ResponseWriter, r *http.Request, moderatorID int64, postID int64) bool {
+	can, err := uh.categoryUcase.CanModeratePost(r.Context(), moderatorID, postID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err)
+		return false
+	}
+	if !can {
+		response.Error(w, http.StatusForbidden, errors.New("post is outside the categories you moderate"))
+		return false
+	}
+	return true
+}
+
+func (uh *UserHandler) GetRoleNotifications(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
 		var (
-			status      int
-			err         error
-			cookie      *http.Cookie
-			user        *models.User
-			postReports []models.PostReport
+			status            int
+			err               error
+			cookie            *http.Cookie
+			user              *models.User
+			roleNotifications []models.RoleNotification
 		)
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if user.Role != config.RoleModerator {
-			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only moderator users allowed"))
-			return
-		}
-		if postReports, err = uh.moderatorUcase.GetMyReports(user.ID); err != nil {
+		roleNotifications, err = uh.userNotificationUcase.GetRoleNotifications(r.Context(), user.ID)
+		if err != nil {
 			response.Error(w, http.StatusBadRequest, err)
 			return
 		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "fetched all post reports by moderator id", http.StatusOK, postReports)
+		response.Success(w, "role notifications", http.StatusOK, roleNotifications)
 	} else {
 		http.Error(w, "Only GET method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) GetAllCategories(w http.ResponseWriter, r *http.Request) {
+func (uh *UserHandler) GetPostReportNotifications(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
 		var (
-			status     int
-			err        error
-			cookie     *http.Cookie
-			user       *models.User
-			categories []models.Category
+			status                  int
+			err                     error
+			cookie                  *http.Cookie
+			user                    *models.User
+			postReportNotifications []models.PostReportNotification
 		)
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if user.Role != config.RoleAdmin {
-			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
-			return
-		}
-		if categories, status, err = uh.categoryUcase.GetAllCategories(); err != nil {
+		postReportNotifications, err = uh.userNotificationUcase.GetPostReportNotifications(r.Context(), user.ID)
+		if err != nil {
 			response.Error(w, http.StatusBadRequest, err)
 			return
 		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "all categories", status, categories)
+		response.Success(w, "post report notifications", http.StatusOK, postReportNotifications)
 	} else {
 		http.Error(w, "Only GET method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) CreateNewCategory(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "POST" {
+func (uh *UserHandler) DeleteRoleNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "DELETE" {
 		var (
-			input  models.Category
 			status int
 			err    error
 			cookie *http.Cookie
 			user   *models.User
 		)
-		if err = json.NewDecoder(r.Body).Decode(&input); err != nil {
-			response.Error(w, http.StatusBadRequest, err)
-			return
-		}
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if user.Role != config.RoleAdmin {
-			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
-			return
-		}
-		if err = uh.categoryUcase.CreateNewCategory(input.Name); err != nil {
+		if err = uh.userNotificationUcase.DeleteAllRoleNotifications(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "new category has been created", http.StatusCreated, input)
+		response.Success(w, "role notifications has been deleted", http.StatusOK, nil)
 	} else {
-		http.Error(w, "Only POST method allowed, return to main page", 405)
+		http.Error(w, "Only DELETE method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+func (uh *UserHandler) DeletePostReportNotifications(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "DELETE" {
 		var (
-			status     int
-			err        error
-			cookie     *http.Cookie
-			user       *models.User
-			categoryID int
+			status int
+			err    error
+			cookie *http.Cookie
+			user   *models.User
 		)
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if user.Role != config.RoleAdmin {
-			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
-			return
-		}
-		_id := r.URL.Path[len("/api/admin/category/delete/"):]
-		if categoryID, err = strconv.Atoi(_id); err != nil {
-			response.Error(w, http.StatusBadRequest, errors.New("category id doesn't exist"))
-			return
-		}
-		if err = uh.categoryUcase.DeleteCategoryByID(int64(categoryID)); err != nil {
+		if err = uh.userNotificationUcase.DeleteAllPostReportNotifications(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "category has been removed", http.StatusOK, nil)
+		response.Success(w, "post report notifications has been deleted", http.StatusOK, nil)
 	} else {
 		http.Error(w, "Only DELETE method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) GetAllPostReports(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "GET" {
+func (uh *UserHandler) DeletePostNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "DELETE" {
 		var (
-			status      int
-			err         error
-			cookie      *http.Cookie
-			user        *models.User
-			postReports []models.PostReport
+			status int
+			err    error
+			cookie *http.Cookie
+			user   *models.User
 		)
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if user.Role != config.RoleAdmin {
-			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
-			return
-		}
-		if postReports, err = uh.adminUcase.GetAllPostReports(); err != nil {
+		if err = uh.userNotificationUcase.DeleteAllPostNotifications(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "get all post reports", http.StatusOK, postReports)
+		response.Success(w, "post notifications has been deleted", http.StatusOK, nil)
 	} else {
-		http.Error(w, "Only GET method allowed, return to main page", 405)
+		http.Error(w, "Only DELETE method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) DismissPostReport(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "DELETE" {
+func (uh *UserHandler) GetPostNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
 		var (
-			status       int
-			err          error
-			cookie       *http.Cookie
-			user         *models.User
-			postReportID int
-			postReport   *models.PostReport
+			status            int
+			err               error
+			cookie            *http.Cookie
+			user              *models.User
+			postNotifications []models.PostNotification
 		)
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if user.Role != config.RoleAdmin {
-			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
-			return
-		}
-		_id := r.URL.Path[len("/api/admin/post/report/dismiss/"):]
-		if postReportID, err = strconv.Atoi(_id); err != nil {
-			response.Error(w, http.StatusBadRequest, errors.New("post report id doesn't exist"))
-			return
-		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
-			return
-		}
-		if postReport, err = uh.moderatorUcase.GetPostReportByID(int64(postReportID)); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
-			return
-		}
-		postReportNotification := models.PostReportNotification{
-			ReceiverID: postReport.ModeratorID,
-			Approved:   false,
-			Deleted:    true,
-		}
-		if err = uh.userNotificationUcase.CreatePostReportNotification(&postReportNotification); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
+		postNotifications, err = uh.userNotificationUcase.GetPostNotifications(r.Context(), user.ID)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
 			return
 		}
-		if err = uh.adminUcase.DismissPostReport(int64(postReportID)); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "post report has been removed", http.StatusOK, nil)
+		response.Success(w, "post notifications", http.StatusOK, postNotifications)
 	} else {
-		http.Error(w, "Only DELETE method allowed, return to main page", 405)
+		http.Error(w, "Only GET method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) AcceptPostReport(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "PUT" {
+func (uh *UserHandler) GetAppealNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
 		var (
-			status       int
-			err          error
-			cookie       *http.Cookie
-			user         *models.User
-			postReportID int
-			postReport   *models.PostReport
+			status              int
+			err                 error
+			cookie              *http.Cookie
+			user                *models.User
+			appealNotifications []models.AppealNotification
 		)
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if user.Role != config.RoleAdmin {
-			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
-			return
-		}
-		_id := r.URL.Path[len("/api/admin/post/report/accept/"):]
-		if postReportID, err = strconv.Atoi(_id); err != nil {
-			response.Error(w, http.StatusBadRequest, errors.New("invalid request id"))
+		appealNotifications, err = uh.userNotificationUcase.GetAppealNotifications(r.Context(), user.ID)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
 			return
 		}
-
-		if postReport, err = uh.moderatorUcase.GetPostReportByID(int64(postReportID)); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
+		response.Success(w, "appeal notifications", http.StatusOK, appealNotifications)
+	} else {
+		http.Error(w, "Only GET method allowed, return to main page", 405)
+		return
+	}
+}
 
-		post, status, err := uh.postUcase.GetPostByID(user.ID, postReport.PostID)
-		if err != nil {
+func (uh *UserHandler) DeleteAppealNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "DELETE" {
+		var (
+			status int
+			err    error
+			cookie *http.Cookie
+			user   *models.User
+		)
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-
-		if err = uh.adminUcase.AcceptPostReport(int64(postReportID)); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
-			return
-		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
-			return
-		}
-		postReportNotification := models.PostReportNotification{
-			ReceiverID: postReport.ModeratorID,
-			Approved:   true,
-			Deleted:    false,
-		}
-		if err = uh.userNotificationUcase.CreatePostReportNotification(&postReportNotification); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
-			return
-		}
-		if err = uh.moderatorUcase.DeletePostReport(int64(postReportID)); err != nil {
+		if err = uh.userNotificationUcase.DeleteAllAppealNotifications(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		postNotification := models.PostNotification{
-			ReceiverID: post.AuthorID,
-			Approved:   false,
-			Banned:     false,
-			Deleted:    true,
-		}
-		if err = uh.userNotificationUcase.CreatePostNotification(&postNotification); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "post report has been accepted", http.StatusOK, nil)
+		response.Success(w, "appeal notifications has been deleted", http.StatusOK, nil)
 	} else {
-		http.Error(w, "Only PUT method allowed, return to main page", 405)
+		http.Error(w, "Only DELETE method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) GetAllModerators(w http.ResponseWriter, r *http.Request) {
+func (uh *UserHandler) GetSecurityNotifications(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
 		var (
-			status     int
-			err        error
-			cookie     *http.Cookie
-			user       *models.User
-			moderators []models.User
+			status                int
+			err                   error
+			cookie                *http.Cookie
+			user                  *models.User
+			securityNotifications []models.SecurityNotification
 		)
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if user.Role != config.RoleAdmin {
-			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
-			return
-		}
-		if moderators, err = uh.adminUcase.GetAllModerators(); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
+		securityNotifications, err = uh.userNotificationUcase.GetSecurityNotifications(r.Context(), user.ID)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
 			return
 		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "get all moderators", http.StatusOK, moderators)
+		response.Success(w, "security notifications", http.StatusOK, securityNotifications)
 	} else {
 		http.Error(w, "Only GET method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) DemoteModerator(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "PUT" {
+func (uh *UserHandler) DeleteSecurityNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "DELETE" {
 		var (
-			status      int
-			err         error
-			cookie      *http.Cookie
-			user        *models.User
-			moderatorID int
+			status int
+			err    error
+			cookie *http.Cookie
+			user   *models.User
 		)
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if user.Role != config.RoleAdmin {
-			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
-			return
-		}
-		_id := r.URL.Path[len("/api/admin/demote/moderator/"):]
-		if moderatorID, err = strconv.Atoi(_id); err != nil {
-			response.Error(w, http.StatusBadRequest, errors.New("invalid userID"))
-			return
-		}
-		if err = uh.adminUcase.DemoteModerator(int64(moderatorID)); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
-			return
-		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
+		if err = uh.userNotificationUcase.DeleteAllSecurityNotifications(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		roleNotification := models.RoleNotification{
-			ReceiverID: int64(moderatorID),
-			Accepted:   false,
-			Declined:   false,
-			Demoted:    true,
-		}
-		if err = uh.userNotificationUcase.CreateRoleNotification(&roleNotification); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "moderator has been demoted", http.StatusOK, nil)
+		response.Success(w, "security notifications has been deleted", http.StatusOK, nil)
 	} else {
-		http.Error(w, "Only PUT method allowed, return to main page", 405)
+		http.Error(w, "Only DELETE method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) GetAllUnapprovedPosts(w http.ResponseWriter, r *http.Request) {
+func (uh *UserHandler) GetMentionSettings(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
 		var (
-			status int
-			err    error
-			cookie *http.Cookie
-			user   *models.User
-			posts  []models.Post
+			status   int
+			err      error
+			cookie   *http.Cookie
+			user     *models.User
+			settings *models.MentionSettings
 		)
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if user.Role != config.RoleModerator {
-			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only moderator users allowed"))
-			return
-		}
-		if posts, err = uh.moderatorUcase.GetAllUnapprovedPosts(); err != nil {
-			response.Error(w, http.StatusBadRequest, err)
+		if settings, err = uh.userNotificationUcase.GetMentionSettings(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "all unapproved posts", http.StatusOK, posts)
+		response.Success(w, "mention settings", http.StatusOK, settings)
 	} else {
 		http.Error(w, "Only GET method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) ApprovePost(w http.ResponseWriter, r *http.Request) {
+// UpdateMentionSettings opts the user in or out of notifications of their
+// @mentions.
+func (uh *UserHandler) UpdateMentionSettings(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "PUT" {
 		var (
+			input  models.MentionSettings
 			status int
 			err    error
 			cookie *http.Cookie
 			user   *models.User
-			postID int
-			post   *models.Post
 		)
-		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
-			response.Error(w, status, err)
-			return
-		}
-		if user.Role != config.RoleModerator {
-			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only moderator users allowed"))
+		if err = json.NewDecoder(r.Body).Decode(&input); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
 			return
 		}
-		_id := r.URL.Path[len("/api/moderator/post/approve/"):]
-		if postID, err = strconv.Atoi(_id); err != nil {
-			response.Error(w, http.StatusBadRequest, errors.New("post id doesn't exist"))
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
 			return
 		}
-		if err = uh.moderatorUcase.ApprovePost(int64(postID)); err != nil {
+		if err = uh.userNotificationUcase.UpdateMentionSettings(r.Context(), user.ID, &input); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		if post, status, err = uh.postUcase.GetPostByID(user.ID, int64(postID)); err != nil {
+		response.Success(w, "mention settings have been updated", http.StatusOK, input)
+	} else {
+		http.Error(w, "Only PUT method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) GetMyModerationActions(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		var (
+			status  int
+			err     error
+			cookie  *http.Cookie
+			user    *models.User
+			actions []models.ModerationAction
+		)
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		postNotification := models.PostNotification{
-			ReceiverID: post.AuthorID,
-			Approved:   true,
-			Banned:     false,
-			Deleted:    false,
+		if actions, err = uh.appealUcase.GetModerationActionsByAuthorID(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
 		}
-		if err = uh.userNotificationUcase.CreatePostNotification(&postNotification); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "post has been approved", http.StatusOK, nil)
+		response.Success(w, "moderation actions on user's posts", http.StatusOK, actions)
 	} else {
-		http.Error(w, "Only PUT method allowed, return to main page", 405)
+		http.Error(w, "Only GET method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) BanPost(w http.ResponseWriter, r *http.Request) {
+func (uh *UserHandler) CreateAppeal(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "POST" {
 		var (
+			input  models.InputAppeal
+			appeal models.Appeal
 			status int
 			err    error
 			cookie *http.Cookie
 			user   *models.User
-			postID int
-			input  models.InputPost
 		)
 		if err = json.NewDecoder(r.Body).Decode(&input); err != nil {
 			response.Error(w, http.StatusBadRequest, err)
 			return
 		}
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if user.Role != config.RoleModerator {
-			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only moderator users allowed"))
+		appeal = models.Appeal{
+			ActionID: input.ActionID,
+			AuthorID: user.ID,
+			Content:  input.Content,
+		}
+		if err = uh.appealUcase.CreateAppeal(r.Context(), &appeal); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
 			return
 		}
-		if err = uh.moderatorUcase.BanPost(int64(postID), input.Bans); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
+		response.Success(w, "appeal has been submitted", http.StatusCreated, appeal)
+	} else {
+		http.Error(w, "Only POST method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) GetMyAppeals(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		var (
+			status  int
+			err     error
+			cookie  *http.Cookie
+			user    *models.User
+			appeals []models.Appeal
+		)
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
 			return
 		}
-		postNotification := models.PostNotification{
-			ReceiverID: input.AuthorID,
-			Approved:   false,
-			Banned:     true,
-			Deleted:    false,
+		if appeals, err = uh.appealUcase.GetAppealsByAuthorID(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
 		}
-		if err = uh.userNotificationUcase.CreatePostNotification(&postNotification); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "post has been banned", http.StatusOK, nil)
+		response.Success(w, "user's appeals", http.StatusOK, appeals)
 	} else {
-		http.Error(w, "Only POST method allowed, return to main page", 405)
+		http.Error(w, "Only GET method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) GetRoleNotifications(w http.ResponseWriter, r *http.Request) {
+func (uh *UserHandler) GetPendingAppeals(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
 		var (
-			status            int
-			err               error
-			cookie            *http.Cookie
-			user              *models.User
-			roleNotifications []models.RoleNotification
+			status  int
+			err     error
+			cookie  *http.Cookie
+			user    *models.User
+			appeals []models.Appeal
 		)
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		roleNotifications, err = uh.userNotificationUcase.GetRoleNotifications(user.ID)
-		if err != nil {
-			response.Error(w, http.StatusBadRequest, err)
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
 			return
 		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
+		if appeals, err = uh.appealUcase.GetPendingAppeals(r.Context()); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "role notifications", http.StatusOK, roleNotifications)
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(w, "pending appeals", http.StatusOK, appeals)
 	} else {
 		http.Error(w, "Only GET method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) GetPostReportNotifications(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "GET" {
+func (uh *UserHandler) ReverseAppeal(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "PUT" {
 		var (
-			status                  int
-			err                     error
-			cookie                  *http.Cookie
-			user                    *models.User
-			postReportNotifications []models.PostReportNotification
+			status   int
+			err      error
+			cookie   *http.Cookie
+			user     *models.User
+			appealID int
+			appeal   *models.Appeal
 		)
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		postReportNotifications, err = uh.userNotificationUcase.GetPostReportNotifications(user.ID)
-		if err != nil {
-			response.Error(w, http.StatusBadRequest, err)
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
 			return
 		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
+		_id := r.URL.Path[len("/api/admin/appeal/reverse/"):]
+		if appealID, err = strconv.Atoi(_id); err != nil {
+			response.Error(w, http.StatusBadRequest, errors.New("invalid appeal id"))
+			return
+		}
+		if appeal, err = uh.appealUcase.GetAppealByID(r.Context(), int64(appealID)); err != nil {
+			response.Error(w, http.StatusNotFound, err)
+			return
+		}
+		if err = uh.appealUcase.ReverseAppeal(r.Context(), appeal.ID, user.ID); err != nil {
+			response.Error(w, http.StatusConflict, err)
+			return
+		}
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "post report notifications", http.StatusOK, postReportNotifications)
+		response.Success(w, "appeal has been accepted, moderation action reversed", http.StatusOK, nil)
 	} else {
-		http.Error(w, "Only GET method allowed, return to main page", 405)
+		http.Error(w, "Only PUT method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) DeleteRoleNotifications(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "DELETE" {
+func (uh *UserHandler) UpholdAppeal(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "PUT" {
 		var (
-			status int
-			err    error
-			cookie *http.Cookie
-			user   *models.User
+			status   int
+			err      error
+			cookie   *http.Cookie
+			user     *models.User
+			appealID int
+			appeal   *models.Appeal
 		)
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if err = uh.userNotificationUcase.DeleteAllRoleNotifications(user.ID); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
+			return
+		}
+		_id := r.URL.Path[len("/api/admin/appeal/uphold/"):]
+		if appealID, err = strconv.Atoi(_id); err != nil {
+			response.Error(w, http.StatusBadRequest, errors.New("invalid appeal id"))
+			return
+		}
+		if appeal, err = uh.appealUcase.GetAppealByID(r.Context(), int64(appealID)); err != nil {
+			response.Error(w, http.StatusNotFound, err)
+			return
+		}
+		if err = uh.appealUcase.UpholdAppeal(r.Context(), appeal.ID, user.ID); err != nil {
+			response.Error(w, http.StatusConflict, err)
 			return
 		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "role notifications has been deleted", http.StatusOK, nil)
+		response.Success(w, "appeal has been rejected, moderation action upheld", http.StatusOK, nil)
 	} else {
-		http.Error(w, "Only DELETE method allowed, return to main page", 405)
+		http.Error(w, "Only PUT method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) DeletePostReportNotifications(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "DELETE" {
+// newModerationAction snapshots the post so that a deleted post can be
+// restored if the author's appeal is accepted.
+func newModerationAction(post *models.Post, moderatorID int64, action string) *models.ModerationAction {
+	categories := make([]string, 0, len(post.Categories))
+	for _, category := range post.Categories {
+		categories = append(categories, category.Name)
+	}
+	return &models.ModerationAction{
+		PostID:        post.ID,
+		AuthorID:      post.AuthorID,
+		ModeratorID:   moderatorID,
+		Action:        action,
+		PostTitle:     post.Title,
+		PostContent:   post.Content,
+		PostCreatedAt: post.CreatedAt,
+		IsImage:       post.IsImage,
+		ImagePath:     post.ImagePath,
+		Categories:    categories,
+	}
+}
+
+func (uh *UserHandler) GetDeletedPosts(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
 		var (
 			status int
 			err    error
 			cookie *http.Cookie
 			user   *models.User
+			posts  []models.Post
 		)
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
+			return
+		}
+		if posts, status, err = uh.postUcase.GetDeletedPosts(r.Context()); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if err = uh.userNotificationUcase.DeleteAllPostReportNotifications(user.ID); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
+		response.Success(w, "get all deleted posts", http.StatusOK, posts)
+	} else {
+		http.Error(w, "Only GET method allowed, return to main page", 405)
+		return
+	}
+}
+
+func (uh *UserHandler) GetDeletedComments(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		var (
+			status   int
+			err      error
+			cookie   *http.Cookie
+			user     *models.User
+			comments []models.Comment
+		)
+		cookie, _ = r.Cookie(config.SessionCookieName)
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
+			return
+		}
+		if comments, status, err = uh.commentUcase.GetDeletedComments(r.Context()); err != nil {
+			response.Error(w, status, err)
+			return
+		}
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "post report notifications has been deleted", http.StatusOK, nil)
+		response.Success(w, "get all deleted comments", http.StatusOK, comments)
 	} else {
-		http.Error(w, "Only DELETE method allowed, return to main page", 405)
+		http.Error(w, "Only GET method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) DeletePostNotifications(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "DELETE" {
+func (uh *UserHandler) RestorePost(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "PUT" {
 		var (
 			status int
 			err    error
 			cookie *http.Cookie
 			user   *models.User
+			postID int
 		)
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		if err = uh.userNotificationUcase.DeleteAllPostNotifications(user.ID); err != nil {
-			response.Error(w, http.StatusInternalServerError, err)
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
+			return
+		}
+		_id := r.URL.Path[len("/api/admin/post/restore/"):]
+		if postID, err = strconv.Atoi(_id); err != nil {
+			response.Error(w, http.StatusBadRequest, errors.New("post id doesn't exist"))
+			return
+		}
+		if status, err = uh.postUcase.Restore(r.Context(), int64(postID)); err != nil {
+			response.Error(w, status, err)
 			return
 		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "post notifications has been deleted", http.StatusOK, nil)
+		response.Success(w, "post has been restored", http.StatusOK, nil)
 	} else {
-		http.Error(w, "Only DELETE method allowed, return to main page", 405)
+		http.Error(w, "Only PUT method allowed, return to main page", 405)
 		return
 	}
 }
 
-func (uh *UserHandler) GetPostNotifications(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "GET" {
+func (uh *UserHandler) RestoreComment(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "PUT" {
 		var (
-			status            int
-			err               error
-			cookie            *http.Cookie
-			user              *models.User
-			postNotifications []models.PostNotification
+			status    int
+			err       error
+			cookie    *http.Cookie
+			user      *models.User
+			commentID int
 		)
 		cookie, _ = r.Cookie(config.SessionCookieName)
-		if user, status, err = uh.userUcase.ValidateSession(cookie.Value); err != nil {
+		if user, status, err = uh.userUcase.ValidateSession(r.Context(), cookie.Value); err != nil {
 			response.Error(w, status, err)
 			return
 		}
-		postNotifications, err = uh.userNotificationUcase.GetPostNotifications(user.ID)
-		if err != nil {
-			response.Error(w, http.StatusBadRequest, err)
+		if user.Role != config.RoleAdmin {
+			response.Error(w, http.StatusForbidden, errors.New("not enough privileges,only admin users allowed"))
+			return
+		}
+		_id := r.URL.Path[len("/api/admin/comment/restore/"):]
+		if commentID, err = strconv.Atoi(_id); err != nil {
+			response.Error(w, http.StatusBadRequest, errors.New("comment id doesn't exist"))
+			return
+		}
+		if err = uh.commentUcase.Restore(r.Context(), int64(commentID)); err != nil {
+			response.Error(w, http.StatusNotFound, err)
 			return
 		}
-		if err = uh.userUcase.UpdateActivity(user.ID); err != nil {
+		if err = uh.userUcase.UpdateActivity(r.Context(), user.ID); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(w, "post notifications", http.StatusOK, postNotifications)
+		response.Success(w, "comment has been restored", http.StatusOK, nil)
 	} else {
-		http.Error(w, "Only GET method allowed, return to main page", 405)
+		http.Error(w, "Only PUT method allowed, return to main page", 405)
 		return
 	}
 }
@@ -1,56 +1,125 @@
 package user
 
 import (
+	"context"
+	"database/sql"
+
 	"github.com/innovember/forum/api/models"
 )
 
 type UserRepository interface {
-	Create(user *models.User) (status int, err error)
-	CheckByUsernameOrEmail(user *models.User) (status int, err error)
-	GetAllUsers() (users []models.User, err error)
-	GetUserByID(userID int64) (user *models.User, err error)
-	GetPassword(username string) (password string, status int, err error)
-	FindUserByUsername(username string) (user *models.User, status int, err error)
-	UpdateSession(userID int64, sessionValue string, expiresAt int64) (err error)
-	ValidateSession(sessionValue string) (user *models.User, status int, err error)
-	CheckSessionByUsername(username string) (status int, err error)
-	UpdateActivity(userID int64) (err error)
-	CreateRoleRequest(userID int64) (err error)
-	GetRoleRequestByUserID(userID int64) (request *models.RoleRequest, err error)
-	DeleteRoleRequest(userID int64) (err error)
-	GetRoleRequestByID(requestID int64) (roleRequest *models.RoleRequest, err error)
+	Create(ctx context.Context, user *models.User) (status int, err error)
+	CheckByUsernameOrEmail(ctx context.Context, user *models.User) (status int, err error)
+	GetAllUsers(ctx context.Context) (users []models.User, err error)
+	GetUserByID(ctx context.Context, userID int64) (user *models.User, err error)
+	GetPassword(ctx context.Context, username string) (password string, status int, err error)
+	FindUserByUsername(ctx context.Context, username string) (user *models.User, status int, err error)
+	FindUserByEmail(ctx context.Context, email string) (user *models.User, status int, err error)
+	UpdateSession(ctx context.Context, userID int64, sessionValue string, expiresAt int64) (err error)
+	ValidateSession(ctx context.Context, sessionValue string) (user *models.User, status int, err error)
+	CheckSessionByUsername(ctx context.Context, username string) (status int, err error)
+	UpdateActivity(ctx context.Context, userID int64) (err error)
+	CreateRoleRequest(ctx context.Context, userID int64) (err error)
+	GetRoleRequestByUserID(ctx context.Context, userID int64) (request *models.RoleRequest, err error)
+	DeleteRoleRequest(ctx context.Context, userID int64) (err error)
+	GetRoleRequestByID(ctx context.Context, requestID int64) (roleRequest *models.RoleRequest, err error)
 }
 
 type AdminRepository interface {
-	UpgradeRole(requestID int64) (err error)
-	GetAllRoleRequests() (roleRequests []models.RoleRequest, err error)
-	DeleteRoleRequest(requestID int64) (err error)
-	GetAllPostReports() (postReports []models.PostReport, err error)
-	AcceptPostReport(postReportID int64) (err error)
-	DismissPostReport(postReportID int64) (err error)
-	GetAllModerators() (moderators []models.User, err error)
-	DemoteModerator(moderatorID int64) (err error)
-	DeletePostReportByPostID(postID int64) error
+	UpgradeRole(ctx context.Context, requestID int64) (err error)
+	UpgradeRoleTx(ctx context.Context, tx *sql.Tx, requestID int64) (err error)
+	GetAllRoleRequests(ctx context.Context) (roleRequests []models.RoleRequest, err error)
+	DeleteRoleRequest(ctx context.Context, requestID int64) (err error)
+	DeleteRoleRequestTx(ctx context.Context, tx *sql.Tx, requestID int64) (userID int64, err error)
+	GetAllPostReports(ctx context.Context) (postReports []models.PostReport, err error)
+	AcceptPostReport(ctx context.Context, postReportID int64) (err error)
+	AcceptPostReportTx(ctx context.Context, tx *sql.Tx, postReportID int64) (err error)
+	DismissPostReport(ctx context.Context, postReportID int64) (err error)
+	GetAllModerators(ctx context.Context) (moderators []models.User, err error)
+	DemoteModerator(ctx context.Context, moderatorID int64) (err error)
+	DemoteModeratorTx(ctx context.Context, tx *sql.Tx, moderatorID int64) (err error)
+	DeletePostReportByPostID(ctx context.Context, postID int64) error
 }
 
 type ModeratorRepository interface {
-	CreatePostReport(postReport *models.PostReport) (err error)
-	DeletePostReport(postReportID int64) (err error)
-	GetMyReports(moderatorID int64) (postReports []models.PostReport, err error)
-	ApprovePost(postID int64) (err error)
-	GetAllUnapprovedPosts() (posts []models.Post, err error)
-	BanPost(postID int64, bans []string) (err error)
-	GetPostReportByID(postReportID int64) (postReport *models.PostReport, err error)
+	CreatePostReport(ctx context.Context, postReport *models.PostReport) (err error)
+	DeletePostReport(ctx context.Context, postReportID int64) (err error)
+	DeletePostReportTx(ctx context.Context, tx *sql.Tx, postReportID int64) (err error)
+	GetMyReports(ctx context.Context, moderatorID int64) (postReports []models.PostReport, err error)
+	ApprovePost(ctx context.Context, postID int64) (err error)
+	GetAllUnapprovedPosts(ctx context.Context) (posts []models.Post, err error)
+	BanPost(ctx context.Context, postID int64, bans []string) (err error)
+	BanPostTx(ctx context.Context, tx *sql.Tx, postID int64, bans []string) (err error)
+	GetPostReportByID(ctx context.Context, postReportID int64) (postReport *models.PostReport, err error)
 }
 
 type UserNotificationRepository interface {
-	CreateRoleNotification(roleNotification *models.RoleNotification) (err error)
-	CreatePostReportNotification(postReportNotification *models.PostReportNotification) (err error)
-	DeleteAllRoleNotifications(userID int64) (err error)
-	DeleteAllPostReportNotifications(userID int64) (err error)
-	GetRoleNotifications(userID int64) (roleNotifications []models.RoleNotification, err error)
-	GetPostReportNotifications(userID int64) (postReportNotifications []models.PostReportNotification, err error)
-	CreatePostNotification(postNotification *models.PostNotification) (err error)
-	DeleteAllPostNotifications(userID int64) (err error)
-	GetPostNotifications(userID int64) (postNotifications []models.PostNotification, err error)
+	CreateRoleNotification(ctx context.Context, roleNotification *models.RoleNotification) (err error)
+	CreateRoleNotificationTx(ctx context.Context, tx *sql.Tx, roleNotification *models.RoleNotification) (err error)
+	CreatePostReportNotification(ctx context.Context, postReportNotification *models.PostReportNotification) (err error)
+	CreatePostReportNotificationTx(ctx context.Context, tx *sql.Tx, postReportNotification *models.PostReportNotification) (err error)
+	DeleteAllRoleNotifications(ctx context.Context, userID int64) (err error)
+	DeleteAllPostReportNotifications(ctx context.Context, userID int64) (err error)
+	GetRoleNotifications(ctx context.Context, userID int64) (roleNotifications []models.RoleNotification, err error)
+	GetPostReportNotifications(ctx context.Context, userID int64) (postReportNotifications []models.PostReportNotification, err error)
+	CreatePostNotification(ctx context.Context, postNotification *models.PostNotification) (err error)
+	CreatePostNotificationTx(ctx context.Context, tx *sql.Tx, postNotification *models.PostNotification) (err error)
+	DeleteAllPostNotifications(ctx context.Context, userID int64) (err error)
+	GetPostNotifications(ctx context.Context, userID int64) (postNotifications []models.PostNotification, err error)
+	CreateAppealNotification(ctx context.Context, appealNotification *models.AppealNotification) (err error)
+	CreateAppealNotificationTx(ctx context.Context, tx *sql.Tx, appealNotification *models.AppealNotification) (err error)
+	DeleteAllAppealNotifications(ctx context.Context, userID int64) (err error)
+	GetAppealNotifications(ctx context.Context, userID int64) (appealNotifications []models.AppealNotification, err error)
+	CreateSecurityNotification(ctx context.Context, securityNotification *models.SecurityNotification) (err error)
+	CreateSecurityNotificationTx(ctx context.Context, tx *sql.Tx, securityNotification *models.SecurityNotification) (err error)
+	DeleteAllSecurityNotifications(ctx context.Context, userID int64) (err error)
+	GetSecurityNotifications(ctx context.Context, userID int64) (securityNotifications []models.SecurityNotification, err error)
+	GetMentionSettings(ctx context.Context, userID int64) (settings *models.MentionSettings, err error)
+	UpdateMentionSettings(ctx context.Context, userID int64, settings *models.MentionSettings) (err error)
+}
+
+type AppealRepository interface {
+	CreateModerationAction(ctx context.Context, action *models.ModerationAction) (err error)
+	CreateModerationActionTx(ctx context.Context, tx *sql.Tx, action *models.ModerationAction) (err error)
+	GetModerationActionByID(ctx context.Context, actionID int64) (action *models.ModerationAction, err error)
+	GetModerationActionsByAuthorID(ctx context.Context, authorID int64) (actions []models.ModerationAction, err error)
+	CreateAppeal(ctx context.Context, appeal *models.Appeal) (err error)
+	CreateAppealTx(ctx context.Context, tx *sql.Tx, appeal *models.Appeal) (err error)
+	GetAppealByID(ctx context.Context, appealID int64) (appeal *models.Appeal, err error)
+	GetAppealByActionID(ctx context.Context, actionID int64) (appeal *models.Appeal, err error)
+	GetAppealsByAuthorID(ctx context.Context, authorID int64) (appeals []models.Appeal, err error)
+	GetPendingAppeals(ctx context.Context) (appeals []models.Appeal, err error)
+	ReverseAppeal(ctx context.Context, appealID int64, adminID int64) (err error)
+	ReverseAppealTx(ctx context.Context, tx *sql.Tx, appealID int64, adminID int64) (authorID int64, err error)
+	UpholdAppeal(ctx context.Context, appealID int64, adminID int64) (err error)
+	UpholdAppealTx(ctx context.Context, tx *sql.Tx, appealID int64, adminID int64) (authorID int64, err error)
+}
+
+type LoginAttemptRepository interface {
+	GetAttempt(ctx context.Context, key string) (attempt *models.LoginAttempt, err error)
+	AddFailureTx(ctx context.Context, tx *sql.Tx, key string, now int64, resetBefore int64) (attempt *models.LoginAttempt, err error)
+	BlockTx(ctx context.Context, tx *sql.Tx, key string, blockedUntil int64) (err error)
+	DeleteAttempt(ctx context.Context, key string) (err error)
+	DeleteStaleAttempts(ctx context.Context, before int64) (deleted int64, err error)
+}
+
+type TwoFactorRepository interface {
+	GetTwoFactor(ctx context.Context, userID int64) (twoFactor *models.TwoFactor, err error)
+	SaveSecret(ctx context.Context, userID int64, secret string) (err error)
+	Enable(ctx context.Context, userID int64, step int64, codeHashes []string) (err error)
+	UseStep(ctx context.Context, userID int64, step int64) (used bool, err error)
+	UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (used bool, err error)
+	ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) (err error)
+	CountRecoveryCodes(ctx context.Context, userID int64) (left int, err error)
+	Delete(ctx context.Context, userID int64) (err error)
+}
+
+type IdentityRepository interface {
+	GetUserIDByIdentity(ctx context.Context, provider, subject string) (userID int64, err error)
+	CreateIdentity(ctx context.Context, identity *models.Identity) (err error)
+	SaveState(ctx context.Context, state *models.OIDCState) (err error)
+	TakeState(ctx context.Context, state string) (oidcState *models.OIDCState, err error)
+	SaveChallenge(ctx context.Context, challenge *models.OIDCChallenge) (err error)
+	TakeChallenge(ctx context.Context, token string) (challenge *models.OIDCChallenge, err error)
+	DeleteExpiredStates(ctx context.Context, now int64) (deleted int64, err error)
 }
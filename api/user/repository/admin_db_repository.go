@@ -3,7 +3,10 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"time"
 
+	"github.com/innovember/forum/api/config"
 	"github.com/innovember/forum/api/models"
 	"github.com/innovember/forum/api/user"
 )
@@ -16,48 +19,61 @@ func NewAdminDBRepository(conn *sql.DB) user.AdminRepository {
 	return &AdminDBRepository{dbConn: conn}
 }
 
-func (ar *AdminDBRepository) UpgradeRole(requestID int64) (err error) {
+func (ar *AdminDBRepository) UpgradeRole(ctx context.Context, requestID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx    context.Context
-		tx     *sql.Tx
-		userID int64
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = ar.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if err = tx.QueryRow(`SELECT user_id
+	if err = ar.UpgradeRoleTx(ctx, tx, requestID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ar *AdminDBRepository) UpgradeRoleTx(ctx context.Context, tx *sql.Tx, requestID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		userID int64
+	)
+	if err = tx.QueryRowContext(ctx, `SELECT user_id
 						 FROM role_requests
 						 WHERE id = ?
 		`, requestID).Scan(&userID); err != nil {
-		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return errors.New("role request not found")
+		}
 		return err
 	}
-	if _, err = tx.Exec(`UPDATE users
+	if _, err = tx.ExecContext(ctx, `UPDATE users
 						 SET role = 1
 						 WHERE id = ? 
 		`, userID); err != nil {
-		tx.Rollback()
-		return err
-	}
-	if err = tx.Commit(); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (ar *AdminDBRepository) GetAllRoleRequests() (roleRequests []models.RoleRequest, err error) {
+func (ar *AdminDBRepository) GetAllRoleRequests(ctx context.Context) (roleRequests []models.RoleRequest, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx      context.Context
 		tx       *sql.Tx
 		rows     *sql.Rows
 		userRepo = NewUserDBRepository(ar.dbConn)
 	)
-	ctx = context.Background()
 	if tx, err = ar.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return nil, err
 	}
-	if rows, err = tx.Query(`SELECT *
+	if rows, err = tx.QueryContext(ctx, `SELECT *
 							 FROM role_requests
 		`); err != nil {
 		tx.Rollback()
@@ -71,7 +87,7 @@ func (ar *AdminDBRepository) GetAllRoleRequests() (roleRequests []models.RoleReq
 			tx.Rollback()
 			return nil, err
 		}
-		r.User, err = userRepo.GetUserByID(r.UserID)
+		r.User, err = userRepo.GetUserByID(ctx, r.UserID)
 		if err != nil {
 			tx.Rollback()
 			return nil, err
@@ -89,16 +105,16 @@ func (ar *AdminDBRepository) GetAllRoleRequests() (roleRequests []models.RoleReq
 	return roleRequests, nil
 }
 
-func (ar *AdminDBRepository) DeleteRoleRequest(requestID int64) (err error) {
+func (ar *AdminDBRepository) DeleteRoleRequest(ctx context.Context, requestID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
-		tx  *sql.Tx
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = ar.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM role_requests
+	if _, err = tx.ExecContext(ctx, `DELETE FROM role_requests
 						 WHERE id = ?
 		`, requestID); err != nil {
 		tx.Rollback()
@@ -110,20 +126,43 @@ func (ar *AdminDBRepository) DeleteRoleRequest(requestID int64) (err error) {
 	return nil
 }
 
-func (ar *AdminDBRepository) GetAllPostReports() (postReports []models.PostReport, err error) {
+// DeleteRoleRequestTx removes the request and returns the user who made it.
+func (ar *AdminDBRepository) DeleteRoleRequestTx(ctx context.Context, tx *sql.Tx, requestID int64) (userID int64, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if err = tx.QueryRowContext(ctx, `SELECT user_id
+						 FROM role_requests
+						 WHERE id = ?
+		`, requestID).Scan(&userID); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errors.New("role request not found")
+		}
+		return 0, err
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM role_requests
+						 WHERE id = ?
+		`, requestID); err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+func (ar *AdminDBRepository) GetAllPostReports(ctx context.Context) (postReports []models.PostReport, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx  context.Context
 		tx   *sql.Tx
 		rows *sql.Rows
 	)
-	ctx = context.Background()
 	if tx, err = ar.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return nil, err
 	}
-	if rows, err = tx.Query(`SELECT r.*, p.title
+	if rows, err = tx.QueryContext(ctx, `SELECT r.id, r.moderator_id, r.post_id,
+							 r.created_at, r.pending, p.title
 							 FROM post_reports AS r
 							 JOIN posts AS p
 							 ON p.id = r.post_id
+							 WHERE p.deleted_at = 0
 		`); err != nil {
 		tx.Rollback()
 		return nil, err
@@ -151,38 +190,67 @@ func (ar *AdminDBRepository) GetAllPostReports() (postReports []models.PostRepor
 	return postReports, nil
 }
 
-func (ar *AdminDBRepository) AcceptPostReport(postReportID int64) (err error) {
+// AcceptPostReport soft deletes the reported post the same way
+// PostRepository.Delete does, so it can still be restored.
+func (ar *AdminDBRepository) AcceptPostReport(ctx context.Context, postReportID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
-		tx  *sql.Tx
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = ar.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM posts
-						 WHERE id IN (
-							 SELECT post_id
-							 FROM post_reports
-							 WHERE id = ?
-						 )
-		`, postReportID); err != nil {
+	if err = ar.AcceptPostReportTx(ctx, tx, postReportID); err != nil {
 		tx.Rollback()
 		return err
 	}
-	return tx.Commit()
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	return nil
 }
 
-func (ar *AdminDBRepository) DismissPostReport(postReportID int64) (err error) {
+func (ar *AdminDBRepository) AcceptPostReportTx(ctx context.Context, tx *sql.Tx, postReportID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
-		tx  *sql.Tx
+		postID int64
+		now    = time.Now().Unix()
+	)
+	if err = tx.QueryRowContext(ctx, `SELECT post_id
+						  FROM post_reports
+						  WHERE id = ?
+		`, postReportID).Scan(&postID); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE posts
+						 SET deleted_at = ?
+						 WHERE id = ?
+						 AND deleted_at = 0
+		`, now, postID); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE comments
+						 SET deleted_at = ?
+						 WHERE post_id = ?
+						 AND deleted_at = 0
+		`, now, postID); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ar *AdminDBRepository) DismissPostReport(ctx context.Context, postReportID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = ar.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM post_reports
+	if _, err = tx.ExecContext(ctx, `DELETE FROM post_reports
 						 WHERE id = ?
 		`, postReportID); err != nil {
 		tx.Rollback()
@@ -194,17 +262,17 @@ func (ar *AdminDBRepository) DismissPostReport(postReportID int64) (err error) {
 	return nil
 }
 
-func (ar *AdminDBRepository) GetAllModerators() (moderators []models.User, err error) {
+func (ar *AdminDBRepository) GetAllModerators(ctx context.Context) (moderators []models.User, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx  context.Context
 		tx   *sql.Tx
 		rows *sql.Rows
 	)
-	ctx = context.Background()
 	if tx, err = ar.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return nil, err
 	}
-	if rows, err = tx.Query(`SELECT id, username,email,created_at, last_active,role
+	if rows, err = tx.QueryContext(ctx, `SELECT id, username,email,created_at, last_active,role
 							 FROM users
 							 WHERE role = 1`); err != nil {
 		return nil, err
@@ -229,39 +297,52 @@ func (ar *AdminDBRepository) GetAllModerators() (moderators []models.User, err e
 	return moderators, tx.Commit()
 }
 
-func (ar *AdminDBRepository) DemoteModerator(moderatorID int64) (err error) {
+func (ar *AdminDBRepository) DemoteModerator(ctx context.Context, moderatorID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
-		tx  *sql.Tx
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = ar.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`UPDATE users
+	if err = ar.DemoteModeratorTx(ctx, tx, moderatorID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ar *AdminDBRepository) DemoteModeratorTx(ctx context.Context, tx *sql.Tx, moderatorID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if _, err = tx.ExecContext(ctx, `UPDATE users
 						 SET role = 0
 						 WHERE id = ? 
 		`, moderatorID); err != nil {
-		tx.Rollback()
 		return err
 	}
-	if err = tx.Commit(); err != nil {
+	if _, err = tx.ExecContext(ctx, `DELETE FROM category_moderators
+						 WHERE user_id = ?`, moderatorID); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (ar *AdminDBRepository) DeletePostReportByPostID(postID int64) error {
+func (ar *AdminDBRepository) DeletePostReportByPostID(ctx context.Context, postID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
 		tx  *sql.Tx
 		err error
 	)
-	ctx = context.Background()
 	if tx, err = ar.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM post_reports
+	if _, err = tx.ExecContext(ctx, `DELETE FROM post_reports
 						 WHERE post_id = ?
 		`, postID); err != nil {
 		tx.Rollback()
@@ -0,0 +1,481 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/db"
+	"github.com/innovember/forum/api/models"
+	"github.com/innovember/forum/api/services/markdown"
+	"github.com/innovember/forum/api/user"
+)
+
+type AppealDBRepository struct {
+	dbConn *sql.DB
+}
+
+func NewAppealDBRepository(conn *sql.DB) user.AppealRepository {
+	return &AppealDBRepository{dbConn: conn}
+}
+
+func (ar *AppealDBRepository) CreateModerationAction(ctx context.Context, action *models.ModerationAction) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx *sql.Tx
+	)
+	if tx, err = ar.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return err
+	}
+	if err = ar.CreateModerationActionTx(ctx, tx, action); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ar *AppealDBRepository) CreateModerationActionTx(ctx context.Context, tx *sql.Tx, action *models.ModerationAction) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		result     sql.Result
+		categories []byte
+		now        = time.Now().Unix()
+	)
+	if categories, err = json.Marshal(action.Categories); err != nil {
+		return err
+	}
+	if result, err = tx.ExecContext(ctx, `INSERT INTO moderation_actions(post_id, author_id,
+		moderator_id, action, post_title, post_content, post_created_at,
+		post_is_image, post_image_path, post_categories, created_at)
+	VALUES(?,?,?,?,?,?,?,?,?,?,?)`,
+		action.PostID, action.AuthorID, action.ModeratorID,
+		action.Action, action.PostTitle, action.PostContent,
+		action.PostCreatedAt, action.IsImage, action.ImagePath,
+		string(categories), now); err != nil {
+		return err
+	}
+	if action.ID, err = result.LastInsertId(); err != nil {
+		return err
+	}
+	action.CreatedAt = now
+	return nil
+}
+
+func (ar *AppealDBRepository) GetModerationActionByID(ctx context.Context, actionID int64) (*models.ModerationAction, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	return getModerationAction(ctx, ar.dbConn, actionID)
+}
+
+func getModerationAction(ctx context.Context, exec db.Executor, actionID int64) (*models.ModerationAction, error) {
+	var (
+		a          models.ModerationAction
+		categories string
+		err        error
+	)
+	if err = exec.QueryRowContext(ctx, `SELECT id, post_id, author_id, moderator_id, action,
+						  post_title, post_content, post_created_at,
+						  post_is_image, post_image_path, post_categories, created_at
+						  FROM moderation_actions
+						  WHERE id = ?
+	`, actionID).Scan(&a.ID, &a.PostID, &a.AuthorID, &a.ModeratorID, &a.Action,
+		&a.PostTitle, &a.PostContent, &a.PostCreatedAt,
+		&a.IsImage, &a.ImagePath, &categories, &a.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("moderation action not found")
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal([]byte(categories), &a.Categories); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (ar *AppealDBRepository) GetModerationActionsByAuthorID(ctx context.Context, authorID int64) (actions []models.ModerationAction, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx   *sql.Tx
+		rows *sql.Rows
+	)
+	if tx, err = ar.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return nil, err
+	}
+	if rows, err = tx.QueryContext(ctx, `SELECT id, post_id, author_id, moderator_id, action,
+							 post_title, post_content, post_created_at,
+							 post_is_image, post_image_path, post_categories, created_at
+							 FROM moderation_actions
+							 WHERE author_id = ?
+							 ORDER BY created_at DESC
+		`, authorID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			a          models.ModerationAction
+			categories string
+		)
+		err = rows.Scan(&a.ID, &a.PostID, &a.AuthorID, &a.ModeratorID, &a.Action,
+			&a.PostTitle, &a.PostContent, &a.PostCreatedAt,
+			&a.IsImage, &a.ImagePath, &categories, &a.CreatedAt)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err = json.Unmarshal([]byte(categories), &a.Categories); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		actions = append(actions, a)
+	}
+	err = rows.Err()
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return actions, tx.Commit()
+}
+
+func (ar *AppealDBRepository) CreateAppeal(ctx context.Context, appeal *models.Appeal) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx *sql.Tx
+	)
+	if tx, err = ar.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return err
+	}
+	if err = ar.CreateAppealTx(ctx, tx, appeal); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (ar *AppealDBRepository) CreateAppealTx(ctx context.Context, tx *sql.Tx, appeal *models.Appeal) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		result sql.Result
+		now    = time.Now().Unix()
+	)
+	if result, err = tx.ExecContext(ctx, `INSERT INTO appeals(action_id, author_id,
+		content, status, created_at)
+	VALUES(?,?,?,?,?)`,
+		appeal.ActionID, appeal.AuthorID, appeal.Content,
+		config.AppealPending, now); err != nil {
+		return err
+	}
+	if appeal.ID, err = result.LastInsertId(); err != nil {
+		return err
+	}
+	appeal.Status = config.AppealPending
+	appeal.CreatedAt = now
+	return nil
+}
+
+func (ar *AppealDBRepository) GetAppealByID(ctx context.Context, appealID int64) (*models.Appeal, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx  *sql.Tx
+		a   models.Appeal
+		err error
+	)
+	if tx, err = ar.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return nil, err
+	}
+	if err = tx.QueryRowContext(ctx, `SELECT id, action_id, author_id, content,
+						  status, admin_id, created_at, resolved_at
+						  FROM appeals
+						  WHERE id = ?
+	`, appealID).Scan(&a.ID, &a.ActionID, &a.AuthorID, &a.Content,
+		&a.Status, &a.AdminID, &a.CreatedAt, &a.ResolvedAt); err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return nil, errors.New("appeal not found")
+		}
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	if a.Action, err = ar.GetModerationActionByID(ctx, a.ActionID); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (ar *AppealDBRepository) GetAppealByActionID(ctx context.Context, actionID int64) (*models.Appeal, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		a   models.Appeal
+		err error
+	)
+	if err = ar.dbConn.QueryRowContext(ctx, `SELECT id, action_id, author_id, content,
+								 status, admin_id, created_at, resolved_at
+								 FROM appeals
+								 WHERE action_id = ?
+	`, actionID).Scan(&a.ID, &a.ActionID, &a.AuthorID, &a.Content,
+		&a.Status, &a.AdminID, &a.CreatedAt, &a.ResolvedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (ar *AppealDBRepository) GetAppealsByAuthorID(ctx context.Context, authorID int64) (appeals []models.Appeal, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	return ar.getAppeals(ctx, `SELECT id, action_id, author_id, content,
+						  status, admin_id, created_at, resolved_at
+						  FROM appeals
+						  WHERE author_id = ?
+						  ORDER BY created_at DESC`, authorID)
+}
+
+func (ar *AppealDBRepository) GetPendingAppeals(ctx context.Context) (appeals []models.Appeal, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	return ar.getAppeals(ctx, `SELECT id, action_id, author_id, content,
+						  status, admin_id, created_at, resolved_at
+						  FROM appeals
+						  WHERE status = ?
+						  ORDER BY created_at ASC`, config.AppealPending)
+}
+
+func (ar *AppealDBRepository) getAppeals(ctx context.Context, query string, args ...interface{}) (appeals []models.Appeal, err error) {
+	var (
+		rows     *sql.Rows
+		userRepo = NewUserDBRepository(ar.dbConn)
+	)
+	if rows, err = ar.dbConn.QueryContext(ctx, query, args...); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var a models.Appeal
+		if err = rows.Scan(&a.ID, &a.ActionID, &a.AuthorID, &a.Content,
+			&a.Status, &a.AdminID, &a.CreatedAt, &a.ResolvedAt); err != nil {
+			return nil, err
+		}
+		appeals = append(appeals, a)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range appeals {
+		if appeals[i].Author, err = userRepo.GetUserByID(ctx, appeals[i].AuthorID); err != nil {
+			return nil, err
+		}
+		if appeals[i].Action, err = ar.GetModerationActionByID(ctx, appeals[i].ActionID); err != nil {
+			return nil, err
+		}
+	}
+	return appeals, nil
+}
+
+func (ar *AppealDBRepository) ReverseAppeal(ctx context.Context, appealID int64, adminID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx *sql.Tx
+	)
+	if tx, err = ar.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return err
+	}
+	if _, err = ar.ReverseAppealTx(ctx, tx, appealID, adminID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReverseAppealTx undoes the moderation action behind a pending appeal: a
+// banned post is unbanned, a deleted post is recreated from the snapshot
+// taken on deletion. It returns the author of the appeal.
+func (ar *AppealDBRepository) ReverseAppealTx(ctx context.Context, tx *sql.Tx, appealID int64, adminID int64) (authorID int64, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		actionID     int64
+		status       int
+		action       *models.ModerationAction
+		result       sql.Result
+		rowsAffected int64
+		now          = time.Now().Unix()
+	)
+	if err = tx.QueryRowContext(ctx, `SELECT action_id, author_id, status
+						  FROM appeals
+						  WHERE id = ?`, appealID).Scan(&actionID, &authorID, &status); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errors.New("appeal not found")
+		}
+		return 0, err
+	}
+	if status != config.AppealPending {
+		return 0, errors.New("appeal has already been resolved")
+	}
+	if action, err = getModerationAction(ctx, tx, actionID); err != nil {
+		return 0, err
+	}
+	switch action.Action {
+	case config.ActionBanned:
+		if result, err = tx.ExecContext(ctx, `UPDATE posts
+								  SET is_banned = 0,
+								  is_approved = 1
+								  WHERE id = ?
+								  AND deleted_at = 0`, action.PostID); err != nil {
+			return 0, err
+		}
+		if rowsAffected, err = result.RowsAffected(); err != nil {
+			return 0, err
+		}
+		if rowsAffected == 0 {
+			return 0, errors.New("banned post no longer exists")
+		}
+		if _, err = tx.ExecContext(ctx, `DELETE FROM posts_bans_bridge
+							 WHERE post_id = ?`, action.PostID); err != nil {
+			return 0, err
+		}
+	case config.ActionDeleted:
+		if err = restorePost(ctx, tx, action); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, errors.New("unknown moderation action")
+	}
+	if result, err = tx.ExecContext(ctx, `UPDATE appeals
+						 SET status = ?,
+						 admin_id = ?,
+						 resolved_at = ?
+						 WHERE id = ?
+						 AND status = ?`,
+		config.AppealReversed, adminID, now, appealID, config.AppealPending); err != nil {
+		return 0, err
+	}
+	if rowsAffected, err = result.RowsAffected(); err != nil {
+		return 0, err
+	}
+	if rowsAffected == 0 {
+		return 0, errors.New("appeal not found or already resolved")
+	}
+	return authorID, nil
+}
+
+func (ar *AppealDBRepository) UpholdAppeal(ctx context.Context, appealID int64, adminID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx *sql.Tx
+	)
+	if tx, err = ar.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return err
+	}
+	if _, err = ar.UpholdAppealTx(ctx, tx, appealID, adminID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpholdAppealTx rejects a pending appeal and returns its author.
+func (ar *AppealDBRepository) UpholdAppealTx(ctx context.Context, tx *sql.Tx, appealID int64, adminID int64) (authorID int64, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		result       sql.Result
+		rowsAffected int64
+		now          = time.Now().Unix()
+	)
+	if result, err = tx.ExecContext(ctx, `UPDATE appeals
+						 SET status = ?,
+						 admin_id = ?,
+						 resolved_at = ?
+						 WHERE id = ?
+						 AND status = ?`,
+		config.AppealUpheld, adminID, now, appealID, config.AppealPending); err != nil {
+		return 0, err
+	}
+	if rowsAffected, err = result.RowsAffected(); err != nil {
+		return 0, err
+	}
+	if rowsAffected == 0 {
+		return 0, errors.New("appeal not found or already resolved")
+	}
+	if err = tx.QueryRowContext(ctx, `SELECT author_id
+						  FROM appeals
+						  WHERE id = ?`, appealID).Scan(&authorID); err != nil {
+		return 0, err
+	}
+	return authorID, nil
+}
+
+// restorePost undoes a soft delete. If the post has already been purged it
+// is re-inserted from the snapshot under its original id and its categories
+// are relinked, except those an admin has deleted since.
+func restorePost(ctx context.Context, tx *sql.Tx, action *models.ModerationAction) (err error) {
+	var (
+		categoryID int64
+		deletedAt  int64
+	)
+	err = tx.QueryRowContext(ctx, `SELECT deleted_at
+					   FROM posts
+					   WHERE id = ?`, action.PostID).Scan(&deletedAt)
+	if err == nil {
+		if deletedAt == 0 {
+			return errors.New("post is not deleted")
+		}
+		if _, err = tx.ExecContext(ctx, `UPDATE posts
+							 SET deleted_at = 0
+							 WHERE id = ?`, action.PostID); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE comments
+						  SET deleted_at = 0
+						  WHERE post_id = ?
+						  AND deleted_at = ?`, action.PostID, deletedAt)
+		return err
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `INSERT INTO posts(id, author_id, title, content, content_html,
+		created_at, edited_at, is_image, image_path, is_approved, is_banned)
+	VALUES(?,?,?,?,?,?,?,?,?,?,?)`,
+		action.PostID, action.AuthorID, action.PostTitle, action.PostContent, markdown.Render(action.PostContent),
+		action.PostCreatedAt, 0, action.IsImage, action.ImagePath, 1, 0); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM posts_categories_bridge
+						 WHERE post_id = ?`, action.PostID); err != nil {
+		return err
+	}
+	for _, category := range action.Categories {
+		if err = tx.QueryRowContext(ctx, `SELECT id FROM categories WHERE name = ?`, category).Scan(&categoryID); err != nil {
+			if err != sql.ErrNoRows {
+				return err
+			}
+			continue
+		}
+		if _, err = tx.ExecContext(ctx, `INSERT INTO posts_categories_bridge (post_id, category_id)
+							 VALUES (?, ?)`, action.PostID, categoryID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/models"
+	"github.com/innovember/forum/api/user"
+)
+
+type IdentityDBRepository struct {
+	dbConn *sql.DB
+}
+
+func NewIdentityDBRepository(conn *sql.DB) user.IdentityRepository {
+	return &IdentityDBRepository{dbConn: conn}
+}
+
+// GetUserIDByIdentity returns 0 when the subject is not linked to a user.
+func (ir *IdentityDBRepository) GetUserIDByIdentity(ctx context.Context, provider, subject string) (userID int64, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if err = ir.dbConn.QueryRowContext(ctx, `SELECT user_id
+							 FROM user_identities
+							 WHERE provider = ? AND subject = ?`,
+		provider, subject).Scan(&userID); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return userID, nil
+}
+
+func (ir *IdentityDBRepository) CreateIdentity(ctx context.Context, identity *models.Identity) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx  *sql.Tx
+		now = time.Now().Unix()
+	)
+	if tx, err = ir.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `INSERT INTO user_identities(provider, subject,
+						 user_id, email, created_at)
+						 VALUES (?,?,?,?,?)`,
+		identity.Provider, identity.Subject, identity.UserID, identity.Email, now); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	identity.CreatedAt = now
+	return nil
+}
+
+func (ir *IdentityDBRepository) SaveState(ctx context.Context, state *models.OIDCState) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx *sql.Tx
+	)
+	if tx, err = ir.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `INSERT INTO oidc_states(state, provider,
+						 nonce, verifier, link_user_id, expires_at)
+						 VALUES (?,?,?,?,?,?)`,
+		state.State, state.Provider, state.Nonce, state.Verifier, state.LinkUserID, state.ExpiresAt); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// TakeState returns and deletes a state, so each can complete one sign-in.
+// It returns nil when there is no such state.
+func (ir *IdentityDBRepository) TakeState(ctx context.Context, state string) (oidcState *models.OIDCState, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx *sql.Tx
+		s  = models.OIDCState{State: state}
+	)
+	if tx, err = ir.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return nil, err
+	}
+	if err = tx.QueryRowContext(ctx, `SELECT provider, nonce, verifier, link_user_id, expires_at
+						 FROM oidc_states
+						 WHERE state = ?`, state).Scan(&s.Provider,
+		&s.Nonce, &s.Verifier, &s.LinkUserID, &s.ExpiresAt); err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM oidc_states
+						 WHERE state = ?`, state); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (ir *IdentityDBRepository) SaveChallenge(ctx context.Context, challenge *models.OIDCChallenge) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if _, err = ir.dbConn.ExecContext(ctx, `INSERT INTO oidc_challenges(token, user_id, expires_at)
+						 VALUES (?,?,?)`,
+		challenge.Token, challenge.UserID, challenge.ExpiresAt); err != nil {
+		return err
+	}
+	return nil
+}
+
+// TakeChallenge returns and deletes a challenge, so each allows one try at
+// a code. It returns nil when there is no such challenge.
+func (ir *IdentityDBRepository) TakeChallenge(ctx context.Context, token string) (challenge *models.OIDCChallenge, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx       *sql.Tx
+		result   sql.Result
+		affected int64
+		c        = models.OIDCChallenge{Token: token}
+	)
+	if tx, err = ir.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return nil, err
+	}
+	if err = tx.QueryRowContext(ctx, `SELECT user_id, expires_at
+						 FROM oidc_challenges
+						 WHERE token = ?`, token).Scan(&c.UserID, &c.ExpiresAt); err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if result, err = tx.ExecContext(ctx, `DELETE FROM oidc_challenges
+						 WHERE token = ?`, token); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	// taken by a concurrent try
+	if affected, err = result.RowsAffected(); err != nil || affected == 0 {
+		tx.Rollback()
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// DeleteExpiredStates deletes the expired sign-in states and two-factor
+// challenges.
+func (ir *IdentityDBRepository) DeleteExpiredStates(ctx context.Context, now int64) (deleted int64, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx         *sql.Tx
+		result     sql.Result
+		challenges int64
+	)
+	if tx, err = ir.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return 0, err
+	}
+	if result, err = tx.ExecContext(ctx, `DELETE FROM oidc_states
+						 WHERE expires_at < ?`, now); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if deleted, err = result.RowsAffected(); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if result, err = tx.ExecContext(ctx, `DELETE FROM oidc_challenges
+						 WHERE expires_at < ?`, now); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if challenges, err = result.RowsAffected(); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	deleted += challenges
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/models"
+	"github.com/innovember/forum/api/user"
+)
+
+type LoginAttemptDBRepository struct {
+	dbConn *sql.DB
+}
+
+func NewLoginAttemptDBRepository(conn *sql.DB) user.LoginAttemptRepository {
+	return &LoginAttemptDBRepository{dbConn: conn}
+}
+
+// GetAttempt returns a zero attempt for a key without failures.
+func (lr *LoginAttemptDBRepository) GetAttempt(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		attempt = models.LoginAttempt{Key: key}
+		err     error
+	)
+	if err = lr.dbConn.QueryRowContext(ctx, `SELECT failures, last_failure_at, blocked_until
+							 FROM login_attempts
+							 WHERE key = ?`, key).Scan(&attempt.Failures,
+		&attempt.LastFailureAt, &attempt.BlockedUntil); err != nil {
+		if err == sql.ErrNoRows {
+			return &attempt, nil
+		}
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// AddFailureTx counts one more failure for key, starting over from one when
+// the last failure is older than resetBefore, and returns the attempt. The
+// increment is done by the database and is the first statement of tx, so
+// concurrent failures for the same key wait for each other instead of
+// overwriting the count.
+func (lr *LoginAttemptDBRepository) AddFailureTx(ctx context.Context, tx *sql.Tx, key string, now int64, resetBefore int64) (attempt *models.LoginAttempt, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	attempt = &models.LoginAttempt{Key: key}
+	if _, err = tx.ExecContext(ctx, `INSERT INTO login_attempts(key, failures,
+						 last_failure_at, blocked_until)
+						 VALUES ($1, 1, $2, 0)
+						 ON CONFLICT(key) DO UPDATE SET
+						 failures = CASE WHEN last_failure_at < $3 THEN 1
+						 ELSE failures + 1 END,
+						 last_failure_at = excluded.last_failure_at`,
+		key, now, resetBefore); err != nil {
+		return nil, err
+	}
+	if err = tx.QueryRowContext(ctx, `SELECT failures, last_failure_at, blocked_until
+							 FROM login_attempts
+							 WHERE key = ?`, key).Scan(&attempt.Failures,
+		&attempt.LastFailureAt, &attempt.BlockedUntil); err != nil {
+		return nil, err
+	}
+	return attempt, nil
+}
+
+func (lr *LoginAttemptDBRepository) BlockTx(ctx context.Context, tx *sql.Tx, key string, blockedUntil int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if _, err = tx.ExecContext(ctx, `UPDATE login_attempts
+						 SET blocked_until = ?
+						 WHERE key = ?`, blockedUntil, key); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (lr *LoginAttemptDBRepository) DeleteAttempt(ctx context.Context, key string) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx *sql.Tx
+	)
+	if tx, err = lr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM login_attempts
+						 WHERE key = ?`, key); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteStaleAttempts drops attempts whose last failure is older than before
+// and that no longer block anyone.
+func (lr *LoginAttemptDBRepository) DeleteStaleAttempts(ctx context.Context, before int64) (deleted int64, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx     *sql.Tx
+		result sql.Result
+	)
+	if tx, err = lr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return 0, err
+	}
+	if result, err = tx.ExecContext(ctx, `DELETE FROM login_attempts
+						 WHERE last_failure_at < ?
+						 AND blocked_until < ?`, before, time.Now().Unix()); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if deleted, err = result.RowsAffected(); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
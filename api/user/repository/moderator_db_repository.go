@@ -5,8 +5,10 @@ import (
 	"database/sql"
 	"time"
 
+	"github.com/innovember/forum/api/config"
 	"github.com/innovember/forum/api/models"
 	postRepo "github.com/innovember/forum/api/post/repository"
+	"github.com/innovember/forum/api/services/imaging"
 	"github.com/innovember/forum/api/user"
 )
 
@@ -18,17 +20,17 @@ func NewModeratorDBRepository(conn *sql.DB) user.ModeratorRepository {
 	return &ModeratorDBRepository{dbConn: conn}
 }
 
-func (mr *ModeratorDBRepository) CreatePostReport(postReport *models.PostReport) (err error) {
+func (mr *ModeratorDBRepository) CreatePostReport(ctx context.Context, postReport *models.PostReport) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
 		tx  *sql.Tx
 		now int64 = time.Now().Unix()
 	)
-	ctx = context.Background()
 	if tx, err = mr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`INSERT INTO post_reports(
+	if _, err = tx.ExecContext(ctx, `INSERT INTO post_reports(
 						moderator_id,post_id,created_at, pending)
 						VALUES (
 							?,?,?,?
@@ -43,18 +45,16 @@ func (mr *ModeratorDBRepository) CreatePostReport(postReport *models.PostReport)
 	return nil
 }
 
-func (mr *ModeratorDBRepository) DeletePostReport(postReportID int64) (err error) {
+func (mr *ModeratorDBRepository) DeletePostReport(ctx context.Context, postReportID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
-		tx  *sql.Tx
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = mr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM post_reports
-						 WHERE id = ?
-		`, postReportID); err != nil {
+	if err = mr.DeletePostReportTx(ctx, tx, postReportID); err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -64,21 +64,35 @@ func (mr *ModeratorDBRepository) DeletePostReport(postReportID int64) (err error
 	return nil
 }
 
-func (mr *ModeratorDBRepository) GetMyReports(moderatorID int64) (postReports []models.PostReport, err error) {
+func (mr *ModeratorDBRepository) DeletePostReportTx(ctx context.Context, tx *sql.Tx, postReportID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var ()
+	if _, err = tx.ExecContext(ctx, `DELETE FROM post_reports
+						 WHERE id = ?
+		`, postReportID); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (mr *ModeratorDBRepository) GetMyReports(ctx context.Context, moderatorID int64) (postReports []models.PostReport, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx  context.Context
 		tx   *sql.Tx
 		rows *sql.Rows
 	)
-	ctx = context.Background()
 	if tx, err = mr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return nil, err
 	}
-	if rows, err = tx.Query(`SELECT r.*, p.title
+	if rows, err = tx.QueryContext(ctx, `SELECT r.id, r.moderator_id, r.post_id,
+							 r.created_at, r.pending, p.title
 							 FROM post_reports AS r
 							 JOIN posts AS p
 							 ON p.id = r.post_id
 							 WHERE moderator_id = ?
+							 AND p.deleted_at = 0
 		`, moderatorID); err != nil {
 		tx.Rollback()
 		return nil, err
@@ -103,18 +117,19 @@ func (mr *ModeratorDBRepository) GetMyReports(moderatorID int64) (postReports []
 	return postReports, tx.Commit()
 }
 
-func (mr *ModeratorDBRepository) ApprovePost(postID int64) (err error) {
+func (mr *ModeratorDBRepository) ApprovePost(ctx context.Context, postID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
-		tx  *sql.Tx
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = mr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`UPDATE posts
+	if _, err = tx.ExecContext(ctx, `UPDATE posts
 						 SET is_approved = 1
-						 WHERE id = ? 
+						 WHERE id = ?
+						 AND deleted_at = 0
 		`, postID); err != nil {
 		tx.Rollback()
 		return err
@@ -125,19 +140,22 @@ func (mr *ModeratorDBRepository) ApprovePost(postID int64) (err error) {
 	return nil
 }
 
-func (mr *ModeratorDBRepository) GetAllUnapprovedPosts() (posts []models.Post, err error) {
+func (mr *ModeratorDBRepository) GetAllUnapprovedPosts(ctx context.Context) (posts []models.Post, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx  context.Context
 		tx   *sql.Tx
 		rows *sql.Rows
 	)
-	ctx = context.Background()
 	if tx, err = mr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return nil, err
 	}
-	if rows, err = tx.Query(`SELECT *
+	if rows, err = tx.QueryContext(ctx, `SELECT id, author_id, title, content, content_html,
+							 created_at, edited_at, is_image,
+							 image_path, is_approved, is_banned
 							 FROM posts
 							 WHERE is_approved = 0
+							 AND deleted_at = 0
 		`); err != nil {
 		tx.Rollback()
 		return nil, err
@@ -145,13 +163,14 @@ func (mr *ModeratorDBRepository) GetAllUnapprovedPosts() (posts []models.Post, e
 	defer rows.Close()
 	for rows.Next() {
 		var p models.Post
-		err = rows.Scan(&p.ID, &p.AuthorID, &p.Title, &p.Content,
+		err = rows.Scan(&p.ID, &p.AuthorID, &p.Title, &p.Content, &p.ContentHTML,
 			&p.CreatedAt, &p.EditedAt, &p.IsImage,
-			&p.ImagePath, &p.IsApproved)
+			&p.ImagePath, &p.IsApproved, &p.IsBanned)
 		if err != nil {
 			tx.Rollback()
 			return nil, err
 		}
+		p.Variants = imaging.Variants(p.IsImage, p.ImagePath)
 		posts = append(posts, p)
 	}
 	err = rows.Err()
@@ -165,44 +184,53 @@ func (mr *ModeratorDBRepository) GetAllUnapprovedPosts() (posts []models.Post, e
 	return posts, nil
 }
 
-func (mr *ModeratorDBRepository) BanPost(postID int64, bans []string) (err error) {
+func (mr *ModeratorDBRepository) BanPost(ctx context.Context, postID int64, bans []string) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx     context.Context
-		tx      *sql.Tx
-		banRepo = postRepo.NewBanDBRepository(mr.dbConn)
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = mr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`UPDATE posts
-						 SET is_banned = 1
-						 WHERE id = ? 
-		`, postID); err != nil {
+	if err = mr.BanPostTx(ctx, tx, postID, bans); err != nil {
 		tx.Rollback()
 		return err
 	}
 	if err = tx.Commit(); err != nil {
 		return err
 	}
-	if err = banRepo.Create(postID, bans); err != nil {
+	return nil
+}
+
+func (mr *ModeratorDBRepository) BanPostTx(ctx context.Context, tx *sql.Tx, postID int64, bans []string) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		banRepo = postRepo.NewBanDBRepository(mr.dbConn)
+	)
+	if _, err = tx.ExecContext(ctx, `UPDATE posts
+						 SET is_banned = 1
+						 WHERE id = ?
+						 AND deleted_at = 0
+		`, postID); err != nil {
 		return err
 	}
-	return nil
+	return banRepo.CreateTx(ctx, tx, postID, bans)
 }
 
-func (mr *ModeratorDBRepository) GetPostReportByID(postReportID int64) (*models.PostReport, error) {
+func (mr *ModeratorDBRepository) GetPostReportByID(ctx context.Context, postReportID int64) (*models.PostReport, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
 		tx  *sql.Tx
 		pr  models.PostReport
 		err error
 	)
-	ctx = context.Background()
 	if tx, err = mr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return nil, err
 	}
-	if err = tx.QueryRow(`SELECT *
+	if err = tx.QueryRowContext(ctx, `SELECT *
 						  FROM post_reports
 						  WHERE id = ?
 	`, postReportID).Scan(&pr.ID, &pr.ModeratorID, &pr.PostID, &pr.CreatedAt, &pr.Pending); err != nil {
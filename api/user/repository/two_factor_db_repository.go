@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/models"
+	"github.com/innovember/forum/api/user"
+)
+
+type TwoFactorDBRepository struct {
+	dbConn *sql.DB
+}
+
+func NewTwoFactorDBRepository(conn *sql.DB) user.TwoFactorRepository {
+	return &TwoFactorDBRepository{dbConn: conn}
+}
+
+// GetTwoFactor returns a disabled enrollment without secret for a user who
+// never enrolled.
+func (tr *TwoFactorDBRepository) GetTwoFactor(ctx context.Context, userID int64) (*models.TwoFactor, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		twoFactor = models.TwoFactor{UserID: userID}
+		err       error
+	)
+	if err = tr.dbConn.QueryRowContext(ctx, `SELECT secret, enabled, last_used_step, created_at
+							 FROM two_factor
+							 WHERE user_id = ?`, userID).Scan(&twoFactor.Secret,
+		&twoFactor.Enabled, &twoFactor.LastUsedStep, &twoFactor.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return &twoFactor, nil
+		}
+		return nil, err
+	}
+	return &twoFactor, nil
+}
+
+// SaveSecret starts a new, pending enrollment and replaces any earlier one.
+func (tr *TwoFactorDBRepository) SaveSecret(ctx context.Context, userID int64, secret string) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx  *sql.Tx
+		now = time.Now().Unix()
+	)
+	if tx, err = tr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `INSERT INTO two_factor(user_id, secret,
+						 enabled, last_used_step, created_at)
+						 VALUES (?,?,0,0,?)
+						 ON CONFLICT(user_id) DO UPDATE SET
+						 secret = excluded.secret,
+						 enabled = 0,
+						 last_used_step = 0,
+						 created_at = excluded.created_at`,
+		userID, secret, now); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Enable turns the pending enrollment on, marks step used and stores a fresh
+// set of recovery codes.
+func (tr *TwoFactorDBRepository) Enable(ctx context.Context, userID int64, step int64, codeHashes []string) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx *sql.Tx
+	)
+	if tx, err = tr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE two_factor
+						 SET enabled = 1, last_used_step = ?
+						 WHERE user_id = ?`, step, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tr.replaceRecoveryCodesTx(ctx, tx, userID, codeHashes); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UseStep records step as the last accepted one. It reports false when that
+// step or a later one was already used, so each code works once.
+func (tr *TwoFactorDBRepository) UseStep(ctx context.Context, userID int64, step int64) (used bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx       *sql.Tx
+		result   sql.Result
+		affected int64
+	)
+	if tx, err = tr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return false, err
+	}
+	if result, err = tx.ExecContext(ctx, `UPDATE two_factor
+						 SET last_used_step = ?
+						 WHERE user_id = ? AND last_used_step < ?`,
+		step, userID, step); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if affected, err = result.RowsAffected(); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// UseRecoveryCode spends an unused recovery code, reporting false when there
+// is none with that hash.
+func (tr *TwoFactorDBRepository) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (used bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx       *sql.Tx
+		result   sql.Result
+		affected int64
+	)
+	if tx, err = tr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return false, err
+	}
+	if result, err = tx.ExecContext(ctx, `UPDATE recovery_codes
+						 SET used_at = ?
+						 WHERE user_id = ? AND code_hash = ? AND used_at = 0`,
+		time.Now().Unix(), userID, codeHash); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if affected, err = result.RowsAffected(); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (tr *TwoFactorDBRepository) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx *sql.Tx
+	)
+	if tx, err = tr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return err
+	}
+	if err = tr.replaceRecoveryCodesTx(ctx, tx, userID, codeHashes); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (tr *TwoFactorDBRepository) replaceRecoveryCodesTx(ctx context.Context, tx *sql.Tx, userID int64, codeHashes []string) (err error) {
+	if _, err = tx.ExecContext(ctx, `DELETE FROM recovery_codes
+						 WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	for _, codeHash := range codeHashes {
+		if _, err = tx.ExecContext(ctx, `INSERT INTO recovery_codes(user_id, code_hash, used_at)
+							 VALUES (?,?,0)`, userID, codeHash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (tr *TwoFactorDBRepository) CountRecoveryCodes(ctx context.Context, userID int64) (left int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if err = tr.dbConn.QueryRowContext(ctx, `SELECT COUNT(*)
+							 FROM recovery_codes
+							 WHERE user_id = ? AND used_at = 0`, userID).Scan(&left); err != nil {
+		return 0, err
+	}
+	return left, nil
+}
+
+func (tr *TwoFactorDBRepository) Delete(ctx context.Context, userID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx *sql.Tx
+	)
+	if tx, err = tr.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM recovery_codes
+						 WHERE user_id = ?`, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM two_factor
+						 WHERE user_id = ?`, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"github.com/innovember/forum/api/config"
 	"github.com/innovember/forum/api/models"
 	"github.com/innovember/forum/api/user"
 	"net/http"
@@ -18,13 +19,15 @@ func NewUserDBRepository(conn *sql.DB) user.UserRepository {
 	return &UserDBRepository{dbConn: conn}
 }
 
-func (ur *UserDBRepository) Create(user *models.User) (status int, err error) {
+func (ur *UserDBRepository) Create(ctx context.Context, user *models.User) (status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		result       sql.Result
 		rowsAffected int64
 		now          int64 = time.Now().Unix()
 	)
-	if result, err = ur.dbConn.Exec(`
+	if result, err = ur.dbConn.ExecContext(ctx, `
 	INSERT INTO users (
 			username,
 			password,
@@ -50,9 +53,11 @@ func (ur *UserDBRepository) Create(user *models.User) (status int, err error) {
 	return http.StatusBadRequest, errors.New("cant create new user")
 }
 
-func (ur *UserDBRepository) CheckByUsernameOrEmail(user *models.User) (status int, err error) {
-	username := ur.dbConn.QueryRow("SELECT username FROM users WHERE username = ?", user.Username).Scan(&user.Username)
-	email := ur.dbConn.QueryRow("SELECT email FROM users WHERE email = ?", user.Email).Scan(&user.Email)
+func (ur *UserDBRepository) CheckByUsernameOrEmail(ctx context.Context, user *models.User) (status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	username := ur.dbConn.QueryRowContext(ctx, "SELECT username FROM users WHERE username = ?", user.Username).Scan(&user.Username)
+	email := ur.dbConn.QueryRowContext(ctx, "SELECT email FROM users WHERE email = ?", user.Email).Scan(&user.Email)
 	if username == nil && email != nil {
 		return http.StatusConflict, errors.New("username already exist")
 	} else if email == nil && username != nil {
@@ -63,9 +68,11 @@ func (ur *UserDBRepository) CheckByUsernameOrEmail(user *models.User) (status in
 	return http.StatusOK, nil
 }
 
-func (ur *UserDBRepository) GetAllUsers() (users []models.User, err error) {
+func (ur *UserDBRepository) GetAllUsers(ctx context.Context) (users []models.User, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var rows *sql.Rows
-	if rows, err = ur.dbConn.Query(`SELECT id, username,email,created_at, last_active FROM users`); err != nil {
+	if rows, err = ur.dbConn.QueryContext(ctx, `SELECT id, username,email,created_at, last_active FROM users`); err != nil {
 		return nil, err
 	}
 	defer rows.Close()
@@ -84,8 +91,10 @@ func (ur *UserDBRepository) GetAllUsers() (users []models.User, err error) {
 	return users, nil
 }
 
-func (ur *UserDBRepository) GetPassword(username string) (password string, status int, err error) {
-	if err = ur.dbConn.QueryRow(`
+func (ur *UserDBRepository) GetPassword(ctx context.Context, username string) (password string, status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if err = ur.dbConn.QueryRowContext(ctx, `
 	SELECT password FROM users WHERE username = ?`, username).Scan(&password); err != nil {
 		if err == sql.ErrNoRows {
 			return "", http.StatusNotFound, errors.New("password not found for username:" + username)
@@ -95,12 +104,14 @@ func (ur *UserDBRepository) GetPassword(username string) (password string, statu
 	return password, http.StatusOK, nil
 }
 
-func (ur *UserDBRepository) FindUserByUsername(username string) (*models.User, int, error) {
+func (ur *UserDBRepository) FindUserByUsername(ctx context.Context, username string) (*models.User, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		user models.User
 		err  error
 	)
-	if err = ur.dbConn.QueryRow(`
+	if err = ur.dbConn.QueryRowContext(ctx, `
 	SELECT id,username,email FROM users WHERE username = ?
 	`, username).Scan(&user.ID, &user.Username, &user.Email); err != nil {
 		if err == sql.ErrNoRows {
@@ -111,13 +122,34 @@ func (ur *UserDBRepository) FindUserByUsername(username string) (*models.User, i
 	return &user, http.StatusOK, nil
 }
 
-func (ur *UserDBRepository) UpdateSession(userID int64, sessionValue string, expiresAt int64) (err error) {
+// FindUserByEmail matches email case-insensitively.
+func (ur *UserDBRepository) FindUserByEmail(ctx context.Context, email string) (*models.User, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		user models.User
+		err  error
+	)
+	if err = ur.dbConn.QueryRowContext(ctx, `
+	SELECT id,username,email,role FROM users WHERE lower(email) = lower(?)
+	`, email).Scan(&user.ID, &user.Username, &user.Email, &user.Role); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, http.StatusNotFound, errors.New("user not found for email:" + email)
+		}
+		return nil, http.StatusInternalServerError, err
+	}
+	return &user, http.StatusOK, nil
+}
+
+func (ur *UserDBRepository) UpdateSession(ctx context.Context, userID int64, sessionValue string, expiresAt int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		result       sql.Result
 		rowsAffected int64
 		now          int64 = time.Now().Unix()
 	)
-	if result, err = ur.dbConn.Exec(`
+	if result, err = ur.dbConn.ExecContext(ctx, `
 	UPDATE users
 	SET session_id = ?,
 	last_active = ?,
@@ -134,12 +166,14 @@ func (ur *UserDBRepository) UpdateSession(userID int64, sessionValue string, exp
 	return errors.New("cant update session")
 }
 
-func (ur *UserDBRepository) ValidateSession(sessionValue string) (*models.User, int, error) {
+func (ur *UserDBRepository) ValidateSession(ctx context.Context, sessionValue string) (*models.User, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		user models.User
 		err  error
 	)
-	if err = ur.dbConn.QueryRow(`
+	if err = ur.dbConn.QueryRowContext(ctx, `
 	SELECT id,username,email,created_at,last_active,role FROM users WHERE session_id = ?`, sessionValue,
 	).Scan(&user.ID, &user.Username,
 		&user.Email, &user.CreatedAt,
@@ -152,9 +186,11 @@ func (ur *UserDBRepository) ValidateSession(sessionValue string) (*models.User,
 	return &user, http.StatusOK, nil
 }
 
-func (ur *UserDBRepository) CheckSessionByUsername(username string) (status int, err error) {
+func (ur *UserDBRepository) CheckSessionByUsername(ctx context.Context, username string) (status int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var user models.User
-	if err = ur.dbConn.QueryRow(`
+	if err = ur.dbConn.QueryRowContext(ctx, `
 	SELECT session_id FROM users WHERE username = ?`, username).Scan(&user.SessionID); err != nil {
 		if err == sql.ErrNoRows {
 			return http.StatusNotFound, errors.New("user not found")
@@ -167,12 +203,14 @@ func (ur *UserDBRepository) CheckSessionByUsername(username string) (status int,
 	return http.StatusForbidden, errors.New("user already authorized")
 }
 
-func (ur *UserDBRepository) GetUserByID(userID int64) (*models.User, error) {
+func (ur *UserDBRepository) GetUserByID(ctx context.Context, userID int64) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
 		user models.User
 		err  error
 	)
-	if err = ur.dbConn.QueryRow(`
+	if err = ur.dbConn.QueryRowContext(ctx, `
 	SELECT id,username,email,created_at,last_active,role
 	FROM users WHERE id = ?`, userID).Scan(&user.ID, &user.Username,
 		&user.Email, &user.CreatedAt,
@@ -185,17 +223,17 @@ func (ur *UserDBRepository) GetUserByID(userID int64) (*models.User, error) {
 	return &user, nil
 }
 
-func (ur *UserDBRepository) UpdateActivity(userID int64) (err error) {
+func (ur *UserDBRepository) UpdateActivity(ctx context.Context, userID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
 		tx  *sql.Tx
 		now int64 = time.Now().Unix()
 	)
-	ctx = context.Background()
 	if tx, err = ur.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`UPDATE users
+	if _, err = tx.ExecContext(ctx, `UPDATE users
 						 SET last_active = ?
 						 WHERE id = ?`, now, userID); err != nil {
 		tx.Rollback()
@@ -207,17 +245,17 @@ func (ur *UserDBRepository) UpdateActivity(userID int64) (err error) {
 	return nil
 }
 
-func (ur *UserDBRepository) CreateRoleRequest(userID int64) (err error) {
+func (ur *UserDBRepository) CreateRoleRequest(ctx context.Context, userID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
 		tx  *sql.Tx
 		now int64 = time.Now().Unix()
 	)
-	ctx = context.Background()
 	if tx, err = ur.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`INSERT INTO role_requests(
+	if _, err = tx.ExecContext(ctx, `INSERT INTO role_requests(
 						user_id, created_at, pending)
 						VALUES (
 							?,?,?
@@ -232,18 +270,18 @@ func (ur *UserDBRepository) CreateRoleRequest(userID int64) (err error) {
 	return nil
 }
 
-func (ur *UserDBRepository) GetRoleRequestByUserID(userID int64) (*models.RoleRequest, error) {
+func (ur *UserDBRepository) GetRoleRequestByUserID(ctx context.Context, userID int64) (*models.RoleRequest, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
 		tx  *sql.Tx
 		err error
 		r   models.RoleRequest
 	)
-	ctx = context.Background()
 	if tx, err = ur.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return nil, err
 	}
-	if err = tx.QueryRow(`SELECT *
+	if err = tx.QueryRowContext(ctx, `SELECT *
 						  FROM role_requests
 						  WHERE user_id = ?
 	`, userID).Scan(&r.ID, &r.UserID, &r.CreatedAt, &r.Pending); err != nil {
@@ -256,16 +294,16 @@ func (ur *UserDBRepository) GetRoleRequestByUserID(userID int64) (*models.RoleRe
 	return &r, nil
 }
 
-func (ur *UserDBRepository) DeleteRoleRequest(userID int64) (err error) {
+func (ur *UserDBRepository) DeleteRoleRequest(ctx context.Context, userID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
-		tx  *sql.Tx
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = ur.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM role_requests
+	if _, err = tx.ExecContext(ctx, `DELETE FROM role_requests
 						 WHERE user_id = ?
 		`, userID); err != nil {
 		tx.Rollback()
@@ -277,18 +315,18 @@ func (ur *UserDBRepository) DeleteRoleRequest(userID int64) (err error) {
 	return nil
 }
 
-func (ur *UserDBRepository) GetRoleRequestByID(requestID int64) (*models.RoleRequest, error) {
+func (ur *UserDBRepository) GetRoleRequestByID(ctx context.Context, requestID int64) (*models.RoleRequest, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
 		tx  *sql.Tx
 		r   models.RoleRequest
 		err error
 	)
-	ctx = context.Background()
 	if tx, err = ur.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return nil, err
 	}
-	if err = tx.QueryRow(`SELECT *
+	if err = tx.QueryRowContext(ctx, `SELECT *
 						  FROM role_requests
 						  WHERE id = ?
 	`, requestID).Scan(&r.ID, &r.UserID, &r.CreatedAt, &r.Pending); err != nil {
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"time"
 
+	"github.com/innovember/forum/api/config"
 	"github.com/innovember/forum/api/models"
 	"github.com/innovember/forum/api/user"
 )
@@ -17,20 +18,16 @@ func NewUserNotificationDBRepository(conn *sql.DB) user.UserNotificationReposito
 	return &UserNotificationDBRepository{dbConn: conn}
 }
 
-func (ur *UserNotificationDBRepository) CreateRoleNotification(roleNotification *models.RoleNotification) (err error) {
+func (ur *UserNotificationDBRepository) CreateRoleNotification(ctx context.Context, roleNotification *models.RoleNotification) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
-		tx  *sql.Tx
-		now = time.Now().Unix()
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = ur.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`INSERT INTO notifications_roles(receiver_id, accepted,
-		declined,demoted,created_at)
-	VALUES(?,?,?,?,?)`, roleNotification.ReceiverID, roleNotification.Accepted,
-		roleNotification.Declined, roleNotification.Demoted, now); err != nil {
+	if err = ur.CreateRoleNotificationTx(ctx, tx, roleNotification); err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -40,20 +37,31 @@ func (ur *UserNotificationDBRepository) CreateRoleNotification(roleNotification
 	return nil
 }
 
-func (ur *UserNotificationDBRepository) CreatePostReportNotification(postReportNotification *models.PostReportNotification) (err error) {
+func (ur *UserNotificationDBRepository) CreateRoleNotificationTx(ctx context.Context, tx *sql.Tx, roleNotification *models.RoleNotification) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
-		tx  *sql.Tx
 		now = time.Now().Unix()
 	)
-	ctx = context.Background()
+	if _, err = tx.ExecContext(ctx, `INSERT INTO notifications_roles(receiver_id, accepted,
+		declined,demoted,created_at)
+	VALUES(?,?,?,?,?)`, roleNotification.ReceiverID, roleNotification.Accepted,
+		roleNotification.Declined, roleNotification.Demoted, now); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ur *UserNotificationDBRepository) CreatePostReportNotification(ctx context.Context, postReportNotification *models.PostReportNotification) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx *sql.Tx
+	)
 	if tx, err = ur.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`INSERT INTO notifications_reports(receiver_id, approved,
-		deleted,created_at)
-	VALUES(?,?,?,?)`, postReportNotification.ReceiverID, postReportNotification.Approved,
-		postReportNotification.Deleted, now); err != nil {
+	if err = ur.CreatePostReportNotificationTx(ctx, tx, postReportNotification); err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -63,16 +71,31 @@ func (ur *UserNotificationDBRepository) CreatePostReportNotification(postReportN
 	return nil
 }
 
-func (ur *UserNotificationDBRepository) DeleteAllRoleNotifications(userID int64) (err error) {
+func (ur *UserNotificationDBRepository) CreatePostReportNotificationTx(ctx context.Context, tx *sql.Tx, postReportNotification *models.PostReportNotification) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		now = time.Now().Unix()
+	)
+	if _, err = tx.ExecContext(ctx, `INSERT INTO notifications_reports(receiver_id, approved,
+		deleted,created_at)
+	VALUES(?,?,?,?)`, postReportNotification.ReceiverID, postReportNotification.Approved,
+		postReportNotification.Deleted, now); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ur *UserNotificationDBRepository) DeleteAllRoleNotifications(ctx context.Context, userID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
-		tx  *sql.Tx
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = ur.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM notifications_roles
+	if _, err = tx.ExecContext(ctx, `DELETE FROM notifications_roles
 						 WHERE receiver_id = ?
 		`, userID); err != nil {
 		tx.Rollback()
@@ -84,16 +107,16 @@ func (ur *UserNotificationDBRepository) DeleteAllRoleNotifications(userID int64)
 	return nil
 }
 
-func (ur *UserNotificationDBRepository) DeleteAllPostReportNotifications(userID int64) (err error) {
+func (ur *UserNotificationDBRepository) DeleteAllPostReportNotifications(ctx context.Context, userID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
-		tx  *sql.Tx
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = ur.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM notifications_reports
+	if _, err = tx.ExecContext(ctx, `DELETE FROM notifications_reports
 						 WHERE receiver_id = ?
 		`, userID); err != nil {
 		tx.Rollback()
@@ -105,17 +128,17 @@ func (ur *UserNotificationDBRepository) DeleteAllPostReportNotifications(userID
 	return nil
 }
 
-func (ur *UserNotificationDBRepository) GetRoleNotifications(userID int64) (roleNotifications []models.RoleNotification, err error) {
+func (ur *UserNotificationDBRepository) GetRoleNotifications(ctx context.Context, userID int64) (roleNotifications []models.RoleNotification, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx  context.Context
 		tx   *sql.Tx
 		rows *sql.Rows
 	)
-	ctx = context.Background()
 	if tx, err = ur.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return nil, err
 	}
-	if rows, err = tx.Query(`SELECT *
+	if rows, err = tx.QueryContext(ctx, `SELECT *
 							 FROM notifications_roles
 							 WHERE receiver_id = ?`,
 		userID); err != nil {
@@ -141,17 +164,17 @@ func (ur *UserNotificationDBRepository) GetRoleNotifications(userID int64) (role
 	return roleNotifications, tx.Commit()
 }
 
-func (ur *UserNotificationDBRepository) GetPostReportNotifications(userID int64) (postReportNotifications []models.PostReportNotification, err error) {
+func (ur *UserNotificationDBRepository) GetPostReportNotifications(ctx context.Context, userID int64) (postReportNotifications []models.PostReportNotification, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx  context.Context
 		tx   *sql.Tx
 		rows *sql.Rows
 	)
-	ctx = context.Background()
 	if tx, err = ur.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return nil, err
 	}
-	if rows, err = tx.Query(`SELECT *
+	if rows, err = tx.QueryContext(ctx, `SELECT *
 							 FROM notifications_reports
 							 WHERE receiver_id = ?`,
 		userID); err != nil {
@@ -177,17 +200,32 @@ func (ur *UserNotificationDBRepository) GetPostReportNotifications(userID int64)
 	return postReportNotifications, tx.Commit()
 }
 
-func (ur *UserNotificationDBRepository) CreatePostNotification(postNotification *models.PostNotification) (err error) {
+func (ur *UserNotificationDBRepository) CreatePostNotification(ctx context.Context, postNotification *models.PostNotification) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
-		tx  *sql.Tx
-		now = time.Now().Unix()
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = ur.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`INSERT INTO notifications_posts(receiver_id, approved,
+	if err = ur.CreatePostNotificationTx(ctx, tx, postNotification); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ur *UserNotificationDBRepository) CreatePostNotificationTx(ctx context.Context, tx *sql.Tx, postNotification *models.PostNotification) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		now = time.Now().Unix()
+	)
+	if _, err = tx.ExecContext(ctx, `INSERT INTO notifications_posts(receiver_id, approved,
 		banned, deleted, created_at)
 	VALUES(?,?,?,?,?)`,
 		postNotification.ReceiverID,
@@ -195,25 +233,21 @@ func (ur *UserNotificationDBRepository) CreatePostNotification(postNotification
 		postNotification.Banned,
 		postNotification.Deleted,
 		now); err != nil {
-		tx.Rollback()
-		return err
-	}
-	if err = tx.Commit(); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (ur *UserNotificationDBRepository) DeleteAllPostNotifications(userID int64) (err error) {
+func (ur *UserNotificationDBRepository) DeleteAllPostNotifications(ctx context.Context, userID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx context.Context
-		tx  *sql.Tx
+		tx *sql.Tx
 	)
-	ctx = context.Background()
 	if tx, err = ur.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return err
 	}
-	if _, err = tx.Exec(`DELETE FROM notifications_posts
+	if _, err = tx.ExecContext(ctx, `DELETE FROM notifications_posts
 						 WHERE receiver_id = ?
 		`, userID); err != nil {
 		tx.Rollback()
@@ -225,17 +259,17 @@ func (ur *UserNotificationDBRepository) DeleteAllPostNotifications(userID int64)
 	return nil
 }
 
-func (ur *UserNotificationDBRepository) GetPostNotifications(userID int64) (postNotifications []models.PostNotification, err error) {
+func (ur *UserNotificationDBRepository) GetPostNotifications(ctx context.Context, userID int64) (postNotifications []models.PostNotification, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
 	var (
-		ctx  context.Context
 		tx   *sql.Tx
 		rows *sql.Rows
 	)
-	ctx = context.Background()
 	if tx, err = ur.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
 		return nil, err
 	}
-	if rows, err = tx.Query(`SELECT *
+	if rows, err = tx.QueryContext(ctx, `SELECT *
 							 FROM notifications_posts
 							 WHERE receiver_id = ?`,
 		userID); err != nil {
@@ -259,3 +293,219 @@ func (ur *UserNotificationDBRepository) GetPostNotifications(userID int64) (post
 	}
 	return postNotifications, tx.Commit()
 }
+
+func (ur *UserNotificationDBRepository) CreateAppealNotification(ctx context.Context, appealNotification *models.AppealNotification) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx *sql.Tx
+	)
+	if tx, err = ur.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return err
+	}
+	if err = ur.CreateAppealNotificationTx(ctx, tx, appealNotification); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ur *UserNotificationDBRepository) CreateAppealNotificationTx(ctx context.Context, tx *sql.Tx, appealNotification *models.AppealNotification) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		now = time.Now().Unix()
+	)
+	if _, err = tx.ExecContext(ctx, `INSERT INTO notifications_appeals(receiver_id, appeal_id,
+		submitted, reversed, upheld, created_at)
+	VALUES(?,?,?,?,?,?)`,
+		appealNotification.ReceiverID,
+		appealNotification.AppealID,
+		appealNotification.Submitted,
+		appealNotification.Reversed,
+		appealNotification.Upheld,
+		now); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ur *UserNotificationDBRepository) DeleteAllAppealNotifications(ctx context.Context, userID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx *sql.Tx
+	)
+	if tx, err = ur.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM notifications_appeals
+						 WHERE receiver_id = ?
+		`, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ur *UserNotificationDBRepository) GetAppealNotifications(ctx context.Context, userID int64) (appealNotifications []models.AppealNotification, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx   *sql.Tx
+		rows *sql.Rows
+	)
+	if tx, err = ur.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return nil, err
+	}
+	if rows, err = tx.QueryContext(ctx, `SELECT id, receiver_id, appeal_id,
+							 submitted, reversed, upheld, created_at
+							 FROM notifications_appeals
+							 WHERE receiver_id = ?
+							 ORDER BY created_at DESC`,
+		userID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var an models.AppealNotification
+		err = rows.Scan(&an.ID, &an.ReceiverID, &an.AppealID,
+			&an.Submitted, &an.Reversed, &an.Upheld, &an.CreatedAt)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		appealNotifications = append(appealNotifications, an)
+	}
+	err = rows.Err()
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return appealNotifications, tx.Commit()
+}
+
+func (ur *UserNotificationDBRepository) CreateSecurityNotification(ctx context.Context, securityNotification *models.SecurityNotification) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx *sql.Tx
+	)
+	if tx, err = ur.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return err
+	}
+	if err = ur.CreateSecurityNotificationTx(ctx, tx, securityNotification); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ur *UserNotificationDBRepository) CreateSecurityNotificationTx(ctx context.Context, tx *sql.Tx, securityNotification *models.SecurityNotification) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		now = time.Now().Unix()
+	)
+	if _, err = tx.ExecContext(ctx, `INSERT INTO notifications_security(receiver_id,
+		locked_until, created_at)
+	VALUES(?,?,?)`,
+		securityNotification.ReceiverID,
+		securityNotification.LockedUntil,
+		now); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ur *UserNotificationDBRepository) DeleteAllSecurityNotifications(ctx context.Context, userID int64) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx *sql.Tx
+	)
+	if tx, err = ur.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM notifications_security
+						 WHERE receiver_id = ?
+		`, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ur *UserNotificationDBRepository) GetSecurityNotifications(ctx context.Context, userID int64) (securityNotifications []models.SecurityNotification, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	var (
+		tx   *sql.Tx
+		rows *sql.Rows
+	)
+	if tx, err = ur.dbConn.BeginTx(ctx, &sql.TxOptions{}); err != nil {
+		return nil, err
+	}
+	if rows, err = tx.QueryContext(ctx, `SELECT id, receiver_id, locked_until, created_at
+							 FROM notifications_security
+							 WHERE receiver_id = ?
+							 ORDER BY created_at DESC`,
+		userID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var sn models.SecurityNotification
+		err = rows.Scan(&sn.ID, &sn.ReceiverID, &sn.LockedUntil, &sn.CreatedAt)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		securityNotifications = append(securityNotifications, sn)
+	}
+	err = rows.Err()
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return securityNotifications, tx.Commit()
+}
+
+func (ur *UserNotificationDBRepository) GetMentionSettings(ctx context.Context, userID int64) (settings *models.MentionSettings, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	settings = &models.MentionSettings{}
+	if err = ur.dbConn.QueryRowContext(ctx, `SELECT mention_notifications
+							 FROM users
+							 WHERE id = ?`, userID).Scan(&settings.Enabled); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// UpdateMentionSettings opts the user in or out of mention notifications.
+// Mentions are still recorded while they are out.
+func (ur *UserNotificationDBRepository) UpdateMentionSettings(ctx context.Context, userID int64, settings *models.MentionSettings) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if _, err = ur.dbConn.ExecContext(ctx, `UPDATE users
+						 SET mention_notifications = ?
+						 WHERE id = ?`, settings.Enabled, userID); err != nil {
+		return err
+	}
+	return nil
+}
@@ -1,55 +1,107 @@
 package user
 
 import (
+	"context"
+	"time"
+
 	"github.com/innovember/forum/api/models"
 )
 
 type UserUsecase interface {
-	Create(user *models.User) (status int, err error)
-	GetAllUsers() (users []models.User, status int, err error)
-	GetUserByID(userID int64) (user *models.User, err error)
-	GetPassword(username string) (password string, status int, err error)
-	FindUserByUsername(username string) (user *models.User, status int, err error)
-	UpdateSession(userID int64, sessionValue string, expiresAt int64) (err error)
-	ValidateSession(sessionValue string) (user *models.User, status int, err error)
-	CheckSessionByUsername(username string) (status int, err error)
-	UpdateActivity(userID int64) (err error)
-	CreateRoleRequest(userID int64) (err error)
-	GetRoleRequestByUserID(userID int64) (request *models.RoleRequest, err error)
-	DeleteRoleRequest(userID int64) (err error)
-	GetRoleRequestByID(requestID int64) (roleRequest *models.RoleRequest, err error)
+	Create(ctx context.Context, user *models.User) (status int, err error)
+	GetAllUsers(ctx context.Context) (users []models.User, status int, err error)
+	GetUserByID(ctx context.Context, userID int64) (user *models.User, err error)
+	GetPassword(ctx context.Context, username string) (password string, status int, err error)
+	FindUserByUsername(ctx context.Context, username string) (user *models.User, status int, err error)
+	FindUserByEmail(ctx context.Context, email string) (user *models.User, status int, err error)
+	UpdateSession(ctx context.Context, userID int64, sessionValue string, expiresAt int64) (err error)
+	ValidateSession(ctx context.Context, sessionValue string) (user *models.User, status int, err error)
+	CheckSessionByUsername(ctx context.Context, username string) (status int, err error)
+	UpdateActivity(ctx context.Context, userID int64) (err error)
+	CreateRoleRequest(ctx context.Context, userID int64) (err error)
+	GetRoleRequestByUserID(ctx context.Context, userID int64) (request *models.RoleRequest, err error)
+	DeleteRoleRequest(ctx context.Context, userID int64) (err error)
+	GetRoleRequestByID(ctx context.Context, requestID int64) (roleRequest *models.RoleRequest, err error)
 }
 
 type AdminUsecase interface {
-	UpgradeRole(requestID int64) (err error)
-	GetAllRoleRequests() (roleRequests []models.RoleRequest, err error)
-	DeleteRoleRequest(requestID int64) (err error)
-	GetAllPostReports() (postReports []models.PostReport, err error)
-	AcceptPostReport(postReportID int64) (err error)
-	DismissPostReport(postReportID int64) (err error)
-	GetAllModerators() (moderators []models.User, err error)
-	DemoteModerator(moderatorID int64) (err error)
-	DeletePostReportByPostID(postID int64) error
+	UpgradeRole(ctx context.Context, requestID int64) (err error)
+	AcceptRoleRequest(ctx context.Context, requestID int64) (err error)
+	DismissRoleRequest(ctx context.Context, requestID int64) (err error)
+	GetAllRoleRequests(ctx context.Context) (roleRequests []models.RoleRequest, err error)
+	DeleteRoleRequest(ctx context.Context, requestID int64) (err error)
+	GetAllPostReports(ctx context.Context) (postReports []models.PostReport, err error)
+	AcceptPostReport(ctx context.Context, postReport *models.PostReport, action *models.ModerationAction) (err error)
+	DismissPostReport(ctx context.Context, postReportID int64) (err error)
+	GetAllModerators(ctx context.Context) (moderators []models.User, err error)
+	DemoteModerator(ctx context.Context, moderatorID int64) (err error)
+	DeletePostReportByPostID(ctx context.Context, postID int64) error
 }
 
 type ModeratorUsecase interface {
-	CreatePostReport(postReport *models.PostReport) (err error)
-	DeletePostReport(postReportID int64) (err error)
-	GetMyReports(moderatorID int64) (postReports []models.PostReport, err error)
-	ApprovePost(postID int64) (err error)
-	GetAllUnapprovedPosts() (posts []models.Post, err error)
-	BanPost(postID int64, bans []string) (err error)
-	GetPostReportByID(postReportID int64) (postReport *models.PostReport, err error)
+	CreatePostReport(ctx context.Context, postReport *models.PostReport) (err error)
+	DeletePostReport(ctx context.Context, postReportID int64) (err error)
+	GetMyReports(ctx context.Context, moderatorID int64) (postReports []models.PostReport, err error)
+	ApprovePost(ctx context.Context, postID int64) (err error)
+	GetAllUnapprovedPosts(ctx context.Context) (posts []models.Post, err error)
+	BanPost(ctx context.Context, action *models.ModerationAction, bans []string) (err error)
+	GetPostReportByID(ctx context.Context, postReportID int64) (postReport *models.PostReport, err error)
 }
 
 type UserNotificationUsecase interface {
-	CreateRoleNotification(roleNotification *models.RoleNotification) (err error)
-	CreatePostReportNotification(postReportNotification *models.PostReportNotification) (err error)
-	DeleteAllRoleNotifications(userID int64) (err error)
-	DeleteAllPostReportNotifications(userID int64) (err error)
-	GetRoleNotifications(userID int64) (roleNotifications []models.RoleNotification, err error)
-	GetPostReportNotifications(userID int64) (postReportNotifications []models.PostReportNotification, err error)
-	CreatePostNotification(postNotification *models.PostNotification) (err error)
-	DeleteAllPostNotifications(userID int64) (err error)
-	GetPostNotifications(userID int64) (postNotifications []models.PostNotification, err error)
+	CreateRoleNotification(ctx context.Context, roleNotification *models.RoleNotification) (err error)
+	CreatePostReportNotification(ctx context.Context, postReportNotification *models.PostReportNotification) (err error)
+	DeleteAllRoleNotifications(ctx context.Context, userID int64) (err error)
+	DeleteAllPostReportNotifications(ctx context.Context, userID int64) (err error)
+	GetRoleNotifications(ctx context.Context, userID int64) (roleNotifications []models.RoleNotification, err error)
+	GetPostReportNotifications(ctx context.Context, userID int64) (postReportNotifications []models.PostReportNotification, err error)
+	CreatePostNotification(ctx context.Context, postNotification *models.PostNotification) (err error)
+	DeleteAllPostNotifications(ctx context.Context, userID int64) (err error)
+	GetPostNotifications(ctx context.Context, userID int64) (postNotifications []models.PostNotification, err error)
+	CreateAppealNotification(ctx context.Context, appealNotification *models.AppealNotification) (err error)
+	DeleteAllAppealNotifications(ctx context.Context, userID int64) (err error)
+	GetAppealNotifications(ctx context.Context, userID int64) (appealNotifications []models.AppealNotification, err error)
+	DeleteAllSecurityNotifications(ctx context.Context, userID int64) (err error)
+	GetSecurityNotifications(ctx context.Context, userID int64) (securityNotifications []models.SecurityNotification, err error)
+	GetMentionSettings(ctx context.Context, userID int64) (settings *models.MentionSettings, err error)
+	UpdateMentionSettings(ctx context.Context, userID int64, settings *models.MentionSettings) (err error)
+}
+
+type AppealUsecase interface {
+	CreateModerationAction(ctx context.Context, action *models.ModerationAction) (err error)
+	DeletePost(ctx context.Context, action *models.ModerationAction) (status int, err error)
+	GetModerationActionByID(ctx context.Context, actionID int64) (action *models.ModerationAction, err error)
+	GetModerationActionsByAuthorID(ctx context.Context, authorID int64) (actions []models.ModerationAction, err error)
+	CreateAppeal(ctx context.Context, appeal *models.Appeal) (err error)
+	GetAppealByID(ctx context.Context, appealID int64) (appeal *models.Appeal, err error)
+	GetAppealByActionID(ctx context.Context, actionID int64) (appeal *models.Appeal, err error)
+	GetAppealsByAuthorID(ctx context.Context, authorID int64) (appeals []models.Appeal, err error)
+	GetPendingAppeals(ctx context.Context) (appeals []models.Appeal, err error)
+	ReverseAppeal(ctx context.Context, appealID int64, adminID int64) (err error)
+	UpholdAppeal(ctx context.Context, appealID int64, adminID int64) (err error)
+}
+
+type LoginAttemptUsecase interface {
+	Check(ctx context.Context, username, ip string) (retryAfter time.Duration, err error)
+	Fail(ctx context.Context, username string, user *models.User, ip string) (err error)
+	Succeed(ctx context.Context, username string) (err error)
+}
+
+type TwoFactorUsecase interface {
+	Required(user *models.User) bool
+	IsEnabled(ctx context.Context, userID int64) (enabled bool, err error)
+	GetStatus(ctx context.Context, user *models.User) (status *models.TwoFactorStatus, err error)
+	Enroll(ctx context.Context, user *models.User) (enrollment *models.TwoFactorEnrollment, err error)
+	Confirm(ctx context.Context, userID int64, code string) (recoveryCodes []string, err error)
+	Verify(ctx context.Context, userID int64, code string) (ok bool, err error)
+	RegenerateRecoveryCodes(ctx context.Context, userID int64) (recoveryCodes []string, err error)
+	Disable(ctx context.Context, userID int64) (err error)
+}
+
+type OIDCUsecase interface {
+	Providers() []string
+	Begin(ctx context.Context, provider string, linkUserID int64) (authURL string, state string, status int, err error)
+	Complete(ctx context.Context, provider, state, code string) (user *models.User, linked bool, status int, err error)
+	StartChallenge(ctx context.Context, userID int64) (token string, err error)
+	TakeChallenge(ctx context.Context, token string) (userID int64, status int, err error)
 }
@@ -1,76 +1,167 @@
 package usecases
 
 import (
+	"context"
+	"database/sql"
+
+	"github.com/innovember/forum/api/db"
 	"github.com/innovember/forum/api/models"
 	"github.com/innovember/forum/api/user"
 )
 
 type AdminUsecase struct {
-	adminRepo user.AdminRepository
+	adminRepo            user.AdminRepository
+	userNotificationRepo user.UserNotificationRepository
+	moderatorRepo        user.ModeratorRepository
+	appealRepo           user.AppealRepository
+	uow                  db.UnitOfWork
 }
 
-func NewAdminUsecase(repo user.AdminRepository) user.AdminUsecase {
-	return &AdminUsecase{adminRepo: repo}
+func NewAdminUsecase(repo user.AdminRepository,
+	userNotificationRepo user.UserNotificationRepository,
+	moderatorRepo user.ModeratorRepository,
+	appealRepo user.AppealRepository,
+	uow db.UnitOfWork) user.AdminUsecase {
+	return &AdminUsecase{adminRepo: repo, userNotificationRepo: userNotificationRepo,
+		moderatorRepo: moderatorRepo, appealRepo: appealRepo, uow: uow}
 }
 
-func (au *AdminUsecase) UpgradeRole(requestID int64) (err error) {
-	if err = au.adminRepo.UpgradeRole(requestID); err != nil {
+func (au *AdminUsecase) UpgradeRole(ctx context.Context, requestID int64) (err error) {
+	if err = au.adminRepo.UpgradeRole(ctx, requestID); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (au *AdminUsecase) GetAllRoleRequests() (roleRequests []models.RoleRequest, err error) {
-	if roleRequests, err = au.adminRepo.GetAllRoleRequests(); err != nil {
+// AcceptRoleRequest promotes the requester, notifies them and removes the
+// request in one transaction.
+func (au *AdminUsecase) AcceptRoleRequest(ctx context.Context, requestID int64) (err error) {
+	return au.uow.Do(ctx, func(tx *sql.Tx) (err error) {
+		var (
+			userID int64
+		)
+		if err = au.adminRepo.UpgradeRoleTx(ctx, tx, requestID); err != nil {
+			return err
+		}
+		if userID, err = au.adminRepo.DeleteRoleRequestTx(ctx, tx, requestID); err != nil {
+			return err
+		}
+		roleNotification := models.RoleNotification{
+			ReceiverID: userID,
+			Accepted:   true,
+			Declined:   false,
+			Demoted:    false,
+		}
+		return au.userNotificationRepo.CreateRoleNotificationTx(ctx, tx, &roleNotification)
+	})
+}
+
+// DismissRoleRequest removes the request and notifies the requester in one
+// transaction.
+func (au *AdminUsecase) DismissRoleRequest(ctx context.Context, requestID int64) (err error) {
+	return au.uow.Do(ctx, func(tx *sql.Tx) (err error) {
+		var (
+			userID int64
+		)
+		if userID, err = au.adminRepo.DeleteRoleRequestTx(ctx, tx, requestID); err != nil {
+			return err
+		}
+		roleNotification := models.RoleNotification{
+			ReceiverID: userID,
+			Accepted:   false,
+			Declined:   true,
+			Demoted:    false,
+		}
+		return au.userNotificationRepo.CreateRoleNotificationTx(ctx, tx, &roleNotification)
+	})
+}
+
+func (au *AdminUsecase) GetAllRoleRequests(ctx context.Context) (roleRequests []models.RoleRequest, err error) {
+	if roleRequests, err = au.adminRepo.GetAllRoleRequests(ctx); err != nil {
 		return nil, err
 	}
 	return roleRequests, nil
 }
 
-func (au *AdminUsecase) DeleteRoleRequest(requestID int64) (err error) {
-	if err = au.adminRepo.DeleteRoleRequest(requestID); err != nil {
+func (au *AdminUsecase) DeleteRoleRequest(ctx context.Context, requestID int64) (err error) {
+	if err = au.adminRepo.DeleteRoleRequest(ctx, requestID); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (au *AdminUsecase) GetAllPostReports() (postReports []models.PostReport, err error) {
-	if postReports, err = au.adminRepo.GetAllPostReports(); err != nil {
+func (au *AdminUsecase) GetAllPostReports(ctx context.Context) (postReports []models.PostReport, err error) {
+	if postReports, err = au.adminRepo.GetAllPostReports(ctx); err != nil {
 		return nil, err
 	}
 	return postReports, nil
 }
 
-func (au *AdminUsecase) AcceptPostReport(postReportID int64) (err error) {
-	if err = au.adminRepo.AcceptPostReport(postReportID); err != nil {
-		return err
-	}
-	return nil
+// AcceptPostReport deletes the reported post, records the deletion so it can
+// be appealed, removes the report and notifies both the moderator who filed
+// it and the author of the post, in one transaction.
+func (au *AdminUsecase) AcceptPostReport(ctx context.Context, postReport *models.PostReport, action *models.ModerationAction) (err error) {
+	return au.uow.Do(ctx, func(tx *sql.Tx) (err error) {
+		if err = au.adminRepo.AcceptPostReportTx(ctx, tx, postReport.ID); err != nil {
+			return err
+		}
+		if err = au.appealRepo.CreateModerationActionTx(ctx, tx, action); err != nil {
+			return err
+		}
+		postReportNotification := models.PostReportNotification{
+			ReceiverID: postReport.ModeratorID,
+			Approved:   true,
+			Deleted:    false,
+		}
+		if err = au.userNotificationRepo.CreatePostReportNotificationTx(ctx, tx, &postReportNotification); err != nil {
+			return err
+		}
+		if err = au.moderatorRepo.DeletePostReportTx(ctx, tx, postReport.ID); err != nil {
+			return err
+		}
+		postNotification := models.PostNotification{
+			ReceiverID: action.AuthorID,
+			Approved:   false,
+			Banned:     false,
+			Deleted:    true,
+		}
+		return au.userNotificationRepo.CreatePostNotificationTx(ctx, tx, &postNotification)
+	})
 }
 
-func (au *AdminUsecase) DismissPostReport(postReportID int64) (err error) {
-	if err = au.adminRepo.DismissPostReport(postReportID); err != nil {
+func (au *AdminUsecase) DismissPostReport(ctx context.Context, postReportID int64) (err error) {
+	if err = au.adminRepo.DismissPostReport(ctx, postReportID); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (au *AdminUsecase) GetAllModerators() (moderators []models.User, err error) {
-	if moderators, err = au.adminRepo.GetAllModerators(); err != nil {
+func (au *AdminUsecase) GetAllModerators(ctx context.Context) (moderators []models.User, err error) {
+	if moderators, err = au.adminRepo.GetAllModerators(ctx); err != nil {
 		return nil, err
 	}
 	return moderators, nil
 }
 
-func (au *AdminUsecase) DemoteModerator(moderatorID int64) (err error) {
-	if err = au.adminRepo.DemoteModerator(moderatorID); err != nil {
-		return err
-	}
-	return nil
+// DemoteModerator drops the moderator role and notifies the user in one
+// transaction.
+func (au *AdminUsecase) DemoteModerator(ctx context.Context, moderatorID int64) (err error) {
+	return au.uow.Do(ctx, func(tx *sql.Tx) (err error) {
+		if err = au.adminRepo.DemoteModeratorTx(ctx, tx, moderatorID); err != nil {
+			return err
+		}
+		roleNotification := models.RoleNotification{
+			ReceiverID: moderatorID,
+			Accepted:   false,
+			Declined:   false,
+			Demoted:    true,
+		}
+		return au.userNotificationRepo.CreateRoleNotificationTx(ctx, tx, &roleNotification)
+	})
 }
 
-func (au *AdminUsecase) DeletePostReportByPostID(postID int64) (err error) {
-	if err = au.adminRepo.DeletePostReportByPostID(postID); err != nil {
+func (au *AdminUsecase) DeletePostReportByPostID(ctx context.Context, postID int64) (err error) {
+	if err = au.adminRepo.DeletePostReportByPostID(ctx, postID); err != nil {
 		return err
 	}
 	return nil
@@ -0,0 +1,191 @@
+package usecases
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/innovember/forum/api/db"
+	"github.com/innovember/forum/api/models"
+	"github.com/innovember/forum/api/post"
+	"github.com/innovember/forum/api/user"
+)
+
+type AppealUsecase struct {
+	appealRepo           user.AppealRepository
+	userNotificationRepo user.UserNotificationRepository
+	postRepo             post.PostRepository
+	uow                  db.UnitOfWork
+}
+
+func NewAppealUsecase(repo user.AppealRepository,
+	userNotificationRepo user.UserNotificationRepository,
+	postRepo post.PostRepository,
+	uow db.UnitOfWork) user.AppealUsecase {
+	return &AppealUsecase{appealRepo: repo, userNotificationRepo: userNotificationRepo, postRepo: postRepo, uow: uow}
+}
+
+func (au *AppealUsecase) CreateModerationAction(ctx context.Context, action *models.ModerationAction) (err error) {
+	if err = au.appealRepo.CreateModerationAction(ctx, action); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeletePost soft deletes the post of a moderation action, records the
+// action so it can be appealed and notifies the author, in one transaction.
+func (au *AppealUsecase) DeletePost(ctx context.Context, action *models.ModerationAction) (status int, err error) {
+	status = http.StatusInternalServerError
+	err = au.uow.Do(ctx, func(tx *sql.Tx) (err error) {
+		if status, err = au.postRepo.DeleteTx(ctx, tx, action.PostID); err != nil {
+			return err
+		}
+		status = http.StatusInternalServerError
+		if err = au.appealRepo.CreateModerationActionTx(ctx, tx, action); err != nil {
+			return err
+		}
+		postNotification := models.PostNotification{
+			ReceiverID: action.AuthorID,
+			Approved:   false,
+			Banned:     false,
+			Deleted:    true,
+		}
+		return au.userNotificationRepo.CreatePostNotificationTx(ctx, tx, &postNotification)
+	})
+	if err != nil {
+		return status, err
+	}
+	return http.StatusOK, nil
+}
+
+func (au *AppealUsecase) GetModerationActionByID(ctx context.Context, actionID int64) (action *models.ModerationAction, err error) {
+	if action, err = au.appealRepo.GetModerationActionByID(ctx, actionID); err != nil {
+		return nil, err
+	}
+	return action, nil
+}
+
+func (au *AppealUsecase) GetModerationActionsByAuthorID(ctx context.Context, authorID int64) (actions []models.ModerationAction, err error) {
+	if actions, err = au.appealRepo.GetModerationActionsByAuthorID(ctx, authorID); err != nil {
+		return nil, err
+	}
+	for i := range actions {
+		if actions[i].Appeal, err = au.appealRepo.GetAppealByActionID(ctx, actions[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return actions, nil
+}
+
+// CreateAppeal files the appeal and notifies its author in one transaction.
+func (au *AppealUsecase) CreateAppeal(ctx context.Context, appeal *models.Appeal) (err error) {
+	var (
+		action   *models.ModerationAction
+		existing *models.Appeal
+	)
+	appeal.Content = strings.TrimSpace(appeal.Content)
+	if appeal.Content == "" {
+		return errors.New("appeal text is required")
+	}
+	if action, err = au.appealRepo.GetModerationActionByID(ctx, appeal.ActionID); err != nil {
+		return err
+	}
+	if action.AuthorID != appeal.AuthorID {
+		return errors.New("can't appeal another user's post")
+	}
+	if existing, err = au.appealRepo.GetAppealByActionID(ctx, appeal.ActionID); err != nil {
+		return err
+	}
+	if existing != nil {
+		return errors.New("appeal for this action already exists")
+	}
+	if err = au.uow.Do(ctx, func(tx *sql.Tx) (err error) {
+		if err = au.appealRepo.CreateAppealTx(ctx, tx, appeal); err != nil {
+			return err
+		}
+		appealNotification := models.AppealNotification{
+			ReceiverID: appeal.AuthorID,
+			AppealID:   appeal.ID,
+			Submitted:  true,
+			Reversed:   false,
+			Upheld:     false,
+		}
+		return au.userNotificationRepo.CreateAppealNotificationTx(ctx, tx, &appealNotification)
+	}); err != nil {
+		return err
+	}
+	appeal.Action = action
+	return nil
+}
+
+func (au *AppealUsecase) GetAppealByID(ctx context.Context, appealID int64) (appeal *models.Appeal, err error) {
+	if appeal, err = au.appealRepo.GetAppealByID(ctx, appealID); err != nil {
+		return nil, err
+	}
+	return appeal, nil
+}
+
+func (au *AppealUsecase) GetAppealByActionID(ctx context.Context, actionID int64) (appeal *models.Appeal, err error) {
+	if appeal, err = au.appealRepo.GetAppealByActionID(ctx, actionID); err != nil {
+		return nil, err
+	}
+	return appeal, nil
+}
+
+func (au *AppealUsecase) GetAppealsByAuthorID(ctx context.Context, authorID int64) (appeals []models.Appeal, err error) {
+	if appeals, err = au.appealRepo.GetAppealsByAuthorID(ctx, authorID); err != nil {
+		return nil, err
+	}
+	return appeals, nil
+}
+
+func (au *AppealUsecase) GetPendingAppeals(ctx context.Context) (appeals []models.Appeal, err error) {
+	if appeals, err = au.appealRepo.GetPendingAppeals(ctx); err != nil {
+		return nil, err
+	}
+	return appeals, nil
+}
+
+// ReverseAppeal undoes the moderation action and notifies the author in one
+// transaction.
+func (au *AppealUsecase) ReverseAppeal(ctx context.Context, appealID int64, adminID int64) (err error) {
+	return au.uow.Do(ctx, func(tx *sql.Tx) (err error) {
+		var (
+			authorID int64
+		)
+		if authorID, err = au.appealRepo.ReverseAppealTx(ctx, tx, appealID, adminID); err != nil {
+			return err
+		}
+		appealNotification := models.AppealNotification{
+			ReceiverID: authorID,
+			AppealID:   appealID,
+			Submitted:  false,
+			Reversed:   true,
+			Upheld:     false,
+		}
+		return au.userNotificationRepo.CreateAppealNotificationTx(ctx, tx, &appealNotification)
+	})
+}
+
+// UpholdAppeal rejects the appeal and notifies the author in one
+// transaction.
+func (au *AppealUsecase) UpholdAppeal(ctx context.Context, appealID int64, adminID int64) (err error) {
+	return au.uow.Do(ctx, func(tx *sql.Tx) (err error) {
+		var (
+			authorID int64
+		)
+		if authorID, err = au.appealRepo.UpholdAppealTx(ctx, tx, appealID, adminID); err != nil {
+			return err
+		}
+		appealNotification := models.AppealNotification{
+			ReceiverID: authorID,
+			AppealID:   appealID,
+			Submitted:  false,
+			Reversed:   false,
+			Upheld:     true,
+		}
+		return au.userNotificationRepo.CreateAppealNotificationTx(ctx, tx, &appealNotification)
+	})
+}
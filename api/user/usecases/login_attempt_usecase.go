@@ -0,0 +1,133 @@
+package usecases
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/db"
+	"github.com/innovember/forum/api/models"
+	"github.com/innovember/forum/api/user"
+)
+
+type LoginAttemptUsecase struct {
+	loginAttemptRepo     user.LoginAttemptRepository
+	userNotificationRepo user.UserNotificationRepository
+	cfg                  config.LoginConfig
+	uow                  db.UnitOfWork
+}
+
+func NewLoginAttemptUsecase(repo user.LoginAttemptRepository,
+	userNotificationRepo user.UserNotificationRepository,
+	cfg config.LoginConfig, uow db.UnitOfWork) user.LoginAttemptUsecase {
+	return &LoginAttemptUsecase{
+		loginAttemptRepo:     repo,
+		userNotificationRepo: userNotificationRepo,
+		cfg:                  cfg,
+		uow:                  uow,
+	}
+}
+
+// Accounts are keyed by the username as typed, whether it exists or not, so
+// unknown and existing accounts back off alike.
+func accountKey(username string) string {
+	return "account:" + strings.ToLower(username)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check returns the time left while the account or the IP is blocked after
+// failed sign-ins, or zero.
+func (lu *LoginAttemptUsecase) Check(ctx context.Context, username, ip string) (retryAfter time.Duration, err error) {
+	var (
+		attempt      *models.LoginAttempt
+		blockedUntil int64
+		now          = time.Now().Unix()
+	)
+	for _, key := range []string{accountKey(username), ipKey(ip)} {
+		if attempt, err = lu.loginAttemptRepo.GetAttempt(ctx, key); err != nil {
+			return 0, err
+		}
+		if attempt.BlockedUntil > blockedUntil {
+			blockedUntil = attempt.BlockedUntil
+		}
+	}
+	if blockedUntil > now {
+		return time.Duration(blockedUntil-now) * time.Second, nil
+	}
+	return 0, nil
+}
+
+// Fail records a failed sign-in for the account and the IP. user is nil when
+// no account has that username. The account is locked once it reaches
+// LockoutAttempts failures, and its owner notified.
+func (lu *LoginAttemptUsecase) Fail(ctx context.Context, username string, user *models.User, ip string) (err error) {
+	return lu.uow.Do(ctx, func(tx *sql.Tx) (err error) {
+		var (
+			account *models.LoginAttempt
+			now     = time.Now()
+		)
+		if account, err = lu.recordFailureTx(ctx, tx, accountKey(username), lu.cfg.AccountFreeAttempts, now); err != nil {
+			return err
+		}
+		if account.Failures >= lu.cfg.LockoutAttempts {
+			account.BlockedUntil = now.Add(lu.cfg.LockoutDuration).Unix()
+			if err = lu.loginAttemptRepo.BlockTx(ctx, tx, account.Key, account.BlockedUntil); err != nil {
+				return err
+			}
+			if account.Failures == lu.cfg.LockoutAttempts && user != nil {
+				if err = lu.userNotificationRepo.CreateSecurityNotificationTx(ctx, tx, &models.SecurityNotification{
+					ReceiverID:  user.ID,
+					LockedUntil: account.BlockedUntil,
+				}); err != nil {
+					return err
+				}
+			}
+		}
+		if _, err = lu.recordFailureTx(ctx, tx, ipKey(ip), lu.cfg.IPFreeAttempts, now); err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// Succeed clears the failures of the account. Those of the IP are kept, so
+// one valid account does not reset a spraying client.
+func (lu *LoginAttemptUsecase) Succeed(ctx context.Context, username string) (err error) {
+	if err = lu.loginAttemptRepo.DeleteAttempt(ctx, accountKey(username)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// recordFailureTx counts a failure for key and blocks it for the backoff
+// once it is past its free attempts.
+func (lu *LoginAttemptUsecase) recordFailureTx(ctx context.Context, tx *sql.Tx, key string, freeAttempts int, now time.Time) (attempt *models.LoginAttempt, err error) {
+	if attempt, err = lu.loginAttemptRepo.AddFailureTx(ctx, tx, key, now.Unix(), now.Add(-lu.cfg.ResetAfter).Unix()); err != nil {
+		return nil, err
+	}
+	if attempt.Failures > freeAttempts {
+		attempt.BlockedUntil = now.Add(lu.backoff(attempt.Failures - freeAttempts)).Unix()
+		if err = lu.loginAttemptRepo.BlockTx(ctx, tx, key, attempt.BlockedUntil); err != nil {
+			return nil, err
+		}
+	}
+	return attempt, nil
+}
+
+// backoff doubles BaseDelay for every failure past the free ones, up to
+// MaxDelay.
+func (lu *LoginAttemptUsecase) backoff(failures int) time.Duration {
+	delay := lu.cfg.BaseDelay
+	for i := 1; i < failures && delay < lu.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > lu.cfg.MaxDelay {
+		delay = lu.cfg.MaxDelay
+	}
+	return delay
+}
@@ -0,0 +1,171 @@
+package usecases
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/db"
+	"github.com/innovember/forum/api/models"
+	"github.com/innovember/forum/api/user/repository"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// testDB opens an in-memory database with the schema and migrations. One
+// connection keeps every query on the same database.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	conn, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetMaxOpenConns(1)
+	t.Cleanup(func() { conn.Close() })
+	if err = db.CheckDB(conn, "../../db/schema.sql"); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+var testLoginConfig = config.LoginConfig{
+	AccountFreeAttempts: 3,
+	IPFreeAttempts:      5,
+	BaseDelay:           time.Second,
+	MaxDelay:            time.Minute,
+	LockoutAttempts:     6,
+	LockoutDuration:     time.Hour,
+	ResetAfter:          time.Hour,
+}
+
+func newTestLoginAttemptUsecase(conn *sql.DB) *LoginAttemptUsecase {
+	return NewLoginAttemptUsecase(repository.NewLoginAttemptDBRepository(conn),
+		repository.NewUserNotificationDBRepository(conn), testLoginConfig,
+		db.NewUnitOfWork(conn)).(*LoginAttemptUsecase)
+}
+
+func TestLoginBackoff(t *testing.T) {
+	lu := &LoginAttemptUsecase{cfg: testLoginConfig}
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{6, 32 * time.Second},
+		{7, time.Minute},
+		{50, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := lu.backoff(tt.failures); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLoginAttemptFail(t *testing.T) {
+	tests := []struct {
+		name          string
+		failures      int
+		known         bool
+		wantBlocked   time.Duration // at least, zero when not blocked
+		notifications int
+	}{
+		{"free attempts", 3, true, 0, 0},
+		{"first backoff", 4, true, time.Second, 0},
+		{"doubled backoff", 5, true, 2 * time.Second, 0},
+		{"lockout", 6, true, time.Hour - time.Minute, 1},
+		{"notified once", 9, true, time.Hour - time.Minute, 1},
+		{"unknown account", 6, false, time.Hour - time.Minute, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := testDB(t)
+			lu := newTestLoginAttemptUsecase(conn)
+			ctx := context.Background()
+			var user *models.User
+			if tt.known {
+				user = &models.User{ID: 5}
+			}
+			for i := 0; i < tt.failures; i++ {
+				// the IP of every attempt differs, so only the account blocks
+				if err := lu.Fail(ctx, "Alice", user, fmt.Sprintf("10.0.0.%d", i+1)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			retryAfter, err := lu.Check(ctx, "alice", "10.0.1.1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantBlocked == 0 && retryAfter != 0 || retryAfter < tt.wantBlocked-time.Second {
+				t.Errorf("retry after = %v, want %v", retryAfter, tt.wantBlocked)
+			}
+			var notifications int
+			conn.QueryRow(`SELECT COUNT(*) FROM notifications_security WHERE receiver_id = 5`).Scan(&notifications)
+			if notifications != tt.notifications {
+				t.Errorf("notifications = %d, want %d", notifications, tt.notifications)
+			}
+		})
+	}
+}
+
+func TestLoginAttemptIPBackoff(t *testing.T) {
+	conn := testDB(t)
+	lu := newTestLoginAttemptUsecase(conn)
+	ctx := context.Background()
+	for i := 0; i < testLoginConfig.IPFreeAttempts+1; i++ {
+		// spraying: one attempt per account
+		if err := lu.Fail(ctx, fmt.Sprintf("user%d", i), nil, "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		username, ip string
+		blocked      bool
+	}{
+		{"someone", "10.0.0.1", true},
+		{"user0", "10.0.0.2", false},
+	}
+	for _, tt := range tests {
+		retryAfter, err := lu.Check(ctx, tt.username, tt.ip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (retryAfter > 0) != tt.blocked {
+			t.Errorf("Check(%s, %s) = %v, want blocked %v", tt.username, tt.ip, retryAfter, tt.blocked)
+		}
+	}
+}
+
+func TestLoginAttemptSucceedAndReset(t *testing.T) {
+	conn := testDB(t)
+	lu := newTestLoginAttemptUsecase(conn)
+	ctx := context.Background()
+	for i := 0; i < testLoginConfig.AccountFreeAttempts+1; i++ {
+		if err := lu.Fail(ctx, "alice", nil, "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := lu.Succeed(ctx, "ALICE"); err != nil {
+		t.Fatal(err)
+	}
+	var accountFailures, ipFailures int
+	conn.QueryRow(`SELECT COUNT(*) FROM login_attempts WHERE key = 'account:alice'`).Scan(&accountFailures)
+	conn.QueryRow(`SELECT failures FROM login_attempts WHERE key = 'ip:10.0.0.1'`).Scan(&ipFailures)
+	if accountFailures != 0 || ipFailures != 4 {
+		t.Fatalf("account rows = %d, IP failures = %d, want 0 and 4", accountFailures, ipFailures)
+	}
+
+	// failures older than ResetAfter start the count over
+	conn.Exec(`UPDATE login_attempts SET last_failure_at = last_failure_at - 7200`)
+	if err := lu.Fail(ctx, "alice", nil, "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	conn.QueryRow(`SELECT failures FROM login_attempts WHERE key = 'ip:10.0.0.1'`).Scan(&ipFailures)
+	if ipFailures != 1 {
+		t.Fatalf("IP failures = %d after ResetAfter, want 1", ipFailures)
+	}
+}
@@ -1,61 +1,85 @@
 package usecases
 
 import (
+	"context"
+	"database/sql"
+
+	"github.com/innovember/forum/api/db"
 	"github.com/innovember/forum/api/models"
 	"github.com/innovember/forum/api/user"
 )
 
 type ModeratorUsecase struct {
-	moderatorRepo user.ModeratorRepository
+	moderatorRepo        user.ModeratorRepository
+	appealRepo           user.AppealRepository
+	userNotificationRepo user.UserNotificationRepository
+	uow                  db.UnitOfWork
 }
 
-func NewModeratorUsecase(repo user.ModeratorRepository) user.ModeratorUsecase {
-	return &ModeratorUsecase{moderatorRepo: repo}
+func NewModeratorUsecase(repo user.ModeratorRepository,
+	appealRepo user.AppealRepository,
+	userNotificationRepo user.UserNotificationRepository,
+	uow db.UnitOfWork) user.ModeratorUsecase {
+	return &ModeratorUsecase{moderatorRepo: repo, appealRepo: appealRepo,
+		userNotificationRepo: userNotificationRepo, uow: uow}
 }
 
-func (mu *ModeratorUsecase) CreatePostReport(postReport *models.PostReport) (err error) {
-	if err = mu.moderatorRepo.CreatePostReport(postReport); err != nil {
+func (mu *ModeratorUsecase) CreatePostReport(ctx context.Context, postReport *models.PostReport) (err error) {
+	if err = mu.moderatorRepo.CreatePostReport(ctx, postReport); err != nil {
 		return err
 	}
 	return nil
 }
-func (mu *ModeratorUsecase) DeletePostReport(postReportID int64) (err error) {
-	if err = mu.moderatorRepo.DeletePostReport(postReportID); err != nil {
+func (mu *ModeratorUsecase) DeletePostReport(ctx context.Context, postReportID int64) (err error) {
+	if err = mu.moderatorRepo.DeletePostReport(ctx, postReportID); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (mu *ModeratorUsecase) GetMyReports(moderatorID int64) (postReports []models.PostReport, err error) {
-	if postReports, err = mu.moderatorRepo.GetMyReports(moderatorID); err != nil {
+func (mu *ModeratorUsecase) GetMyReports(ctx context.Context, moderatorID int64) (postReports []models.PostReport, err error) {
+	if postReports, err = mu.moderatorRepo.GetMyReports(ctx, moderatorID); err != nil {
 		return nil, err
 	}
 	return postReports, nil
 }
 
-func (mu *ModeratorUsecase) ApprovePost(postID int64) (err error) {
-	if err = mu.moderatorRepo.ApprovePost(postID); err != nil {
+func (mu *ModeratorUsecase) ApprovePost(ctx context.Context, postID int64) (err error) {
+	if err = mu.moderatorRepo.ApprovePost(ctx, postID); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (mu *ModeratorUsecase) GetAllUnapprovedPosts() (posts []models.Post, err error) {
-	if posts, err = mu.moderatorRepo.GetAllUnapprovedPosts(); err != nil {
+func (mu *ModeratorUsecase) GetAllUnapprovedPosts(ctx context.Context) (posts []models.Post, err error) {
+	if posts, err = mu.moderatorRepo.GetAllUnapprovedPosts(ctx); err != nil {
 		return nil, err
 	}
 	return posts, nil
 }
 
-func (mu *ModeratorUsecase) BanPost(postID int64, bans []string) (err error) {
-	if err = mu.moderatorRepo.BanPost(postID, bans); err != nil {
-		return err
-	}
-	return nil
+// BanPost bans the post of a moderation action, records the action so it
+// can be appealed and notifies the author, in one transaction.
+func (mu *ModeratorUsecase) BanPost(ctx context.Context, action *models.ModerationAction, bans []string) (err error) {
+	return mu.uow.Do(ctx, func(tx *sql.Tx) (err error) {
+		if err = mu.moderatorRepo.BanPostTx(ctx, tx, action.PostID, bans); err != nil {
+			return err
+		}
+		if err = mu.appealRepo.CreateModerationActionTx(ctx, tx, action); err != nil {
+			return err
+		}
+		postNotification := models.PostNotification{
+			ReceiverID: action.AuthorID,
+			Approved:   false,
+			Banned:     true,
+			Deleted:    false,
+		}
+		return mu.userNotificationRepo.CreatePostNotificationTx(ctx, tx, &postNotification)
+	})
 }
 
-func (mu *ModeratorUsecase) GetPostReportByID(postReportID int64) (postReport *models.PostReport, err error) {
-	if postReport, err = mu.moderatorRepo.GetPostReportByID(postReportID); err != nil {
+func (mu *ModeratorUsecase) GetPostReportByID(ctx context.Context, postReportID int64) (postReport *models.PostReport, err error) {
+	if postReport, err = mu.moderatorRepo.GetPostReportByID(ctx, postReportID); err != nil {
 		return nil, err
 	}
 	return postReport, nil
@@ -0,0 +1,250 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/models"
+	"github.com/innovember/forum/api/security"
+	"github.com/innovember/forum/api/services/oidc"
+	"github.com/innovember/forum/api/user"
+)
+
+const (
+	// A sign-in has this long to come back from the provider
+	oidcStateTTL = 10 * time.Minute
+	// and then this long for the two-factor code, when the user has 2FA
+	oidcChallengeTTL = 5 * time.Minute
+)
+
+type OIDCUsecase struct {
+	userRepo      user.UserRepository
+	identityRepo  user.IdentityRepository
+	twoFactorRepo user.TwoFactorRepository
+	providers     map[string]*oidc.Provider
+}
+
+func NewOIDCUsecase(userRepo user.UserRepository, identityRepo user.IdentityRepository,
+	twoFactorRepo user.TwoFactorRepository, providers map[string]*oidc.Provider) user.OIDCUsecase {
+	return &OIDCUsecase{
+		userRepo:      userRepo,
+		identityRepo:  identityRepo,
+		twoFactorRepo: twoFactorRepo,
+		providers:     providers,
+	}
+}
+
+func (ou *OIDCUsecase) Providers() []string {
+	return oidc.Names(ou.providers)
+}
+
+// Begin starts a sign-in at provider, or with linkUserID the linking of the
+// provider account to that signed-in user, and returns the URL to send the
+// browser to and the state the callback must carry.
+func (ou *OIDCUsecase) Begin(ctx context.Context, provider string, linkUserID int64) (authURL string, state string, status int, err error) {
+	var (
+		p        *oidc.Provider
+		ok       bool
+		nonce    string
+		verifier string
+	)
+	if p, ok = ou.providers[provider]; !ok {
+		return "", "", http.StatusNotFound, errors.New("unknown sign-in provider")
+	}
+	if state, err = oidc.RandomToken(); err != nil {
+		return "", "", http.StatusInternalServerError, err
+	}
+	if nonce, err = oidc.RandomToken(); err != nil {
+		return "", "", http.StatusInternalServerError, err
+	}
+	if verifier, err = oidc.RandomToken(); err != nil {
+		return "", "", http.StatusInternalServerError, err
+	}
+	if authURL, err = p.AuthCodeURL(ctx, state, nonce, verifier); err != nil {
+		return "", "", http.StatusBadGateway, err
+	}
+	if err = ou.identityRepo.SaveState(ctx, &models.OIDCState{
+		State:      state,
+		Provider:   provider,
+		Nonce:      nonce,
+		Verifier:   verifier,
+		LinkUserID: linkUserID,
+		ExpiresAt:  time.Now().Add(oidcStateTTL).Unix(),
+	}); err != nil {
+		return "", "", http.StatusInternalServerError, err
+	}
+	return authURL, state, http.StatusOK, nil
+}
+
+// Complete redeems the code of a callback. A link started by Begin links
+// the provider account to its user, and linked is true. Otherwise it
+// returns the user the callback signs in: the one linked to the subject,
+// or the one with the verified email, or a new account if the provider
+// allows. Staff and users with 2FA are never linked by email; they sign in
+// with their password and link the provider themselves.
+func (ou *OIDCUsecase) Complete(ctx context.Context, provider, state, code string) (user *models.User, linked bool, status int, err error) {
+	var (
+		p         *oidc.Provider
+		ok        bool
+		oidcState *models.OIDCState
+		claims    *oidc.Claims
+		userID    int64
+		twoFactor *models.TwoFactor
+	)
+	if p, ok = ou.providers[provider]; !ok {
+		return nil, false, http.StatusNotFound, errors.New("unknown sign-in provider")
+	}
+	if oidcState, err = ou.identityRepo.TakeState(ctx, state); err != nil {
+		return nil, false, http.StatusInternalServerError, err
+	}
+	if oidcState == nil || oidcState.Provider != provider || oidcState.ExpiresAt < time.Now().Unix() {
+		return nil, false, http.StatusBadRequest, errors.New("sign-in expired or invalid, start again")
+	}
+	if claims, err = p.Exchange(ctx, code, oidcState.Verifier, oidcState.Nonce); err != nil {
+		return nil, false, http.StatusUnauthorized, err
+	}
+	if userID, err = ou.identityRepo.GetUserIDByIdentity(ctx, provider, claims.Subject); err != nil {
+		return nil, false, http.StatusInternalServerError, err
+	}
+	if oidcState.LinkUserID != 0 {
+		if userID != 0 && userID != oidcState.LinkUserID {
+			return nil, false, http.StatusConflict, errors.New("this provider account is linked to another user")
+		}
+		if userID == 0 {
+			if err = ou.identityRepo.CreateIdentity(ctx, &models.Identity{
+				Provider: provider,
+				Subject:  claims.Subject,
+				UserID:   oidcState.LinkUserID,
+				Email:    claims.Email,
+			}); err != nil {
+				return nil, false, http.StatusInternalServerError, err
+			}
+		}
+		if user, err = ou.userRepo.GetUserByID(ctx, oidcState.LinkUserID); err != nil {
+			return nil, false, http.StatusInternalServerError, err
+		}
+		return user, true, http.StatusOK, nil
+	}
+	if userID != 0 {
+		if user, err = ou.userRepo.GetUserByID(ctx, userID); err != nil {
+			return nil, false, http.StatusInternalServerError, err
+		}
+		return user, false, http.StatusOK, nil
+	}
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, false, http.StatusForbidden, errors.New("the provider did not return a verified email")
+	}
+	if user, status, err = ou.userRepo.FindUserByEmail(ctx, claims.Email); err != nil {
+		if status != http.StatusNotFound {
+			return nil, false, status, err
+		}
+		if !p.AutoCreate() {
+			return nil, false, http.StatusForbidden, errors.New("no account uses this email")
+		}
+		if user, err = ou.createUser(ctx, claims); err != nil {
+			return nil, false, http.StatusInternalServerError, err
+		}
+	} else {
+		if twoFactor, err = ou.twoFactorRepo.GetTwoFactor(ctx, user.ID); err != nil {
+			return nil, false, http.StatusInternalServerError, err
+		}
+		if user.Role >= config.RoleModerator || twoFactor.Enabled {
+			return nil, false, http.StatusForbidden, errors.New("sign in with your password and link this provider from your account")
+		}
+	}
+	if err = ou.identityRepo.CreateIdentity(ctx, &models.Identity{
+		Provider: provider,
+		Subject:  claims.Subject,
+		UserID:   user.ID,
+		Email:    claims.Email,
+	}); err != nil {
+		return nil, false, http.StatusInternalServerError, err
+	}
+	return user, false, http.StatusOK, nil
+}
+
+// StartChallenge holds the provider sign-in of a user with 2FA until the
+// code is given, and returns the token that stands for it.
+func (ou *OIDCUsecase) StartChallenge(ctx context.Context, userID int64) (token string, err error) {
+	if token, err = oidc.RandomToken(); err != nil {
+		return "", err
+	}
+	if err = ou.identityRepo.SaveChallenge(ctx, &models.OIDCChallenge{
+		Token:     token,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(oidcChallengeTTL).Unix(),
+	}); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// TakeChallenge returns the user of a challenge, which can be taken once.
+func (ou *OIDCUsecase) TakeChallenge(ctx context.Context, token string) (userID int64, status int, err error) {
+	var challenge *models.OIDCChallenge
+	if challenge, err = ou.identityRepo.TakeChallenge(ctx, token); err != nil {
+		return 0, http.StatusInternalServerError, err
+	}
+	if challenge == nil || challenge.ExpiresAt < time.Now().Unix() {
+		return 0, http.StatusUnauthorized, errors.New("sign-in expired or invalid, start again")
+	}
+	return challenge.UserID, http.StatusOK, nil
+}
+
+var usernameUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// createUser makes an account for claims, with a password nobody knows:
+// the user signs in through the provider only.
+func (ou *OIDCUsecase) createUser(ctx context.Context, claims *oidc.Claims) (*models.User, error) {
+	var (
+		password string
+		base     string
+		err      error
+		status   int
+	)
+	if password, err = oidc.RandomToken(); err != nil {
+		return nil, err
+	}
+	if password, err = security.Hash(password); err != nil {
+		return nil, err
+	}
+	base = claims.PreferredUsername
+	if base == "" {
+		base = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	if base = usernameUnsafe.ReplaceAllString(base, ""); base == "" {
+		base = "user"
+	}
+	for i := 0; i < 100; i++ {
+		username := base
+		if i > 0 {
+			username += strconv.Itoa(i)
+		}
+		if _, status, err = ou.userRepo.FindUserByUsername(ctx, username); status != http.StatusNotFound {
+			if err != nil && status != http.StatusOK {
+				return nil, err
+			}
+			continue
+		}
+		newUser := &models.User{
+			Username: username,
+			Password: password,
+			Email:    claims.Email,
+			Role:     config.RoleUser,
+		}
+		if _, err = ou.userRepo.Create(ctx, newUser); err != nil {
+			return nil, err
+		}
+		if newUser, _, err = ou.userRepo.FindUserByUsername(ctx, username); err != nil {
+			return nil, err
+		}
+		return newUser, nil
+	}
+	return nil, errors.New("no free username for " + base)
+}
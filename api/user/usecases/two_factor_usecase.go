@@ -0,0 +1,147 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/innovember/forum/api/config"
+	"github.com/innovember/forum/api/models"
+	"github.com/innovember/forum/api/security"
+	"github.com/innovember/forum/api/user"
+)
+
+type TwoFactorUsecase struct {
+	twoFactorRepo user.TwoFactorRepository
+	cfg           config.TwoFactorConfig
+}
+
+func NewTwoFactorUsecase(repo user.TwoFactorRepository, cfg config.TwoFactorConfig) user.TwoFactorUsecase {
+	return &TwoFactorUsecase{
+		twoFactorRepo: repo,
+		cfg:           cfg,
+	}
+}
+
+// Required reports whether the policy makes 2FA mandatory for the user.
+func (tu *TwoFactorUsecase) Required(user *models.User) bool {
+	return tu.cfg.RequireForStaff && user.Role >= config.RoleModerator
+}
+
+func (tu *TwoFactorUsecase) IsEnabled(ctx context.Context, userID int64) (enabled bool, err error) {
+	var twoFactor *models.TwoFactor
+	if twoFactor, err = tu.twoFactorRepo.GetTwoFactor(ctx, userID); err != nil {
+		return false, err
+	}
+	return twoFactor.Enabled, nil
+}
+
+func (tu *TwoFactorUsecase) GetStatus(ctx context.Context, user *models.User) (status *models.TwoFactorStatus, err error) {
+	status = &models.TwoFactorStatus{Required: tu.Required(user)}
+	if status.Enabled, err = tu.IsEnabled(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if status.Enabled {
+		if status.RecoveryCodesLeft, err = tu.twoFactorRepo.CountRecoveryCodes(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// Enroll issues a new secret. It stays pending, and sign-in unaffected,
+// until Confirm is called with a code generated from it.
+func (tu *TwoFactorUsecase) Enroll(ctx context.Context, user *models.User) (enrollment *models.TwoFactorEnrollment, err error) {
+	var (
+		enabled bool
+		secret  string
+	)
+	if enabled, err = tu.IsEnabled(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+	if secret, err = security.GenerateTOTPSecret(); err != nil {
+		return nil, err
+	}
+	if err = tu.twoFactorRepo.SaveSecret(ctx, user.ID, secret); err != nil {
+		return nil, err
+	}
+	return &models.TwoFactorEnrollment{
+		Secret: secret,
+		URI:    security.TOTPURI(tu.cfg.Issuer, user.Username, secret),
+	}, nil
+}
+
+// Confirm enables a pending enrollment once code matches its secret, and
+// returns the recovery codes, shown to the user this one time only. It
+// returns no codes and no error when code is wrong.
+func (tu *TwoFactorUsecase) Confirm(ctx context.Context, userID int64, code string) (recoveryCodes []string, err error) {
+	var (
+		twoFactor *models.TwoFactor
+		step      int64
+		ok        bool
+	)
+	if twoFactor, err = tu.twoFactorRepo.GetTwoFactor(ctx, userID); err != nil {
+		return nil, err
+	}
+	if twoFactor.Secret == "" || twoFactor.Enabled {
+		return nil, errors.New("no pending two-factor enrollment")
+	}
+	if step, ok = security.VerifyTOTP(twoFactor.Secret, code, time.Now(), tu.cfg.Skew); !ok {
+		return nil, nil
+	}
+	if recoveryCodes, err = security.GenerateRecoveryCodes(tu.cfg.RecoveryCodes); err != nil {
+		return nil, err
+	}
+	if err = tu.twoFactorRepo.Enable(ctx, userID, step, hashRecoveryCodes(recoveryCodes)); err != nil {
+		return nil, err
+	}
+	return recoveryCodes, nil
+}
+
+// Verify accepts a TOTP code not used before, or an unused recovery code,
+// which is then spent.
+func (tu *TwoFactorUsecase) Verify(ctx context.Context, userID int64, code string) (ok bool, err error) {
+	var (
+		twoFactor *models.TwoFactor
+		step      int64
+	)
+	if twoFactor, err = tu.twoFactorRepo.GetTwoFactor(ctx, userID); err != nil {
+		return false, err
+	}
+	if !twoFactor.Enabled {
+		return false, nil
+	}
+	if step, ok = security.VerifyTOTP(twoFactor.Secret, code, time.Now(), tu.cfg.Skew); ok {
+		return tu.twoFactorRepo.UseStep(ctx, userID, step)
+	}
+	return tu.twoFactorRepo.UseRecoveryCode(ctx, userID, security.HashRecoveryCode(code))
+}
+
+// RegenerateRecoveryCodes replaces every recovery code, used or not.
+func (tu *TwoFactorUsecase) RegenerateRecoveryCodes(ctx context.Context, userID int64) (recoveryCodes []string, err error) {
+	if recoveryCodes, err = security.GenerateRecoveryCodes(tu.cfg.RecoveryCodes); err != nil {
+		return nil, err
+	}
+	if err = tu.twoFactorRepo.ReplaceRecoveryCodes(ctx, userID, hashRecoveryCodes(recoveryCodes)); err != nil {
+		return nil, err
+	}
+	return recoveryCodes, nil
+}
+
+func (tu *TwoFactorUsecase) Disable(ctx context.Context, userID int64) (err error) {
+	if err = tu.twoFactorRepo.Delete(ctx, userID); err != nil {
+		return err
+	}
+	return nil
+}
+
+func hashRecoveryCodes(codes []string) []string {
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = security.HashRecoveryCode(code)
+	}
+	return hashes
+}
@@ -1,6 +1,7 @@
 package usecases
 
 import (
+	"context"
 	"github.com/innovember/forum/api/models"
 	"github.com/innovember/forum/api/user"
 )
@@ -13,64 +14,113 @@ func NewUserNotificationUsecase(repo user.UserNotificationRepository) user.UserN
 	return &UserNotificationUsecase{userNotificationRepo: repo}
 }
 
-func (uu *UserNotificationUsecase) CreateRoleNotification(roleNotification *models.RoleNotification) (err error) {
-	if err = uu.userNotificationRepo.CreateRoleNotification(roleNotification); err != nil {
+func (uu *UserNotificationUsecase) CreateRoleNotification(ctx context.Context, roleNotification *models.RoleNotification) (err error) {
+	if err = uu.userNotificationRepo.CreateRoleNotification(ctx, roleNotification); err != nil {
 		return err
 	}
 	return nil
 }
-func (uu *UserNotificationUsecase) CreatePostReportNotification(postReportNotification *models.PostReportNotification) (err error) {
-	if err = uu.userNotificationRepo.CreatePostReportNotification(postReportNotification); err != nil {
+func (uu *UserNotificationUsecase) CreatePostReportNotification(ctx context.Context, postReportNotification *models.PostReportNotification) (err error) {
+	if err = uu.userNotificationRepo.CreatePostReportNotification(ctx, postReportNotification); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (uu *UserNotificationUsecase) DeleteAllRoleNotifications(userID int64) (err error) {
-	if err = uu.userNotificationRepo.DeleteAllRoleNotifications(userID); err != nil {
+func (uu *UserNotificationUsecase) DeleteAllRoleNotifications(ctx context.Context, userID int64) (err error) {
+	if err = uu.userNotificationRepo.DeleteAllRoleNotifications(ctx, userID); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (uu *UserNotificationUsecase) DeleteAllPostReportNotifications(userID int64) (err error) {
-	if err = uu.userNotificationRepo.DeleteAllPostReportNotifications(userID); err != nil {
+func (uu *UserNotificationUsecase) DeleteAllPostReportNotifications(ctx context.Context, userID int64) (err error) {
+	if err = uu.userNotificationRepo.DeleteAllPostReportNotifications(ctx, userID); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (uu *UserNotificationUsecase) GetRoleNotifications(userID int64) (roleNotifications []models.RoleNotification, err error) {
-	if roleNotifications, err = uu.userNotificationRepo.GetRoleNotifications(userID); err != nil {
+func (uu *UserNotificationUsecase) GetRoleNotifications(ctx context.Context, userID int64) (roleNotifications []models.RoleNotification, err error) {
+	if roleNotifications, err = uu.userNotificationRepo.GetRoleNotifications(ctx, userID); err != nil {
 		return nil, err
 	}
 	return roleNotifications, nil
 }
 
-func (uu *UserNotificationUsecase) GetPostReportNotifications(userID int64) (postReportNotifications []models.PostReportNotification, err error) {
-	if postReportNotifications, err = uu.userNotificationRepo.GetPostReportNotifications(userID); err != nil {
+func (uu *UserNotificationUsecase) GetPostReportNotifications(ctx context.Context, userID int64) (postReportNotifications []models.PostReportNotification, err error) {
+	if postReportNotifications, err = uu.userNotificationRepo.GetPostReportNotifications(ctx, userID); err != nil {
 		return nil, err
 	}
 	return postReportNotifications, nil
 }
 
-func (uu *UserNotificationUsecase) CreatePostNotification(postNotification *models.PostNotification) (err error) {
-	if err = uu.userNotificationRepo.CreatePostNotification(postNotification); err != nil {
+func (uu *UserNotificationUsecase) CreatePostNotification(ctx context.Context, postNotification *models.PostNotification) (err error) {
+	if err = uu.userNotificationRepo.CreatePostNotification(ctx, postNotification); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (uu *UserNotificationUsecase) DeleteAllPostNotifications(userID int64) (err error) {
-	if err = uu.userNotificationRepo.DeleteAllPostNotifications(userID); err != nil {
+func (uu *UserNotificationUsecase) DeleteAllPostNotifications(ctx context.Context, userID int64) (err error) {
+	if err = uu.userNotificationRepo.DeleteAllPostNotifications(ctx, userID); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (uu *UserNotificationUsecase) GetPostNotifications(userID int64) (postNotifications []models.PostNotification, err error) {
-	if postNotifications, err = uu.userNotificationRepo.GetPostNotifications(userID); err != nil {
+func (uu *UserNotificationUsecase) GetPostNotifications(ctx context.Context, userID int64) (postNotifications []models.PostNotification, err error) {
+	if postNotifications, err = uu.userNotificationRepo.GetPostNotifications(ctx, userID); err != nil {
 		return nil, err
 	}
 	return postNotifications, nil
 }
+
+func (uu *UserNotificationUsecase) CreateAppealNotification(ctx context.Context, appealNotification *models.AppealNotification) (err error) {
+	if err = uu.userNotificationRepo.CreateAppealNotification(ctx, appealNotification); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (uu *UserNotificationUsecase) DeleteAllAppealNotifications(ctx context.Context, userID int64) (err error) {
+	if err = uu.userNotificationRepo.DeleteAllAppealNotifications(ctx, userID); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (uu *UserNotificationUsecase) GetAppealNotifications(ctx context.Context, userID int64) (appealNotifications []models.AppealNotification, err error) {
+	if appealNotifications, err = uu.userNotificationRepo.GetAppealNotifications(ctx, userID); err != nil {
+		return nil, err
+	}
+	return appealNotifications, nil
+}
+
+func (uu *UserNotificationUsecase) DeleteAllSecurityNotifications(ctx context.Context, userID int64) (err error) {
+	if err = uu.userNotificationRepo.DeleteAllSecurityNotifications(ctx, userID); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (uu *UserNotificationUsecase) GetSecurityNotifications(ctx context.Context, userID int64) (securityNotifications []models.SecurityNotification, err error) {
+	if securityNotifications, err = uu.userNotificationRepo.GetSecurityNotifications(ctx, userID); err != nil {
+		return nil, err
+	}
+	return securityNotifications, nil
+}
+
+func (uu *UserNotificationUsecase) GetMentionSettings(ctx context.Context, userID int64) (settings *models.MentionSettings, err error) {
+	if settings, err = uu.userNotificationRepo.GetMentionSettings(ctx, userID); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+func (uu *UserNotificationUsecase) UpdateMentionSettings(ctx context.Context, userID int64, settings *models.MentionSettings) (err error) {
+	if err = uu.userNotificationRepo.UpdateMentionSettings(ctx, userID, settings); err != nil {
+		return err
+	}
+	return nil
+}
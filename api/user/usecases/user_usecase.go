@@ -1,6 +1,7 @@
 package usecases
 
 import (
+	"context"
 	"github.com/innovember/forum/api/models"
 	"github.com/innovember/forum/api/user"
 	"net/http"
@@ -14,93 +15,100 @@ func NewUserUsecase(repo user.UserRepository) user.UserUsecase {
 	return &UserUsecase{userRepo: repo}
 }
 
-func (uu *UserUsecase) Create(user *models.User) (status int, err error) {
-	if status, err = uu.userRepo.CheckByUsernameOrEmail(user); err != nil {
+func (uu *UserUsecase) Create(ctx context.Context, user *models.User) (status int, err error) {
+	if status, err = uu.userRepo.CheckByUsernameOrEmail(ctx, user); err != nil {
 		return status, err
 	}
-	if status, err = uu.userRepo.Create(user); err != nil {
+	if status, err = uu.userRepo.Create(ctx, user); err != nil {
 		return status, err
 	}
 	return status, nil
 }
 
-func (uu *UserUsecase) GetAllUsers() (users []models.User, status int, err error) {
-	if users, err = uu.userRepo.GetAllUsers(); err != nil {
+func (uu *UserUsecase) GetAllUsers(ctx context.Context) (users []models.User, status int, err error) {
+	if users, err = uu.userRepo.GetAllUsers(ctx); err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
 	return users, http.StatusOK, nil
 }
 
-func (uu *UserUsecase) GetPassword(username string) (password string, status int, err error) {
-	if password, status, err = uu.userRepo.GetPassword(username); err != nil {
+func (uu *UserUsecase) GetPassword(ctx context.Context, username string) (password string, status int, err error) {
+	if password, status, err = uu.userRepo.GetPassword(ctx, username); err != nil {
 		return "", status, err
 	}
 	return password, status, nil
 }
 
-func (uu *UserUsecase) FindUserByUsername(username string) (user *models.User, status int, err error) {
-	if user, status, err = uu.userRepo.FindUserByUsername(username); err != nil {
+func (uu *UserUsecase) FindUserByUsername(ctx context.Context, username string) (user *models.User, status int, err error) {
+	if user, status, err = uu.userRepo.FindUserByUsername(ctx, username); err != nil {
 		return nil, status, err
 	}
 	return user, status, nil
 }
 
-func (uu *UserUsecase) UpdateSession(userID int64, sessionValue string, expiresAt int64) (err error) {
-	if err = uu.userRepo.UpdateSession(userID, sessionValue, expiresAt); err != nil {
+func (uu *UserUsecase) FindUserByEmail(ctx context.Context, email string) (user *models.User, status int, err error) {
+	if user, status, err = uu.userRepo.FindUserByEmail(ctx, email); err != nil {
+		return nil, status, err
+	}
+	return user, status, nil
+}
+
+func (uu *UserUsecase) UpdateSession(ctx context.Context, userID int64, sessionValue string, expiresAt int64) (err error) {
+	if err = uu.userRepo.UpdateSession(ctx, userID, sessionValue, expiresAt); err != nil {
 		return err
 	}
 	return nil
 }
-func (uu *UserUsecase) ValidateSession(sessionValue string) (user *models.User, status int, err error) {
-	if user, status, err = uu.userRepo.ValidateSession(sessionValue); err != nil {
+func (uu *UserUsecase) ValidateSession(ctx context.Context, sessionValue string) (user *models.User, status int, err error) {
+	if user, status, err = uu.userRepo.ValidateSession(ctx, sessionValue); err != nil {
 		return nil, status, err
 	}
 	return user, status, nil
 }
 
-func (uu *UserUsecase) CheckSessionByUsername(username string) (status int, err error) {
-	if status, err = uu.userRepo.CheckSessionByUsername(username); err != nil {
+func (uu *UserUsecase) CheckSessionByUsername(ctx context.Context, username string) (status int, err error) {
+	if status, err = uu.userRepo.CheckSessionByUsername(ctx, username); err != nil {
 		return status, err
 	}
 	return status, nil
 }
-func (uu *UserUsecase) GetUserByID(userID int64) (user *models.User, err error) {
-	if user, err = uu.userRepo.GetUserByID(userID); err != nil {
+func (uu *UserUsecase) GetUserByID(ctx context.Context, userID int64) (user *models.User, err error) {
+	if user, err = uu.userRepo.GetUserByID(ctx, userID); err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
-func (uu *UserUsecase) UpdateActivity(userID int64) (err error) {
-	if err = uu.userRepo.UpdateActivity(userID); err != nil {
+func (uu *UserUsecase) UpdateActivity(ctx context.Context, userID int64) (err error) {
+	if err = uu.userRepo.UpdateActivity(ctx, userID); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (uu *UserUsecase) CreateRoleRequest(userID int64) (err error) {
-	if err = uu.userRepo.CreateRoleRequest(userID); err != nil {
+func (uu *UserUsecase) CreateRoleRequest(ctx context.Context, userID int64) (err error) {
+	if err = uu.userRepo.CreateRoleRequest(ctx, userID); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (uu *UserUsecase) GetRoleRequestByUserID(userID int64) (request *models.RoleRequest, err error) {
-	if request, err = uu.userRepo.GetRoleRequestByUserID(userID); err != nil {
+func (uu *UserUsecase) GetRoleRequestByUserID(ctx context.Context, userID int64) (request *models.RoleRequest, err error) {
+	if request, err = uu.userRepo.GetRoleRequestByUserID(ctx, userID); err != nil {
 		return nil, err
 	}
 	return request, nil
 }
 
-func (uu *UserUsecase) DeleteRoleRequest(userID int64) (err error) {
-	if err = uu.userRepo.DeleteRoleRequest(userID); err != nil {
+func (uu *UserUsecase) DeleteRoleRequest(ctx context.Context, userID int64) (err error) {
+	if err = uu.userRepo.DeleteRoleRequest(ctx, userID); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (uu *UserUsecase) GetRoleRequestByID(requestID int64) (roleRequest *models.RoleRequest, err error) {
-	if roleRequest, err = uu.userRepo.GetRoleRequestByID(requestID); err != nil {
+func (uu *UserUsecase) GetRoleRequestByID(ctx context.Context, requestID int64) (roleRequest *models.RoleRequest, err error) {
+	if roleRequest, err = uu.userRepo.GetRoleRequestByID(ctx, requestID); err != nil {
 		return nil, err
 	}
 	return roleRequest, nil